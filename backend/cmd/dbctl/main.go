@@ -0,0 +1,268 @@
+// Command dbctl runs database operations for deploy pipelines and operators
+//
+//	dbctl migrate up|down [steps]|status|plan
+//	dbctl seed
+//	dbctl health
+//	dbctl stats
+//
+// The database is configured from the environment: DATABASE_URL (required),
+// DATABASE_REPLICA_URL, ENVIRONMENT (defaults to development), DB_MIGRATIONS_DIR
+// (defaults to migrations) and DB_SEEDS_DIR (defaults to seeds).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"trae-nutrition-backend/database"
+
+	"gorm.io/gorm"
+)
+
+const usage = `usage: dbctl <command>
+
+commands:
+  migrate up            apply pending migrations
+  migrate down [steps]  revert the last steps migrations (defaults to 1)
+  migrate status        show applied and pending migrations
+  migrate plan          show the SQL migrate up would run
+  seed                  run the seeders allowed in ENVIRONMENT
+  health                check every connection pool
+  stats                 show connection pool statistics`
+
+// errUsage is returned for an unknown command or invalid arguments
+var errUsage = errors.New("invalid arguments")
+
+// migrationFile matches golang-migrate style files, e.g. 0003_index_meals.up.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// seedDependency matches a seed file's "-- depends: units, foods" header
+var seedDependency = regexp.MustCompile(`(?m)^--\s*depends:\s*(.+)$`)
+
+func main() {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, logger, os.Args[1:]); err != nil {
+		logger.Error("dbctl failed", "error", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// run executes the command in args against the database configured from the environment
+func run(ctx context.Context, logger *slog.Logger, args []string) error {
+	if args[0] == "migrate" && len(args) < 2 {
+		return errUsage
+	}
+	switch args[0] {
+	case "migrate", "seed", "health", "stats":
+	default:
+		return errUsage
+	}
+
+	config, err := loadConfig(logger)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewProductionDatabase(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "migrate":
+		return migrate(ctx, db, args[1:])
+	case "seed":
+		seeders, err := loadSeeders(getEnv("DB_SEEDS_DIR", "seeds"))
+		if err != nil {
+			return err
+		}
+		return db.Seed(ctx, seeders...)
+	case "health":
+		report := db.CheckHealth(ctx)
+		if err := printJSON(report); err != nil {
+			return err
+		}
+		return db.Health()
+	default:
+		return printJSON(db.Stats())
+	}
+}
+
+// migrate runs a migrate subcommand
+func migrate(ctx context.Context, db *database.ProductionDatabase, args []string) error {
+	switch args[0] {
+	case "up":
+		return db.MigrateUp(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("%w: steps must be a positive number", errUsage)
+			}
+			steps = n
+		}
+		return db.MigrateDown(ctx, steps)
+	case "status":
+		status, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(status)
+	case "plan":
+		plan, err := db.MigratePlan(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Print(plan.SQL())
+		return err
+	default:
+		return errUsage
+	}
+}
+
+// loadConfig builds the database configuration from the environment
+func loadConfig(logger *slog.Logger) (*database.ProductionConfig, error) {
+	config := database.DefaultProductionConfig()
+	config.DatabaseURL = os.Getenv("DATABASE_URL")
+	if config.DatabaseURL == "" {
+		return nil, errors.New("DATABASE_URL is not set")
+	}
+	config.ReadReplicaURL = os.Getenv("DATABASE_REPLICA_URL")
+	config.Environment = getEnv("ENVIRONMENT", "development")
+	config.Logger = logger
+	// Seed files hold several statements, which prepared statements cannot
+	config.PrepareStmt = false
+
+	migrations, err := loadMigrations(getEnv("DB_MIGRATIONS_DIR", "migrations"))
+	if err != nil {
+		return nil, err
+	}
+	config.Migrations = migrations
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadMigrations reads the up and down SQL of each migration version in dir
+// Files not named like 0003_index_meals.up.sql are ignored.
+func loadMigrations(dir string) ([]database.Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*database.Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		statements, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration: %w", err)
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &database.Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if match[3] == "up" {
+			migration.Up = string(statements)
+		} else {
+			migration.Down = string(statements)
+		}
+	}
+
+	migrations := make([]database.Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// loadSeeders turns each .sql file in dir into a Seeder named after the file
+// A "-- depends: units, foods" line names the seeders that must run first; the SQL
+// runs on every seed, so it must be idempotent, e.g. INSERT ... ON CONFLICT DO NOTHING.
+func loadSeeders(dir string) ([]database.Seeder, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	seeders := make([]database.Seeder, 0, len(paths))
+	for _, path := range paths {
+		statements, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seed file: %w", err)
+		}
+
+		var dependsOn []string
+		for _, match := range seedDependency.FindAllStringSubmatch(string(statements), -1) {
+			for _, name := range strings.Split(match[1], ",") {
+				dependsOn = append(dependsOn, strings.TrimSpace(name))
+			}
+		}
+
+		seeders = append(seeders, database.Seeder{
+			Name:      strings.TrimSuffix(filepath.Base(path), ".sql"),
+			DependsOn: dependsOn,
+			Seed: func(ctx context.Context, tx *gorm.DB) error {
+				return tx.Exec(string(statements)).Error
+			},
+		})
+	}
+	return seeders, nil
+}
+
+// printJSON writes value to stdout as indented JSON
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// getEnv returns the environment variable key, or fallback when it is unset
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
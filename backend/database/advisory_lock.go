@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// AdvisoryLockKey derives the advisory lock key of a named job, e.g. "nightly_rollup"
+func AdvisoryLockKey(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return int64(hash.Sum64())
+}
+
+// WithAdvisoryLock runs fn in a primary transaction holding the transaction-level
+// advisory lock key (pg_advisory_xact_lock), waiting for another holder to finish
+// first. The lock is released when the transaction ends, so a crashed holder can
+// never leave it taken. Databases other than Postgres have no advisory locks, so
+// fn runs without one.
+func (db *ProductionDatabase) WithAdvisoryLock(ctx context.Context, key int64, fn func(tx *gorm.DB) error) error {
+	return db.TransactionWithOptions(ctx, sql.TxOptions{}, func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", key).Error; err != nil {
+				return fmt.Errorf("failed to acquire advisory lock %d: %w", key, err)
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// TryWithAdvisoryLock is WithAdvisoryLock without waiting: while another
+// transaction holds the lock it returns false without running fn, so a job
+// scheduled on every replica runs on only one of them
+func (db *ProductionDatabase) TryWithAdvisoryLock(ctx context.Context, key int64, fn func(tx *gorm.DB) error) (bool, error) {
+	acquired := false
+	err := db.TransactionWithOptions(ctx, sql.TxOptions{}, func(tx *gorm.DB) error {
+		acquired = true
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", key).Scan(&acquired).Error; err != nil {
+				return fmt.Errorf("failed to try advisory lock %d: %w", key, err)
+			}
+		}
+		if !acquired {
+			return nil
+		}
+		return fn(tx)
+	})
+	return acquired, err
+}
+
+// AdvisoryLock is a session-level advisory lock held on a dedicated primary
+// connection until Release, independent of any transaction
+type AdvisoryLock struct {
+	key  int64
+	conn *sql.Conn
+	once sync.Once
+	err  error
+}
+
+// Key returns the lock's key
+func (l *AdvisoryLock) Key() int64 {
+	return l.key
+}
+
+// held checks the lock's session is still alive; the server drops the lock with
+// the session, so a dead connection means the lock may be held elsewhere already
+func (l *AdvisoryLock) held(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	return l.conn.PingContext(ctx)
+}
+
+// Release unlocks the lock and returns its connection to the pool; later calls do nothing
+func (l *AdvisoryLock) Release() error {
+	l.once.Do(func() {
+		if l.conn == nil {
+			return
+		}
+		// Closing the connection would not end the server session, so unlock explicitly
+		if _, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+			l.err = fmt.Errorf("failed to release advisory lock %d: %w", l.key, err)
+		}
+		if err := l.conn.Close(); err != nil && l.err == nil {
+			l.err = err
+		}
+	})
+	return l.err
+}
+
+// TryAdvisoryLock takes the session-level advisory lock key (pg_try_advisory_lock)
+// without waiting, returning nil while another session holds it. The lock pins a
+// primary connection until Release, so callers must release it. Databases other
+// than Postgres have no advisory locks, so the lock is always granted.
+func (db *ProductionDatabase) TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	if db.primary().Dialector.Name() != "postgres" {
+		return &AdvisoryLock{key: key}, nil
+	}
+
+	conn, err := db.primaryPool().Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for advisory lock %d: %w", key, err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to try advisory lock %d: %w", key, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return &AdvisoryLock{key: key, conn: conn}, nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestAdvisoryLockKey_IsStablePerName(t *testing.T) {
+	assert.Equal(t, AdvisoryLockKey("nightly_rollup"), AdvisoryLockKey("nightly_rollup"))
+	assert.NotEqual(t, AdvisoryLockKey("nightly_rollup"), AdvisoryLockKey("purge"))
+}
+
+func TestAdvisoryLocks_RunWithoutLocksOutsidePostgres(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	ctx := context.Background()
+	key := AdvisoryLockKey("nightly_rollup")
+
+	ran := false
+	require.NoError(t, db.WithAdvisoryLock(ctx, key, func(tx *gorm.DB) error {
+		ran = true
+		return tx.Exec("SELECT 1").Error
+	}))
+	assert.True(t, ran)
+
+	ran = false
+	acquired, err := db.TryWithAdvisoryLock(ctx, key, func(*gorm.DB) error {
+		ran = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	assert.True(t, ran)
+
+	lock, err := db.TryAdvisoryLock(ctx, key)
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.Equal(t, key, lock.Key())
+	require.NoError(t, lock.Release())
+	require.NoError(t, lock.Release())
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// defaultAuditBufferSize is how many audit records can wait for the sink before new ones are dropped
+const defaultAuditBufferSize = 1024
+
+const auditActorKey contextKey = "database.audit_actor"
+
+// auditedWrite matches the statement kind and target table of INSERT, UPDATE and DELETE statements
+var auditedWrite = regexp.MustCompile(`(?is)^\s*(INSERT\s+INTO|UPDATE|DELETE\s+FROM)\s+(?:ONLY\s+)?([^\s(]+)`)
+
+// AuditRecord describes one write for the audit log. It carries the statement's
+// fingerprint rather than its SQL, so the written values never reach the sink.
+type AuditRecord struct {
+	Time          time.Time
+	Operation     string // INSERT, UPDATE or DELETE
+	Table         string
+	Fingerprint   string
+	RowsAffected  int64
+	OperationName string // see WithOperationName
+	User          string // see WithAuditActor
+	Role          string
+}
+
+// AuditSink receives an AuditRecord for every successful write
+// Records are delivered one at a time from a single goroutine, off the write path.
+type AuditSink interface {
+	Audit(record AuditRecord)
+}
+
+// auditActor is the user and role writes run with a context are attributed to
+type auditActor struct {
+	user string
+	role string
+}
+
+// WithAuditActor attributes the writes run with ctx to user acting as role in the audit log
+func WithAuditActor(ctx context.Context, user, role string) context.Context {
+	return context.WithValue(ctx, auditActorKey, auditActor{user: user, role: role})
+}
+
+// AuditActor returns the user and role stored on ctx, or empty strings if there are none
+func AuditActor(ctx context.Context) (user, role string) {
+	if ctx == nil {
+		return "", ""
+	}
+	actor, _ := ctx.Value(auditActorKey).(auditActor)
+	return actor.user, actor.role
+}
+
+// AuditRecordsDropped returns how many audit records were dropped because the sink fell behind
+func (db *ProductionDatabase) AuditRecordsDropped() int64 {
+	return atomic.LoadInt64(&db.auditDropped)
+}
+
+// startAuditing delivers queued audit records to the AuditSink until the database is
+// closed, then flushes the records still queued
+func (db *ProductionDatabase) startAuditing() {
+	bufferSize := db.config.AuditBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultAuditBufferSize
+	}
+	db.auditRecords = make(chan AuditRecord, bufferSize)
+
+	db.maintenance.Add(1)
+	go func() {
+		defer db.maintenance.Done()
+		for {
+			select {
+			case record := <-db.auditRecords:
+				db.config.AuditSink.Audit(record)
+			case <-db.maintenanceCtx.Done():
+				for {
+					select {
+					case record := <-db.auditRecords:
+						db.config.AuditSink.Audit(record)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// auditWrite queues an audit record for a successful INSERT, UPDATE or DELETE
+// Writes inside a transaction are recorded as they run, even if it later rolls back.
+func (db *ProductionDatabase) auditWrite(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.SQL.Len() == 0 {
+		return
+	}
+
+	sql := tx.Statement.SQL.String()
+	match := auditedWrite.FindStringSubmatch(sql)
+	if match == nil {
+		return
+	}
+
+	table := tx.Statement.Table
+	if table == "" {
+		table = strings.Trim(match[2], "`\"")
+	}
+
+	ctx := tx.Statement.Context
+	user, role := AuditActor(ctx)
+	record := AuditRecord{
+		Time:          time.Now(),
+		Operation:     strings.ToUpper(strings.Fields(match[1])[0]),
+		Table:         table,
+		Fingerprint:   fingerprintSQL(sql),
+		RowsAffected:  tx.RowsAffected,
+		OperationName: OperationName(ctx),
+		User:          user,
+		Role:          role,
+	}
+
+	select {
+	case db.auditRecords <- record:
+	default:
+		atomic.AddInt64(&db.auditDropped, 1)
+	}
+}
@@ -0,0 +1,400 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// auditLogTable is the table AuditLogEntry rows are appended to
+	auditLogTable = "audit_log"
+
+	// auditLogInstanceKey carries the rows an update or delete is about to change to its after callback
+	auditLogInstanceKey = "database:audit_log_before"
+
+	// encryptedAuditValue stands in for the values of encrypted columns in the audit log
+	encryptedAuditValue = "[encrypted]"
+)
+
+// AuditChange is a column's value before and after a change; Old is nil for
+// inserts and New is nil for deletes
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditLogEntry records one change of a row of the AuditLogModels: who made it,
+// in which request, and the columns it changed. Create the table with
+// Migrate(&AuditLogEntry{}) and revoke UPDATE and DELETE on it from the
+// application's role; the package already refuses to update or delete entries.
+type AuditLogEntry struct {
+	ID        uint64    `gorm:"primaryKey" json:"id"`
+	Time      time.Time `gorm:"not null;index" json:"time"`
+	Operation string    `gorm:"not null" json:"operation"` // INSERT, UPDATE or DELETE
+	Table     string    `gorm:"column:table_name;not null;index:audit_log_row_idx" json:"table"`
+	RowID     string    `gorm:"not null;index:audit_log_row_idx" json:"row_id"` // primary key, comma-separated if composite
+	User      string    `gorm:"column:actor;index" json:"user"`                 // see WithAuditActor
+	Role      string    `json:"role"`
+	RequestID string    `gorm:"index" json:"request_id"` // see WithRequestID
+
+	Changes map[string]AuditChange `gorm:"serializer:json" json:"changes"`
+}
+
+// TableName implements gorm's Tabler
+func (AuditLogEntry) TableName() string {
+	return auditLogTable
+}
+
+// AuditLogQuery selects audit log entries; empty fields match every entry
+type AuditLogQuery struct {
+	Table     string
+	RowID     string
+	User      string
+	RequestID string
+	Since     time.Time
+	Until     time.Time
+	// Limit caps the entries returned (0 returns all)
+	Limit int
+}
+
+// AuditLog returns the entries matching query, oldest first, read from the primary
+func (db *ProductionDatabase) AuditLog(ctx context.Context, query AuditLogQuery) ([]AuditLogEntry, error) {
+	tx := db.primary().WithContext(ctx).Model(&AuditLogEntry{})
+	if query.Table != "" {
+		tx = tx.Where("table_name = ?", query.Table)
+	}
+	if query.RowID != "" {
+		tx = tx.Where("row_id = ?", query.RowID)
+	}
+	if query.User != "" {
+		tx = tx.Where("actor = ?", query.User)
+	}
+	if query.RequestID != "" {
+		tx = tx.Where("request_id = ?", query.RequestID)
+	}
+	if !query.Since.IsZero() {
+		tx = tx.Where("time >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		tx = tx.Where("time < ?", query.Until)
+	}
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+
+	var entries []AuditLogEntry
+	if err := tx.Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// registerAuditLog installs the callbacks appending the changes of AuditLogModels
+// rows to the audit log, in the transaction making them
+func (db *ProductionDatabase) registerAuditLog(gormDB *gorm.DB) error {
+	audited := make(map[string]bool, len(db.config.AuditLogModels))
+	for _, model := range db.config.AuditLogModels {
+		stmt := &gorm.Statement{DB: gormDB}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse audited model %T: %w", model, err)
+		}
+		audited[stmt.Table] = true
+	}
+	isAudited := func(tx *gorm.DB) bool {
+		return tx.Error == nil && tx.Statement.Schema != nil && audited[tx.Statement.Table]
+	}
+
+	callbacks := gormDB.Callback()
+	registrations := []struct {
+		name     string
+		register func(string, func(*gorm.DB)) error
+		fn       func(*gorm.DB)
+	}{
+		{"audit_log_create", callbacks.Create().After("gorm:create").Register, func(tx *gorm.DB) {
+			if isAudited(tx) {
+				db.auditCreated(tx)
+			}
+		}},
+		{"audit_log_update_before", callbacks.Update().Before("gorm:update").Register, func(tx *gorm.DB) {
+			if tx.Statement.Table == auditLogTable {
+				tx.AddError(ErrAuditLogAppendOnly)
+			} else if isAudited(tx) {
+				captureAuditedRows(tx)
+			}
+		}},
+		{"audit_log_update", callbacks.Update().After("gorm:update").Register, func(tx *gorm.DB) {
+			db.auditChanged(tx, "UPDATE")
+		}},
+		{"audit_log_delete_before", callbacks.Delete().Before("gorm:delete").Register, func(tx *gorm.DB) {
+			if tx.Statement.Table == auditLogTable {
+				tx.AddError(ErrAuditLogAppendOnly)
+			} else if isAudited(tx) {
+				captureAuditedRows(tx)
+			}
+		}},
+		{"audit_log_delete", callbacks.Delete().After("gorm:delete").Register, func(tx *gorm.DB) {
+			db.auditChanged(tx, "DELETE")
+		}},
+	}
+	for _, registration := range registrations {
+		if err := registration.register("database:"+registration.name, registration.fn); err != nil {
+			return fmt.Errorf("failed to register audit log callback: %w", err)
+		}
+	}
+	return nil
+}
+
+// modelRows returns a query for the rows of the statement's model matching conditions
+func modelRows(tx *gorm.DB, conditions ...clause.Expression) *gorm.DB {
+	stmt := tx.Statement
+	query := tx.Session(&gorm.Session{NewDB: true}).Model(reflect.New(stmt.Schema.ModelType).Interface())
+	if stmt.Unscoped {
+		query = query.Unscoped()
+	}
+	if len(conditions) > 0 {
+		query = query.Where(clause.And(conditions...))
+	}
+	return query
+}
+
+// captureAuditedRows reads the rows an update or delete is about to change, so
+// its after callback can tell what changed
+func captureAuditedRows(tx *gorm.DB) {
+	if rows, ok := statementRows(tx); ok {
+		tx.InstanceSet(auditLogInstanceKey, rows)
+	}
+}
+
+// statementRows reads the rows the update or delete of tx is about to change,
+// reporting false when there are none to read or reading them failed
+func statementRows(tx *gorm.DB) ([]map[string]interface{}, bool) {
+	stmt := tx.Statement
+
+	var conditions []clause.Expression
+	if where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where); ok {
+		conditions = append(conditions, where.Exprs...)
+	}
+	// GORM only adds the model's primary key condition while building the statement
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		for _, field := range stmt.Schema.PrimaryFields {
+			if value, zero := field.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+				conditions = append(conditions, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value})
+			}
+		}
+	}
+	if len(conditions) == 0 && !tx.AllowGlobalUpdate {
+		// GORM refuses the statement with ErrMissingWhereClause
+		return nil, false
+	}
+
+	rows, err := findRows(modelRows(tx, conditions...))
+	if err != nil {
+		tx.AddError(fmt.Errorf("failed to read the rows the statement changes: %w", err))
+		return nil, false
+	}
+	return rows, true
+}
+
+// findRows reads the rows query selects, with each column's value as stored
+// Scanning into maps through GORM would scan serializer fields into their Go
+// types, which the stored values do not convert to, so the rows are scanned here.
+func findRows(query *gorm.DB) ([]map[string]interface{}, error) {
+	rows, err := query.Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// auditCreated appends the created models to the audit log
+func (db *ProductionDatabase) auditCreated(tx *gorm.DB) {
+	stmt := tx.Statement
+
+	var models []reflect.Value
+	switch value := stmt.ReflectValue; value.Kind() {
+	case reflect.Struct:
+		models = append(models, value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			models = append(models, reflect.Indirect(value.Index(i)))
+		}
+	}
+
+	entries := make([]AuditLogEntry, 0, len(models))
+	for _, model := range models {
+		row := make(map[string]interface{}, len(stmt.Schema.DBNames))
+		for _, name := range stmt.Schema.DBNames {
+			if isEncryptedField(stmt.Schema.FieldsByDBName[name]) {
+				row[name] = encryptedAuditValue
+				continue
+			}
+			value, err := columnValue(stmt.Context, stmt.Schema.FieldsByDBName[name], model)
+			if err != nil {
+				tx.AddError(fmt.Errorf("failed to read %s for the audit log: %w", name, err))
+				return
+			}
+			row[name] = value
+		}
+		entries = append(entries, db.auditLogEntry(tx, "INSERT", row, auditDiff(nil, row)))
+	}
+	db.appendAuditLog(tx, entries)
+}
+
+// columnValue returns the value GORM writes to the column of field for model
+// Serializer fields are serialized the way they are stored, like the rows an
+// update or delete reads back.
+func columnValue(ctx context.Context, field *schema.Field, model reflect.Value) (interface{}, error) {
+	value, _ := field.ValueOf(ctx, model)
+	if valuer, ok := value.(driver.Valuer); ok && field.Serializer != nil {
+		return valuer.Value()
+	}
+	return value, nil
+}
+
+// auditChanged appends the rows an update or delete changed to the audit log,
+// comparing them with what captureAuditedRows read before
+func (db *ProductionDatabase) auditChanged(tx *gorm.DB, operation string) {
+	value, ok := tx.InstanceGet(auditLogInstanceKey)
+	if !ok || tx.Error != nil || tx.RowsAffected == 0 {
+		return
+	}
+	before := value.([]map[string]interface{})
+	if len(before) == 0 {
+		return
+	}
+	primaryFields := tx.Statement.Schema.PrimaryFields
+
+	after := make(map[string]map[string]interface{}, len(before))
+	if operation == "UPDATE" && len(primaryFields) > 0 {
+		rows, err := findRows(modelRows(tx, primaryKeyCondition(primaryFields, before)).Unscoped())
+		if err != nil {
+			tx.AddError(fmt.Errorf("failed to read rows for the audit log: %w", err))
+			return
+		}
+		for _, row := range rows {
+			after[auditRowID(primaryFields, row)] = row
+		}
+	}
+
+	entries := make([]AuditLogEntry, 0, len(before))
+	for _, row := range before {
+		changes := auditDiff(row, after[auditRowID(primaryFields, row)])
+		if operation == "UPDATE" && len(changes) == 0 {
+			continue
+		}
+		entries = append(entries, db.auditLogEntry(tx, operation, row, changes))
+	}
+	db.appendAuditLog(tx, entries)
+}
+
+// auditLogEntry describes a change of row made by the statement of tx
+// Encrypted columns are recorded as changed, but not with their values.
+func (db *ProductionDatabase) auditLogEntry(tx *gorm.DB, operation string, row map[string]interface{}, changes map[string]AuditChange) AuditLogEntry {
+	for column, change := range changes {
+		if !isEncryptedField(tx.Statement.Schema.FieldsByDBName[column]) {
+			continue
+		}
+		if change.Old != nil {
+			change.Old = encryptedAuditValue
+		}
+		if change.New != nil {
+			change.New = encryptedAuditValue
+		}
+		changes[column] = change
+	}
+
+	ctx := tx.Statement.Context
+	user, role := AuditActor(ctx)
+	return AuditLogEntry{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Table:     tx.Statement.Table,
+		RowID:     auditRowID(tx.Statement.Schema.PrimaryFields, row),
+		User:      user,
+		Role:      role,
+		RequestID: RequestID(ctx),
+		Changes:   changes,
+	}
+}
+
+// appendAuditLog inserts entries in the statement's transaction, so they are only
+// kept if the change is
+func (db *ProductionDatabase) appendAuditLog(tx *gorm.DB, entries []AuditLogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	if err := tx.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+		tx.AddError(fmt.Errorf("failed to write the audit log: %w", err))
+	}
+}
+
+// primaryKeyCondition matches the rows with the primary keys of rows
+func primaryKeyCondition(primaryFields []*schema.Field, rows []map[string]interface{}) clause.Expression {
+	keys := make([]clause.Expression, 0, len(rows))
+	for _, row := range rows {
+		key := make([]clause.Expression, 0, len(primaryFields))
+		for _, field := range primaryFields {
+			key = append(key, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: row[field.DBName]})
+		}
+		keys = append(keys, clause.And(key...))
+	}
+	return clause.Or(keys...)
+}
+
+// auditRowID formats the primary key of row
+func auditRowID(primaryFields []*schema.Field, row map[string]interface{}) string {
+	key := make([]string, len(primaryFields))
+	for i, field := range primaryFields {
+		key[i] = fmt.Sprint(row[field.DBName])
+	}
+	return strings.Join(key, ",")
+}
+
+// auditDiff returns the columns whose values differ between before and after;
+// a nil before or after stands for a row that did not exist
+func auditDiff(before, after map[string]interface{}) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+	for column, old := range before {
+		if value, ok := after[column]; after == nil || !ok || !reflect.DeepEqual(old, value) {
+			changes[column] = AuditChange{Old: old, New: after[column]}
+		}
+	}
+	for column, value := range after {
+		if _, ok := before[column]; !ok {
+			changes[column] = AuditChange{New: value}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// auditedCondition is a model whose changes go to the audit log
+type auditedCondition struct {
+	ID       uint `gorm:"primaryKey"`
+	UserID   uint
+	Name     string
+	Severity int
+}
+
+// auditedPrescription is an audited model with a serialized field
+type auditedPrescription struct {
+	ID          uint     `gorm:"primaryKey"`
+	Medications []string `gorm:"serializer:json"`
+}
+
+// newAuditLogTestDatabase opens a database auditing auditedCondition and auditedPrescription
+func newAuditLogTestDatabase(t *testing.T) *ProductionDatabase {
+	t.Helper()
+	config := newSQLiteTestConfig(t, "primary")
+	config.AuditLogModels = []interface{}{&auditedCondition{}, &auditedPrescription{}}
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&auditedCondition{}, &auditedPrescription{}, &AuditLogEntry{}))
+	return db
+}
+
+func TestAuditLog_RecordsRowChangesWithActorAndRequest(t *testing.T) {
+	db := newAuditLogTestDatabase(t)
+	ctx := WithRequestID(WithAuditActor(context.Background(), "dr-khaled", "clinician"), "req-42")
+	conn := db.WithContext(ctx)
+
+	condition := auditedCondition{UserID: 7, Name: "hypertension", Severity: 2}
+	require.NoError(t, conn.Create(&condition).Error)
+	require.NoError(t, conn.Model(&condition).Update("severity", 3).Error)
+	require.NoError(t, conn.Model(&auditedCondition{}).Where("user_id = ?", 7).Update("name", "hypertension").Error)
+	require.NoError(t, conn.Delete(&auditedCondition{}, condition.ID).Error)
+
+	entries, err := db.AuditLog(ctx, AuditLogQuery{Table: "audited_conditions", RowID: "1"})
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "updates changing nothing are not recorded")
+
+	assert.Equal(t, "INSERT", entries[0].Operation)
+	assert.Equal(t, "dr-khaled", entries[0].User)
+	assert.Equal(t, "clinician", entries[0].Role)
+	assert.Equal(t, "req-42", entries[0].RequestID)
+	assert.Equal(t, "hypertension", entries[0].Changes["name"].New)
+	assert.Nil(t, entries[0].Changes["name"].Old)
+
+	assert.Equal(t, "UPDATE", entries[1].Operation)
+	assert.Equal(t, map[string]AuditChange{"severity": {Old: float64(2), New: float64(3)}}, entries[1].Changes)
+
+	assert.Equal(t, "DELETE", entries[2].Operation)
+	assert.Equal(t, "hypertension", entries[2].Changes["name"].Old)
+	assert.Nil(t, entries[2].Changes["name"].New)
+
+	byRequest, err := db.AuditLog(ctx, AuditLogQuery{RequestID: "req-42", User: "dr-khaled", Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, byRequest, 2)
+}
+
+func TestAuditLog_RecordsSerializedFieldsAsStored(t *testing.T) {
+	db := newAuditLogTestDatabase(t)
+	ctx := context.Background()
+
+	prescription := auditedPrescription{Medications: []string{"metformin"}}
+	require.NoError(t, db.WithContext(ctx).Create(&prescription).Error)
+	prescription.Medications = append(prescription.Medications, "insulin")
+	require.NoError(t, db.WithContext(ctx).Model(&prescription).Updates(&prescription).Error)
+
+	entries, err := db.AuditLog(ctx, AuditLogQuery{Table: "audited_prescriptions"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "INSERT", entries[0].Operation)
+	assert.Equal(t, `["metformin"]`, entries[0].Changes["medications"].New)
+	assert.Equal(t, "UPDATE", entries[1].Operation)
+	assert.Equal(t, `["metformin"]`, entries[1].Changes["medications"].Old)
+	assert.Equal(t, `["metformin","insulin"]`, entries[1].Changes["medications"].New)
+}
+
+func TestAuditLog_RollsBackWithTheChange(t *testing.T) {
+	db := newAuditLogTestDatabase(t)
+
+	require.Error(t, db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, tx.Create(&auditedCondition{UserID: 7, Name: "diabetes"}).Error)
+		return errors.New("rolled back")
+	}))
+
+	entries, err := db.AuditLog(context.Background(), AuditLogQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestAuditLog_IsAppendOnly(t *testing.T) {
+	db := newAuditLogTestDatabase(t)
+	require.NoError(t, db.GetDB().Create(&auditedCondition{UserID: 7, Name: "asthma"}).Error)
+
+	err := db.GetDB().Model(&AuditLogEntry{}).Where("id = ?", 1).Update("actor", "someone-else").Error
+	assert.ErrorIs(t, err, ErrAuditLogAppendOnly)
+	assert.ErrorIs(t, db.GetDB().Delete(&AuditLogEntry{}, 1).Error, ErrAuditLogAppendOnly)
+
+	entries, err := db.AuditLog(context.Background(), AuditLogQuery{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Empty(t, entries[0].User)
+}
+
+func TestAuditLog_KeepsEncryptedColumnsOut(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.AuditLogModels = []interface{}{&medicalRecord{}}
+	config.EncryptionKeyring = testKeyring(t, "k1", "k1")
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&medicalRecord{}, &AuditLogEntry{}))
+
+	record := medicalRecord{UserID: 7, Condition: "type 2 diabetes", Allergies: []string{"peanuts"}}
+	require.NoError(t, db.GetDB().Create(&record).Error)
+	record.Condition = "prediabetes"
+	require.NoError(t, db.GetDB().Model(&record).Select("condition").Updates(&record).Error)
+	require.NoError(t, db.GetDB().Delete(&record).Error)
+
+	var stored []string
+	require.NoError(t, db.GetDB().Raw("SELECT changes FROM audit_log").Scan(&stored).Error)
+	require.Len(t, stored, 3)
+	for _, changes := range stored {
+		assert.NotContains(t, changes, "diabetes")
+		assert.NotContains(t, changes, "peanuts")
+		assert.NotContains(t, changes, "enc:v1:")
+	}
+
+	entries, err := db.AuditLog(context.Background(), AuditLogQuery{Table: "medical_records"})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, AuditChange{New: "[encrypted]"}, entries[0].Changes["condition"])
+	assert.Equal(t, map[string]AuditChange{"condition": {Old: "[encrypted]", New: "[encrypted]"}}, entries[1].Changes)
+	assert.Equal(t, AuditChange{Old: "[encrypted]"}, entries[2].Changes["allergies"])
+}
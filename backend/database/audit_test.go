@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// channelAuditSink hands audit records to the test
+type channelAuditSink chan AuditRecord
+
+func (s channelAuditSink) Audit(record AuditRecord) { s <- record }
+
+type auditTestMeal struct {
+	ID   uint
+	Name string
+}
+
+func TestAuditSink_ReceivesRedactedWriteRecord(t *testing.T) {
+	sink := make(channelAuditSink, 10)
+	config := newSQLiteTestConfig(t, "primary")
+	config.AuditSink = sink
+	db := newSQLiteTestDatabase(t, config)
+
+	require.NoError(t, db.GetDB().AutoMigrate(&auditTestMeal{}))
+
+	ctx := WithAuditActor(WithOperationName(context.Background(), "log_meal"), "user-42", "dietitian")
+	require.NoError(t, db.WithContext(ctx).Create(&auditTestMeal{Name: "secret oatmeal"}).Error)
+	require.NoError(t, db.WithContext(ctx).Exec("UPDATE audit_test_meals SET name = 'secret porridge' WHERE id = 1").Error)
+
+	var records []AuditRecord
+	for len(records) < 2 {
+		select {
+		case record := <-sink:
+			records = append(records, record)
+		case <-time.After(time.Second):
+			t.Fatalf("audit sink received %d of 2 records", len(records))
+		}
+	}
+
+	insert := records[0]
+	assert.Equal(t, "INSERT", insert.Operation)
+	assert.Equal(t, "audit_test_meals", insert.Table)
+	assert.Equal(t, int64(1), insert.RowsAffected)
+	assert.Equal(t, "log_meal", insert.OperationName)
+	assert.Equal(t, "user-42", insert.User)
+	assert.Equal(t, "dietitian", insert.Role)
+
+	update := records[1]
+	assert.Equal(t, "UPDATE", update.Operation)
+	assert.Equal(t, "audit_test_meals", update.Table)
+	assert.Equal(t, int64(1), update.RowsAffected)
+
+	for _, record := range records {
+		assert.False(t, strings.Contains(record.Fingerprint, "secret"), "values must be redacted: %s", record.Fingerprint)
+	}
+	assert.Equal(t, "UPDATE audit_test_meals SET name = ? WHERE id = ?", update.Fingerprint)
+
+	// Reads are not audited
+	var count int64
+	require.NoError(t, db.GetDB().Raw("SELECT COUNT(*) FROM audit_test_meals").Scan(&count).Error)
+	select {
+	case record := <-sink:
+		t.Fatalf("unexpected audit record for a read: %+v", record)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// blockingAuditSink never returns from Audit until released
+type blockingAuditSink chan struct{}
+
+func (s blockingAuditSink) Audit(AuditRecord) { <-s }
+
+func TestAuditSink_SlowSinkDoesNotBlockWrites(t *testing.T) {
+	sink := make(blockingAuditSink)
+	config := newSQLiteTestConfig(t, "primary")
+	config.AuditSink = sink
+	config.AuditBufferSize = 1
+	db := newSQLiteTestDatabase(t, config)
+	t.Cleanup(func() { close(sink) })
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE journal (entry TEXT)").Error)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, db.GetDB().Exec("INSERT INTO journal (entry) VALUES ('x')").Error)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writes blocked on the audit sink")
+	}
+	assert.Positive(t, db.AuditRecordsDropped())
+}
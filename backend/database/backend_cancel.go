@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// cancelBackendTimeout bounds the side connection used to cancel a statement
+const cancelBackendTimeout = 5 * time.Second
+
+// BackendCanceller is implemented by driver connections that can stop their running
+// statement on the server. With CancelBackendOnDisconnect it is called as soon as a
+// statement's context is done; lib/pq connections are handled by the package.
+type BackendCanceller interface {
+	CancelBackend() error
+}
+
+// postgresCanceller cancels a server process's running statement with pg_cancel_backend
+type postgresCanceller struct {
+	// connector opens the side connection that issues the cancel
+	connector driver.Connector
+	pid       int64
+}
+
+// newPostgresCanceller reads the backend pid of conn so its statements can be cancelled later
+func newPostgresCanceller(ctx context.Context, connector driver.Connector, conn driver.Conn) (*postgresCanceller, error) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return nil, errors.New("driver does not support QueryContext")
+	}
+
+	rows, err := queryer.QueryContext(ctx, "SELECT pg_backend_pid()", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("pg_backend_pid returned no rows")
+		}
+		return nil, err
+	}
+
+	pid, ok := dest[0].(int64)
+	if !ok {
+		return nil, fmt.Errorf("unexpected pg_backend_pid value %T", dest[0])
+	}
+	return &postgresCanceller{connector: connector, pid: pid}, nil
+}
+
+// CancelBackend asks the server to cancel the process's running statement over a side connection
+func (c *postgresCanceller) CancelBackend() error {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelBackendTimeout)
+	defer cancel()
+
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return errors.New("driver does not support ExecContext")
+	}
+	_, err = execer.ExecContext(ctx, "SELECT pg_cancel_backend($1)", []driver.NamedValue{{Ordinal: 1, Value: c.pid}})
+	return err
+}
+
+// cancelOnDone cancels the connection's running statement on the server if ctx is
+// done before the returned stop is called. stop waits for an issued cancel to
+// finish, so it cannot hit the next statement run on the connection.
+func (c *hookedConn) cancelOnDone(ctx context.Context) (stop func()) {
+	if c.canceller == nil || ctx.Done() == nil {
+		return func() {}
+	}
+
+	finished := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			if err := c.canceller.CancelBackend(); err != nil {
+				c.connector.logger.Error("Failed to cancel statement on the server", "error", err)
+			}
+		case <-finished:
+		}
+	}()
+
+	return func() {
+		close(finished)
+		<-exited
+	}
+}
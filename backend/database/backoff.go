@@ -0,0 +1,56 @@
+package database
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// defaultMaxBackoff caps a backoff without a configured maximum
+const defaultMaxBackoff = 5 * time.Minute
+
+// backoff is an exponential delay between reconnect or retry attempts
+// It doubles from base with every consecutive failure, up to max, and
+// starts over from base once an attempt succeeds, so a later blip is retried
+// quickly rather than at the delay an earlier outage escalated to.
+type backoff struct {
+	base time.Duration
+	max  time.Duration
+
+	failures int
+}
+
+// failed records a failed attempt and returns how long to wait before the next one
+func (b *backoff) failed() time.Duration {
+	b.failures++
+	if b.base <= 0 {
+		return 0
+	}
+
+	max := b.max
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+
+	delay := b.base
+	for i := 1; i < b.failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// reset starts the next failure over at the base delay
+func (b *backoff) reset() {
+	b.failures = 0
+}
+
+// fullJitter returns a random delay between 0 and delay, so clients retrying after
+// the same failure spread out instead of retrying in lockstep
+func fullJitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay + 1)
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BenchmarkOptions describes the workload BenchmarkPool drives
+type BenchmarkOptions struct {
+	// Concurrent workers (defaults to MaxOpenConnections)
+	Concurrency int
+
+	// How long to run the workload (defaults to 10s)
+	Duration time.Duration
+
+	// Fraction of operations that are writes, between 0 and 1
+	WriteRatio float64
+
+	// Statements run by reads (against the read database, defaults to SELECT 1)
+	// and by writes (against the primary, required when WriteRatio > 0)
+	ReadQuery  string
+	ReadArgs   []interface{}
+	WriteQuery string
+	WriteArgs  []interface{}
+}
+
+// BenchmarkResult summarises a BenchmarkPool run
+type BenchmarkResult struct {
+	Duration   time.Duration
+	Operations int64
+	Reads      int64
+	Writes     int64
+	Errors     int64
+
+	// Successful operations per second
+	Throughput float64
+
+	// Latency of the successful operations
+	Latency Percentiles
+
+	// Pool contention during the run, summed over the primary and replica pools
+	PoolWaitCount    int64
+	PoolWaitDuration time.Duration
+}
+
+// benchmarkWorker accumulates one worker's results
+type benchmarkWorker struct {
+	latency       latencyHistogram
+	reads, writes int64
+	errors        int64
+}
+
+// BenchmarkPool drives a concurrent read/write workload to compare pool settings
+// such as MaxOpenConnections. It generates real load and belongs in staging, not production.
+func (db *ProductionDatabase) BenchmarkPool(ctx context.Context, opts BenchmarkOptions) (BenchmarkResult, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = db.config.MaxOpenConnections
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = 10 * time.Second
+	}
+	if opts.ReadQuery == "" {
+		opts.ReadQuery = "SELECT 1"
+	}
+	if opts.WriteRatio < 0 || opts.WriteRatio > 1 {
+		return BenchmarkResult{}, errors.New("benchmark write ratio must be between 0 and 1")
+	}
+	if opts.WriteRatio > 0 && opts.WriteQuery == "" {
+		return BenchmarkResult{}, errors.New("benchmark write query is required when the write ratio is positive")
+	}
+
+	before := db.poolStats()
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	started := time.Now()
+	workers := make([]benchmarkWorker, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func(worker *benchmarkWorker, seed int64) {
+			defer wg.Done()
+			db.runBenchmarkWorker(ctx, opts, worker, rand.New(rand.NewSource(seed)))
+		}(&workers[i], started.UnixNano()+int64(i))
+	}
+	wg.Wait()
+
+	result := BenchmarkResult{Duration: time.Since(started)}
+	var latency latencyHistogram
+	for i := range workers {
+		worker := &workers[i]
+		result.Reads += worker.reads
+		result.Writes += worker.writes
+		result.Errors += worker.errors
+		latency.merge(&worker.latency)
+	}
+	result.Operations = result.Reads + result.Writes + result.Errors
+	result.Latency = latency.percentiles()
+	result.Throughput = float64(result.Reads+result.Writes) / result.Duration.Seconds()
+
+	for role, after := range db.poolStats() {
+		result.PoolWaitCount += after.WaitCount - before[role].WaitCount
+		result.PoolWaitDuration += after.WaitDuration - before[role].WaitDuration
+	}
+
+	return result, nil
+}
+
+// runBenchmarkWorker issues operations until ctx is done
+func (db *ProductionDatabase) runBenchmarkWorker(ctx context.Context, opts BenchmarkOptions, worker *benchmarkWorker, random *rand.Rand) {
+	for ctx.Err() == nil {
+		write := opts.WriteRatio > 0 && random.Float64() < opts.WriteRatio
+
+		started := time.Now()
+		var err error
+		if write {
+			err = db.primary().WithContext(ctx).Exec(opts.WriteQuery, opts.WriteArgs...).Error
+		} else {
+			err = db.GetReadDB().WithContext(ctx).Exec(opts.ReadQuery, opts.ReadArgs...).Error
+		}
+		elapsed := time.Since(started)
+
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// Cut short by the end of the run
+			return
+		case err != nil:
+			worker.errors++
+		case write:
+			worker.writes++
+			worker.latency.record(elapsed)
+		default:
+			worker.reads++
+			worker.latency.record(elapsed)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarkPool_ReportsThroughputAndLatency(t *testing.T) {
+	// A single connection for four workers makes pool waits certain
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxOpenConnections = 1
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE bench (id integer PRIMARY KEY, v integer)").Error)
+
+	result, err := db.BenchmarkPool(context.Background(), BenchmarkOptions{
+		Concurrency: 4,
+		Duration:    100 * time.Millisecond,
+		WriteRatio:  0.25,
+		ReadQuery:   "SELECT count(*) FROM bench",
+		WriteQuery:  "INSERT INTO bench (v) VALUES (?)",
+		WriteArgs:   []interface{}{1},
+	})
+	require.NoError(t, err)
+
+	assert.Zero(t, result.Errors)
+	assert.Positive(t, result.Reads)
+	assert.Positive(t, result.Writes)
+	assert.Positive(t, result.Throughput)
+	assert.Equal(t, uint64(result.Reads+result.Writes), result.Latency.Count)
+	assert.Positive(t, result.Latency.P50)
+	assert.GreaterOrEqual(t, result.Latency.P99, result.Latency.P50)
+	assert.Positive(t, result.PoolWaitCount)
+}
+
+func TestBenchmarkPool_RequiresWriteQueryForWrites(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	_, err := db.BenchmarkPool(context.Background(), BenchmarkOptions{Duration: time.Millisecond, WriteRatio: 0.5})
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultCopyChunkSize is the rows per chunk of a CopyFrom without a ChunkSize
+	defaultCopyChunkSize = 10000
+
+	// maxInsertParameters caps the bind parameters of one multi-row INSERT, below
+	// SQLite's and Postgres' limits
+	maxInsertParameters = 32766
+)
+
+// CopySource yields the rows CopyFrom inserts, one at a time, so huge imports need
+// not be held in memory
+type CopySource interface {
+	// Next advances to the next row, returning false once the rows are exhausted
+	Next() bool
+
+	// Values returns the current row's values, in the order of CopyFrom's columns
+	Values() ([]interface{}, error)
+
+	// Err returns the error that ended the rows early, if any
+	Err() error
+}
+
+// CopyFromRows returns a CopySource yielding rows
+func CopyFromRows(rows [][]interface{}) CopySource {
+	return &sliceCopySource{rows: rows, next: -1}
+}
+
+type sliceCopySource struct {
+	rows [][]interface{}
+	next int
+}
+
+func (s *sliceCopySource) Next() bool {
+	s.next++
+	return s.next < len(s.rows)
+}
+
+func (s *sliceCopySource) Values() ([]interface{}, error) {
+	return s.rows[s.next], nil
+}
+
+func (s *sliceCopySource) Err() error {
+	return nil
+}
+
+// CopyOptions tunes CopyFrom
+type CopyOptions struct {
+	// Rows committed per transaction (defaults to 10000)
+	ChunkSize int
+
+	// Progress is called after each chunk commits with the rows inserted so far
+	Progress func(inserted int64)
+}
+
+// BulkInsert inserts rows into table's columns, as CopyFrom does
+func (db *ProductionDatabase) BulkInsert(ctx context.Context, table string, columns []string, rows [][]interface{}, opts CopyOptions) (int64, error) {
+	return db.CopyFrom(ctx, table, columns, CopyFromRows(rows), opts)
+}
+
+// CopyFrom inserts the rows of source into table's columns on the primary, returning
+// how many were inserted. On Postgres rows stream through the COPY protocol; other
+// databases get multi-row INSERTs. Rows are committed in chunks of ChunkSize, so a
+// failure keeps the chunks committed before it, and the count returned tells where
+// to resume.
+func (db *ProductionDatabase) CopyFrom(ctx context.Context, table string, columns []string, source CopySource, opts CopyOptions) (int64, error) {
+	if table == "" || len(columns) == 0 {
+		return 0, errors.New("bulk insert needs a table and columns")
+	}
+	if err := db.primaryAvailable(); err != nil {
+		return 0, err
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultCopyChunkSize
+	}
+
+	insert := db.insertChunk
+	if db.primary().Dialector.Name() == "postgres" {
+		insert = db.copyChunk
+	}
+
+	var inserted int64
+	err := db.traced(ctx, "db.copy_from", func(ctx context.Context) error {
+		chunk := make([][]interface{}, 0, chunkSize)
+		flush := func() error {
+			if len(chunk) == 0 {
+				return nil
+			}
+			if err := insert(ctx, table, columns, chunk); err != nil {
+				return fmt.Errorf("bulk insert into %s failed after %d rows: %w", table, inserted, TranslateError(err))
+			}
+			inserted += int64(len(chunk))
+			chunk = chunk[:0]
+			if opts.Progress != nil {
+				opts.Progress(inserted)
+			}
+			return nil
+		}
+
+		for source.Next() {
+			values, err := source.Values()
+			if err != nil {
+				return fmt.Errorf("bulk insert into %s failed reading row %d: %w", table, inserted+int64(len(chunk))+1, err)
+			}
+			if len(values) != len(columns) {
+				return fmt.Errorf("bulk insert into %s: row %d has %d values for %d columns", table, inserted+int64(len(chunk))+1, len(values), len(columns))
+			}
+			chunk = append(chunk, values)
+			if len(chunk) == chunkSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := source.Err(); err != nil {
+			return fmt.Errorf("bulk insert into %s failed reading rows: %w", table, err)
+		}
+		return flush()
+	}, attribute.String("db.sql.table", table))
+	return inserted, err
+}
+
+// copyChunk inserts rows in one transaction through COPY ... FROM STDIN
+func (db *ProductionDatabase) copyChunk(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	tx, err := db.primaryPool().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement := pq.CopyIn(table, columns...)
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		statement = pq.CopyInSchema(schema, name, columns...)
+	}
+	stmt, err := tx.PrepareContext(ctx, statement)
+	if err != nil {
+		return err
+	}
+	for _, values := range rows {
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	// An Exec without values ends the COPY and reports its errors
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertChunk inserts rows in one transaction through multi-row INSERTs, each
+// within maxInsertParameters
+func (db *ProductionDatabase) insertChunk(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	return db.primary().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = tx.Statement.Quote(column)
+		}
+		prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", tx.Statement.Quote(table), strings.Join(quoted, ", "))
+		placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+
+		perStatement := max(1, maxInsertParameters/len(columns))
+		for start := 0; start < len(rows); start += perStatement {
+			batch := rows[start:min(start+perStatement, len(rows))]
+			values := make([]string, len(batch))
+			args := make([]interface{}, 0, len(batch)*len(columns))
+			for i, row := range batch {
+				values[i] = placeholders
+				args = append(args, row...)
+			}
+			if err := tx.Exec(prefix+strings.Join(values, ", "), args...).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
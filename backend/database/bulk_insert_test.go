@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkInsert_FallsBackToMultiRowInserts(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE foods (id integer PRIMARY KEY, name text NOT NULL, calories integer)").Error)
+
+	rows := make([][]interface{}, 25)
+	for i := range rows {
+		rows[i] = []interface{}{i + 1, fmt.Sprintf("food %d", i+1), (i + 1) * 10}
+	}
+
+	var progress []int64
+	inserted, err := db.BulkInsert(context.Background(), "foods", []string{"id", "name", "calories"}, rows, CopyOptions{
+		ChunkSize: 10,
+		Progress:  func(inserted int64) { progress = append(progress, inserted) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), inserted)
+	assert.Equal(t, []int64{10, 20, 25}, progress)
+
+	var total int64
+	require.NoError(t, db.GetDB().Raw("SELECT sum(calories) FROM foods").Scan(&total).Error)
+	assert.Equal(t, int64(3250), total)
+}
+
+func TestBulkInsert_KeepsCommittedChunksOnFailure(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE foods (id integer PRIMARY KEY, name text NOT NULL)").Error)
+
+	rows := [][]interface{}{{1, "apple"}, {2, "pear"}, {3, "plum"}, {3, "duplicate"}}
+	inserted, err := db.BulkInsert(context.Background(), "foods", []string{"id", "name"}, rows, CopyOptions{ChunkSize: 2})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed after 2 rows")
+	assert.Equal(t, int64(2), inserted)
+
+	var count int64
+	require.NoError(t, db.GetDB().Raw("SELECT count(*) FROM foods").Scan(&count).Error)
+	assert.Equal(t, int64(2), count, "the failed chunk rolled back")
+
+	_, err = db.BulkInsert(context.Background(), "foods", []string{"id", "name"}, [][]interface{}{{4}}, CopyOptions{})
+	assert.ErrorContains(t, err, "has 1 values for 2 columns")
+}
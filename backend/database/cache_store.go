@@ -0,0 +1,247 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CacheStore stores encoded query results for CachedQuery
+// Entries can carry tags so related results are invalidated together.
+type CacheStore interface {
+	// Get returns the value stored under key and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores value under key for ttl and associates it with tags
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+
+	// Delete removes the given keys
+	Delete(ctx context.Context, keys ...string) error
+
+	// InvalidateTags removes every entry associated with any of the tags
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// memoryCacheSweepInterval is how often MemoryCacheStore.Set drops expired entries
+// that were never read again
+const memoryCacheSweepInterval = time.Minute
+
+// memoryCacheEntry is a value held by MemoryCacheStore
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+	tags      []string
+}
+
+// MemoryCacheStore is a process-local CacheStore
+// Expired entries are dropped when read and swept periodically on Set, and tags
+// are forgotten with the last entry stored under them, so the store stays bounded
+// by its live entries.
+type MemoryCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	tags    map[string]map[string]struct{}
+
+	// nextSweep is when Set next sweeps expired entries
+	nextSweep time.Time
+}
+
+// NewMemoryCacheStore creates an empty in-memory cache store
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{
+		entries:   make(map[string]memoryCacheEntry),
+		tags:      make(map[string]map[string]struct{}),
+		nextSweep: time.Now().Add(memoryCacheSweepInterval),
+	}
+}
+
+// Get implements CacheStore
+func (s *MemoryCacheStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		s.remove(key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set implements CacheStore
+func (s *MemoryCacheStore) Set(_ context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+	}
+
+	// The entry's previous tags no longer apply
+	s.remove(key)
+	s.entries[key] = memoryCacheEntry{value: value, expiresAt: now.Add(ttl), tags: tags}
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	return nil
+}
+
+// Delete implements CacheStore
+func (s *MemoryCacheStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		s.remove(key)
+	}
+	return nil
+}
+
+// InvalidateTags implements CacheStore
+func (s *MemoryCacheStore) InvalidateTags(_ context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.remove(key)
+		}
+	}
+	return nil
+}
+
+// remove drops key and unlinks it from its tags, forgetting tags left empty
+// Callers must hold s.mu.
+func (s *MemoryCacheStore) remove(key string) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	delete(s.entries, key)
+
+	for _, tag := range entry.tags {
+		keys := s.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+// sweep drops every entry expired at now and schedules the next sweep
+// Callers must hold s.mu.
+func (s *MemoryCacheStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			s.remove(key)
+		}
+	}
+	s.nextSweep = now.Add(memoryCacheSweepInterval)
+}
+
+// addTagScript adds an entry key (ARGV[1]) to a tag set (KEYS[1]) and extends the
+// set's expiry to the entry's TTL in milliseconds (ARGV[2]) unless it already
+// outlives it; a set kept without expiry for a persistent entry stays that way
+var addTagScript = redis.NewScript(`
+local ttl = redis.call("PTTL", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+if ttl == -2 or (ttl >= 0 and ttl < tonumber(ARGV[2])) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return ttl
+`)
+
+// RedisCacheStore is a CacheStore shared by every instance using the same Redis
+// Tags are kept as Redis sets of the keys stored under them, which expire with
+// the longest-lived entry added to them.
+type RedisCacheStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisCacheStore creates a cache store on client, namespacing every key with prefix
+func NewRedisCacheStore(client redis.UniversalClient, prefix string) *RedisCacheStore {
+	return &RedisCacheStore{client: client, prefix: prefix}
+}
+
+func (s *RedisCacheStore) entryKey(key string) string {
+	return s.prefix + ":query:" + key
+}
+
+func (s *RedisCacheStore) tagKey(tag string) string {
+	return s.prefix + ":tag:" + tag
+}
+
+// Get implements CacheStore
+func (s *RedisCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, s.entryKey(key)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set implements CacheStore
+func (s *RedisCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	entryKey := s.entryKey(key)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, entryKey, value, ttl)
+		for _, tag := range tags {
+			tagKey := s.tagKey(tag)
+			if ttl > 0 {
+				addTagScript.Eval(ctx, pipe, []string{tagKey}, entryKey, max(ttl.Milliseconds(), 1))
+			} else {
+				// The entry never expires, so neither may its tag
+				pipe.SAdd(ctx, tagKey, entryKey)
+				pipe.Persist(ctx, tagKey)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// Delete implements CacheStore
+func (s *RedisCacheStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	entryKeys := make([]string, len(keys))
+	for i, key := range keys {
+		entryKeys[i] = s.entryKey(key)
+	}
+	return s.client.Del(ctx, entryKeys...).Err()
+}
+
+// InvalidateTags implements CacheStore
+func (s *RedisCacheStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		tagKey := s.tagKey(tag)
+
+		entryKeys, err := s.client.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+
+		if err := s.client.Del(ctx, append(entryKeys, tagKey)...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// notFoundCacheValue marks a cached not-found; it is not valid JSON, so no result can collide with it
+var notFoundCacheValue = []byte("\x00not-found")
+
+// CachedQuery fills dest from the cache under key, or runs fn against the read
+// database to fill it and caches the JSON-encoded result for ttl under the tags.
+// Concurrent misses on the same key run fn once; the other callers wait for it
+// and decode its result into their own dest.
+// With NegativeTTL set, an fn returning gorm.ErrRecordNotFound is cached for
+// NegativeTTL and replayed to later callers without touching the database.
+// Cache failures are logged and fall through to the database.
+func (db *ProductionDatabase) CachedQuery(ctx context.Context, key string, ttl time.Duration, dest interface{}, fn func(*gorm.DB) error, tags ...string) error {
+	store := db.cache
+
+	data, ok, err := store.Get(ctx, key)
+	switch {
+	case err != nil:
+		db.logger().Warn("Query cache get failed", "key", key, "error", err)
+	case ok && bytes.Equal(data, notFoundCacheValue):
+		// Translated like the error of the statement the entry stands in for
+		return TranslateError(gorm.ErrRecordNotFound)
+	case ok:
+		err := json.Unmarshal(data, dest)
+		if err == nil {
+			return nil
+		}
+		db.logger().Warn("Discarding undecodable query cache entry", "key", key, "error", err)
+	}
+
+	loaded := false
+	result, err, _ := db.cacheLoads.Do(key, func() (interface{}, error) {
+		loaded = true
+		return db.loadCachedQuery(ctx, key, ttl, dest, fn, tags)
+	})
+	if err != nil || loaded {
+		return err
+	}
+
+	// Another caller loaded the result into its own dest
+	if err := json.Unmarshal(result.([]byte), dest); err != nil {
+		return fmt.Errorf("failed to decode cached query result: %w", err)
+	}
+	return nil
+}
+
+// loadCachedQuery runs fn to fill dest and stores the result under key,
+// returning the encoded result for callers that waited on the same key
+func (db *ProductionDatabase) loadCachedQuery(ctx context.Context, key string, ttl time.Duration, dest interface{}, fn func(*gorm.DB) error, tags []string) ([]byte, error) {
+	store := db.cache
+
+	if err := fn(db.GetReadDBContext(ctx).WithContext(ctx)); err != nil {
+		if db.config.NegativeTTL > 0 && errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := store.Set(ctx, key, notFoundCacheValue, db.config.NegativeTTL, tags...); err != nil {
+				db.logger().Warn("Query cache set failed", "key", key, "error", err)
+			}
+		}
+		return nil, err
+	}
+
+	data, err := json.Marshal(dest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cached query result: %w", err)
+	}
+	if err := store.Set(ctx, key, data, ttl, tags...); err != nil {
+		db.logger().Warn("Query cache set failed", "key", key, "error", err)
+	}
+	return data, nil
+}
+
+// AutoCachedQuery is CachedQuery keyed by the query itself: fn is first run in
+// DryRun mode to render its SQL, and the SQL, bound values and dest type are hashed
+// into the cache key. On a miss fn runs again for real, so it must build the whole
+// query, e.g. tx.Where("user_id = ?", id).Find(dest).
+func (db *ProductionDatabase) AutoCachedQuery(ctx context.Context, ttl time.Duration, dest interface{}, fn func(*gorm.DB) *gorm.DB, tags ...string) error {
+	key, err := db.renderedQueryKey(ctx, dest, fn)
+	if err != nil {
+		return err
+	}
+
+	return db.CachedQuery(ctx, key, ttl, dest, func(tx *gorm.DB) error {
+		return fn(tx).Error
+	}, tags...)
+}
+
+// renderedQueryKey derives a cache key from the SQL and bound values fn renders
+func (db *ProductionDatabase) renderedQueryKey(ctx context.Context, dest interface{}, fn func(*gorm.DB) *gorm.DB) (string, error) {
+	stmt := fn(db.GetReadDBContext(ctx).WithContext(ctx).Session(&gorm.Session{DryRun: true})).Statement
+	// Scan and Rows cannot complete a dry run, but the SQL is rendered by then
+	if stmt.Error != nil && !errors.Is(stmt.Error, gorm.ErrDryRunModeUnsupported) {
+		return "", stmt.Error
+	}
+	query := stmt.SQL.String()
+	if query == "" {
+		return "", fmt.Errorf("query builder produced no SQL")
+	}
+
+	vars, err := json.Marshal(stmt.Vars)
+	if err != nil {
+		vars = []byte(fmt.Sprintf("%#v", stmt.Vars))
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%T\x00%s\x00", dest, query)
+	hash.Write(vars)
+	return "sql:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// InvalidateCacheTags removes every cached query result stored under any of the tags
+func (db *ProductionDatabase) InvalidateCacheTags(ctx context.Context, tags ...string) error {
+	return db.cache.InvalidateTags(ctx, tags...)
+}
@@ -0,0 +1,281 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type cachedQueryTestFood struct {
+	ID   int
+	Name string
+}
+
+// newCachePod opens a database standing in for one application instance on the cache store
+func newCachePod(t *testing.T, name string, store CacheStore) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, name)
+	config.CacheStore = store
+	return newSQLiteTestDatabase(t, config)
+}
+
+// newRedisTestClient connects to an in-process Redis server that lives for the test
+func newRedisTestClient(t *testing.T, server *miniredis.Miniredis) *redis.Client {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+// assertCrossPodCacheHit fills the cache from one pod and reads it back from another
+// whose database has no foods table, so only a cache hit can succeed
+func assertCrossPodCacheHit(t *testing.T, podA, podB *ProductionDatabase) {
+	ctx := context.Background()
+
+	require.NoError(t, podA.GetDB().Exec("CREATE TABLE foods (id integer PRIMARY KEY, name text)").Error)
+	require.NoError(t, podA.GetDB().Exec("INSERT INTO foods (id, name) VALUES (1, 'oats'), (2, 'lentils')").Error)
+
+	queries := 0
+	load := func(dest *[]cachedQueryTestFood) func(*gorm.DB) error {
+		return func(tx *gorm.DB) error {
+			queries++
+			return tx.Raw("SELECT id, name FROM foods ORDER BY id").Scan(dest).Error
+		}
+	}
+
+	var fromA []cachedQueryTestFood
+	require.NoError(t, podA.CachedQuery(ctx, "foods:all", time.Minute, &fromA, load(&fromA), "foods"))
+	require.Len(t, fromA, 2)
+	assert.Equal(t, 1, queries)
+
+	var fromB []cachedQueryTestFood
+	require.NoError(t, podB.CachedQuery(ctx, "foods:all", time.Minute, &fromB, load(&fromB), "foods"))
+	assert.Equal(t, fromA, fromB)
+	assert.Equal(t, 1, queries, "the second pod must be served from the shared cache")
+
+	// Invalidating the tag from either pod forces the next read back to the database
+	require.NoError(t, podB.InvalidateCacheTags(ctx, "foods"))
+
+	var again []cachedQueryTestFood
+	require.NoError(t, podA.CachedQuery(ctx, "foods:all", time.Minute, &again, load(&again), "foods"))
+	assert.Equal(t, 2, queries)
+	assert.Equal(t, fromA, again)
+}
+
+func TestCachedQuery_MemoryStoreSharedAcrossPods(t *testing.T) {
+	store := NewMemoryCacheStore()
+	assertCrossPodCacheHit(t, newCachePod(t, "pod_a", store), newCachePod(t, "pod_b", store))
+}
+
+func TestCachedQuery_RedisStoreSharedAcrossPods(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	// Each pod has its own client, like separate processes
+	podA := newCachePod(t, "pod_a", NewRedisCacheStore(newRedisTestClient(t, server), "database-test"))
+	podB := newCachePod(t, "pod_b", NewRedisCacheStore(newRedisTestClient(t, server), "database-test"))
+
+	assertCrossPodCacheHit(t, podA, podB)
+	assert.True(t, server.Exists("database-test:query:foods:all"), "entries must be stored in Redis")
+}
+
+func TestMemoryCacheStore_ExpiresEntries(t *testing.T) {
+	store := NewMemoryCacheStore()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "short", []byte("1"), 10*time.Millisecond))
+	require.NoError(t, store.Set(ctx, "long", []byte("2"), time.Minute))
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok, err := store.Get(ctx, "short")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	value, ok, err := store.Get(ctx, "long")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), value)
+
+	require.NoError(t, store.Delete(ctx, "long"))
+	_, ok, err = store.Get(ctx, "long")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemoryCacheStore_SweepsExpiredEntriesAndTags(t *testing.T) {
+	store := NewMemoryCacheStore()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "short", []byte("1"), 10*time.Millisecond, "users", "meals"))
+	require.NoError(t, store.Set(ctx, "long", []byte("2"), time.Minute, "meals"))
+	time.Sleep(20 * time.Millisecond)
+
+	// "short" is never read again; the next Set after the sweep interval drops it
+	store.nextSweep = time.Now()
+	require.NoError(t, store.Set(ctx, "other", []byte("3"), time.Minute))
+	assert.NotContains(t, store.entries, "short")
+	assert.NotContains(t, store.tags, "users", "a tag is forgotten with its last entry")
+	assert.Equal(t, map[string]struct{}{"long": {}}, store.tags["meals"])
+
+	require.NoError(t, store.Delete(ctx, "long"))
+	assert.Empty(t, store.tags)
+
+	// Restoring a key without tags unlinks it from its old ones
+	require.NoError(t, store.Set(ctx, "retagged", []byte("4"), time.Minute, "users"))
+	require.NoError(t, store.Set(ctx, "retagged", []byte("5"), time.Minute))
+	assert.Empty(t, store.tags)
+}
+
+func TestRedisCacheStore_TagsExpireWithTheirEntries(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := NewRedisCacheStore(newRedisTestClient(t, server), "database-test")
+	ctx := context.Background()
+	tagKey := store.tagKey("meals")
+
+	require.NoError(t, store.Set(ctx, "a", []byte("1"), time.Minute, "meals"))
+	assert.Equal(t, time.Minute, server.TTL(tagKey))
+
+	// A longer-lived entry extends the tag; a shorter one does not shorten it
+	require.NoError(t, store.Set(ctx, "b", []byte("2"), 10*time.Minute, "meals"))
+	assert.Equal(t, 10*time.Minute, server.TTL(tagKey))
+	require.NoError(t, store.Set(ctx, "c", []byte("3"), time.Second, "meals"))
+	assert.Equal(t, 10*time.Minute, server.TTL(tagKey))
+
+	server.FastForward(10 * time.Minute)
+	assert.False(t, server.Exists(tagKey))
+
+	// Entries stored without expiry keep their tag forever
+	require.NoError(t, store.Set(ctx, "d", []byte("4"), 0, "meals"))
+	require.NoError(t, store.Set(ctx, "e", []byte("5"), time.Minute, "meals"))
+	assert.Zero(t, server.TTL(tagKey))
+	assert.True(t, server.Exists(tagKey))
+}
+
+func TestCachedQuery_NegativeTTLCachesNotFound(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.NegativeTTL = 100 * time.Millisecond
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE foods (id integer PRIMARY KEY, name text)").Error)
+
+	queries := 0
+	lookup := func() error {
+		var food cachedQueryTestFood
+		return db.CachedQuery(ctx, "foods:42", time.Minute, &food, func(tx *gorm.DB) error {
+			queries++
+			return tx.Table("foods").Where("id = ?", 42).First(&food).Error
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		err := lookup()
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.ErrorIs(t, err, ErrNotFound, "cached and uncached not-founds must match the same errors")
+	}
+	assert.Equal(t, 1, queries, "lookups within NegativeTTL must be served from the cache")
+
+	// Once the not-found expires the record created meanwhile is found
+	require.NoError(t, db.GetDB().Exec("INSERT INTO foods (id, name) VALUES (42, 'quinoa')").Error)
+	time.Sleep(150 * time.Millisecond)
+
+	assert.NoError(t, lookup())
+	assert.Equal(t, 2, queries)
+}
+
+func TestCachedQuery_ConcurrentMissesLoadOnce(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE foods (id integer PRIMARY KEY, name text)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO foods (id, name) VALUES (1, 'oats'), (2, 'lentils')").Error)
+
+	var loads int32
+	loading := make(chan struct{})
+	unblock := make(chan struct{})
+
+	const callers = 10
+	results := make([][]cachedQueryTestFood, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := db.CachedQuery(ctx, "foods:all", time.Minute, &results[i], func(tx *gorm.DB) error {
+				if atomic.AddInt32(&loads, 1) == 1 {
+					close(loading)
+				}
+				<-unblock
+				return tx.Raw("SELECT id, name FROM foods ORDER BY id").Scan(&results[i]).Error
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+
+	// Hold the first load until the other callers have missed the cache too
+	<-loading
+	time.Sleep(50 * time.Millisecond)
+	close(unblock)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	for _, result := range results {
+		assert.Equal(t, []cachedQueryTestFood{{ID: 1, Name: "oats"}, {ID: 2, Name: "lentils"}}, result)
+	}
+}
+
+func TestCachedQuery_NotFoundUncachedByDefault(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	ctx := context.Background()
+
+	queries := 0
+	for i := 0; i < 2; i++ {
+		var food cachedQueryTestFood
+		err := db.CachedQuery(ctx, "foods:42", time.Minute, &food, func(*gorm.DB) error {
+			queries++
+			return gorm.ErrRecordNotFound
+		})
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	}
+	assert.Equal(t, 2, queries)
+}
+
+func TestAutoCachedQuery_KeyedByRenderedSQL(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE foods (id integer PRIMARY KEY, name text)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO foods (id, name) VALUES (1, 'oats'), (2, 'lentils')").Error)
+
+	queries := 0
+	lookup := func(id int) cachedQueryTestFood {
+		var food cachedQueryTestFood
+		require.NoError(t, db.AutoCachedQuery(ctx, time.Minute, &food, func(tx *gorm.DB) *gorm.DB {
+			if !tx.DryRun {
+				queries++
+			}
+			return tx.Table("foods").Where("id = ?", id).First(&food)
+		}, "foods"))
+		return food
+	}
+
+	assert.Equal(t, "oats", lookup(1).Name)
+	assert.Equal(t, "oats", lookup(1).Name)
+	assert.Equal(t, 1, queries, "identical SQL and values must hit the cache")
+
+	assert.Equal(t, "lentils", lookup(2).Name)
+	assert.Equal(t, 2, queries, "different values must miss the cache")
+
+	// Tag invalidation applies to automatically keyed entries too
+	require.NoError(t, db.InvalidateCacheTags(ctx, "foods"))
+	lookup(1)
+	assert.Equal(t, 3, queries)
+}
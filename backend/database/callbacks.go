@@ -0,0 +1,182 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statement instance keys used to carry state from before to after callbacks
+const (
+	breakerInstanceKey    = "database:breaker"
+	startedAtInstanceKey  = "database:started_at"
+	repreparedInstanceKey = "database:reprepared"
+	spanInstanceKey       = "database:span"
+)
+
+// registerCallbacks installs the package's statement instrumentation on a GORM instance
+// serving the given role (primary/replica)
+func (db *ProductionDatabase) registerCallbacks(gormDB *gorm.DB, role string) error {
+	callbacks := gormDB.Callback()
+
+	// run is GORM's own callback for the statement type, used to re-execute it
+	registrations := []struct {
+		name   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+		run    func(*gorm.DB)
+	}{
+		{"create", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register, callbacks.Create().Get("gorm:create")},
+		{"query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register, callbacks.Query().Get("gorm:query")},
+		{"update", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register, callbacks.Update().Get("gorm:update")},
+		{"delete", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register, callbacks.Delete().Get("gorm:delete")},
+		{"row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register, callbacks.Row().Get("gorm:row")},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register, callbacks.Raw().Get("gorm:raw")},
+	}
+
+	// Statements GORM builds or runs as raw SQL can be routed to another role
+	routed := []struct {
+		name     string
+		register func(string, func(*gorm.DB)) error
+	}{
+		{"query", callbacks.Query().Before("gorm:query").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register},
+	}
+	for _, registration := range routed {
+		route := func(tx *gorm.DB) {
+			db.routeStatement(tx, role)
+			db.claimReplicaSlot(tx, role)
+		}
+		if err := registration.register("database:route_"+registration.name, route); err != nil {
+			return fmt.Errorf("failed to register %s routing callback: %w", registration.name, err)
+		}
+	}
+
+	if err := registerOptimisticLocking(gormDB); err != nil {
+		return err
+	}
+	if len(db.config.AuditLogModels) > 0 {
+		if err := db.registerAuditLog(gormDB); err != nil {
+			return err
+		}
+	}
+	if db.config.EncryptionKeyring != nil {
+		if err := db.registerEncryption(gormDB); err != nil {
+			return err
+		}
+	}
+	if db.config.MaskReads {
+		if err := db.registerMasking(gormDB); err != nil {
+			return err
+		}
+	}
+	// Registered without HistoryModels too, so AsOf queries fail rather than read live rows
+	if err := db.registerHistory(gormDB); err != nil {
+		return err
+	}
+
+	for _, registration := range registrations {
+		name, run := registration.name, registration.run
+		before := db.beforeStatement
+		if db.tracer != nil {
+			before = func(tx *gorm.DB) {
+				db.startStatementSpan(tx, name)
+				db.beforeStatement(tx)
+			}
+		}
+		after := func(tx *gorm.DB) { db.afterStatement(tx, role, run) }
+
+		if err := registration.before("database:before_"+registration.name, before); err != nil {
+			return fmt.Errorf("failed to register %s callback: %w", registration.name, err)
+		}
+		if err := registration.after("database:after_"+registration.name, after); err != nil {
+			return fmt.Errorf("failed to register %s callback: %w", registration.name, err)
+		}
+	}
+
+	return nil
+}
+
+// beforeStatement runs before GORM executes a statement
+func (db *ProductionDatabase) beforeStatement(tx *gorm.DB) {
+	if inTransaction(tx) && isNonTransactionalDDL(tx.Statement.SQL.String()) {
+		tx.AddError(fmt.Errorf("%w: %s", ErrNonTransactionalDDL, fingerprintSQL(tx.Statement.SQL.String())))
+		return
+	}
+
+	if breaker := db.breakerFor(tx.Statement.Context); breaker != nil {
+		if err := breaker.allow(); err != nil {
+			tx.AddError(err)
+			return
+		}
+		tx.InstanceSet(breakerInstanceKey, breaker)
+	}
+
+	db.pinStatement(tx)
+	tx.InstanceSet(startedAtInstanceKey, time.Now())
+}
+
+// afterStatement runs after GORM executed a statement on the pool serving role
+// run re-executes the statement through GORM's own callback
+func (db *ProductionDatabase) afterStatement(tx *gorm.DB, role string, run func(*gorm.DB)) {
+	db.reprepareOnCachedPlanError(tx, run)
+	releaseReplicaSlot(tx)
+
+	if value, ok := tx.InstanceGet(breakerInstanceKey); ok {
+		value.(*circuitBreaker).record(isBreakerFailure(tx.Error))
+	}
+
+	if value, ok := tx.InstanceGet(routedRoleInstanceKey); ok {
+		role = value.(string)
+	}
+
+	if db.targetBreakers != nil {
+		db.targetBreakers.get(role).record(isBreakerFailure(tx.Error))
+	}
+	if role == "primary" && isConnectionFailure(tx.Error) {
+		db.primaryConnectionFailed(tx.Error)
+	}
+	if isStatementTimeout(tx.Statement.Context, tx.Error) {
+		db.statementTimedOut(tx, role)
+	}
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		db.statementErrors.record(tx.Error)
+	}
+	if name, ok := tx.Get(namedQuerySettingKey); ok {
+		db.namedQueryStats.record(name.(string), tx.Error)
+	}
+	if role == "primary" && tx.Error == nil && !guessReadOnly(tx) {
+		StickToPrimary(tx.Statement.Context)
+		markCausalWrite(tx.Statement.Context)
+	}
+	if db.config.WarnOnUnorderedLimit {
+		db.checkUnorderedLimit(tx)
+	}
+	if db.auditRecords != nil {
+		db.auditWrite(tx)
+	}
+
+	if value, ok := tx.InstanceGet(startedAtInstanceKey); ok {
+		startedAt := value.(time.Time)
+		duration := time.Since(startedAt)
+
+		if db.latency != nil {
+			db.latency.record(OperationName(tx.Statement.Context), duration)
+		}
+		if scope := RequestScopeFrom(tx.Statement.Context); scope != nil {
+			scope.record(fingerprintSQL(tx.Statement.SQL.String()), startedAt, duration, role)
+		}
+	}
+
+	tx.Error = TranslateError(tx.Error)
+	if db.config.WrapQueryErrors {
+		wrapQueryError(tx)
+	}
+
+	if db.tracer != nil {
+		endStatementSpan(tx, role)
+	}
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const causalSessionKey contextKey = "database.causal_session"
+
+const (
+	// primaryLSNSQL reports the primary's current WAL position
+	primaryLSNSQL = "SELECT pg_current_wal_lsn()::text"
+
+	// replayLSNSQL reports how far a replica has replayed the WAL
+	replayLSNSQL = "SELECT COALESCE(pg_last_wal_replay_lsn(), '0/0')::text"
+)
+
+// LSN is a position in the primary's write-ahead log
+type LSN uint64
+
+// ParseLSN parses an LSN in Postgres' text form, e.g. "16/B374D848"
+func ParseLSN(s string) (LSN, error) {
+	high, low, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid LSN %q", s)
+	}
+	h, err := strconv.ParseUint(high, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q: %w", s, err)
+	}
+	l, err := strconv.ParseUint(low, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q: %w", s, err)
+	}
+	return LSN(h<<32 | l), nil
+}
+
+// String formats the LSN in Postgres' text form
+func (lsn LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint64(lsn)>>32, uint64(lsn)&0xFFFFFFFF)
+}
+
+// causalSession tracks the WAL position a session's reads must see
+type causalSession struct {
+	mu       sync.Mutex
+	target   LSN  // newest primary position the session's reads must see
+	wrote    bool // the session wrote since target was captured
+	replayed LSN  // newest position the replica was seen to have replayed
+}
+
+// WithCausalConsistency starts a causal session: after a write with the returned
+// context, or contexts derived from it, reads with them only use a replica once it
+// replayed the primary's WAL past the write, and go to the primary until then.
+// after is the position an earlier request of the session reached (see CausalLSN),
+// or 0. Each replica read checks the replica's replay position until it caught up.
+func (db *ProductionDatabase) WithCausalConsistency(ctx context.Context, after LSN) context.Context {
+	return context.WithValue(ctx, causalSessionKey, &causalSession{target: after})
+}
+
+// CausalLSN returns the WAL position reads in ctx's causal session must see, e.g. to
+// carry the session to the user's next request in a cookie, or 0 without a session
+func (db *ProductionDatabase) CausalLSN(ctx context.Context) (LSN, error) {
+	session := causalSessionFrom(ctx)
+	if session == nil {
+		return 0, nil
+	}
+	return db.causalTarget(ctx, session)
+}
+
+// markCausalWrite records a write on the primary in ctx's causal session
+// Its position is captured at the session's next read, by when the write has committed.
+func markCausalWrite(ctx context.Context) {
+	if session := causalSessionFrom(ctx); session != nil {
+		session.mu.Lock()
+		session.wrote = true
+		session.mu.Unlock()
+	}
+}
+
+// causalTarget returns the position the session's reads must see, first capturing
+// the primary's position if the session wrote since the last capture
+func (db *ProductionDatabase) causalTarget(ctx context.Context, session *causalSession) (LSN, error) {
+	session.mu.Lock()
+	wrote := session.wrote
+	session.wrote = false
+	target := session.target
+	session.mu.Unlock()
+
+	if !wrote {
+		return target, nil
+	}
+
+	lsn, err := db.readLSN(ContextWithIntent(ctx, WriteIntent), db.primary(), primaryLSNSQL)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if err != nil {
+		session.wrote = true
+		return 0, fmt.Errorf("failed to capture primary WAL position: %w", err)
+	}
+	session.target = max(session.target, lsn)
+	return session.target, nil
+}
+
+// replicaReplayedSession reports whether replica replayed the WAL past the position
+// ctx's causal session must see; it does when there is no session
+func (db *ProductionDatabase) replicaReplayedSession(ctx context.Context, replica *gorm.DB) bool {
+	session := causalSessionFrom(ctx)
+	if session == nil {
+		return true
+	}
+
+	target, err := db.causalTarget(ctx, session)
+	if err != nil {
+		db.logger().Warn("Causal read falling back to primary", "error", err)
+		return false
+	}
+
+	session.mu.Lock()
+	replayed := session.replayed
+	session.mu.Unlock()
+	if replayed >= target {
+		return true
+	}
+
+	replayed, err = db.readLSN(ctx, replica, replayLSNSQL)
+	if err != nil {
+		db.logger().Warn("Causal read falling back to primary", "error", err)
+		return false
+	}
+
+	session.mu.Lock()
+	session.replayed = max(session.replayed, replayed)
+	session.mu.Unlock()
+	return replayed >= target
+}
+
+// readLSN reads a WAL position from conn with query
+func (db *ProductionDatabase) readLSN(ctx context.Context, conn *gorm.DB, query string) (LSN, error) {
+	probe := db.lsnProbe
+	if probe == nil {
+		probe = queryLSN
+	}
+	return probe(ctx, conn, query)
+}
+
+// queryLSN runs query, which returns a WAL position as text, on conn
+func queryLSN(ctx context.Context, conn *gorm.DB, query string) (LSN, error) {
+	var text string
+	if err := conn.WithContext(ctx).Raw(query).Scan(&text).Error; err != nil {
+		return 0, fmt.Errorf("failed to read WAL position: %w", err)
+	}
+	return ParseLSN(text)
+}
+
+// causalSessionFrom returns the causal session carried by ctx, or nil if there is none
+func causalSessionFrom(ctx context.Context) *causalSession {
+	if ctx == nil {
+		return nil
+	}
+	session, _ := ctx.Value(causalSessionKey).(*causalSession)
+	return session
+}
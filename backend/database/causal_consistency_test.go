@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// walPositions stubs the primary's WAL position and the replica's replay position
+type walPositions struct {
+	primary, replayed LSN
+	primaryErr        error
+	replayReads       int
+}
+
+func (w *walPositions) probe(_ context.Context, _ *gorm.DB, query string) (LSN, error) {
+	if query == primaryLSNSQL {
+		return w.primary, w.primaryErr
+	}
+	w.replayReads++
+	return w.replayed, nil
+}
+
+func newCausalTestDatabase(t *testing.T) (*ProductionDatabase, *walPositions) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	db := newSQLiteTestDatabase(t, config)
+	seedNodeName(t, db.primaryDB, "primary")
+
+	positions := &walPositions{}
+	db.lsnProbe = positions.probe
+	return db, positions
+}
+
+func TestLSN_ParseAndString(t *testing.T) {
+	lsn, err := ParseLSN("16/B374D848")
+	require.NoError(t, err)
+	assert.Equal(t, LSN(0x16B374D848), lsn)
+	assert.Equal(t, "16/B374D848", lsn.String())
+
+	_, err = ParseLSN("B374D848")
+	assert.Error(t, err)
+}
+
+func TestCausalConsistency_ReadsWaitForReplicaToReplayWrites(t *testing.T) {
+	db, positions := newCausalTestDatabase(t)
+	ctx := db.WithCausalConsistency(context.Background(), 0)
+
+	// Until the session writes, any replica will do
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(ctx))
+	assert.Zero(t, positions.replayReads)
+
+	positions.primary, positions.replayed = 0x2000, 0x1000
+	require.NoError(t, db.GetWriteDB().WithContext(ctx).Exec("UPDATE node SET name = name").Error)
+	assert.Same(t, db.primaryDB, db.GetReadDBContext(ctx))
+
+	lsn, err := db.CausalLSN(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, LSN(0x2000), lsn)
+
+	positions.replayed = 0x2000
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(ctx))
+
+	// Once caught up, reads stop asking the replica until the session writes again
+	reads := positions.replayReads
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(ctx))
+	assert.Equal(t, reads, positions.replayReads)
+
+	// Other sessions are unaffected
+	positions.replayed = 0
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(context.Background()))
+}
+
+func TestCausalConsistency_SessionCarriesAcrossRequests(t *testing.T) {
+	db, positions := newCausalTestDatabase(t)
+	positions.replayed = 0x1000
+
+	ctx := db.WithCausalConsistency(context.Background(), 0x2000)
+	assert.Same(t, db.primaryDB, db.GetReadDBContext(ctx))
+
+	positions.replayed = 0x3000
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(ctx))
+}
+
+func TestCausalConsistency_FailedCaptureFallsBackToPrimary(t *testing.T) {
+	db, positions := newCausalTestDatabase(t)
+	ctx := db.WithCausalConsistency(context.Background(), 0)
+	positions.primaryErr = assert.AnError
+	positions.replayed = 0x1000
+
+	markCausalWrite(ctx)
+	assert.Same(t, db.primaryDB, db.GetReadDBContext(ctx))
+
+	// The write is still pending capture on the next read
+	positions.primaryErr, positions.primary = nil, 0x800
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(ctx))
+}
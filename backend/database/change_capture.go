@@ -0,0 +1,399 @@
+package database
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultChangeCapturePollInterval is how often CaptureChanges polls when PollInterval is unset
+	defaultChangeCapturePollInterval = time.Second
+
+	// defaultChangeCaptureBatchSize is how many changes a poll reads when BatchSize is unset
+	defaultChangeCaptureBatchSize = 1000
+)
+
+// postgresEpoch is the zero of pgoutput timestamps
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ChangeOperation is the kind of row change a Change describes
+type ChangeOperation string
+
+const (
+	ChangeInsert   ChangeOperation = "INSERT"
+	ChangeUpdate   ChangeOperation = "UPDATE"
+	ChangeDelete   ChangeOperation = "DELETE"
+	ChangeTruncate ChangeOperation = "TRUNCATE"
+)
+
+// Change is one row change read from a logical replication slot. Values are in
+// Postgres' text form, nil for NULL; unchanged TOASTed values are left out of New.
+type Change struct {
+	Operation ChangeOperation
+	Schema    string
+	Table     string
+
+	// Old holds the replica identity columns of an updated or deleted row, or the
+	// whole row with REPLICA IDENTITY FULL; nil for updates leaving the key alone
+	Old map[string]interface{}
+	// New holds the inserted or updated row
+	New map[string]interface{}
+
+	// LSN is where the change was written; CommitTime and TransactionID are its transaction's
+	LSN           LSN
+	CommitTime    time.Time
+	TransactionID uint32
+}
+
+// ChangeCapture configures a change data capture consumer
+type ChangeCapture struct {
+	// Slot is the logical replication slot, created with the pgoutput plugin if absent
+	Slot string
+	// Publication selects the captured tables, created if absent for Tables, or for all tables without any
+	Publication string
+	Tables      []string
+
+	// Interval between polls once the slot has no more changes (defaults to 1s)
+	PollInterval time.Duration
+	// Changes read per poll, rounded up to whole transactions (defaults to 1000)
+	BatchSize int
+}
+
+// CaptureChanges streams the row changes of capture's publication to handler,
+// one batch of whole transactions at a time, until ctx is done or handler fails.
+// The slot only moves past a batch once handler returned nil, so changes are
+// delivered at least once, also across restarts. A slot keeps its WAL on the
+// primary until consumed, so drop captures that are no longer run with
+// DropChangeCapture; a slot serves one consumer at a time (see LeaderElector).
+func (db *ProductionDatabase) CaptureChanges(ctx context.Context, capture ChangeCapture, handler func(ctx context.Context, changes []Change) error) error {
+	if db.primary().Dialector.Name() != "postgres" {
+		return ErrChangeCaptureUnsupported
+	}
+	if err := db.ensureChangeCapture(ctx, capture); err != nil {
+		return err
+	}
+
+	interval := capture.PollInterval
+	if interval <= 0 {
+		interval = defaultChangeCapturePollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	decoder := newPgoutputDecoder()
+	for {
+		read, err := db.captureBatch(ctx, capture, decoder, handler)
+		if err != nil {
+			return err
+		}
+		if read > 0 {
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// DropChangeCapture drops capture's replication slot and publication, releasing the WAL the slot kept
+func (db *ProductionDatabase) DropChangeCapture(ctx context.Context, capture ChangeCapture) error {
+	if db.primary().Dialector.Name() != "postgres" {
+		return ErrChangeCaptureUnsupported
+	}
+
+	pool := db.primaryPool()
+	if _, err := pool.ExecContext(ctx, "SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = $1", capture.Slot); err != nil {
+		return fmt.Errorf("failed to drop replication slot %s: %w", capture.Slot, err)
+	}
+	if _, err := pool.ExecContext(ctx, "DROP PUBLICATION IF EXISTS "+quoteIdentifier(capture.Publication)); err != nil {
+		return fmt.Errorf("failed to drop publication %s: %w", capture.Publication, err)
+	}
+	return nil
+}
+
+// ensureChangeCapture creates capture's publication and slot if they do not exist yet
+func (db *ProductionDatabase) ensureChangeCapture(ctx context.Context, capture ChangeCapture) error {
+	pool := db.primaryPool()
+
+	var exists bool
+	if err := pool.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_publication WHERE pubname = $1)", capture.Publication).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up publication %s: %w", capture.Publication, err)
+	}
+	if !exists {
+		target := "ALL TABLES"
+		if len(capture.Tables) > 0 {
+			tables := make([]string, len(capture.Tables))
+			for i, table := range capture.Tables {
+				tables[i] = quoteQualifiedIdentifier(table)
+			}
+			target = "TABLE " + strings.Join(tables, ", ")
+		}
+		if _, err := pool.ExecContext(ctx, "CREATE PUBLICATION "+quoteIdentifier(capture.Publication)+" FOR "+target); err != nil {
+			return fmt.Errorf("failed to create publication %s: %w", capture.Publication, err)
+		}
+	}
+
+	if err := pool.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)", capture.Slot).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up replication slot %s: %w", capture.Slot, err)
+	}
+	if !exists {
+		if _, err := pool.ExecContext(ctx, "SELECT pg_create_logical_replication_slot($1, 'pgoutput')", capture.Slot); err != nil {
+			return fmt.Errorf("failed to create replication slot %s: %w", capture.Slot, err)
+		}
+	}
+	return nil
+}
+
+// captureBatch peeks at the slot's next changes, hands them to handler and then
+// advances the slot past them, returning how many messages were read
+func (db *ProductionDatabase) captureBatch(ctx context.Context, capture ChangeCapture, decoder *pgoutputDecoder, handler func(ctx context.Context, changes []Change) error) (int, error) {
+	batchSize := capture.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultChangeCaptureBatchSize
+	}
+
+	rows, err := db.primaryPool().QueryContext(ctx,
+		"SELECT lsn::text, data FROM pg_logical_slot_peek_binary_changes($1, NULL, $2, 'proto_version', '1', 'publication_names', $3)",
+		capture.Slot, batchSize, capture.Publication)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read replication slot %s: %w", capture.Slot, err)
+	}
+	defer rows.Close()
+
+	var changes []Change
+	var last string
+	read := 0
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&last, &data); err != nil {
+			return 0, err
+		}
+		lsn, err := ParseLSN(last)
+		if err != nil {
+			return 0, err
+		}
+		decoded, err := decoder.decode(lsn, data)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode change at %s: %w", last, err)
+		}
+		changes = append(changes, decoded...)
+		read++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+	if read == 0 {
+		return 0, nil
+	}
+
+	if len(changes) > 0 {
+		if err := handler(ctx, changes); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := db.primaryPool().ExecContext(ctx, "SELECT pg_replication_slot_advance($1, $2::pg_lsn)", capture.Slot, last); err != nil {
+		return 0, fmt.Errorf("failed to advance replication slot %s: %w", capture.Slot, err)
+	}
+	return read, nil
+}
+
+// quoteQualifiedIdentifier quotes a possibly schema-qualified Postgres identifier
+func quoteQualifiedIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// pgoutputRelation is a table described by a pgoutput Relation message
+type pgoutputRelation struct {
+	schema  string
+	table   string
+	columns []string
+}
+
+// pgoutputDecoder decodes pgoutput (protocol version 1) messages into Changes,
+// remembering the relations and the transaction the messages refer to
+type pgoutputDecoder struct {
+	relations     map[uint32]pgoutputRelation
+	commitTime    time.Time
+	transactionID uint32
+}
+
+// newPgoutputDecoder returns a decoder that has seen no relations yet
+func newPgoutputDecoder() *pgoutputDecoder {
+	return &pgoutputDecoder{relations: make(map[uint32]pgoutputRelation)}
+}
+
+// decode decodes one message, returning the changes it carries
+func (d *pgoutputDecoder) decode(lsn LSN, data []byte) ([]Change, error) {
+	message := &pgoutputReader{data: data}
+	switch message.byte() {
+	case 'B':
+		message.uint64() // final LSN
+		d.commitTime = postgresEpoch.Add(time.Duration(int64(message.uint64())) * time.Microsecond)
+		d.transactionID = message.uint32()
+		return nil, message.err
+	case 'R':
+		id := message.uint32()
+		relation := pgoutputRelation{schema: message.string(), table: message.string()}
+		message.byte() // replica identity
+		columns := int(message.uint16())
+		for i := 0; i < columns && message.err == nil; i++ {
+			message.byte() // flags
+			relation.columns = append(relation.columns, message.string())
+			message.uint32() // type OID
+			message.uint32() // type modifier
+		}
+		if message.err == nil {
+			d.relations[id] = relation
+		}
+		return nil, message.err
+	case 'I':
+		change, relation := d.change(ChangeInsert, lsn, message)
+		if message.byte() == 'N' {
+			change.New = d.tuple(message, relation)
+		}
+		return []Change{change}, message.err
+	case 'U':
+		change, relation := d.change(ChangeUpdate, lsn, message)
+		kind := message.byte()
+		if kind == 'K' || kind == 'O' {
+			change.Old = d.tuple(message, relation)
+			kind = message.byte()
+		}
+		if kind == 'N' {
+			change.New = d.tuple(message, relation)
+		}
+		return []Change{change}, message.err
+	case 'D':
+		change, relation := d.change(ChangeDelete, lsn, message)
+		message.byte() // 'K' or 'O'
+		change.Old = d.tuple(message, relation)
+		return []Change{change}, message.err
+	case 'T':
+		relations := int(message.uint32())
+		message.byte() // options
+		var changes []Change
+		for i := 0; i < relations && message.err == nil; i++ {
+			relation := d.relations[message.uint32()]
+			changes = append(changes, Change{Operation: ChangeTruncate, Schema: relation.schema, Table: relation.table,
+				LSN: lsn, CommitTime: d.commitTime, TransactionID: d.transactionID})
+		}
+		return changes, message.err
+	default:
+		// Commit, origin, type and message messages carry no row changes
+		return nil, message.err
+	}
+}
+
+// change starts the Change of a row message, reading the relation it refers to
+func (d *pgoutputDecoder) change(operation ChangeOperation, lsn LSN, message *pgoutputReader) (Change, pgoutputRelation) {
+	id := message.uint32()
+	relation, ok := d.relations[id]
+	if !ok && message.err == nil {
+		message.err = fmt.Errorf("change for unknown relation %d", id)
+	}
+	return Change{Operation: operation, Schema: relation.schema, Table: relation.table,
+		LSN: lsn, CommitTime: d.commitTime, TransactionID: d.transactionID}, relation
+}
+
+// tuple reads a TupleData section into a map of column values
+func (d *pgoutputDecoder) tuple(message *pgoutputReader, relation pgoutputRelation) map[string]interface{} {
+	columns := int(message.uint16())
+	values := make(map[string]interface{}, columns)
+	for i := 0; i < columns && message.err == nil; i++ {
+		name := fmt.Sprintf("column%d", i+1)
+		if i < len(relation.columns) {
+			name = relation.columns[i]
+		}
+		switch kind := message.byte(); kind {
+		case 'n':
+			values[name] = nil
+		case 'u':
+			// Unchanged TOASTed value, not sent
+		case 't':
+			values[name] = string(message.bytes(int(message.uint32())))
+		default:
+			if message.err == nil {
+				message.err = fmt.Errorf("unknown tuple value kind %q", kind)
+			}
+		}
+	}
+	return values
+}
+
+// errTruncatedMessage is recorded when a pgoutput message ends early
+var errTruncatedMessage = errors.New("truncated pgoutput message")
+
+// pgoutputReader reads big-endian pgoutput fields, recording the first error
+type pgoutputReader struct {
+	data []byte
+	err  error
+}
+
+// bytes reads the next n bytes
+func (r *pgoutputReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = errTruncatedMessage
+		return nil
+	}
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *pgoutputReader) byte() byte {
+	if value := r.bytes(1); value != nil {
+		return value[0]
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint16() uint16 {
+	if value := r.bytes(2); value != nil {
+		return binary.BigEndian.Uint16(value)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint32() uint32 {
+	if value := r.bytes(4); value != nil {
+		return binary.BigEndian.Uint32(value)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint64() uint64 {
+	if value := r.bytes(8); value != nil {
+		return binary.BigEndian.Uint64(value)
+	}
+	return 0
+}
+
+// string reads a NUL-terminated string
+func (r *pgoutputReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	end := strings.IndexByte(string(r.data), 0)
+	if end < 0 {
+		r.err = errTruncatedMessage
+		return ""
+	}
+	value := string(r.data[:end])
+	r.data = r.data[end+1:]
+	return value
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pgoutputMessage builds a pgoutput message from bytes, big-endian integers and
+// NUL-terminated strings
+type pgoutputMessage []byte
+
+func (m pgoutputMessage) byte(b byte) pgoutputMessage { return append(m, b) }
+func (m pgoutputMessage) uint16(v uint16) pgoutputMessage {
+	return binary.BigEndian.AppendUint16(m, v)
+}
+func (m pgoutputMessage) uint32(v uint32) pgoutputMessage {
+	return binary.BigEndian.AppendUint32(m, v)
+}
+func (m pgoutputMessage) uint64(v uint64) pgoutputMessage {
+	return binary.BigEndian.AppendUint64(m, v)
+}
+func (m pgoutputMessage) string(s string) pgoutputMessage { return append(append(m, s...), 0) }
+func (m pgoutputMessage) text(s string) pgoutputMessage {
+	return append(m.byte('t').uint32(uint32(len(s))), s...)
+}
+
+func TestPgoutputDecoder_DecodesRowChanges(t *testing.T) {
+	decoder := newPgoutputDecoder()
+	commitTime := time.Date(2026, time.March, 3, 8, 0, 0, 0, time.UTC)
+
+	begin := pgoutputMessage{}.byte('B').uint64(0x10).uint64(uint64(commitTime.Sub(postgresEpoch).Microseconds())).uint32(731)
+	relation := pgoutputMessage{}.byte('R').uint32(16384).string("public").string("meals").byte('d').uint16(3).
+		byte(1).string("id").uint32(20).uint32(0xFFFFFFFF).
+		byte(0).string("name").uint32(25).uint32(0xFFFFFFFF).
+		byte(0).string("notes").uint32(25).uint32(0xFFFFFFFF)
+	insert := pgoutputMessage{}.byte('I').uint32(16384).byte('N').uint16(3).text("7").text("oats").byte('n')
+	update := pgoutputMessage{}.byte('U').uint32(16384).byte('K').uint16(3).text("7").byte('n').byte('n').
+		byte('N').uint16(3).text("8").text("porridge").byte('u')
+	remove := pgoutputMessage{}.byte('D').uint32(16384).byte('K').uint16(3).text("8").byte('n').byte('n')
+	commit := pgoutputMessage{}.byte('C').byte(0).uint64(0x10).uint64(0x20).uint64(0)
+
+	var changes []Change
+	for i, message := range []pgoutputMessage{begin, relation, insert, update, remove, commit} {
+		decoded, err := decoder.decode(LSN(i), message)
+		require.NoError(t, err)
+		changes = append(changes, decoded...)
+	}
+
+	require.Len(t, changes, 3)
+	assert.Equal(t, Change{
+		Operation:     ChangeInsert,
+		Schema:        "public",
+		Table:         "meals",
+		New:           map[string]interface{}{"id": "7", "name": "oats", "notes": nil},
+		LSN:           2,
+		CommitTime:    commitTime,
+		TransactionID: 731,
+	}, changes[0])
+
+	assert.Equal(t, ChangeUpdate, changes[1].Operation)
+	assert.Equal(t, map[string]interface{}{"id": "7", "name": nil, "notes": nil}, changes[1].Old)
+	assert.Equal(t, map[string]interface{}{"id": "8", "name": "porridge"}, changes[1].New, "unchanged TOASTed values are left out")
+
+	assert.Equal(t, ChangeDelete, changes[2].Operation)
+	assert.Equal(t, "8", changes[2].Old["id"])
+	assert.Nil(t, changes[2].New)
+}
+
+func TestPgoutputDecoder_RejectsMalformedMessages(t *testing.T) {
+	decoder := newPgoutputDecoder()
+
+	_, err := decoder.decode(0, pgoutputMessage{}.byte('I').uint32(1).byte('N').uint16(0))
+	assert.ErrorContains(t, err, "unknown relation")
+
+	_, err = decoder.decode(0, pgoutputMessage{}.byte('R').uint32(1).string("public"))
+	assert.ErrorIs(t, err, errTruncatedMessage)
+}
+
+func TestCaptureChanges_RequiresPostgres(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	capture := ChangeCapture{Slot: "analytics", Publication: "analytics"}
+
+	err := db.CaptureChanges(context.Background(), capture, func(context.Context, []Change) error { return nil })
+	assert.ErrorIs(t, err, ErrChangeCaptureUnsupported)
+	assert.ErrorIs(t, db.DropChangeCapture(context.Background(), capture), ErrChangeCaptureUnsupported)
+}
+
+func TestQuoteQualifiedIdentifier(t *testing.T) {
+	assert.Equal(t, `"public"."meals"`, quoteQualifiedIdentifier("public.meals"))
+	assert.Equal(t, `"meal""s"`, quoteQualifiedIdentifier(`meal"s`))
+}
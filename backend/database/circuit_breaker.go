@@ -0,0 +1,343 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// defaultBreakerName keys the breaker shared by unnamed operations, or by everything when breakers are global
+const defaultBreakerName = "default"
+
+// defaultBreakerWindow is how many recent outcomes CircuitBreakerErrorRate is measured over
+const defaultBreakerWindow = 20
+
+// circuitBreaker opens after consecutive failures or, with an errorRate, once that
+// share of the last outcomes in its window failed; it fails fast while open and
+// lets a single probe through once the cooldown has elapsed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     BreakerState
+	openedAt  time.Time
+	probing   bool
+
+	// errorRate opens the breaker once reached over a full window (0 disables)
+	errorRate      float64
+	window         []bool // ring of recent outcomes, true for a failure
+	windowNext     int
+	windowFilled   int
+	windowFailures int
+}
+
+// allow returns ErrCircuitOpen while the breaker is open
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of an allowed operation
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		if b.state != BreakerClosed {
+			b.resetWindow()
+		}
+		b.observe(false)
+		b.failures = 0
+		b.state = BreakerClosed
+		return
+	}
+
+	b.observe(true)
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold || b.errorRateExceeded() {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// observe adds an outcome to the error rate window
+func (b *circuitBreaker) observe(failed bool) {
+	if len(b.window) == 0 {
+		return
+	}
+	if b.windowFilled == len(b.window) {
+		if b.window[b.windowNext] {
+			b.windowFailures--
+		}
+	} else {
+		b.windowFilled++
+	}
+	b.window[b.windowNext] = failed
+	if failed {
+		b.windowFailures++
+	}
+	b.windowNext = (b.windowNext + 1) % len(b.window)
+}
+
+// errorRateExceeded reports whether a full window failed at errorRate or above
+func (b *circuitBreaker) errorRateExceeded() bool {
+	return b.errorRate > 0 && b.windowFilled == len(b.window) &&
+		float64(b.windowFailures) >= b.errorRate*float64(len(b.window))
+}
+
+// resetWindow forgets the outcomes of a breaker that recovered
+func (b *circuitBreaker) resetWindow() {
+	clear(b.window)
+	b.windowNext, b.windowFilled, b.windowFailures = 0, 0, 0
+}
+
+// trip opens the breaker immediately, whatever its failure count
+func (b *circuitBreaker) trip(at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerOpen
+	b.openedAt = at
+	b.probing = false
+}
+
+// State returns the current breaker state
+func (b *circuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// breakerSet holds circuit breakers keyed by name
+type breakerSet struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	errorRate float64
+	window    int
+	breakers  map[string]*circuitBreaker
+
+	// trippedAt is when tripAll last ran; breakers created within the cooldown start open
+	trippedAt time.Time
+}
+
+// newBreakerSet creates breakers from the circuit breaking settings of config
+func newBreakerSet(config *ProductionConfig) *breakerSet {
+	set := &breakerSet{
+		threshold: config.CircuitBreakerThreshold,
+		cooldown:  config.CircuitBreakerCooldown,
+		breakers:  make(map[string]*circuitBreaker),
+	}
+	if config.CircuitBreakerErrorRate > 0 {
+		set.errorRate = config.CircuitBreakerErrorRate
+		set.window = config.CircuitBreakerWindow
+		if set.window <= 0 {
+			set.window = defaultBreakerWindow
+		}
+	}
+	return set
+}
+
+// get returns the named breaker, creating it closed on first use
+func (s *breakerSet) get(name string) *circuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	breaker, ok := s.breakers[name]
+	if !ok {
+		breaker = &circuitBreaker{threshold: s.threshold, cooldown: s.cooldown, state: BreakerClosed, errorRate: s.errorRate}
+		if s.window > 0 {
+			breaker.window = make([]bool, s.window)
+		}
+		if !s.trippedAt.IsZero() && time.Since(s.trippedAt) < s.cooldown {
+			breaker.state = BreakerOpen
+			breaker.openedAt = s.trippedAt
+		}
+		s.breakers[name] = breaker
+	}
+	return breaker
+}
+
+// tripAll opens every breaker, including those created during the cooldown
+func (s *breakerSet) tripAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trippedAt = time.Now()
+	for _, breaker := range s.breakers {
+		breaker.trip(s.trippedAt)
+	}
+}
+
+// reset closes every breaker by discarding them along with the last tripAll
+func (s *breakerSet) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trippedAt = time.Time{}
+	s.breakers = make(map[string]*circuitBreaker)
+}
+
+// states returns the state of every breaker
+func (s *breakerSet) states() map[string]BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make(map[string]BreakerState, len(s.breakers))
+	for name, breaker := range s.breakers {
+		states[name] = breaker.State()
+	}
+	return states
+}
+
+// breakerFor returns the breaker guarding operations run with ctx, or nil when breakers are disabled
+func (db *ProductionDatabase) breakerFor(ctx context.Context) *circuitBreaker {
+	if db.breakers == nil {
+		return nil
+	}
+
+	name := defaultBreakerName
+	if db.config.PerOperationBreakers {
+		if operation := OperationName(ctx); operation != "" {
+			name = operation
+		}
+	}
+	return db.breakers.get(name)
+}
+
+// CircuitBreakerStates returns the state of every circuit breaker keyed by operation name
+func (db *ProductionDatabase) CircuitBreakerStates() map[string]BreakerState {
+	if db.breakers == nil {
+		return map[string]BreakerState{}
+	}
+	return db.breakers.states()
+}
+
+// TargetBreakerStates returns the state of the breaker of each target, "primary" and
+// "replica", that has run a statement; empty without TargetBreakers
+func (db *ProductionDatabase) TargetBreakerStates() map[string]BreakerState {
+	if db.targetBreakers == nil {
+		return map[string]BreakerState{}
+	}
+	return db.targetBreakers.states()
+}
+
+// targetAvailable reports whether operations may use the target serving role
+// Once an open target breaker's cooldown elapsed, the caller that half-opens it
+// pings the target as the probe; callers fail fast meanwhile.
+func (db *ProductionDatabase) targetAvailable(role string) bool {
+	if db.targetBreakers == nil {
+		return true
+	}
+
+	breaker := db.targetBreakers.get(role)
+	if breaker.allow() != nil {
+		return false
+	}
+	if breaker.State() != BreakerHalfOpen {
+		return true
+	}
+
+	err := db.pingTarget(role)
+	breaker.record(err != nil)
+	if err != nil {
+		db.logger().Warn("Circuit breaker probe failed", "target", role, "error", err)
+		return false
+	}
+	db.logger().Info("Circuit breaker probe succeeded, closing breaker", "target", role)
+	return true
+}
+
+// pingTarget pings the pool serving role, bounded by HealthCheckTimeout
+func (db *ProductionDatabase) pingTarget(role string) error {
+	target := db.primary()
+	if role == "replica" {
+		if target = db.replica(); target == nil {
+			return ErrReplicaUnavailable
+		}
+	}
+	sqlDB, err := target.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if timeout := db.config.HealthCheckTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// primaryAvailable returns ErrCircuitOpen while the primary's target breaker is open
+func (db *ProductionDatabase) primaryAvailable() error {
+	if !db.targetAvailable("primary") {
+		return fmt.Errorf("%w: primary", ErrCircuitOpen)
+	}
+	return nil
+}
+
+// isBreakerFailure reports whether an error indicates the database is struggling
+// Missing rows, caller cancellation and data errors are the caller's problem, not the database's
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	return !isNonRetryableError(err)
+}
+
+// primaryConnectionFailed reacts to a statement losing its connection to the primary
+// The other pooled connections most likely point at the same dead server, so every
+// breaker opens at once and the health checker verifies the primary right away,
+// rather than each in-flight request discovering the outage on its own.
+func (db *ProductionDatabase) primaryConnectionFailed(err error) {
+	db.logger().Error("Primary connection failure, failing fast until the primary recovers", "error", err)
+	db.emit(EventPrimaryConnectionFailure, "primary connection failed, circuit breakers opened", err)
+
+	if db.breakers != nil {
+		db.breakers.tripAll()
+	}
+	if db.targetBreakers != nil {
+		db.targetBreakers.get("primary").trip(time.Now())
+	}
+	if db.healthChecker != nil {
+		db.healthChecker.Wake()
+	}
+}
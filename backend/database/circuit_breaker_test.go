@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newBreakerTestDatabase(t *testing.T, perOperation bool) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.CircuitBreakerThreshold = 2
+	config.CircuitBreakerCooldown = time.Minute
+	config.PerOperationBreakers = perOperation
+	db := newSQLiteTestDatabase(t, config)
+
+	seedNodeName(t, db.primaryDB, "primary")
+	return db
+}
+
+func TestPerOperationBreakers_FailingOperationOpensOnlyItsBreaker(t *testing.T) {
+	db := newBreakerTestDatabase(t, true)
+
+	heavy := WithOperationName(context.Background(), "heavy_report")
+	getUser := WithOperationName(context.Background(), "get_user")
+
+	for i := 0; i < 2; i++ {
+		err := db.GetDB().WithContext(heavy).Exec("SELECT * FROM missing_report_table").Error
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+
+	// The heavy report now fails fast without reaching the database
+	err := db.GetDB().WithContext(heavy).Exec("SELECT 1").Error
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	// Other operations keep flowing
+	var name string
+	require.NoError(t, db.GetDB().WithContext(getUser).Raw("SELECT name FROM node").Scan(&name).Error)
+	assert.Equal(t, "primary", name)
+
+	states := db.CircuitBreakerStates()
+	assert.Equal(t, BreakerOpen, states["heavy_report"])
+	assert.Equal(t, BreakerClosed, states["get_user"])
+	assert.Equal(t, states, db.Stats()["circuit_breakers"])
+}
+
+func TestGlobalBreaker_FailingOperationOpensForEveryone(t *testing.T) {
+	db := newBreakerTestDatabase(t, false)
+
+	heavy := WithOperationName(context.Background(), "heavy_report")
+	getUser := WithOperationName(context.Background(), "get_user")
+
+	for i := 0; i < 2; i++ {
+		require.Error(t, db.GetDB().WithContext(heavy).Exec("SELECT * FROM missing_report_table").Error)
+	}
+
+	var name string
+	err := db.GetDB().WithContext(getUser).Raw("SELECT name FROM node").Scan(&name).Error
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, BreakerOpen, db.CircuitBreakerStates()[defaultBreakerName])
+}
+
+func TestPrimaryConnectionFailure_OpensBreakersAndWakesHealthCheck(t *testing.T) {
+	var healthLog syncBuffer
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.CircuitBreakerThreshold = 5
+	config.CircuitBreakerCooldown = time.Minute
+	config.PerOperationBreakers = true
+	config.HealthCheckInterval = time.Hour
+	config.LogHealthEveryTick = true
+	config.Logger = slog.New(slog.NewJSONHandler(&healthLog, nil))
+	var events []Event
+	config.OnEvent = func(event Event) { events = append(events, event) }
+	db := newSQLiteTestDatabase(t, config)
+
+	// Simulate the server dropping the connection mid-statement
+	require.NoError(t, db.primaryDB.Callback().Raw().Before("gorm:raw").Register("test:connection_failure", func(tx *gorm.DB) {
+		if tx.Error == nil {
+			tx.AddError(&pq.Error{Code: "08006", Message: "connection failure"})
+		}
+	}))
+
+	heavy := WithOperationName(context.Background(), "heavy_report")
+	err := db.GetDB().WithContext(heavy).Exec("SELECT 1").Error
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+
+	// One connection failure is enough, for this operation and every other one
+	assert.Equal(t, BreakerOpen, db.CircuitBreakerStates()["heavy_report"])
+	getUser := WithOperationName(context.Background(), "get_user")
+	assert.ErrorIs(t, db.GetDB().WithContext(getUser).Exec("SELECT 1").Error, ErrCircuitOpen)
+
+	require.Eventually(t, func() bool {
+		return recordWithMessage(jsonRecords(t, healthLog.String()), "Database health report") != nil
+	}, 2*time.Second, 10*time.Millisecond, "the health checker should run without waiting for its interval")
+
+	require.NotEmpty(t, events)
+	assert.Equal(t, EventPrimaryConnectionFailure, events[0].Type)
+}
+
+func TestPrimaryConnectionFailure_RecognisesNetworkErrors(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.HealthCheckInterval = time.Hour
+	var events []Event
+	config.OnEvent = func(event Event) { events = append(events, event) }
+	db := newSQLiteTestDatabase(t, config)
+
+	// lib/pq reports a refused connection as a *net.OpError without a SQLSTATE
+	require.NoError(t, db.primaryDB.Callback().Raw().Before("gorm:raw").Register("test:connection_refused", func(tx *gorm.DB) {
+		if tx.Error == nil {
+			tx.AddError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+		}
+	}))
+
+	require.Error(t, db.GetDB().Exec("SELECT 1").Error)
+	require.NotEmpty(t, events)
+	assert.Equal(t, EventPrimaryConnectionFailure, events[0].Type)
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, cooldown: 10 * time.Millisecond, state: BreakerClosed}
+
+	require.NoError(t, breaker.allow())
+	breaker.record(true)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+
+	time.Sleep(15 * time.Millisecond)
+
+	// A single probe is let through; concurrent calls still fail fast
+	require.NoError(t, breaker.allow())
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+
+	breaker.record(false)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.NoError(t, breaker.allow())
+}
+
+func TestCircuitBreaker_OpensOnErrorRate(t *testing.T) {
+	config := &ProductionConfig{
+		CircuitBreakerThreshold: 10,
+		CircuitBreakerCooldown:  time.Minute,
+		CircuitBreakerErrorRate: 0.5,
+		CircuitBreakerWindow:    4,
+	}
+	breaker := newBreakerSet(config).get("default")
+
+	// Failures never run consecutively, but half of the last four failed
+	for _, failed := range []bool{true, false, true, false} {
+		breaker.record(failed)
+		assert.Equal(t, BreakerClosed, breaker.State())
+	}
+	breaker.record(true)
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
+
+// newTargetBreakerTestDatabase opens a primary and a replica guarded by target breakers
+func newTargetBreakerTestDatabase(t *testing.T, cooldown time.Duration) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.CircuitBreakerThreshold = 2
+	config.CircuitBreakerCooldown = cooldown
+	config.PerOperationBreakers = true
+	config.TargetBreakers = true
+	return newSQLiteTestDatabase(t, config)
+}
+
+func TestTargetBreakers_OpenPrimaryFailsRetriesAndTransactionsFast(t *testing.T) {
+	db := newTargetBreakerTestDatabase(t, time.Minute)
+
+	// Failures of different operations add up on the primary's breaker
+	for _, operation := range []string{"heavy_report", "get_user"} {
+		ctx := WithOperationName(context.Background(), operation)
+		require.Error(t, db.GetDB().WithContext(ctx).Exec("SELECT * FROM missing_table").Error)
+	}
+	assert.Equal(t, BreakerOpen, db.TargetBreakerStates()["primary"])
+	assert.Equal(t, db.TargetBreakerStates(), db.Stats()["target_circuit_breakers"])
+
+	calls := 0
+	err := db.RetryOperation(context.Background(), func() error { calls++; return nil })
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Zero(t, calls)
+
+	assert.ErrorIs(t, db.Transaction(func(*gorm.DB) error { return nil }), ErrCircuitOpen)
+	assert.ErrorIs(t, db.TransactionContext(context.Background(), 0, func(*gorm.DB) error { return nil }), ErrCircuitOpen)
+}
+
+func TestTargetBreakers_OpenReplicaSendsReadsToPrimaryUntilProbeSucceeds(t *testing.T) {
+	db := newTargetBreakerTestDatabase(t, 20*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		require.Error(t, db.replicaDB.Exec("SELECT * FROM missing_table").Error)
+	}
+	assert.Equal(t, BreakerOpen, db.TargetBreakerStates()["replica"])
+	assert.Same(t, db.primaryDB, db.GetReadDB())
+
+	// After the cooldown a ping probes the replica and closes the breaker
+	time.Sleep(30 * time.Millisecond)
+	assert.Same(t, db.replicaDB, db.GetReadDB())
+	assert.Equal(t, BreakerClosed, db.TargetBreakerStates()["replica"])
+}
+
+func TestTargetBreakers_FailedProbeKeepsBreakerOpen(t *testing.T) {
+	db := newTargetBreakerTestDatabase(t, 20*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		require.Error(t, db.replicaDB.Exec("SELECT * FROM missing_table").Error)
+	}
+	replicaPool, err := db.replicaDB.DB()
+	require.NoError(t, err)
+	require.NoError(t, replicaPool.Close())
+
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, db.targetAvailable("replica"))
+	assert.Equal(t, BreakerOpen, db.TargetBreakerStates()["replica"])
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const commitHooksKey contextKey = "database.commit_hooks"
+
+// commitHooks are the functions registered to run around a transaction's commit
+type commitHooks struct {
+	mu     sync.Mutex
+	before []func() error
+	after  []func()
+}
+
+// BeforeCommit registers fn to run once the function of the transaction tx belongs
+// to returned successfully, just before the commit and still within the
+// transaction. When fn fails the transaction rolls back and returns its error.
+// Outside a transaction started by ProductionDatabase there is nothing to wait
+// for, so fn runs right away and BeforeCommit returns its error.
+func BeforeCommit(tx *gorm.DB, fn func() error) error {
+	hooks := commitHooksFrom(tx)
+	if hooks == nil {
+		return fn()
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.before = append(hooks.before, fn)
+	return nil
+}
+
+// AfterCommit registers fn to run once the transaction tx belongs to committed,
+// e.g. to invalidate caches or publish events only for changes that were kept.
+// fn never runs when the transaction rolls back. Outside a transaction started by
+// ProductionDatabase fn runs right away.
+// Hooks belong to the outermost transaction: those registered within a nested
+// transaction run when the outermost one commits, even if the savepoint rolled back.
+func AfterCommit(tx *gorm.DB, fn func()) {
+	hooks := commitHooksFrom(tx)
+	if hooks == nil {
+		fn()
+		return
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.after = append(hooks.after, fn)
+}
+
+// commitHooksFrom returns the hooks of the transaction tx belongs to, or nil
+func commitHooksFrom(tx *gorm.DB) *commitHooks {
+	if tx == nil || tx.Statement == nil || tx.Statement.Context == nil {
+		return nil
+	}
+	hooks, _ := tx.Statement.Context.Value(commitHooksKey).(*commitHooks)
+	return hooks
+}
+
+// runTransaction runs fn in a transaction on conn bound to ctx, running the hooks
+// fn registered with BeforeCommit and AfterCommit in the order they were registered
+func runTransaction(ctx context.Context, conn *gorm.DB, fn func(*gorm.DB) error, opts ...*sql.TxOptions) error {
+	hooks := &commitHooks{}
+	ctx = context.WithValue(ctx, commitHooksKey, hooks)
+
+	err := conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		// A hook may register further hooks, so the list is read afresh every time
+		for i := 0; ; i++ {
+			hooks.mu.Lock()
+			if i == len(hooks.before) {
+				hooks.mu.Unlock()
+				return nil
+			}
+			hook := hooks.before[i]
+			hooks.mu.Unlock()
+
+			if err := hook(); err != nil {
+				return fmt.Errorf("before-commit hook failed: %w", err)
+			}
+		}
+	}, opts...)
+	if err != nil {
+		return TranslateError(err)
+	}
+
+	hooks.mu.Lock()
+	after := hooks.after
+	hooks.mu.Unlock()
+	for _, hook := range after {
+		hook()
+	}
+	return nil
+}
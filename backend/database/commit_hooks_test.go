@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestCommitHooks_RunAroundTheCommit(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE budget_entries (id integer PRIMARY KEY, note text)").Error)
+
+	var calls []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		AfterCommit(tx, func() { calls = append(calls, "after") })
+		require.NoError(t, BeforeCommit(tx, func() error {
+			calls = append(calls, "before")
+			// Still within the transaction
+			return tx.Exec("INSERT INTO budget_entries (note) VALUES ('before commit')").Error
+		}))
+		calls = append(calls, "fn")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fn", "before", "after"}, calls)
+
+	var rows int64
+	require.NoError(t, db.GetDB().Raw("SELECT count(*) FROM budget_entries").Scan(&rows).Error)
+	assert.Equal(t, int64(1), rows)
+}
+
+func TestCommitHooks_SkippedOnRollback(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE budget_entries (id integer PRIMARY KEY, note text)").Error)
+
+	failure := errors.New("boom")
+	committed := false
+	err := db.TransactionWithOptions(context.Background(), sql.TxOptions{}, func(tx *gorm.DB) error {
+		AfterCommit(tx, func() { committed = true })
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.False(t, committed)
+
+	// A failing before-commit hook rolls the transaction back
+	err = db.TransactionContext(context.Background(), 0, func(tx *gorm.DB) error {
+		AfterCommit(tx, func() { committed = true })
+		require.NoError(t, BeforeCommit(tx, func() error { return failure }))
+		return tx.Exec("INSERT INTO budget_entries (note) VALUES ('rolled back')").Error
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.False(t, committed)
+
+	var rows int64
+	require.NoError(t, db.GetDB().Raw("SELECT count(*) FROM budget_entries").Scan(&rows).Error)
+	assert.Zero(t, rows)
+}
+
+func TestCommitHooks_RunRightAwayOutsideTransactions(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	ran := false
+	AfterCommit(db.GetDB(), func() { ran = true })
+	assert.True(t, ran)
+
+	failure := errors.New("boom")
+	assert.ErrorIs(t, BeforeCommit(db.GetDB(), func() error { return failure }), failure)
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// allowedConnSettings are the session settings ReadConnSettings and WriteConnSettings may change
+var allowedConnSettings = map[string]bool{
+	"work_mem":                            true,
+	"maintenance_work_mem":                true,
+	"temp_buffers":                        true,
+	"statement_timeout":                   true,
+	"lock_timeout":                        true,
+	"idle_in_transaction_session_timeout": true,
+	"default_transaction_read_only":       true,
+	"random_page_cost":                    true,
+	"effective_cache_size":                true,
+	"jit":                                 true,
+	"search_path":                         true,
+	"timezone":                            true,
+}
+
+// validateConnSettings rejects settings outside allowedConnSettings
+func validateConnSettings(field string, settings map[string]string) error {
+	for name := range settings {
+		if !allowedConnSettings[name] {
+			return fmt.Errorf("%s: setting %q is not allowed", field, name)
+		}
+	}
+	return nil
+}
+
+// connSettings returns the session settings for connections of a pool role
+func (config *ProductionConfig) connSettings(role string) map[string]string {
+	if role == "replica" {
+		return config.ReadConnSettings
+	}
+	return config.WriteConnSettings
+}
+
+// connSettingStatements turns session settings into SET statements in name order
+// Names are validated against allowedConnSettings, values are quoted as literals.
+func connSettingStatements(settings map[string]string) []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statements := make([]string, 0, len(names))
+	for _, name := range names {
+		statements = append(statements, fmt.Sprintf("SET %s TO %s", name, pq.QuoteLiteral(settings[name])))
+	}
+	return statements
+}
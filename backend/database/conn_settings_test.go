@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnSettings_AppliedPerRole(t *testing.T) {
+	config := newPostgresTestConfig(t)
+	config.ReadReplicaURL = config.DatabaseURL
+	config.ReadConnSettings = map[string]string{"work_mem": "64MB"}
+	config.WriteConnSettings = map[string]string{"work_mem": "8MB", "statement_timeout": "15s"}
+	db, err := NewProductionDatabase(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NotNil(t, db.replicaDB)
+
+	var replicaWorkMem, primaryWorkMem, primaryTimeout string
+	require.NoError(t, db.replicaDB.Raw("SELECT current_setting('work_mem')").Scan(&replicaWorkMem).Error)
+	require.NoError(t, db.GetWriteDB().Raw("SELECT current_setting('work_mem')").Scan(&primaryWorkMem).Error)
+	require.NoError(t, db.GetWriteDB().Raw("SELECT current_setting('statement_timeout')").Scan(&primaryTimeout).Error)
+
+	assert.Equal(t, "64MB", replicaWorkMem)
+	assert.Equal(t, "8MB", primaryWorkMem)
+	assert.Equal(t, "15s", primaryTimeout)
+}
+
+func TestConnSettings_RunOnEveryNewConnection(t *testing.T) {
+	stub := &stubConnector{}
+
+	config := DefaultProductionConfig()
+	config.WriteConnSettings = map[string]string{"work_mem": "8MB", "lock_timeout": "2s"}
+	config.ReadConnSettings = map[string]string{"work_mem": "64MB"}
+	config.Connector = func(string) (driver.Connector, error) { return stub, nil }
+
+	connector, err := newConnector(config, "primary", "stub")
+	require.NoError(t, err)
+
+	sqlDB := sql.OpenDB(connector)
+	defer sqlDB.Close()
+	sqlDB.SetMaxOpenConns(1)
+
+	_, err = sqlDB.Exec("SELECT 1")
+	require.NoError(t, err)
+	_, err = sqlDB.Exec("SELECT 2")
+	require.NoError(t, err)
+
+	// Settings run once when the connection opens, not on reuse
+	assert.Equal(t, []string{"SET lock_timeout TO '2s'", "SET work_mem TO '8MB'", "SELECT 1", "SELECT 2"}, stub.Statements())
+}
+
+func TestConnSettings_RejectsSettingOutsideAllowlist(t *testing.T) {
+	config := DefaultProductionConfig()
+	config.ReadConnSettings = map[string]string{"session_replication_role": "replica"}
+
+	err := config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "session_replication_role")
+
+	_, err = NewProductionDatabase(config)
+	assert.Error(t, err)
+}
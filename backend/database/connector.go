@@ -0,0 +1,311 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/lib/pq"
+	"golang.org/x/time/rate"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// deallocateAllSQL drops every prepared statement held by the server session
+const deallocateAllSQL = "DEALLOCATE ALL"
+
+// hookedConnector wraps a driver connector so pooled connections run the configured hooks
+type hookedConnector struct {
+	driver.Connector
+
+	// Statements run once on every new connection
+	initStatements []string
+
+	// Statements run before a previously used connection is handed out again
+	resetStatements []string
+
+	// cancelBackend cancels statements on the server once their context is done
+	cancelBackend bool
+
+	// postgresCancel sends those cancels with pg_cancel_backend over cancelConnector,
+	// for lib/pq connections that do not implement BackendCanceller
+	postgresCancel  bool
+	cancelConnector driver.Connector
+
+	// credentials refreshes the DSN when a connection's credentials expire; nil without a CredentialProvider
+	credentials *credentialConnector
+
+	// traceApplicationName sets application_name to the trace of each statement's context
+	traceApplicationName bool
+
+	// maskReads fakes the masked columns of rows read for statements tagged by markMaskedRead
+	maskReads bool
+
+	logger *slog.Logger
+}
+
+// newConnector builds the connector chain for a pool serving role (primary/replica)
+func newConnector(config *ProductionConfig, role, dsn string) (driver.Connector, error) {
+	base, err := config.connector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials *credentialConnector
+	if config.CredentialProvider != nil {
+		credentials = &credentialConnector{
+			dsn:      dsn,
+			provider: config.CredentialProvider,
+			connect:  config.connector,
+			base:     base,
+		}
+		base = credentials
+	}
+
+	// Cancels bypass the rate limiter: they must not queue behind a connection stampede
+	cancelConnector := base
+
+	if config.ConnectionOpenRateLimit > 0 {
+		base = newRateLimitedConnector(base, config.ConnectionOpenRateLimit, config.ConnectionOpenBurst)
+	}
+
+	connector := &hookedConnector{
+		Connector:      base,
+		initStatements: connSettingStatements(config.connSettings(role)),
+		cancelBackend:  config.CancelBackendOnDisconnect,
+		credentials:    credentials,
+
+		traceApplicationName: config.TraceApplicationName,
+		maskReads:            config.MaskReads,
+		logger:               config.logger(),
+	}
+	if config.CancelBackendOnDisconnect && config.Connector == nil {
+		connector.postgresCancel = true
+		connector.cancelConnector = cancelConnector
+	}
+	if config.DeallocateOnReturn {
+		connector.resetStatements = append(connector.resetStatements, deallocateAllSQL)
+	}
+
+	return connector, nil
+}
+
+// connector returns the base driver connector for a DSN, defaulting to lib/pq
+func (config *ProductionConfig) connector(dsn string) (driver.Connector, error) {
+	if config.Connector != nil {
+		return config.Connector(dsn)
+	}
+	return pq.NewConnector(dsn)
+}
+
+// dialector returns the GORM dialector for an opened pool, defaulting to postgres
+func (config *ProductionConfig) dialector(sqlDB *sql.DB) gorm.Dialector {
+	if config.Dialector != nil {
+		return config.Dialector(sqlDB)
+	}
+	return postgres.New(postgres.Config{Conn: sqlDB})
+}
+
+// openPool opens a configured connection pool for dsn and wraps it with GORM
+func openPool(config *ProductionConfig, role, dsn string, gormConfig *gorm.Config) (*gorm.DB, *sql.DB, error) {
+	connector, err := newConnector(config, role, dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create connector: %w", err)
+	}
+
+	sqlDB := sql.OpenDB(connector)
+	sqlDB.SetMaxOpenConns(config.MaxOpenConnections)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConnections)
+	sqlDB.SetConnMaxLifetime(config.ConnectionMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.ConnectionMaxIdleTime)
+
+	// gorm.Open stores the pool's callbacks and connection on the config it is given,
+	// so every pool needs its own copy or the last one opened takes over the others
+	poolConfig := *gormConfig
+	gormDB, err := gorm.Open(config.dialector(sqlDB), &poolConfig)
+	if err != nil {
+		sqlDB.Close()
+		return nil, nil, err
+	}
+
+	return gormDB, sqlDB, nil
+}
+
+// rateLimitedConnector paces the opening of new physical connections
+type rateLimitedConnector struct {
+	driver.Connector
+	limiter *rate.Limiter
+}
+
+// newRateLimitedConnector allows perSecond connection opens with bursts of up to burst (at least 1)
+func newRateLimitedConnector(base driver.Connector, perSecond float64, burst int) *rateLimitedConnector {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitedConnector{Connector: base, limiter: rate.NewLimiter(rate.Limit(perSecond), burst)}
+}
+
+// Connect waits for the rate limiter before opening a connection
+func (c *rateLimitedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("connection open rate limit: %w", err)
+	}
+	return c.Connector.Connect(ctx)
+}
+
+// Connect opens a connection, runs the init statements and wraps it with the connector's hooks
+func (c *hookedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hooked := &hookedConn{Conn: conn, connector: c}
+	if c.cancelBackend {
+		if canceller, ok := conn.(BackendCanceller); ok {
+			hooked.canceller = canceller
+		} else if c.postgresCancel {
+			canceller, err := newPostgresCanceller(ctx, c.cancelConnector, conn)
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to prepare backend cancellation: %w", err)
+			}
+			hooked.canceller = canceller
+		}
+	}
+
+	for _, statement := range c.initStatements {
+		if _, err := hooked.ExecContext(ctx, statement, nil); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to initialise connection with %q: %w", statement, err)
+		}
+	}
+	return hooked, nil
+}
+
+// hookedConn forwards every optional driver interface to the wrapped connection
+type hookedConn struct {
+	driver.Conn
+	connector *hookedConnector
+
+	// canceller stops running statements on the server; nil leaves it to the driver
+	canceller BackendCanceller
+
+	// applicationName is the trace tag last set as the session's application_name
+	applicationName string
+}
+
+// ResetSession runs the driver's own reset followed by the configured reset statements
+// database/sql calls it before reusing a connection that was returned to the pool
+func (c *hookedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		if err := resetter.ResetSession(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, statement := range c.connector.resetStatements {
+		if _, err := c.ExecContext(ctx, statement, nil); err != nil {
+			// A session we could not clean must not be reused
+			return driver.ErrBadConn
+		}
+	}
+	return nil
+}
+
+// ExecContext forwards to the wrapped connection
+func (c *hookedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		if err := c.tagApplicationName(ctx); err != nil {
+			return nil, err
+		}
+		defer c.cancelOnDone(ctx)()
+		result, err := execer.ExecContext(ctx, query, args)
+		return result, c.discardOnAuthExpiry(err)
+	}
+	return nil, driver.ErrSkip
+}
+
+// QueryContext forwards to the wrapped connection
+func (c *hookedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		if err := c.tagApplicationName(ctx); err != nil {
+			return nil, err
+		}
+		defer c.cancelOnDone(ctx)()
+		rows, err := queryer.QueryContext(ctx, query, args)
+		if err != nil {
+			return nil, c.discardOnAuthExpiry(err)
+		}
+		if c.connector.maskReads {
+			rows = maskDriverRows(ctx, rows)
+		}
+		return rows, nil
+	}
+	return nil, driver.ErrSkip
+}
+
+// PrepareContext forwards to the wrapped connection
+func (c *hookedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err := preparer.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, c.discardOnAuthExpiry(err)
+		}
+		return c.maskStmt(stmt), nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stmt, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return c.maskStmt(stmt), nil
+}
+
+// maskStmt wraps a prepared statement so masked reads through it are faked too
+func (c *hookedConn) maskStmt(stmt driver.Stmt) driver.Stmt {
+	if !c.connector.maskReads {
+		return stmt
+	}
+	return &maskedStmt{Stmt: stmt, conn: c}
+}
+
+// BeginTx forwards to the wrapped connection
+func (c *hookedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("driver does not support transaction options")
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without BeginTx
+}
+
+// Ping forwards to the wrapped connection
+func (c *hookedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue forwards to the wrapped connection
+func (c *hookedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// IsValid forwards to the wrapped connection
+func (c *hookedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
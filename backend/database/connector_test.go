@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeallocateOnReturn_ResetIssuesDeallocate(t *testing.T) {
+	stub := &stubConnector{}
+
+	config := DefaultProductionConfig()
+	config.PrepareStmt = false
+	config.DeallocateOnReturn = true
+	config.Connector = func(string) (driver.Connector, error) { return stub, nil }
+
+	connector, err := newConnector(config, "primary", "stub")
+	require.NoError(t, err)
+
+	sqlDB := sql.OpenDB(connector)
+	defer sqlDB.Close()
+	sqlDB.SetMaxOpenConns(1)
+
+	_, err = sqlDB.Exec("SELECT 1")
+	require.NoError(t, err)
+	_, err = sqlDB.Exec("SELECT 2")
+	require.NoError(t, err)
+
+	// The connection returned after the first statement is reset before its reuse
+	assert.Equal(t, []string{"SELECT 1", deallocateAllSQL, "SELECT 2"}, stub.Statements())
+	assert.Len(t, stub.Conns(), 1)
+}
+
+func TestDeallocateOnReturn_DisabledByDefault(t *testing.T) {
+	stub := &stubConnector{}
+
+	config := DefaultProductionConfig()
+	config.Connector = func(string) (driver.Connector, error) { return stub, nil }
+
+	connector, err := newConnector(config, "primary", "stub")
+	require.NoError(t, err)
+
+	sqlDB := sql.OpenDB(connector)
+	defer sqlDB.Close()
+	sqlDB.SetMaxOpenConns(1)
+
+	_, err = sqlDB.Exec("SELECT 1")
+	require.NoError(t, err)
+	_, err = sqlDB.Exec("SELECT 2")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"SELECT 1", "SELECT 2"}, stub.Statements())
+}
+
+func TestDeallocateOnReturn_RejectsPreparedStatementCache(t *testing.T) {
+	config := DefaultProductionConfig()
+	config.DeallocateOnReturn = true
+
+	assert.Error(t, config.Validate())
+
+	_, err := NewProductionDatabase(config)
+	assert.ErrorContains(t, err, "DeallocateOnReturn")
+
+	config.PrepareStmt = false
+	assert.NoError(t, config.Validate())
+}
+
+func TestConnectionOpenRateLimit_PacesNewConnections(t *testing.T) {
+	stub := &stubConnector{}
+
+	config := DefaultProductionConfig()
+	config.ConnectionOpenRateLimit = 20
+	config.Connector = func(string) (driver.Connector, error) { return stub, nil }
+
+	connector, err := newConnector(config, "primary", "stub")
+	require.NoError(t, err)
+
+	sqlDB := sql.OpenDB(connector)
+	defer sqlDB.Close()
+
+	// A stampede of requests each needing its own connection
+	const connections = 8
+	conns := make(chan *sql.Conn, connections)
+	var wg sync.WaitGroup
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := sqlDB.Conn(context.Background())
+			if assert.NoError(t, err) {
+				conns <- conn
+			}
+		}()
+	}
+	wg.Wait()
+	close(conns)
+	for conn := range conns {
+		conn.Close()
+	}
+
+	connects := stub.Connects()
+	require.Len(t, connects, connections)
+
+	// 20 per second with a burst of one spaces opens 50ms apart
+	interval := 50 * time.Millisecond
+	tolerance := 10 * time.Millisecond
+	for i := 1; i < len(connects); i++ {
+		assert.GreaterOrEqual(t, connects[i].Sub(connects[i-1]), interval-tolerance, "connection %d opened too soon", i)
+	}
+
+	// Reusing pooled connections is not rate limited
+	started := time.Now()
+	for i := 0; i < connections; i++ {
+		_, err := sqlDB.Exec("SELECT 1")
+		require.NoError(t, err)
+	}
+	assert.Less(t, time.Since(started), interval)
+	assert.Len(t, stub.Connects(), connections)
+}
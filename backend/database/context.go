@@ -0,0 +1,40 @@
+package database
+
+import "context"
+
+// contextKey namespaces values the package stores on contexts
+type contextKey string
+
+const operationNameKey contextKey = "database.operation_name"
+
+// WithOperationName names the database operations run with ctx, e.g. "get_user"
+// The name keys per-operation circuit breakers and instrumentation
+func WithOperationName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, operationNameKey, name)
+}
+
+// OperationName returns the operation name stored on ctx, or "" if there is none
+func OperationName(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	name, _ := ctx.Value(operationNameKey).(string)
+	return name
+}
+
+const requestIDKey contextKey = "database.request_id"
+
+// WithRequestID tags the writes run with ctx with the ID of the request making
+// them, which the audit log records (see AuditLogModels)
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID stored on ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
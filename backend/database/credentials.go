@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// isAuthFailure reports whether the server rejected the connection's credentials (SQLSTATE class 28)
+func isAuthFailure(err error) bool {
+	return strings.HasPrefix(sqlState(err), "28")
+}
+
+// credentialConnector opens connections with the DSN CredentialProvider returns
+// The DSN is cached until a connection reports expired credentials.
+type credentialConnector struct {
+	dsn      string
+	provider func(ctx context.Context, dsn string) (string, error)
+	connect  func(dsn string) (driver.Connector, error)
+	base     driver.Connector
+
+	mu     sync.Mutex
+	cached string
+}
+
+// credentialedDSN returns the cached DSN, asking the provider for one if there is
+// none; fresh reports whether the provider was asked
+func (c *credentialConnector) credentialedDSN(ctx context.Context) (dsn string, fresh bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != "" {
+		return c.cached, false, nil
+	}
+	dsn, err = c.provider(ctx, c.dsn)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to refresh database credentials: %w", err)
+	}
+	c.cached = dsn
+	return dsn, true, nil
+}
+
+// expire drops the cached DSN so the next connection refreshes its credentials
+func (c *credentialConnector) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cached = ""
+}
+
+// Connect opens a connection with the current credentials. Cached credentials the
+// server rejects are refreshed and tried once more; fresh credentials being
+// rejected is a genuine authentication failure and returned as is.
+func (c *credentialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	for {
+		dsn, fresh, err := c.credentialedDSN(ctx)
+		if err != nil {
+			return nil, err
+		}
+		connector, err := c.connect(dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := connector.Connect(ctx)
+		if err == nil || fresh || !isAuthFailure(err) {
+			return conn, err
+		}
+		c.expire()
+	}
+}
+
+// Driver returns the driver of the configured DSN
+func (c *credentialConnector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+// discardOnAuthExpiry turns an authentication failure into driver.ErrBadConn and
+// expires the cached credentials. database/sql then discards the connection and
+// retries the statement on a new one, which authenticates with refreshed credentials.
+// The server rejected the statement, so it did not run and is safe to retry.
+func (c *hookedConn) discardOnAuthExpiry(err error) error {
+	if c.connector.credentials == nil || !isAuthFailure(err) {
+		return err
+	}
+	c.connector.credentials.expire()
+	return driver.ErrBadConn
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenAuthServer stands in for a server authenticating connections by a token in their DSN
+type tokenAuthServer struct {
+	mu      sync.Mutex
+	expired map[string]bool
+	revoked map[string]bool
+}
+
+func (s *tokenAuthServer) tokenState(token string) (expired, revoked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expired[token], s.revoked[token]
+}
+
+func (s *tokenAuthServer) connector(dsn string) (driver.Connector, error) {
+	base, token, _ := strings.Cut(dsn, "#token=")
+	return &tokenAuthConnector{server: s, dsn: base, token: token}, nil
+}
+
+type tokenAuthConnector struct {
+	server *tokenAuthServer
+	dsn    string
+	token  string
+}
+
+func (c *tokenAuthConnector) Connect(context.Context) (driver.Conn, error) {
+	if _, revoked := c.server.tokenState(c.token); revoked {
+		return nil, &pq.Error{Code: "28P01", Message: "password authentication failed"}
+	}
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenAuthConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), connector: c}, nil
+}
+
+func (c *tokenAuthConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+// tokenAuthConn fails every statement once its token has expired
+type tokenAuthConn struct {
+	*sqlite3.SQLiteConn
+	connector *tokenAuthConnector
+}
+
+func (c *tokenAuthConn) authError() error {
+	if expired, _ := c.connector.server.tokenState(c.connector.token); expired {
+		return &pq.Error{Code: "28000", Message: "IAM token expired"}
+	}
+	return nil
+}
+
+func (c *tokenAuthConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.authError(); err != nil {
+		return nil, err
+	}
+	return c.SQLiteConn.PrepareContext(ctx, query)
+}
+
+func (c *tokenAuthConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.authError(); err != nil {
+		return nil, err
+	}
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+func (c *tokenAuthConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.authError(); err != nil {
+		return nil, err
+	}
+	return c.SQLiteConn.QueryContext(ctx, query, args)
+}
+
+// newTokenAuthDatabase opens a database whose provider hands out tokens in order
+func newTokenAuthDatabase(t *testing.T, server *tokenAuthServer, tokens ...string) (*ProductionDatabase, *int) {
+	refreshes := 0
+	config := newSQLiteTestConfig(t, "primary")
+	config.Connector = server.connector
+	config.CredentialProvider = func(ctx context.Context, dsn string) (string, error) {
+		token := tokens[min(refreshes, len(tokens)-1)]
+		refreshes++
+		return fmt.Sprintf("%s#token=%s", dsn, token), nil
+	}
+	return newSQLiteTestDatabase(t, config), &refreshes
+}
+
+func TestCredentialProvider_ExpiredTokenRefreshedTransparently(t *testing.T) {
+	server := &tokenAuthServer{expired: map[string]bool{}, revoked: map[string]bool{}}
+	db, refreshes := newTokenAuthDatabase(t, server, "first", "second")
+	require.Equal(t, 1, *refreshes)
+
+	// The idle connection's token expires while it sits in the pool
+	server.mu.Lock()
+	server.expired["first"] = true
+	server.mu.Unlock()
+
+	var answer int
+	require.NoError(t, db.GetDB().Raw("SELECT 42").Scan(&answer).Error)
+	assert.Equal(t, 42, answer)
+	assert.Equal(t, 2, *refreshes, "credentials must be refreshed once")
+}
+
+func TestCredentialProvider_RejectedFreshCredentialsFail(t *testing.T) {
+	server := &tokenAuthServer{expired: map[string]bool{}, revoked: map[string]bool{}}
+	db, refreshes := newTokenAuthDatabase(t, server, "first", "revoked")
+
+	server.mu.Lock()
+	server.expired["first"] = true
+	server.revoked["revoked"] = true
+	server.mu.Unlock()
+
+	var answer int
+	err := db.GetDB().Raw("SELECT 42").Scan(&answer).Error
+	require.Error(t, err)
+	assert.True(t, isAuthFailure(err), "the authentication failure must be surfaced, got %v", err)
+	assert.Equal(t, 2, *refreshes, "rejected fresh credentials must not be retried")
+}
@@ -15,23 +15,28 @@ func NewDatabase(db *sql.DB) *Database {
 }
 
 // QueryRow executes a query that returns at most one row
+// Its error only surfaces from Scan, which returns it untranslated; pass it to
+// TranslateError to match it against ErrNotFound and the other sentinels.
 func (d *Database) QueryRow(query string, args ...interface{}) *sql.Row {
 	return d.DB.QueryRow(query, args...)
 }
 
 // Query executes a query that returns rows
 func (d *Database) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return d.DB.Query(query, args...)
+	rows, err := d.DB.Query(query, args...)
+	return rows, TranslateError(err)
 }
 
 // Exec executes a query without returning rows
 func (d *Database) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return d.DB.Exec(query, args...)
+	result, err := d.DB.Exec(query, args...)
+	return result, TranslateError(err)
 }
 
 // Begin starts a transaction
 func (d *Database) Begin() (*sql.Tx, error) {
-	return d.DB.Begin()
+	tx, err := d.DB.Begin()
+	return tx, TranslateError(err)
 }
 
 // Close closes the database connection
@@ -41,5 +46,5 @@ func (d *Database) Close() error {
 
 // Ping verifies the connection to the database
 func (d *Database) Ping() error {
-	return d.DB.Ping()
+	return TranslateError(d.DB.Ping())
 }
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// nonTransactionalDDL matches statements Postgres refuses to run in a transaction block
+// REFRESH MATERIALIZED VIEW CONCURRENTLY is allowed in one and deliberately not matched.
+var nonTransactionalDDL = []*regexp.Regexp{
+	regexp.MustCompile(`(?is)^\s*(create\s+(unique\s+)?|drop\s+)index\s+concurrently\b`),
+	regexp.MustCompile(`(?is)^\s*reindex\b.*\bconcurrently\b`),
+	regexp.MustCompile(`(?is)^\s*alter\s+table\b.*\bdetach\s+partition\b.*\bconcurrently\b`),
+	regexp.MustCompile(`(?is)^\s*vacuum\b`),
+	regexp.MustCompile(`(?is)^\s*(create|drop)\s+(database|tablespace)\b`),
+	regexp.MustCompile(`(?is)^\s*alter\s+system\b`),
+}
+
+// isNonTransactionalDDL reports whether a statement cannot run inside a transaction
+func isNonTransactionalDDL(sql string) bool {
+	for _, pattern := range nonTransactionalDDL {
+		if pattern.MatchString(sql) {
+			return true
+		}
+	}
+	return false
+}
+
+// inTransaction reports whether a statement runs inside a transaction
+func inTransaction(tx *gorm.DB) bool {
+	_, ok := tx.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+// ExecDDLNonTransactional runs DDL that cannot run inside a transaction, such as
+// CREATE INDEX CONCURRENTLY or VACUUM, on the primary outside of any transaction.
+// Inside Transaction or TransactionContext such statements fail with ErrNonTransactionalDDL.
+func (db *ProductionDatabase) ExecDDLNonTransactional(ctx context.Context, sql string, args ...interface{}) error {
+	return db.primary().WithContext(ctx).Exec(sql, args...).Error
+}
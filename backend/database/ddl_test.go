@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTransactionContext_RejectsConcurrentIndex(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE meals (id integer PRIMARY KEY, name text)").Error)
+
+	err := db.TransactionContext(context.Background(), 0, func(tx *gorm.DB) error {
+		return tx.Exec("CREATE INDEX CONCURRENTLY idx_meals_name ON meals (name)").Error
+	})
+	require.ErrorIs(t, err, ErrNonTransactionalDDL)
+	assert.Contains(t, err.Error(), "ExecDDLNonTransactional")
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.Exec("VACUUM").Error
+	})
+	assert.ErrorIs(t, err, ErrNonTransactionalDDL)
+}
+
+func TestIsNonTransactionalDDL(t *testing.T) {
+	for _, sql := range []string{
+		"CREATE INDEX CONCURRENTLY idx ON meals (name)",
+		"create unique index concurrently idx on meals (name)",
+		"DROP INDEX CONCURRENTLY idx",
+		"REINDEX INDEX CONCURRENTLY idx",
+		"ALTER TABLE events DETACH PARTITION events_2023 CONCURRENTLY",
+		"VACUUM ANALYZE meals",
+		"CREATE DATABASE nutrition",
+		"ALTER SYSTEM SET work_mem = '8MB'",
+	} {
+		assert.True(t, isNonTransactionalDDL(sql), sql)
+	}
+
+	for _, sql := range []string{
+		"CREATE INDEX idx ON meals (name)",
+		"REFRESH MATERIALIZED VIEW CONCURRENTLY meal_totals",
+		"SELECT * FROM meals WHERE name = 'concurrently'",
+		"ALTER TABLE events DETACH PARTITION events_2023",
+	} {
+		assert.False(t, isNonTransactionalDDL(sql), sql)
+	}
+}
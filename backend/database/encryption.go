@@ -0,0 +1,332 @@
+package database
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// encryptedSerializerName is the serializer tag of encrypted fields
+	encryptedSerializerName = "encrypted"
+
+	// encryptedValuePrefix starts every encrypted value, followed by the key ID
+	// and the base64 nonce and ciphertext: enc:v1:<key ID>:<data>
+	encryptedValuePrefix = "enc:v1:"
+
+	// defaultReencryptBatchSize is the ReencryptModel batch size used when none is given
+	defaultReencryptBatchSize = 500
+
+	keyringKey contextKey = "database.keyring"
+
+	// assignmentsInstanceKey carries the plaintext of encrypted update assignments to restoreAssignments
+	assignmentsInstanceKey = "database:encrypted_assignments"
+)
+
+func init() {
+	schema.RegisterSerializer(encryptedSerializerName, encryptedSerializer{})
+}
+
+// Keyring holds the AES keys encrypted fields are sealed with, by ID. Values are
+// always encrypted with the current key and decrypted with the key they name, so
+// keys rotate by adding a new current key, running ReencryptModel for every
+// model with encrypted fields, and only then retiring the old key.
+type Keyring struct {
+	current string
+	ciphers map[string]cipher.AEAD
+}
+
+// NewKeyring returns a keyring encrypting with keys[current]; keys are 16, 24 or
+// 32 bytes long, for AES-128, AES-192 or AES-256 in GCM mode
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keyring", current)
+	}
+
+	keyring := &Keyring{current: current, ciphers: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		keyring.ciphers[id] = aead
+	}
+	return keyring, nil
+}
+
+// CurrentKeyID returns the ID of the key values are encrypted with
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// encrypt seals plaintext with the current key, bound to the column it is stored in
+func (k *Keyring) encrypt(plaintext, column []byte) (string, error) {
+	aead := k.ciphers[k.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, column)
+	return encryptedValuePrefix + k.current + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value encrypt sealed for column
+func (k *Keyring) decrypt(value string, column []byte) ([]byte, error) {
+	id, data, ok := strings.Cut(strings.TrimPrefix(value, encryptedValuePrefix), ":")
+	if !ok {
+		return nil, ErrDecryptionFailed
+	}
+	aead, ok := k.ciphers[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrEncryptionKeyUnavailable, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], column)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// encryptedSerializer encrypts fields tagged `gorm:"serializer:encrypted"` with
+// the keyring of the statement's context. Values are JSON encoded before they are
+// encrypted, and bound to their table and column so they cannot be moved to another.
+type encryptedSerializer struct{}
+
+// Value implements schema.SerializerValuerInterface
+func (encryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	if value := reflect.ValueOf(fieldValue); !value.IsValid() || (value.Kind() == reflect.Pointer && value.IsNil()) {
+		return nil, nil
+	}
+	keyring, _ := ctx.Value(keyringKey).(*Keyring)
+	if keyring == nil {
+		return nil, fmt.Errorf("%w: cannot encrypt %s", ErrEncryptionKeyUnavailable, field.Name)
+	}
+
+	plaintext, err := json.Marshal(fieldValue)
+	if err != nil {
+		return nil, err
+	}
+	return keyring.encrypt(plaintext, encryptedColumn(field))
+}
+
+// Scan implements schema.SerializerInterface. Values that are not encrypted yet,
+// e.g. written before the field was, are read as they are.
+func (encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := reflect.New(field.FieldType)
+
+	var stored string
+	switch value := dbValue.(type) {
+	case nil:
+	case string:
+		stored = value
+	case []byte:
+		stored = string(value)
+	default:
+		return fmt.Errorf("cannot decrypt %s from %T", field.Name, dbValue)
+	}
+
+	switch {
+	case stored == "":
+	case strings.HasPrefix(stored, encryptedValuePrefix):
+		keyring, _ := ctx.Value(keyringKey).(*Keyring)
+		if keyring == nil {
+			return fmt.Errorf("%w: cannot decrypt %s", ErrEncryptionKeyUnavailable, field.Name)
+		}
+		plaintext, err := keyring.decrypt(stored, encryptedColumn(field))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", field.Name, err)
+		}
+		if err := json.Unmarshal(plaintext, fieldValue.Interface()); err != nil {
+			return err
+		}
+	case fieldValue.Elem().Kind() == reflect.String:
+		fieldValue.Elem().SetString(stored)
+	default:
+		if err := json.Unmarshal([]byte(stored), fieldValue.Interface()); err != nil {
+			return err
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// isEncryptedField reports whether field is stored with the encrypted serializer
+func isEncryptedField(field *schema.Field) bool {
+	return field != nil && strings.EqualFold(field.TagSettings["SERIALIZER"], encryptedSerializerName)
+}
+
+// encryptedColumn is the additional data binding an encrypted value to its column
+func encryptedColumn(field *schema.Field) []byte {
+	return []byte(field.Schema.Table + "." + field.DBName)
+}
+
+// registerEncryption installs the callbacks handing statements the EncryptionKeyring
+func (db *ProductionDatabase) registerEncryption(gormDB *gorm.DB) error {
+	withKeyring := func(tx *gorm.DB) {
+		tx.Statement.Context = context.WithValue(tx.Statement.Context, keyringKey, db.config.EncryptionKeyring)
+	}
+
+	callbacks := gormDB.Callback()
+	registrations := []struct {
+		name     string
+		register func(string, func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("gorm:create").Register},
+		{"query", callbacks.Query().Before("gorm:query").Register},
+		{"update", callbacks.Update().Before("gorm:update").Register},
+		{"delete", callbacks.Delete().Before("gorm:delete").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register},
+	}
+	for _, registration := range registrations {
+		if err := registration.register("database:keyring_"+registration.name, withKeyring); err != nil {
+			return fmt.Errorf("failed to register encryption callback: %w", err)
+		}
+	}
+
+	// Registered after the keyring callback, which the update needs to encrypt
+	if err := callbacks.Update().Before("gorm:update").Register("database:encrypt_assignments", encryptAssignments); err != nil {
+		return fmt.Errorf("failed to register encryption callback: %w", err)
+	}
+	if err := callbacks.Update().After("gorm:update").Register("database:restore_assignments", restoreAssignments); err != nil {
+		return fmt.Errorf("failed to register encryption callback: %w", err)
+	}
+	return nil
+}
+
+// encryptAssignments encrypts the values an update assigns to encrypted fields
+// through a map, e.g. Update("condition", value), which GORM would write without
+// the serializer. The caller's map is left as it is, and restoreAssignments puts
+// the plaintext back on the model GORM copies the assigned values to.
+func encryptAssignments(tx *gorm.DB) {
+	stmt := tx.Statement
+	assigned, ok := stmt.Dest.(map[string]interface{})
+	if !ok || stmt.Schema == nil || tx.Error != nil {
+		return
+	}
+
+	var encrypted map[string]interface{}
+	plaintexts := make(map[*schema.Field]interface{})
+	for name, value := range assigned {
+		field := stmt.Schema.LookUpField(name)
+		if !isEncryptedField(field) {
+			continue
+		}
+		switch value.(type) {
+		case clause.Expression, *gorm.DB:
+			continue
+		}
+
+		ciphertext, err := encryptedSerializer{}.Value(stmt.Context, field, stmt.ReflectValue, value)
+		if err != nil {
+			tx.AddError(err)
+			return
+		}
+		if encrypted == nil {
+			encrypted = make(map[string]interface{}, len(assigned))
+			for name, value := range assigned {
+				encrypted[name] = value
+			}
+		}
+		encrypted[name] = ciphertext
+		plaintexts[field] = value
+	}
+
+	if encrypted != nil {
+		stmt.Dest = encrypted
+		tx.InstanceSet(assignmentsInstanceKey, plaintexts)
+	}
+}
+
+// restoreAssignments sets the fields encryptAssignments encrypted back to their
+// plaintext on the updated model
+func restoreAssignments(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(assignmentsInstanceKey)
+	if !ok {
+		return
+	}
+	stmt := tx.Statement
+
+	var models []reflect.Value
+	switch model := stmt.ReflectValue; model.Kind() {
+	case reflect.Struct:
+		models = append(models, model)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < model.Len(); i++ {
+			models = append(models, reflect.Indirect(model.Index(i)))
+		}
+	}
+
+	for field, plaintext := range value.(map[*schema.Field]interface{}) {
+		for _, model := range models {
+			if model.CanAddr() {
+				if err := field.Set(stmt.Context, model, plaintext); err != nil {
+					tx.AddError(fmt.Errorf("failed to restore %s: %w", field.Name, err))
+				}
+			}
+		}
+	}
+}
+
+// ReencryptModel rewrites the encrypted fields of every row of model, batchSize
+// rows per transaction, so they are encrypted with the current key of the
+// EncryptionKeyring, and returns how many rows it rewrote
+func (db *ProductionDatabase) ReencryptModel(ctx context.Context, model interface{}, batchSize int) (int, error) {
+	conn := db.primary().WithContext(ctx)
+	stmt := &gorm.Statement{DB: conn}
+	if err := stmt.Parse(model); err != nil {
+		return 0, fmt.Errorf("failed to parse model %T: %w", model, err)
+	}
+
+	var columns []string
+	for _, field := range stmt.Schema.Fields {
+		if isEncryptedField(field) && field.DBName != "" {
+			columns = append(columns, field.DBName)
+		}
+	}
+	if len(columns) == 0 {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = defaultReencryptBatchSize
+	}
+
+	rewritten := 0
+	rows := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+	result := conn.Model(model).FindInBatches(rows.Interface(), batchSize, func(batch *gorm.DB, _ int) error {
+		return db.TransactionWithOptions(ctx, sql.TxOptions{}, func(tx *gorm.DB) error {
+			for i := 0; i < rows.Elem().Len(); i++ {
+				row := rows.Elem().Index(i).Addr().Interface()
+				if err := tx.Model(row).Select(columns).Updates(row).Error; err != nil {
+					return err
+				}
+				rewritten++
+			}
+			return nil
+		})
+	})
+	return rewritten, result.Error
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/clause"
+)
+
+// medicalRecord is a model with encrypted fields
+type medicalRecord struct {
+	ID        uint `gorm:"primaryKey"`
+	UserID    uint
+	Condition string   `gorm:"serializer:encrypted"`
+	Allergies []string `gorm:"serializer:encrypted"`
+}
+
+// testKeyring returns a keyring encrypting with current, holding a key for every ID
+func testKeyring(t *testing.T, current string, ids ...string) *Keyring {
+	t.Helper()
+	keys := make(map[string][]byte, len(ids))
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	keyring, err := NewKeyring(current, keys)
+	require.NoError(t, err)
+	return keyring
+}
+
+// storedColumn returns a column of the medical record as stored in the table
+func storedColumn(t *testing.T, db *ProductionDatabase, column string, id uint) string {
+	t.Helper()
+	var stored string
+	require.NoError(t, db.primaryPool().QueryRow("SELECT "+column+" FROM medical_records WHERE id = ?", id).Scan(&stored))
+	return stored
+}
+
+func TestEncryptedFields_AreNeverStoredInPlaintext(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.EncryptionKeyring = testKeyring(t, "k1", "k1")
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&medicalRecord{}))
+
+	record := medicalRecord{UserID: 7, Condition: "type 2 diabetes", Allergies: []string{"peanuts"}}
+	require.NoError(t, db.GetDB().Create(&record).Error)
+
+	stored := storedColumn(t, db, "condition", record.ID)
+	assert.Contains(t, stored, "enc:v1:k1:")
+	assert.NotContains(t, stored, "diabetes")
+	assert.NotContains(t, storedColumn(t, db, "allergies", record.ID), "peanuts")
+
+	var loaded medicalRecord
+	require.NoError(t, db.GetDB().First(&loaded, record.ID).Error)
+	assert.Equal(t, record, loaded)
+
+	require.NoError(t, db.GetDB().Model(&loaded).Update("condition", "prediabetes").Error)
+	assert.Equal(t, "prediabetes", loaded.Condition, "the model keeps the plaintext")
+	assert.NotContains(t, storedColumn(t, db, "condition", record.ID), "prediabetes")
+
+	allergies := map[string]interface{}{"allergies": []string{"shellfish"}}
+	require.NoError(t, db.GetDB().Model(&loaded).Updates(allergies).Error)
+	assert.Equal(t, []string{"shellfish"}, allergies["allergies"], "the caller's map is left as it is")
+	assert.NotContains(t, storedColumn(t, db, "allergies", record.ID), "shellfish")
+
+	require.NoError(t, db.GetDB().First(&loaded, record.ID).Error)
+	assert.Equal(t, "prediabetes", loaded.Condition)
+	assert.Equal(t, []string{"shellfish"}, loaded.Allergies)
+}
+
+func TestEncryptedFields_DecryptOnRawScans(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.EncryptionKeyring = testKeyring(t, "k1", "k1")
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&medicalRecord{}))
+
+	record := medicalRecord{UserID: 7, Condition: "type 2 diabetes", Allergies: []string{"peanuts"}}
+	require.NoError(t, db.GetDB().Create(&record).Error)
+
+	var scanned []medicalRecord
+	require.NoError(t, db.GetDB().Raw("SELECT * FROM medical_records").Scan(&scanned).Error)
+	assert.Equal(t, []medicalRecord{record}, scanned)
+
+	var deleted []medicalRecord
+	require.NoError(t, db.GetDB().Clauses(clause.Returning{}).Where("id = ?", record.ID).Delete(&deleted).Error)
+	assert.Equal(t, []medicalRecord{record}, deleted)
+}
+
+func TestEncryptedFields_RejectTamperingAndMovedValues(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.EncryptionKeyring = testKeyring(t, "k1", "k1")
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&medicalRecord{}))
+
+	record := medicalRecord{UserID: 7, Condition: "asthma", Allergies: []string{"pollen"}}
+	require.NoError(t, db.GetDB().Create(&record).Error)
+
+	// A value copied to another column no longer decrypts
+	require.NoError(t, db.GetDB().Exec("UPDATE medical_records SET allergies = condition").Error)
+	var loaded medicalRecord
+	assert.ErrorIs(t, db.GetDB().First(&loaded, record.ID).Error, ErrDecryptionFailed)
+
+	require.NoError(t, db.GetDB().Exec("UPDATE medical_records SET allergies = NULL, condition = ?", "enc:v1:k9:AAAA").Error)
+	assert.ErrorIs(t, db.GetDB().First(&loaded, record.ID).Error, ErrEncryptionKeyUnavailable)
+}
+
+func TestReencryptModel_RotatesToTheCurrentKey(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.EncryptionKeyring = testKeyring(t, "k1", "k1")
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&medicalRecord{}))
+	ctx := context.Background()
+
+	records := []medicalRecord{{UserID: 7, Condition: "celiac"}, {UserID: 8, Condition: "gout"}}
+	require.NoError(t, db.GetDB().Create(&records).Error)
+	// A row written before the field was encrypted
+	require.NoError(t, db.GetDB().Exec("INSERT INTO medical_records (id, user_id, condition) VALUES (3, 9, 'anemia')").Error)
+
+	db.config.EncryptionKeyring = testKeyring(t, "k2", "k1", "k2")
+	rewritten, err := db.ReencryptModel(ctx, &medicalRecord{}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rewritten)
+
+	for _, id := range []uint{1, 2, 3} {
+		assert.Contains(t, storedColumn(t, db, "condition", id), "enc:v1:k2:")
+	}
+	var loaded medicalRecord
+	require.NoError(t, db.GetDB().First(&loaded, 3).Error)
+	assert.Equal(t, "anemia", loaded.Condition)
+}
+
+func TestEncryptedFields_RequireAKeyring(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.Migrate(&medicalRecord{}))
+
+	err := db.GetDB().Create(&medicalRecord{UserID: 7, Condition: "asthma"}).Error
+	assert.ErrorIs(t, err, ErrEncryptionKeyUnavailable)
+}
+
+func TestNewKeyring_ValidatesKeys(t *testing.T) {
+	_, err := NewKeyring("k2", map[string][]byte{"k1": make([]byte, 32)})
+	assert.Error(t, err)
+	_, err = NewKeyring("k1", map[string][]byte{"k1": make([]byte, 7)})
+	assert.Error(t, err)
+	_, err = NewKeyring("k:1", map[string][]byte{"k:1": make([]byte, 32)})
+	assert.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ErrorClass groups database errors by what went wrong, independent of the driver
+// and of the server's message language
+type ErrorClass string
+
+const (
+	// ErrorClassUnknown is an error without a SQLSTATE the package recognises
+	ErrorClassUnknown ErrorClass = "unknown"
+
+	// ErrorClassIntegrityViolation is a unique, foreign key, check or not-null violation (class 23)
+	ErrorClassIntegrityViolation ErrorClass = "integrity_violation"
+
+	// ErrorClassDataException is a value the statement cannot handle, such as invalid
+	// input syntax, division by zero or an out of range number (class 22)
+	ErrorClassDataException ErrorClass = "data_exception"
+
+	// ErrorClassSyntaxOrAccess is a malformed statement, a missing object or a denied privilege (class 42)
+	ErrorClassSyntaxOrAccess ErrorClass = "syntax_or_access"
+
+	// ErrorClassAuthorization is a rejected login (class 28)
+	ErrorClassAuthorization ErrorClass = "authorization"
+
+	// ErrorClassSerializationFailure is a transaction that could not be serialized (40001)
+	ErrorClassSerializationFailure ErrorClass = "serialization_failure"
+
+	// ErrorClassDeadlock is a statement aborted to break a deadlock (40P01)
+	ErrorClassDeadlock ErrorClass = "deadlock"
+
+	// ErrorClassTransactionRollback is any other transaction the server rolled back (class 40)
+	ErrorClassTransactionRollback ErrorClass = "transaction_rollback"
+
+	// ErrorClassCanceled is a statement cancelled by statement_timeout, by request or
+	// by its context (57014)
+	ErrorClassCanceled ErrorClass = "canceled"
+
+	// ErrorClassConnection is a lost or refused connection, including server shutdown
+	// (class 08, 57P01-57P03)
+	ErrorClassConnection ErrorClass = "connection"
+
+	// ErrorClassInsufficientResources is a server out of connections, memory or disk (class 53)
+	ErrorClassInsufficientResources ErrorClass = "insufficient_resources"
+)
+
+// sqlStateClasses maps SQLSTATE classes (the first two characters) to an ErrorClass
+var sqlStateClasses = map[string]ErrorClass{
+	"08": ErrorClassConnection,
+	"22": ErrorClassDataException,
+	"23": ErrorClassIntegrityViolation,
+	"28": ErrorClassAuthorization,
+	"40": ErrorClassTransactionRollback,
+	"42": ErrorClassSyntaxOrAccess,
+	"53": ErrorClassInsufficientResources,
+}
+
+// sqlStateCodes maps SQLSTATE codes classified more precisely than their class
+var sqlStateCodes = map[string]ErrorClass{
+	sqlStateSerializationFailure: ErrorClassSerializationFailure,
+	sqlStateDeadlockDetected:     ErrorClassDeadlock,
+	sqlStateQueryCanceled:        ErrorClassCanceled,
+	"57P01":                      ErrorClassConnection, // admin_shutdown
+	"57P02":                      ErrorClassConnection, // crash_shutdown
+	"57P03":                      ErrorClassConnection, // cannot_connect_now
+}
+
+// nonRetryableErrorClasses fail the same way on every attempt
+var nonRetryableErrorClasses = map[ErrorClass]bool{
+	ErrorClassIntegrityViolation: true,
+	ErrorClassDataException:      true,
+	ErrorClassSyntaxOrAccess:     true,
+	ErrorClassAuthorization:      true,
+}
+
+// ClassifyError classifies err by the SQLSTATE of the lib/pq or pgx error it wraps
+// Errors without a SQLSTATE are classified when they are one of GORM's translated
+// constraint errors, a cancelled context, a bad connection, or the network error or
+// EOF lib/pq returns for a refused, reset or dropped connection; anything else is
+// ErrorClassUnknown. Messages are never inspected.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassUnknown
+	}
+
+	if code := sqlState(err); code != "" {
+		if class, ok := sqlStateCodes[code]; ok {
+			return class
+		}
+		if class, ok := sqlStateClasses[code[:min(len(code), 2)]]; ok {
+			return class
+		}
+		return ErrorClassUnknown
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.Is(err, gorm.ErrForeignKeyViolated), errors.Is(err, gorm.ErrCheckConstraintViolated):
+		return ErrorClassIntegrityViolation
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassCanceled
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorClassConnection
+	}
+	// Checked after the context errors: context.DeadlineExceeded is a net.Error too
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorClassConnection
+	}
+	return ErrorClassUnknown
+}
+
+// errorCounts counts failed statements per ErrorClass
+type errorCounts struct {
+	mu      sync.Mutex
+	byClass map[ErrorClass]int64
+}
+
+// record counts one failed statement by the class of its error
+func (c *errorCounts) record(err error) {
+	class := ClassifyError(err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.byClass == nil {
+		c.byClass = make(map[ErrorClass]int64)
+	}
+	c.byClass[class]++
+}
+
+// StatementErrorsByClass returns how many statements failed with errors of each
+// ErrorClass; missing rows are not counted as failures
+func (db *ProductionDatabase) StatementErrorsByClass() map[ErrorClass]int64 {
+	db.statementErrors.mu.Lock()
+	defer db.statementErrors.mu.Unlock()
+
+	counts := make(map[ErrorClass]int64, len(db.statementErrors.byClass))
+	for class, count := range db.statementErrors.byClass {
+		counts[class] = count
+	}
+	return counts
+}
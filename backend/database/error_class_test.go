@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestClassifyError_BySQLSTATE(t *testing.T) {
+	cases := map[string]ErrorClass{
+		"23505": ErrorClassIntegrityViolation,
+		"23503": ErrorClassIntegrityViolation,
+		"22P02": ErrorClassDataException,
+		"42P01": ErrorClassSyntaxOrAccess,
+		"28P01": ErrorClassAuthorization,
+		"40001": ErrorClassSerializationFailure,
+		"40P01": ErrorClassDeadlock,
+		"40003": ErrorClassTransactionRollback,
+		"57014": ErrorClassCanceled,
+		"57P01": ErrorClassConnection,
+		"08006": ErrorClassConnection,
+		"53300": ErrorClassInsufficientResources,
+		"XX000": ErrorClassUnknown,
+	}
+	for code, class := range cases {
+		// Messages are localized, so they must not matter
+		assert.Equal(t, class, ClassifyError(&pq.Error{Code: pq.ErrorCode(code), Message: "nicht eindeutig"}), "lib/pq %s", code)
+		assert.Equal(t, class, ClassifyError(fmt.Errorf("query: %w", &pgconn.PgError{Code: code})), "pgx %s", code)
+	}
+}
+
+func TestClassifyError_WithoutSQLSTATE(t *testing.T) {
+	assert.Equal(t, ErrorClassUnknown, ClassifyError(nil))
+	assert.Equal(t, ErrorClassUnknown, ClassifyError(errors.New("duplicate key value violates unique constraint")))
+	assert.Equal(t, ErrorClassIntegrityViolation, ClassifyError(gorm.ErrDuplicatedKey))
+	assert.Equal(t, ErrorClassCanceled, ClassifyError(context.DeadlineExceeded))
+	assert.Equal(t, ErrorClassConnection, ClassifyError(driver.ErrBadConn))
+}
+
+func TestClassifyError_NetworkErrors(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	assert.Equal(t, ErrorClassConnection, ClassifyError(refused))
+	assert.Equal(t, ErrorClassConnection, ClassifyError(fmt.Errorf("failed to connect: %w", refused)))
+	assert.Equal(t, ErrorClassConnection, ClassifyError(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}))
+	assert.Equal(t, ErrorClassConnection, ClassifyError(&net.DNSError{Err: "no such host", Name: "db.internal"}))
+	assert.Equal(t, ErrorClassConnection, ClassifyError(io.EOF))
+	assert.Equal(t, ErrorClassConnection, ClassifyError(fmt.Errorf("query: %w", io.ErrUnexpectedEOF)))
+
+	// A context deadline is a net.Error as well, but stays a cancellation
+	assert.Equal(t, ErrorClassCanceled, ClassifyError(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+}
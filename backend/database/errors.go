@@ -0,0 +1,93 @@
+package database
+
+import "errors"
+
+var (
+	// ErrReplicaTooLagged is returned when no replica is within the requested lag and primary fallback is disabled
+	ErrReplicaTooLagged = errors.New("database: replica lag exceeds the allowed maximum")
+
+	// ErrReplicaUnavailable is returned when no replica can serve a read and primary fallback is disabled
+	ErrReplicaUnavailable = errors.New("database: no read replica available")
+
+	// ErrReplicaBudgetExceeded is returned when the replica read budget is exhausted under RejectOverBudget
+	ErrReplicaBudgetExceeded = errors.New("database: replica read budget exceeded")
+
+	// ErrMigrationLockTimeout is returned when the migration advisory lock could not be acquired in time
+	ErrMigrationLockTimeout = errors.New("database: timed out waiting for migration lock")
+
+	// ErrCircuitOpen is returned without touching the database while a circuit breaker is open
+	ErrCircuitOpen = errors.New("database: circuit breaker is open")
+
+	// ErrResultTooLarge is returned by the scanning helpers once a result exceeds MaxResultBytes
+	ErrResultTooLarge = errors.New("database: result exceeds the maximum size")
+
+	// ErrStatStatementsUnavailable is returned by TopStatements when pg_stat_statements is not installed
+	ErrStatStatementsUnavailable = errors.New("database: pg_stat_statements extension is not installed")
+
+	// ErrNotIndexOnlyScan is returned by AssertIndexOnlyScan when the plan reads the table heap
+	ErrNotIndexOnlyScan = errors.New("database: query plan is not an index-only scan")
+
+	// ErrNonTransactionalDDL is returned for statements Postgres refuses to run inside a transaction
+	ErrNonTransactionalDDL = errors.New("database: statement cannot run inside a transaction, use ExecDDLNonTransactional")
+
+	// ErrUnknownMigration is returned for a migration version that is not in Migrations
+	ErrUnknownMigration = errors.New("database: unknown migration version")
+
+	// ErrUnknownQuery is returned by Named and NamedExec for names not in Queries
+	ErrUnknownQuery = errors.New("database: unknown named query")
+
+	// ErrIrreversibleMigration is returned when rolling back a migration without Down SQL
+	ErrIrreversibleMigration = errors.New("database: migration cannot be reverted")
+
+	// ErrNotFound matches statements that found no row (see TranslateError)
+	ErrNotFound = errors.New("database: record not found")
+
+	// ErrDuplicateKey matches unique constraint violations (SQLSTATE 23505)
+	ErrDuplicateKey = errors.New("database: duplicate key")
+
+	// ErrForeignKeyViolation matches foreign key constraint violations (SQLSTATE 23503)
+	ErrForeignKeyViolation = errors.New("database: foreign key violation")
+
+	// ErrSerializationFailure matches transactions that could not be serialized (SQLSTATE 40001)
+	ErrSerializationFailure = errors.New("database: serialization failure")
+
+	// ErrConnectionLost matches lost or refused connections (see ErrorClassConnection)
+	ErrConnectionLost = errors.New("database: connection lost")
+
+	// ErrStaleObject is returned by updates of an OptimisticLock model whose row
+	// another writer changed or deleted since it was read
+	ErrStaleObject = errors.New("database: stale object, the row was modified concurrently")
+
+	// ErrLockOutsideTransaction is returned by locking reads run outside a
+	// transaction, whose locks would be released as soon as the statement returned
+	ErrLockOutsideTransaction = errors.New("database: locking read outside a transaction")
+
+	// ErrInvalidSeeders is returned by Seed for unnamed, duplicate or cyclic seeders
+	ErrInvalidSeeders = errors.New("database: invalid seeders")
+
+	// ErrInvalidCursor is returned by PaginateKeyset for cursors it did not issue
+	ErrInvalidCursor = errors.New("database: invalid pagination cursor")
+
+	// ErrPageTooDeep is returned by PaginateOffset for pages beyond MaxPaginationOffset
+	ErrPageTooDeep = errors.New("database: page too deep, use keyset pagination")
+
+	// ErrInvalidQueryParameter is returned by ParseListQuery for parameters the
+	// ListQuerySpec does not allow; handlers should answer 400 Bad Request
+	ErrInvalidQueryParameter = errors.New("database: invalid query parameter")
+
+	// ErrChangeCaptureUnsupported is returned by CaptureChanges on databases other than Postgres
+	ErrChangeCaptureUnsupported = errors.New("database: change data capture requires Postgres")
+
+	// ErrAuditLogAppendOnly is returned by updates and deletes of audit log entries
+	ErrAuditLogAppendOnly = errors.New("database: the audit log is append-only")
+
+	// ErrNoHistory is returned by AsOf queries of models that are not in HistoryModels
+	ErrNoHistory = errors.New("database: model has no history table")
+
+	// ErrEncryptionKeyUnavailable is returned for encrypted fields without an
+	// EncryptionKeyring, or sealed with a key the keyring does not hold
+	ErrEncryptionKeyUnavailable = errors.New("database: encryption key unavailable")
+
+	// ErrDecryptionFailed is returned for encrypted values that were tampered with or corrupted
+	ErrDecryptionFailed = errors.New("database: decryption failed")
+)
@@ -0,0 +1,66 @@
+package database
+
+import "time"
+
+// EventType identifies a notable database event
+type EventType string
+
+const (
+	// EventReplicaRecoveryConflict is emitted when a replica read conflicted with recovery and was retried on the primary
+	EventReplicaRecoveryConflict EventType = "replica_recovery_conflict"
+
+	// EventIsolationDowngraded is emitted when SerializableTransaction falls back to REPEATABLE READ
+	EventIsolationDowngraded EventType = "isolation_downgraded"
+
+	// EventPrimaryConnectionFailure is emitted when a statement lost its connection to the primary
+	EventPrimaryConnectionFailure EventType = "primary_connection_failure"
+
+	// EventPrimaryWarming is emitted when a rebuilt primary pool starts running PrimaryWarmupQueries
+	EventPrimaryWarming EventType = "primary_warming"
+
+	// EventPrimaryWarmed is emitted once the warmup finished or gave up, just before the pool takes traffic
+	EventPrimaryWarmed EventType = "primary_warmed"
+
+	// EventRegionFailoverStarted is emitted when FailoverToRegion starts connecting to the new region
+	EventRegionFailoverStarted EventType = "region_failover_started"
+
+	// EventRegionFailoverCompleted is emitted once the new region's pools took over and the old ones drained
+	EventRegionFailoverCompleted EventType = "region_failover_completed"
+
+	// EventRegionFailoverFailed is emitted when the new region could not be reached; the old pools stay in use
+	EventRegionFailoverFailed EventType = "region_failover_failed"
+
+	// EventReplicaStale is emitted when the monitored replica lag exceeds MaxReplicaLag and reads move to the primary
+	EventReplicaStale EventType = "replica_stale"
+
+	// EventReplicaCaughtUp is emitted when the monitored replica lag is back within MaxReplicaLag
+	EventReplicaCaughtUp EventType = "replica_caught_up"
+
+	// EventLeadershipAcquired is emitted when a LeaderElector takes its advisory lock and starts leading
+	EventLeadershipAcquired EventType = "leadership_acquired"
+
+	// EventLeadershipLost is emitted when a LeaderElector stops leading, after its work has stopped
+	EventLeadershipLost EventType = "leadership_lost"
+)
+
+// Event describes something notable that happened inside the database layer
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Message string
+	Err     error
+}
+
+// emit delivers an event to the configured OnEvent callback
+func (db *ProductionDatabase) emit(eventType EventType, message string, err error) {
+	if db.config == nil || db.config.OnEvent == nil {
+		return
+	}
+
+	db.config.OnEvent(Event{
+		Type:    eventType,
+		Time:    time.Now(),
+		Message: message,
+		Err:     err,
+	})
+}
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// filterColumnName matches the column names a Filter interpolates, optionally table-qualified
+var filterColumnName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// filterOrder is one ORDER BY column of a Filter
+type filterOrder struct {
+	column string
+	desc   bool
+}
+
+// Filter builds the WHERE, ORDER BY and LIMIT of a search query from parameterized
+// conditions, so values never end up in the SQL text. Conditions are ANDed. Column
+// names must be plain identifiers; anything else makes the filter fail when
+// applied, so user input may pick a column but cannot inject SQL through it.
+// A Filter applies to GORM queries through Scope and to raw SQL through SQL.
+type Filter struct {
+	conditions []string
+	args       []interface{}
+	orders     []filterOrder
+	limit      int
+	err        error
+}
+
+// NewFilter returns a filter matching every row
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+// Where adds condition, with a ? placeholder for each of args
+func (f *Filter) Where(condition string, args ...interface{}) *Filter {
+	if placeholders := countPlaceholders(condition); placeholders != len(args) {
+		return f.fail(fmt.Errorf("filter condition %q has %d placeholders for %d values", condition, placeholders, len(args)))
+	}
+	f.conditions = append(f.conditions, "("+condition+")")
+	f.args = append(f.args, args...)
+	return f
+}
+
+// In adds column IN (values), for a slice of values; an empty slice matches no row
+func (f *Filter) In(column string, values interface{}) *Filter {
+	if !f.validColumn(column) {
+		return f
+	}
+	slice := reflect.ValueOf(values)
+	if slice.Kind() != reflect.Slice && slice.Kind() != reflect.Array {
+		return f.fail(fmt.Errorf("filter In on %s needs a slice, got %T", column, values))
+	}
+	if slice.Len() == 0 {
+		f.conditions = append(f.conditions, "(1 = 0)")
+		return f
+	}
+
+	placeholders := make([]string, slice.Len())
+	for i := range placeholders {
+		placeholders[i] = "?"
+		f.args = append(f.args, slice.Index(i).Interface())
+	}
+	f.conditions = append(f.conditions, fmt.Sprintf("(%s IN (%s))", column, strings.Join(placeholders, ", ")))
+	return f
+}
+
+// Between adds column BETWEEN low AND high, both inclusive
+func (f *Filter) Between(column string, low, high interface{}) *Filter {
+	if !f.validColumn(column) {
+		return f
+	}
+	f.conditions = append(f.conditions, fmt.Sprintf("(%s BETWEEN ? AND ?)", column))
+	f.args = append(f.args, low, high)
+	return f
+}
+
+// OrderBy orders the rows by column, after any columns ordered by before
+func (f *Filter) OrderBy(column string, desc bool) *Filter {
+	if f.validColumn(column) {
+		f.orders = append(f.orders, filterOrder{column: column, desc: desc})
+	}
+	return f
+}
+
+// Limit returns at most n rows; n <= 0 removes the limit
+func (f *Filter) Limit(n int) *Filter {
+	f.limit = n
+	return f
+}
+
+// Err returns the first invalid condition or column added, if any
+func (f *Filter) Err() error {
+	return f.err
+}
+
+// Scope applies the filter to a GORM query, failing it if the filter is invalid
+func (f *Filter) Scope() func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if f.err != nil {
+			tx.AddError(f.err)
+			return tx
+		}
+		if len(f.conditions) > 0 {
+			tx = tx.Where(strings.Join(f.conditions, " AND "), f.args...)
+		}
+		for _, order := range f.orders {
+			tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: order.column, Raw: true}, Desc: order.desc})
+		}
+		if f.limit > 0 {
+			tx = tx.Limit(f.limit)
+		}
+		return tx
+	}
+}
+
+// SQL appends the filter's WHERE, ORDER BY and LIMIT to base, a query without
+// them or placeholders, returning the query with $1, $2, ... placeholders, as
+// lib/pq expects, and its arguments
+func (f *Filter) SQL(base string) (string, []interface{}, error) {
+	if f.err != nil {
+		return "", nil, f.err
+	}
+
+	var query strings.Builder
+	query.WriteString(base)
+	if len(f.conditions) > 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(numberPlaceholders(strings.Join(f.conditions, " AND ")))
+	}
+	for i, order := range f.orders {
+		if i == 0 {
+			query.WriteString(" ORDER BY ")
+		} else {
+			query.WriteString(", ")
+		}
+		query.WriteString(order.column)
+		if order.desc {
+			query.WriteString(" DESC")
+		}
+	}
+	if f.limit > 0 {
+		query.WriteString(" LIMIT ")
+		query.WriteString(strconv.Itoa(f.limit))
+	}
+
+	args := make([]interface{}, len(f.args))
+	copy(args, f.args)
+	return query.String(), args, nil
+}
+
+// QueryFilter runs base, filtered by filter, returning its rows
+func (d *Database) QueryFilter(base string, filter *Filter) (*sql.Rows, error) {
+	query, args, err := filter.SQL(base)
+	if err != nil {
+		return nil, err
+	}
+	return d.Query(query, args...)
+}
+
+// validColumn reports whether column may be interpolated, failing the filter if not
+func (f *Filter) validColumn(column string) bool {
+	if !filterColumnName.MatchString(column) {
+		f.fail(fmt.Errorf("invalid filter column %q", column))
+		return false
+	}
+	return true
+}
+
+// fail records err unless the filter already failed
+func (f *Filter) fail(err error) *Filter {
+	if f.err == nil {
+		f.err = err
+	}
+	return f
+}
+
+// countPlaceholders counts the ? placeholders of sql outside quoted literals
+func countPlaceholders(sql string) int {
+	count := 0
+	forEachPlaceholder(sql, func(int) { count++ })
+	return count
+}
+
+// numberPlaceholders rewrites the ? placeholders of sql outside quoted literals to $1, $2, ...
+func numberPlaceholders(sql string) string {
+	var numbered strings.Builder
+	last, n := 0, 0
+	forEachPlaceholder(sql, func(i int) {
+		n++
+		numbered.WriteString(sql[last:i])
+		numbered.WriteString("$" + strconv.Itoa(n))
+		last = i + 1
+	})
+	numbered.WriteString(sql[last:])
+	return numbered.String()
+}
+
+// forEachPlaceholder calls fn with the index of each ? outside quoted literals
+func forEachPlaceholder(sql string, fn func(int)) {
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			fn(i)
+		}
+	}
+}
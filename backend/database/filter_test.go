@@ -0,0 +1,85 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type filterFood struct {
+	ID       int64
+	Name     string
+	Category string
+	Calories int
+}
+
+func searchFilter() *Filter {
+	return NewFilter().
+		Where("name <> ?", "water").
+		In("category", []string{"fruit", "grain"}).
+		Between("calories", 50, 400).
+		OrderBy("calories", true).
+		OrderBy("id", false).
+		Limit(2)
+}
+
+func TestFilter_SQL(t *testing.T) {
+	query, args, err := searchFilter().SQL("SELECT id FROM foods")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM foods WHERE (name <> $1) AND (category IN ($2, $3)) AND (calories BETWEEN $4 AND $5) ORDER BY calories DESC, id LIMIT 2", query)
+	assert.Equal(t, []interface{}{"water", "fruit", "grain", 50, 400}, args)
+
+	// Question marks in literals are not placeholders
+	query, args, err = NewFilter().Where("note <> '?' AND name = ?", "tea").SQL("SELECT id FROM foods")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM foods WHERE (note <> '?' AND name = $1)", query)
+	assert.Equal(t, []interface{}{"tea"}, args)
+
+	query, _, err = NewFilter().In("category", []string{}).SQL("SELECT id FROM foods")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM foods WHERE (1 = 0)", query)
+}
+
+func TestFilter_RejectsInvalidInput(t *testing.T) {
+	_, _, err := NewFilter().OrderBy("calories; DROP TABLE foods", false).SQL("SELECT id FROM foods")
+	assert.ErrorContains(t, err, "invalid filter column")
+
+	assert.Error(t, NewFilter().Where("name = ? AND category = ?", "tea").Err())
+	assert.Error(t, NewFilter().In("category", "fruit").Err())
+}
+
+func TestFilter_ComposesWithGormAndDatabase(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&filterFood{}))
+	require.NoError(t, db.GetDB().Create([]filterFood{
+		{Name: "apple", Category: "fruit", Calories: 52},
+		{Name: "oats", Category: "grain", Calories: 389},
+		{Name: "rice", Category: "grain", Calories: 130},
+		{Name: "steak", Category: "meat", Calories: 271},
+		{Name: "water", Category: "fruit", Calories: 60},
+	}).Error)
+
+	var foods []filterFood
+	require.NoError(t, db.GetDB().Scopes(searchFilter().Scope()).Find(&foods).Error)
+	require.Len(t, foods, 2)
+	assert.Equal(t, "oats", foods[0].Name)
+	assert.Equal(t, "rice", foods[1].Name)
+
+	assert.Error(t, db.GetDB().Scopes(NewFilter().OrderBy("1; --", false).Scope()).Find(&foods).Error)
+
+	sqlDB, err := db.GetDB().DB()
+	require.NoError(t, err)
+	rows, err := NewDatabase(sqlDB).QueryFilter("SELECT name FROM filter_foods", searchFilter())
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"oats", "rice"}, names)
+}
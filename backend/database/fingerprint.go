@@ -0,0 +1,26 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	fingerprintString      = regexp.MustCompile(`'(?:[^']|'')*'`)
+	fingerprintNumber      = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	fingerprintPlaceholder = regexp.MustCompile(`\$\d+`)
+	fingerprintList        = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	fingerprintSpace       = regexp.MustCompile(`\s+`)
+)
+
+// fingerprintSQL normalises a statement so executions differing only in their
+// values share one fingerprint: literals and placeholders become ?, IN lists
+// collapse to a single ? and whitespace is squeezed
+func fingerprintSQL(sql string) string {
+	fingerprint := fingerprintString.ReplaceAllString(sql, "?")
+	fingerprint = fingerprintPlaceholder.ReplaceAllString(fingerprint, "?")
+	fingerprint = fingerprintNumber.ReplaceAllString(fingerprint, "?")
+	fingerprint = fingerprintList.ReplaceAllString(fingerprint, "(?)")
+	fingerprint = fingerprintSpace.ReplaceAllString(fingerprint, " ")
+	return strings.TrimSpace(fingerprint)
+}
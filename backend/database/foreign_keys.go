@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// FKInfo describes a foreign key whose referencing columns have no covering index
+type FKInfo struct {
+	Table           string
+	Constraint      string
+	Columns         []string
+	ReferencedTable string
+}
+
+// unindexedForeignKeysSQL lists foreign keys for which no valid, non-partial index
+// has the referencing columns as its leading columns (in any order)
+const unindexedForeignKeysSQL = `
+SELECT c.conrelid::regclass::text,
+       c.conname,
+       ARRAY(SELECT a.attname
+             FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, position)
+             JOIN pg_catalog.pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+             ORDER BY k.position)::text[],
+       c.confrelid::regclass::text
+FROM pg_catalog.pg_constraint c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.connamespace
+WHERE c.contype = 'f'
+  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+  AND NOT EXISTS (
+    SELECT 1
+    FROM pg_catalog.pg_index i
+    WHERE i.indrelid = c.conrelid
+      AND i.indisvalid
+      AND i.indpred IS NULL
+      AND (i.indkey::int2[])[0:array_length(c.conkey, 1) - 1] @> c.conkey
+  )
+ORDER BY 1, 2`
+
+// UnindexedForeignKeys returns the foreign keys on the primary lacking an index on
+// their referencing columns, which make cascades and referential checks slow
+func (db *ProductionDatabase) UnindexedForeignKeys(ctx context.Context) ([]FKInfo, error) {
+	rows, err := db.primary().WithContext(ctx).Raw(unindexedForeignKeysSQL).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []FKInfo
+	for rows.Next() {
+		var fk FKInfo
+		if err := rows.Scan(&fk.Table, &fk.Constraint, pq.Array(&fk.Columns), &fk.ReferencedTable); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key: %w", err)
+		}
+		keys = append(keys, fk)
+	}
+
+	return keys, rows.Err()
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnindexedForeignKeys_ReportsOnlyMissingIndexes(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+
+	for _, statement := range []string{
+		"DROP TABLE IF EXISTS fk_test_indexed, fk_test_unindexed, fk_test_parent",
+		"CREATE TABLE fk_test_parent (id int PRIMARY KEY)",
+		"CREATE TABLE fk_test_unindexed (id int PRIMARY KEY, parent_id int REFERENCES fk_test_parent (id))",
+		"CREATE TABLE fk_test_indexed (id int PRIMARY KEY, parent_id int REFERENCES fk_test_parent (id))",
+		"CREATE INDEX fk_test_indexed_parent_id ON fk_test_indexed (parent_id)",
+	} {
+		require.NoError(t, db.GetDB().Exec(statement).Error)
+	}
+	t.Cleanup(func() { db.GetDB().Exec("DROP TABLE IF EXISTS fk_test_indexed, fk_test_unindexed, fk_test_parent") })
+
+	keys, err := db.UnindexedForeignKeys(context.Background())
+	require.NoError(t, err)
+
+	byTable := make(map[string]FKInfo)
+	for _, fk := range keys {
+		byTable[fk.Table] = fk
+	}
+
+	require.Contains(t, byTable, "fk_test_unindexed")
+	assert.Equal(t, []string{"parent_id"}, byTable["fk_test_unindexed"].Columns)
+	assert.Equal(t, "fk_test_parent", byTable["fk_test_unindexed"].ReferencedTable)
+	assert.NotContains(t, byTable, "fk_test_indexed")
+}
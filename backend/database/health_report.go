@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// NodeHealth is the health of a single connection pool at check time
+type NodeHealth struct {
+	Role              string                 `json:"role"`
+	Healthy           bool                   `json:"healthy"`
+	Error             string                 `json:"error,omitempty"`
+	PingLatencyMS     float64                `json:"ping_latency_ms"`
+	Pool              map[string]interface{} `json:"pool"`
+	ReplicaLagSeconds *float64               `json:"replica_lag_seconds,omitempty"`
+	ReplicaLagError   string                 `json:"replica_lag_error,omitempty"`
+}
+
+// HealthReport is the full result of a health check across all nodes
+type HealthReport struct {
+	Time  time.Time    `json:"time"`
+	Nodes []NodeHealth `json:"nodes"`
+}
+
+// CheckHealth pings every connection pool and collects pool statistics and replica lag
+func (db *ProductionDatabase) CheckHealth(ctx context.Context) HealthReport {
+	report := HealthReport{Time: time.Now()}
+
+	if sqlDB, err := db.primary().DB(); err == nil {
+		report.Nodes = append(report.Nodes, checkNode(ctx, "primary", sqlDB))
+	} else {
+		report.Nodes = append(report.Nodes, NodeHealth{Role: "primary", Error: err.Error()})
+	}
+
+	if replicaDB := db.replica(); replicaDB != nil {
+		node := NodeHealth{Role: "replica"}
+		if sqlDB, err := replicaDB.DB(); err == nil {
+			node = checkNode(ctx, "replica", sqlDB)
+		} else {
+			node.Error = err.Error()
+		}
+
+		if node.Healthy {
+			if lag, err := db.ReplicaLag(ctx); err == nil {
+				seconds := lag.Seconds()
+				node.ReplicaLagSeconds = &seconds
+			} else {
+				node.ReplicaLagError = err.Error()
+			}
+		}
+		report.Nodes = append(report.Nodes, node)
+	}
+
+	return report
+}
+
+// checkNode pings a pool and records its latency and statistics
+func checkNode(ctx context.Context, role string, sqlDB *sql.DB) NodeHealth {
+	node := NodeHealth{Role: role}
+
+	start := time.Now()
+	err := sqlDB.PingContext(ctx)
+	node.PingLatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		node.Error = err.Error()
+	} else {
+		node.Healthy = true
+	}
+
+	node.Pool = poolStatsMap(sqlDB.Stats())
+	return node
+}
+
+// logHealthReport logs the tick's health report as a single record
+func (hc *HealthChecker) logHealthReport() {
+	if !hc.db.config.LogHealthEveryTick {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
+	defer cancel()
+
+	report := hc.db.CheckHealth(ctx)
+	hc.db.logger().Info("Database health report", "time", report.Time, "nodes", report.Nodes)
+}
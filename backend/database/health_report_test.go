@@ -0,0 +1,78 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writers and readers
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogHealthEveryTick_EmitsReportPerNode(t *testing.T) {
+	var output syncBuffer
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.HealthCheckInterval = 10 * time.Millisecond
+	config.LogHealthEveryTick = true
+	config.Logger = slog.New(slog.NewJSONHandler(&output, nil))
+	newSQLiteTestDatabase(t, config)
+
+	var record map[string]interface{}
+	require.Eventually(t, func() bool {
+		record = recordWithMessage(jsonRecords(t, output.String()), "Database health report")
+		return record != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// The nodes attribute decodes like the report's own JSON
+	encoded, err := json.Marshal(record["nodes"])
+	require.NoError(t, err)
+	var nodes []NodeHealth
+	require.NoError(t, json.Unmarshal(encoded, &nodes))
+	require.Len(t, nodes, 2)
+
+	roles := map[string]NodeHealth{}
+	for _, node := range nodes {
+		roles[node.Role] = node
+	}
+	for _, role := range []string{"primary", "replica"} {
+		node, ok := roles[role]
+		require.True(t, ok, "missing %s node", role)
+		assert.True(t, node.Healthy)
+		assert.Contains(t, node.Pool, "open_connections")
+	}
+}
+
+func TestLogHealthEveryTick_DisabledByDefault(t *testing.T) {
+	var output syncBuffer
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.HealthCheckInterval = 10 * time.Millisecond
+	config.Logger = slog.New(slog.NewJSONHandler(&output, nil))
+	newSQLiteTestDatabase(t, config)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, recordWithMessage(jsonRecords(t, output.String()), "Database health report"))
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newPostgresTestConfig returns a configuration for TEST_DATABASE_URL, skipping the test when it is unset
+func newPostgresTestConfig(t *testing.T) *ProductionConfig {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping Postgres test")
+	}
+
+	config := DefaultProductionConfig()
+	config.DatabaseURL = url
+	return config
+}
+
+// newPostgresTestDatabase connects to TEST_DATABASE_URL, skipping the test when it is unset
+func newPostgresTestDatabase(t *testing.T) *ProductionDatabase {
+	t.Helper()
+
+	db, err := NewProductionDatabase(newPostgresTestConfig(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// sqliteConnector opens SQLite connections for a DSN
+type sqliteConnector struct {
+	dsn string
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return (&sqlite3.SQLiteDriver{}).Open(c.dsn)
+}
+
+func (c sqliteConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// sqliteTestDSN returns a shared-cache in-memory DSN private to the test and name
+func sqliteTestDSN(t *testing.T, name string) string {
+	return fmt.Sprintf("file:%s_%s?mode=memory&cache=shared", nonIdentifierChars.ReplaceAllString(t.Name(), "_"), name)
+}
+
+// newSQLiteTestConfig returns a configuration whose pools connect to in-memory SQLite
+func newSQLiteTestConfig(t *testing.T, name string) *ProductionConfig {
+	config := DefaultProductionConfig()
+	config.DatabaseURL = sqliteTestDSN(t, name)
+	config.LogLevel = logger.Silent
+	config.Connector = func(dsn string) (driver.Connector, error) {
+		return sqliteConnector{dsn: dsn}, nil
+	}
+	config.Dialector = func(conn *sql.DB) gorm.Dialector {
+		return sqlite.New(sqlite.Config{Conn: conn})
+	}
+	return config
+}
+
+// newSQLiteTestDatabase opens a ProductionDatabase for the configuration and closes it with the test
+func newSQLiteTestDatabase(t *testing.T, config *ProductionConfig) *ProductionDatabase {
+	t.Helper()
+
+	db, err := NewProductionDatabase(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// stubConnector is an in-memory driver whose connections record every statement
+type stubConnector struct {
+	mu         sync.Mutex
+	statements []string
+	connects   []time.Time
+	conns      []*stubConn
+}
+
+func (c *stubConnector) Connect(context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conn := &stubConn{connector: c}
+	c.connects = append(c.connects, time.Now())
+	c.conns = append(c.conns, conn)
+	return conn, nil
+}
+
+func (c *stubConnector) Driver() driver.Driver { return c }
+
+func (c *stubConnector) Open(string) (driver.Conn, error) { return c.Connect(context.Background()) }
+
+func (c *stubConnector) record(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statements = append(c.statements, query)
+}
+
+// Statements returns every statement executed across all connections
+func (c *stubConnector) Statements() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.statements...)
+}
+
+// Connects returns the time each physical connection was opened
+func (c *stubConnector) Connects() []time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Time(nil), c.connects...)
+}
+
+// Conns returns every connection opened so far
+func (c *stubConnector) Conns() []*stubConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*stubConn(nil), c.conns...)
+}
+
+type stubConn struct {
+	connector *stubConnector
+
+	mu     sync.Mutex
+	dead   bool
+	closed bool
+}
+
+// Kill makes every later ping on the connection fail as a broken connection
+func (c *stubConn) Kill() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dead = true
+}
+
+func (c *stubConn) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *stubConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("stub: prepare not supported")
+}
+
+func (c *stubConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *stubConn) Begin() (driver.Tx, error) { return stubTx{}, nil }
+
+func (c *stubConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.connector.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *stubConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.connector.record(query)
+	return &stubRows{}, nil
+}
+
+func (c *stubConn) Ping(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dead {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+type stubRows struct{}
+
+func (*stubRows) Columns() []string              { return []string{"result"} }
+func (*stubRows) Close() error                   { return nil }
+func (*stubRows) Next(dest []driver.Value) error { return io.EOF }
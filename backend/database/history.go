@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// historyTableSuffix names the history table of a HistoryModels table
+	historyTableSuffix = "_history"
+
+	// historyInstanceKey carries the rows an update or delete is about to change to its after callback
+	historyInstanceKey = "database:history_before"
+
+	// asOfSettingKey carries the time AsOf queries read the history at
+	asOfSettingKey = "database:as_of"
+)
+
+// AsOf returns the primary database bound to ctx, reading the rows of
+// HistoryModels as they were at at, from their history tables:
+//
+//	db.AsOf(ctx, lastTuesday).Where("user_id = ?", userID).Find(&plans)
+//
+// Queries of other models fail with ErrNoHistory.
+func (db *ProductionDatabase) AsOf(ctx context.Context, at time.Time) *gorm.DB {
+	return db.primary().WithContext(ctx).Set(asOfSettingKey, at.UTC())
+}
+
+// MigrateHistory creates the <table>_history table of every HistoryModels table,
+// with the table's columns and the valid_from and valid_to range each version of
+// a row was current in, and adds the columns the table gained since. Run it after
+// migrating the tables themselves.
+func (db *ProductionDatabase) MigrateHistory(ctx context.Context) error {
+	conn := db.primary().WithContext(ctx)
+	timestampType := "TIMESTAMP"
+	if conn.Dialector.Name() == "postgres" {
+		timestampType = "TIMESTAMPTZ"
+	}
+
+	for _, model := range db.config.HistoryModels {
+		stmt := &gorm.Statement{DB: conn}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse history model %T: %w", model, err)
+		}
+		table, history := stmt.Table, stmt.Table+historyTableSuffix
+
+		if !conn.Migrator().HasTable(history) {
+			statements := []string{
+				fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s WHERE 1 = 0", stmt.Quote(history), stmt.Quote(table)),
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN valid_from %s", stmt.Quote(history), timestampType),
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN valid_to %s", stmt.Quote(history), timestampType),
+			}
+			if len(stmt.Schema.PrimaryFieldDBNames) > 0 {
+				columns := make([]string, 0, len(stmt.Schema.PrimaryFieldDBNames)+1)
+				for _, name := range append(stmt.Schema.PrimaryFieldDBNames, "valid_from") {
+					columns = append(columns, stmt.Quote(name))
+				}
+				statements = append(statements, fmt.Sprintf("CREATE INDEX %s ON %s (%s)",
+					stmt.Quote(history+"_valid_idx"), stmt.Quote(history), strings.Join(columns, ", ")))
+			}
+			for _, statement := range statements {
+				if err := conn.Exec(statement).Error; err != nil {
+					return fmt.Errorf("failed to create history table %s: %w", history, err)
+				}
+			}
+			continue
+		}
+
+		columns, err := conn.Migrator().ColumnTypes(table)
+		if err != nil {
+			return fmt.Errorf("failed to read the columns of %s: %w", table, err)
+		}
+		for _, column := range columns {
+			if conn.Migrator().HasColumn(history, column.Name()) {
+				continue
+			}
+			statement := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", stmt.Quote(history), stmt.Quote(column.Name()), column.DatabaseTypeName())
+			if err := conn.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to add column %s to history table %s: %w", column.Name(), history, err)
+			}
+		}
+	}
+	return nil
+}
+
+// registerHistory installs the callbacks keeping the history tables of
+// HistoryModels current and serving AsOf queries from them
+func (db *ProductionDatabase) registerHistory(gormDB *gorm.DB) error {
+	historized := make(map[string]bool, len(db.config.HistoryModels))
+	for _, model := range db.config.HistoryModels {
+		stmt := &gorm.Statement{DB: gormDB}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse history model %T: %w", model, err)
+		}
+		historized[stmt.Table] = true
+	}
+	isHistorized := func(tx *gorm.DB) bool {
+		return tx.Error == nil && tx.Statement.Schema != nil && historized[tx.Statement.Table]
+	}
+
+	callbacks := gormDB.Callback()
+	registrations := []struct {
+		name     string
+		register func(string, func(*gorm.DB)) error
+		fn       func(*gorm.DB)
+	}{
+		{"history_as_of", callbacks.Query().Before("gorm:query").Register, func(tx *gorm.DB) {
+			if at, ok := tx.Get(asOfSettingKey); ok && tx.Error == nil {
+				if !historized[tx.Statement.Table] {
+					tx.AddError(fmt.Errorf("%w: %s", ErrNoHistory, tx.Statement.Table))
+					return
+				}
+				readHistoryAsOf(tx, at.(time.Time))
+			}
+		}},
+		{"history_create", callbacks.Create().After("gorm:create").Register, func(tx *gorm.DB) {
+			if isHistorized(tx) {
+				recordCreatedHistory(tx)
+			}
+		}},
+		{"history_update_before", callbacks.Update().Before("gorm:update").Register, func(tx *gorm.DB) {
+			if isHistorized(tx) {
+				if rows, ok := statementRows(tx); ok {
+					tx.InstanceSet(historyInstanceKey, rows)
+				}
+			}
+		}},
+		{"history_update", callbacks.Update().After("gorm:update").Register, func(tx *gorm.DB) {
+			recordChangedHistory(tx, true)
+		}},
+		{"history_delete_before", callbacks.Delete().Before("gorm:delete").Register, func(tx *gorm.DB) {
+			if isHistorized(tx) {
+				if rows, ok := statementRows(tx); ok {
+					tx.InstanceSet(historyInstanceKey, rows)
+				}
+			}
+		}},
+		{"history_delete", callbacks.Delete().After("gorm:delete").Register, func(tx *gorm.DB) {
+			recordChangedHistory(tx, false)
+		}},
+	}
+	for _, registration := range registrations {
+		if err := registration.register("database:"+registration.name, registration.fn); err != nil {
+			return fmt.Errorf("failed to register history callback: %w", err)
+		}
+	}
+	return nil
+}
+
+// readHistoryAsOf points a query at its model's history table, at the versions
+// current at at
+func readHistoryAsOf(tx *gorm.DB, at time.Time) {
+	stmt := tx.Statement
+	stmt.Table += historyTableSuffix
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Lte{Column: clause.Column{Table: clause.CurrentTable, Name: "valid_from"}, Value: at},
+		clause.Or(
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "valid_to"}, Value: nil},
+			clause.Gt{Column: clause.Column{Table: clause.CurrentTable, Name: "valid_to"}, Value: at},
+		),
+	}})
+}
+
+// recordCreatedHistory starts the history of the created models
+func recordCreatedHistory(tx *gorm.DB) {
+	stmt := tx.Statement
+	primaryFields := stmt.Schema.PrimaryFields
+	if len(primaryFields) == 0 {
+		return
+	}
+
+	var rows []map[string]interface{}
+	addRow := func(model reflect.Value) {
+		row := make(map[string]interface{}, len(primaryFields))
+		for _, field := range primaryFields {
+			row[field.DBName], _ = field.ValueOf(stmt.Context, model)
+		}
+		rows = append(rows, row)
+	}
+	switch value := stmt.ReflectValue; value.Kind() {
+	case reflect.Struct:
+		addRow(value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			addRow(reflect.Indirect(value.Index(i)))
+		}
+	}
+	if len(rows) > 0 {
+		insertHistoryVersions(tx, rows, time.Now().UTC())
+	}
+}
+
+// recordChangedHistory ends the current versions of the rows an update or delete
+// changed, and for updates starts their new versions
+func recordChangedHistory(tx *gorm.DB, updated bool) {
+	value, ok := tx.InstanceGet(historyInstanceKey)
+	if !ok || tx.Error != nil || tx.RowsAffected == 0 {
+		return
+	}
+	rows := value.([]map[string]interface{})
+	primaryFields := tx.Statement.Schema.PrimaryFields
+	if len(rows) == 0 || len(primaryFields) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	err := tx.Session(&gorm.Session{NewDB: true}).Table(tx.Statement.Table+historyTableSuffix).
+		Where(primaryKeyCondition(primaryFields, rows)).
+		Where("valid_to IS NULL").
+		Update("valid_to", now).Error
+	if err != nil {
+		tx.AddError(fmt.Errorf("failed to write history: %w", err))
+		return
+	}
+	if updated {
+		insertHistoryVersions(tx, rows, now)
+	}
+}
+
+// insertHistoryVersions copies the current state of the rows with the primary
+// keys of rows into the history table, as versions valid from validFrom
+func insertHistoryVersions(tx *gorm.DB, rows []map[string]interface{}, validFrom time.Time) {
+	stmt := tx.Statement
+
+	columns := make([]clause.Column, 0, len(stmt.Schema.DBNames)+1)
+	quoted := make([]string, 0, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		columns = append(columns, clause.Column{Name: name})
+		quoted = append(quoted, stmt.Quote(name))
+	}
+	columns = append(columns, clause.Column{Name: "valid_from"})
+
+	versions := modelRows(tx, primaryKeyCondition(stmt.Schema.PrimaryFields, rows)).Unscoped().
+		Select(strings.Join(quoted, ", ")+", ?", validFrom)
+	err := tx.Session(&gorm.Session{NewDB: true}).
+		Exec("INSERT INTO ? (?) ?", clause.Table{Name: stmt.Table + historyTableSuffix}, columns, versions).Error
+	if err != nil {
+		tx.AddError(fmt.Errorf("failed to write history: %w", err))
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historizedPlan is a model whose versions are kept in a history table
+type historizedPlan struct {
+	ID       uint `gorm:"primaryKey"`
+	UserID   uint
+	Name     string
+	Calories int
+}
+
+// planAsOf returns the user's plans as they were at at
+func planAsOf(t *testing.T, db *ProductionDatabase, at time.Time) []historizedPlan {
+	t.Helper()
+	var plans []historizedPlan
+	require.NoError(t, db.AsOf(context.Background(), at).Where("user_id = ?", 7).Find(&plans).Error)
+	return plans
+}
+
+// tick returns the current time between two pauses, so writes before and after
+// it get distinct timestamps
+func tick() time.Time {
+	time.Sleep(5 * time.Millisecond)
+	defer time.Sleep(5 * time.Millisecond)
+	return time.Now()
+}
+
+func TestAsOf_ReadsRowsAsTheyWere(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.HistoryModels = []interface{}{&historizedPlan{}}
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(&historizedPlan{}))
+	require.NoError(t, db.MigrateHistory(ctx))
+
+	beforeCreate := tick()
+	plan := historizedPlan{UserID: 7, Name: "cutting", Calories: 1800}
+	require.NoError(t, db.GetDB().Create(&plan).Error)
+	afterCreate := tick()
+	require.NoError(t, db.GetDB().Model(&plan).Update("calories", 2000).Error)
+	afterUpdate := tick()
+	require.NoError(t, db.GetDB().Delete(&plan).Error)
+	afterDelete := tick()
+
+	assert.Empty(t, planAsOf(t, db, beforeCreate))
+	assert.Equal(t, []historizedPlan{{ID: plan.ID, UserID: 7, Name: "cutting", Calories: 1800}}, planAsOf(t, db, afterCreate))
+	assert.Equal(t, []historizedPlan{{ID: plan.ID, UserID: 7, Name: "cutting", Calories: 2000}}, planAsOf(t, db, afterUpdate))
+	assert.Empty(t, planAsOf(t, db, afterDelete))
+}
+
+func TestAsOf_RejectsModelsWithoutHistory(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.Migrate(&historizedPlan{}))
+
+	var plans []historizedPlan
+	err := db.AsOf(context.Background(), time.Now()).Find(&plans).Error
+	assert.ErrorIs(t, err, ErrNoHistory)
+}
+
+func TestMigrateHistory_AddsNewColumns(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.HistoryModels = []interface{}{&historizedPlan{}}
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(&historizedPlan{}))
+	require.NoError(t, db.MigrateHistory(ctx))
+	assert.Equal(t, []string{"id", "user_id", "name", "calories", "valid_from", "valid_to"}, tableColumns(t, db, "historized_plans_history"))
+
+	require.NoError(t, db.GetDB().Exec("ALTER TABLE historized_plans ADD COLUMN notes TEXT").Error)
+	require.NoError(t, db.MigrateHistory(ctx))
+	assert.Contains(t, tableColumns(t, db, "historized_plans_history"), "notes")
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const indexOnlyScanNode = "Index Only Scan"
+
+// AssertIndexOnlyScan EXPLAINs the query built by fn without running it and returns
+// ErrNotIndexOnlyScan unless every table it reads is read by an index-only scan.
+// Meant for performance tests guarding hot queries against heap fetches creeping
+// in with schema changes. The plan depends on statistics, so ANALYZE beforehand.
+func (db *ProductionDatabase) AssertIndexOnlyScan(ctx context.Context, fn func(*gorm.DB) *gorm.DB) error {
+	plans, err := explainQuery(ctx, db.GetReadDB().WithContext(ctx), fn)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, plan := range plans {
+		for _, scan := range relationScans(plan) {
+			if scan.NodeType != indexOnlyScanNode {
+				return fmt.Errorf("%w: %s on %s", ErrNotIndexOnlyScan, scan.NodeType, scan.RelationName)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: the plan reads no table", ErrNotIndexOnlyScan)
+	}
+	return nil
+}
+
+// relationScans returns every node in a plan tree that reads a table
+func relationScans(node explainNode) []explainNode {
+	var scans []explainNode
+	if node.RelationName != "" {
+		scans = append(scans, node)
+	}
+	for _, child := range node.Plans {
+		scans = append(scans, relationScans(child)...)
+	}
+	return scans
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestAssertIndexOnlyScan(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("DROP TABLE IF EXISTS index_only_scan_test").Error)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE index_only_scan_test (id serial PRIMARY KEY, category int, note text)").Error)
+	t.Cleanup(func() { db.GetDB().Exec("DROP TABLE IF EXISTS index_only_scan_test") })
+
+	require.NoError(t, db.GetDB().Exec("INSERT INTO index_only_scan_test (category, note) SELECT g % 1000, 'row ' || g FROM generate_series(1, 50000) g").Error)
+	require.NoError(t, db.GetDB().Exec("CREATE INDEX index_only_scan_test_category ON index_only_scan_test (category)").Error)
+	// Index-only scans need an up to date visibility map
+	require.NoError(t, db.GetDB().Exec("VACUUM ANALYZE index_only_scan_test").Error)
+
+	// Only the indexed column is read
+	err := db.AssertIndexOnlyScan(ctx, func(tx *gorm.DB) *gorm.DB {
+		var categories []int
+		return tx.Table("index_only_scan_test").Select("category").Where("category = ?", 7).Find(&categories)
+	})
+	assert.NoError(t, err)
+
+	// note is not in the index, so matching rows are fetched from the heap
+	err = db.AssertIndexOnlyScan(ctx, func(tx *gorm.DB) *gorm.DB {
+		var notes []string
+		return tx.Table("index_only_scan_test").Select("note").Where("category = ?", 7).Find(&notes)
+	})
+	assert.ErrorIs(t, err, ErrNotIndexOnlyScan)
+}
+
+func TestRelationScans_FindsNestedScans(t *testing.T) {
+	plan := explainNode{NodeType: "Nested Loop", Plans: []explainNode{
+		{NodeType: "Index Only Scan", RelationName: "meals"},
+		{NodeType: "Bitmap Heap Scan", RelationName: "foods", Plans: []explainNode{
+			{NodeType: "Bitmap Index Scan"},
+		}},
+	}}
+
+	scans := relationScans(plan)
+	require.Len(t, scans, 2)
+	assert.Equal(t, "meals", scans[0].RelationName)
+	assert.Equal(t, "Bitmap Heap Scan", scans[1].NodeType)
+}
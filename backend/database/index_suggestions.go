@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Heuristic thresholds for SuggestIndexes: only sequential scans over tables of at
+// least indexSuggestionMinRows whose filter keeps at most indexSuggestionMaxSelectivity
+// of the rows are worth an index
+const (
+	indexSuggestionMinRows        = 10000
+	indexSuggestionMaxSelectivity = 0.1
+)
+
+// IndexSuggestion is a candidate index for a sequential scan found by SuggestIndexes
+type IndexSuggestion struct {
+	Table     string
+	Columns   []string
+	Filter    string
+	Reason    string
+	Statement string
+}
+
+// explainNode is the subset of an EXPLAIN (FORMAT JSON) plan node the plan checks inspect
+type explainNode struct {
+	NodeType     string        `json:"Node Type"`
+	RelationName string        `json:"Relation Name"`
+	Filter       string        `json:"Filter"`
+	PlanRows     float64       `json:"Plan Rows"`
+	Plans        []explainNode `json:"Plans"`
+}
+
+// SuggestIndexes captures the query built by fn without running it, EXPLAINs it and
+// suggests indexes for sequential scans on large tables with selective filters.
+// The suggestions are heuristic hints for review, not a substitute for measuring.
+func (db *ProductionDatabase) SuggestIndexes(ctx context.Context, fn func(*gorm.DB) *gorm.DB) ([]IndexSuggestion, error) {
+	readDB := db.GetReadDB().WithContext(ctx)
+
+	plans, err := explainQuery(ctx, readDB, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions []IndexSuggestion
+	for _, plan := range plans {
+		for _, scan := range filteredSeqScans(plan) {
+			suggestion, ok, err := db.suggestIndex(ctx, readDB, scan)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+// explainQuery captures the query built by fn on readDB without running it and
+// returns the root node of each of its EXPLAIN (FORMAT JSON) plans
+func explainQuery(ctx context.Context, readDB *gorm.DB, fn func(*gorm.DB) *gorm.DB) ([]explainNode, error) {
+	stmt := fn(readDB.Session(&gorm.Session{DryRun: true})).Statement
+	if stmt.Error != nil {
+		return nil, stmt.Error
+	}
+	query := stmt.SQL.String()
+	if query == "" {
+		return nil, fmt.Errorf("query builder produced no SQL")
+	}
+
+	sqlDB, err := readDB.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	var planJSON []byte
+	if err := sqlDB.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, stmt.Vars...).Scan(&planJSON); err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	var plans []struct {
+		Plan explainNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(planJSON, &plans); err != nil {
+		return nil, fmt.Errorf("failed to decode query plan: %w", err)
+	}
+
+	roots := make([]explainNode, 0, len(plans))
+	for _, plan := range plans {
+		roots = append(roots, plan.Plan)
+	}
+	return roots, nil
+}
+
+// filteredSeqScans returns every sequential scan with a filter in a plan tree
+func filteredSeqScans(node explainNode) []explainNode {
+	var scans []explainNode
+	if node.NodeType == "Seq Scan" && node.RelationName != "" && node.Filter != "" {
+		scans = append(scans, node)
+	}
+	for _, child := range node.Plans {
+		scans = append(scans, filteredSeqScans(child)...)
+	}
+	return scans
+}
+
+// suggestIndex turns a filtered sequential scan into a suggestion when the table is
+// large and the filter selective
+func (db *ProductionDatabase) suggestIndex(ctx context.Context, readDB *gorm.DB, scan explainNode) (IndexSuggestion, bool, error) {
+	var tableRows float64
+	if err := readDB.Raw("SELECT reltuples FROM pg_class WHERE oid = ?::regclass", scan.RelationName).Scan(&tableRows).Error; err != nil {
+		return IndexSuggestion{}, false, fmt.Errorf("failed to estimate size of %s: %w", scan.RelationName, err)
+	}
+	if tableRows < indexSuggestionMinRows {
+		return IndexSuggestion{}, false, nil
+	}
+
+	selectivity := scan.PlanRows / tableRows
+	if selectivity > indexSuggestionMaxSelectivity {
+		return IndexSuggestion{}, false, nil
+	}
+
+	var tableColumns []string
+	if err := readDB.Raw("SELECT attname FROM pg_attribute WHERE attrelid = ?::regclass AND attnum > 0 AND NOT attisdropped", scan.RelationName).Scan(&tableColumns).Error; err != nil {
+		return IndexSuggestion{}, false, fmt.Errorf("failed to list columns of %s: %w", scan.RelationName, err)
+	}
+
+	columns := filterColumns(scan.Filter, tableColumns)
+	if len(columns) == 0 {
+		return IndexSuggestion{}, false, nil
+	}
+
+	return IndexSuggestion{
+		Table:   scan.RelationName,
+		Columns: columns,
+		Filter:  scan.Filter,
+		Reason: fmt.Sprintf("sequential scan of ~%.0f rows keeping an estimated %.2f%%",
+			tableRows, selectivity*100),
+		Statement: fmt.Sprintf("CREATE INDEX CONCURRENTLY ON %s (%s)", scan.RelationName, strings.Join(columns, ", ")),
+	}, true, nil
+}
+
+var (
+	// filterLiteral matches quoted string literals in a plan filter
+	filterLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	// filterIdentifier matches bare or double-quoted identifiers in a plan filter
+	filterIdentifier = regexp.MustCompile(`"((?:[^"]|"")+)"|\b([A-Za-z_][A-Za-z0-9_$]*)\b`)
+)
+
+// filterColumns returns the table columns referenced by a plan filter, in order of appearance
+func filterColumns(filter string, tableColumns []string) []string {
+	known := make(map[string]bool, len(tableColumns))
+	for _, column := range tableColumns {
+		known[column] = true
+	}
+
+	seen := make(map[string]bool)
+	var columns []string
+	for _, match := range filterIdentifier.FindAllStringSubmatch(filterLiteral.ReplaceAllString(filter, "''"), -1) {
+		name := match[2]
+		if match[1] != "" {
+			name = strings.ReplaceAll(match[1], `""`, `"`)
+		}
+		if known[name] && !seen[name] {
+			seen[name] = true
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
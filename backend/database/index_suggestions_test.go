@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestSuggestIndexes_SuggestsFilteredColumnOnSeqScan(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("DROP TABLE IF EXISTS index_suggestion_test").Error)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE index_suggestion_test (id serial PRIMARY KEY, category int, note text)").Error)
+	t.Cleanup(func() { db.GetDB().Exec("DROP TABLE IF EXISTS index_suggestion_test") })
+
+	require.NoError(t, db.GetDB().Exec("INSERT INTO index_suggestion_test (category, note) SELECT g % 1000, 'row ' || g FROM generate_series(1, 50000) g").Error)
+	require.NoError(t, db.GetDB().Exec("ANALYZE index_suggestion_test").Error)
+
+	suggestions, err := db.SuggestIndexes(ctx, func(tx *gorm.DB) *gorm.DB {
+		var rows []map[string]interface{}
+		return tx.Table("index_suggestion_test").Where("category = ?", 7).Find(&rows)
+	})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+
+	assert.Equal(t, "index_suggestion_test", suggestions[0].Table)
+	assert.Equal(t, []string{"category"}, suggestions[0].Columns)
+	assert.Contains(t, suggestions[0].Statement, "(category)")
+}
+
+func TestSuggestIndexes_ParsesPlanFilters(t *testing.T) {
+	var plans []struct {
+		Plan explainNode `json:"Plan"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`[{"Plan": {
+		"Node Type": "Hash Join",
+		"Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "meals", "Filter": "((status)::text = 'user_id'::text) AND (\"userId\" > 10)", "Plan Rows": 5},
+			{"Node Type": "Seq Scan", "Relation Name": "users", "Plan Rows": 500},
+			{"Node Type": "Index Scan", "Relation Name": "foods", "Filter": "(kcal > 100)", "Plan Rows": 1}
+		]
+	}}]`), &plans))
+
+	scans := filteredSeqScans(plans[0].Plan)
+	require.Len(t, scans, 1)
+	assert.Equal(t, "meals", scans[0].RelationName)
+
+	// Literals naming a column are not mistaken for column references
+	columns := filterColumns(scans[0].Filter, []string{"id", "status", "user_id", "userId"})
+	assert.Equal(t, []string{"status", "userId"}, columns)
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// defaultKeysetLimit is the page size of a Keyset without a Limit
+const defaultKeysetLimit = 20
+
+// Keyset describes how PaginateKeyset pages through rows: ordered by Column, with
+// IDColumn breaking ties, so a page starts right after the last row of the previous
+// one instead of skipping an OFFSET. Both columns must be NOT NULL and should be
+// covered by one index, e.g. (created_at, id).
+type Keyset struct {
+	Column    string // defaults to created_at
+	IDColumn  string // defaults to id
+	Ascending bool   // pages run newest first unless set
+	Limit     int    // rows per page, defaults to 20
+}
+
+// KeysetPage is one page of rows; NextCursor fetches the next one while HasMore
+type KeysetPage[T any] struct {
+	Items      []T
+	HasMore    bool
+	NextCursor string
+}
+
+// PaginateKeyset returns the page of the rows tx selects that follows cursor, or
+// the first page for an empty cursor. tx may filter the rows but must not order or
+// limit them. Cursors are opaque tokens encoding the sort keys of a page's last
+// row; malformed ones fail with ErrInvalidCursor.
+func PaginateKeyset[T any](tx *gorm.DB, keyset Keyset, cursor string) (*KeysetPage[T], error) {
+	keyset = keyset.withDefaults()
+	column, id, err := keysetFields[T](tx, keyset)
+	if err != nil {
+		return nil, err
+	}
+
+	query := tx.Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+		{Column: clause.Column{Name: keyset.Column}, Desc: !keyset.Ascending},
+		{Column: clause.Column{Name: keyset.IDColumn}, Desc: !keyset.Ascending},
+	}})
+	if cursor != "" {
+		after, afterID, err := decodeKeysetCursor(cursor, column, id)
+		if err != nil {
+			return nil, err
+		}
+		operator := "<"
+		if keyset.Ascending {
+			operator = ">"
+		}
+		query = query.Where("(?, ?) "+operator+" (?, ?)",
+			clause.Column{Name: keyset.Column}, clause.Column{Name: keyset.IDColumn}, after, afterID)
+	}
+
+	// One row beyond the page tells whether another page follows
+	var items []T
+	if err := query.Limit(keyset.Limit + 1).Find(&items).Error; err != nil {
+		return nil, TranslateError(err)
+	}
+
+	page := &KeysetPage[T]{Items: items}
+	if len(items) > keyset.Limit {
+		page.Items = items[:keyset.Limit]
+		page.HasMore = true
+		page.NextCursor, err = encodeKeysetCursor(tx.Statement.Context, page.Items[keyset.Limit-1], column, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// Page is PaginateKeyset on the rows the scopes select, read as the repository reads
+func (r *Repository[T]) Page(ctx context.Context, keyset Keyset, cursor string, scopes ...func(*gorm.DB) *gorm.DB) (*KeysetPage[T], error) {
+	var page *KeysetPage[T]
+	err := r.read(ctx, func(tx *gorm.DB) error {
+		var err error
+		page, err = PaginateKeyset[T](tx.Scopes(scopes...), keyset, cursor)
+		return err
+	})
+	return page, err
+}
+
+// withDefaults fills in the keyset's unset settings
+func (k Keyset) withDefaults() Keyset {
+	if k.Column == "" {
+		k.Column = "created_at"
+	}
+	if k.IDColumn == "" {
+		k.IDColumn = "id"
+	}
+	if k.Limit <= 0 {
+		k.Limit = defaultKeysetLimit
+	}
+	return k
+}
+
+// keysetFields returns T's fields for the keyset's columns
+func keysetFields[T any](tx *gorm.DB, keyset Keyset) (column, id *schema.Field, err error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse keyset model: %w", err)
+	}
+	if column = stmt.Schema.LookUpField(keyset.Column); column == nil {
+		return nil, nil, fmt.Errorf("keyset column %s is not a field of %s", keyset.Column, stmt.Schema.Name)
+	}
+	if id = stmt.Schema.LookUpField(keyset.IDColumn); id == nil {
+		return nil, nil, fmt.Errorf("keyset column %s is not a field of %s", keyset.IDColumn, stmt.Schema.Name)
+	}
+	return column, id, nil
+}
+
+// encodeKeysetCursor returns the cursor of the page ending with item
+func encodeKeysetCursor(ctx context.Context, item interface{}, column, id *schema.Field) (string, error) {
+	row := reflect.ValueOf(item)
+	value, _ := column.ValueOf(ctx, row)
+	idValue, _ := id.ValueOf(ctx, row)
+
+	data, err := json.Marshal([]interface{}{value, idValue})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeKeysetCursor returns the sort keys cursor encodes, typed as their fields
+func decodeKeysetCursor(cursor string, column, id *schema.Field) (interface{}, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	var keys []json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil || len(keys) != 2 {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	value := reflect.New(column.FieldType)
+	idValue := reflect.New(id.FieldType)
+	if json.Unmarshal(keys[0], value.Interface()) != nil || json.Unmarshal(keys[1], idValue.Interface()) != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	return value.Elem().Interface(), idValue.Elem().Interface(), nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type keysetMealLog struct {
+	ID        int64
+	UserID    int64
+	CreatedAt time.Time
+}
+
+// keysetIDs returns the IDs of the logs in order
+func keysetIDs(logs []keysetMealLog) []int64 {
+	ids := make([]int64, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+	return ids
+}
+
+func newKeysetTestDatabase(t *testing.T) *ProductionDatabase {
+	t.Helper()
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&keysetMealLog{}))
+
+	// Logs 2 and 3 share a timestamp, so the ID breaks the tie
+	base := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	logs := []keysetMealLog{
+		{ID: 1, UserID: 1, CreatedAt: base},
+		{ID: 2, UserID: 1, CreatedAt: base.Add(time.Hour)},
+		{ID: 3, UserID: 1, CreatedAt: base.Add(time.Hour)},
+		{ID: 4, UserID: 2, CreatedAt: base.Add(2 * time.Hour)},
+		{ID: 5, UserID: 1, CreatedAt: base.Add(3 * time.Hour)},
+	}
+	require.NoError(t, db.GetDB().Create(&logs).Error)
+	return db
+}
+
+func TestPaginateKeyset_WalksPagesNewestFirst(t *testing.T) {
+	db := newKeysetTestDatabase(t)
+	keyset := Keyset{Limit: 2}
+	userLogs := db.GetDB().Model(&keysetMealLog{}).Where("user_id = ?", 1)
+
+	var ids []int64
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		page, err := PaginateKeyset[keysetMealLog](userLogs.Session(&gorm.Session{}), keyset, cursor)
+		require.NoError(t, err)
+		ids = append(ids, keysetIDs(page.Items)...)
+		if !page.HasMore {
+			assert.Empty(t, page.NextCursor)
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, []int64{5, 3, 2, 1}, ids)
+}
+
+func TestRepository_PageAscending(t *testing.T) {
+	db := newKeysetTestDatabase(t)
+	repo := NewRepository[keysetMealLog](db)
+	ctx := context.Background()
+
+	page, err := repo.Page(ctx, Keyset{Ascending: true, Limit: 3}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, keysetIDs(page.Items))
+	require.True(t, page.HasMore)
+
+	page, err = repo.Page(ctx, Keyset{Ascending: true, Limit: 3}, page.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 5}, keysetIDs(page.Items))
+	assert.False(t, page.HasMore)
+
+	_, err = repo.Page(ctx, Keyset{}, "not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = repo.Page(ctx, Keyset{Column: "eaten_at"}, "")
+	assert.ErrorContains(t, err, "eaten_at")
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OverallLatencyKey is the LatencyPercentiles entry covering every statement
+const OverallLatencyKey = "all"
+
+// Latency histogram layout: values are recorded in microseconds with exact buckets
+// below latencySubBuckets and latencySubBuckets buckets per power of two above it,
+// bounding the relative error of a reported percentile to about 1.6%
+const (
+	latencySubBucketBits = 6
+	latencySubBuckets    = 1 << latencySubBucketBits
+	latencyMaxShift      = 32 // values above ~76 hours are clamped
+	latencyBucketCount   = (latencyMaxShift + 2) * latencySubBuckets
+)
+
+// Percentiles summarises a latency distribution
+type Percentiles struct {
+	Count uint64        `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// latencyHistogram is a fixed-size HDR-style histogram of durations
+type latencyHistogram struct {
+	counts [latencyBucketCount]uint64
+	count  uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[latencyBucketIndex(uint64(d/time.Microsecond))]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// merge adds every value recorded in other
+func (h *latencyHistogram) merge(other *latencyHistogram) {
+	for index, count := range other.counts {
+		h.counts[index] += count
+	}
+	h.count += other.count
+	h.sum += other.sum
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// quantile returns the latency below which the fraction q of recorded values fall
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := uint64(q*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for index, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			value := latencyBucketValue(index)
+			if value > h.max {
+				value = h.max
+			}
+			return value
+		}
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) percentiles() Percentiles {
+	return Percentiles{
+		Count: h.count,
+		P50:   h.quantile(0.50),
+		P95:   h.quantile(0.95),
+		P99:   h.quantile(0.99),
+		Max:   h.max,
+	}
+}
+
+// latencyBucketIndex maps a value in microseconds to its bucket
+func latencyBucketIndex(micros uint64) int {
+	if micros < latencySubBuckets {
+		return int(micros)
+	}
+
+	shift := bits.Len64(micros) - 1 - latencySubBucketBits
+	if shift > latencyMaxShift {
+		return latencyBucketCount - 1
+	}
+	sub := micros >> uint(shift)
+	return (shift+1)*latencySubBuckets + int(sub-latencySubBuckets)
+}
+
+// latencyBucketValue returns the midpoint of a bucket as a duration
+func latencyBucketValue(index int) time.Duration {
+	if index < latencySubBuckets {
+		return time.Duration(index) * time.Microsecond
+	}
+
+	shift := uint(index/latencySubBuckets - 1)
+	sub := uint64(index%latencySubBuckets + latencySubBuckets)
+	low := sub << shift
+	width := uint64(1) << shift
+	return time.Duration(low+width/2) * time.Microsecond
+}
+
+// latencyTracker keeps statement latency histograms overall and per operation name
+type latencyTracker struct {
+	mu          sync.Mutex
+	overall     latencyHistogram
+	byOperation map[string]*latencyHistogram
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{byOperation: make(map[string]*latencyHistogram)}
+}
+
+// record adds a statement latency, also under its operation name when it has one
+func (t *latencyTracker) record(operation string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.overall.record(d)
+	if operation == "" {
+		return
+	}
+
+	histogram, ok := t.byOperation[operation]
+	if !ok {
+		histogram = &latencyHistogram{}
+		t.byOperation[operation] = histogram
+	}
+	histogram.record(d)
+}
+
+// visit calls fn with every histogram under its LatencyPercentiles key, in key order
+func (t *latencyTracker) visit(fn func(key string, histogram *latencyHistogram)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn(OverallLatencyKey, &t.overall)
+
+	operations := make([]string, 0, len(t.byOperation))
+	for operation := range t.byOperation {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		fn(operation, t.byOperation[operation])
+	}
+}
+
+// LatencyPercentiles returns statement latency percentiles since the database was opened,
+// under OverallLatencyKey for every statement and under each operation name set with
+// WithOperationName
+func (db *ProductionDatabase) LatencyPercentiles() map[string]Percentiles {
+	results := make(map[string]Percentiles)
+	if db.latency == nil {
+		return results
+	}
+
+	db.latency.visit(func(key string, histogram *latencyHistogram) {
+		results[key] = histogram.percentiles()
+	})
+	return results
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyTracker_ReportsPercentilesOfKnownDistribution(t *testing.T) {
+	tracker := newLatencyTracker()
+
+	// 1ms..1000ms uniformly, so pN is N% of a second
+	for i := 1; i <= 1000; i++ {
+		tracker.record("profile.load", time.Duration(i)*time.Millisecond)
+	}
+	// A handful of fast statements without an operation name
+	for i := 0; i < 10; i++ {
+		tracker.record("", 500*time.Microsecond)
+	}
+
+	db := &ProductionDatabase{latency: tracker}
+	percentiles := db.LatencyPercentiles()
+
+	operation := percentiles["profile.load"]
+	assert.Equal(t, uint64(1000), operation.Count)
+	assert.InEpsilon(t, float64(500*time.Millisecond), float64(operation.P50), 0.02)
+	assert.InEpsilon(t, float64(950*time.Millisecond), float64(operation.P95), 0.02)
+	assert.InEpsilon(t, float64(990*time.Millisecond), float64(operation.P99), 0.02)
+	assert.Equal(t, time.Second, operation.Max)
+
+	overall := percentiles[OverallLatencyKey]
+	assert.Equal(t, uint64(1010), overall.Count)
+	assert.InEpsilon(t, float64(950*time.Millisecond), float64(overall.P95), 0.03)
+}
+
+func TestLatencyTracker_RecordsStatementsThroughCallbacks(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	ctx := WithOperationName(context.Background(), "health.probe")
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, db.GetDB().WithContext(ctx).Exec("SELECT 1").Error)
+	}
+	require.NoError(t, db.GetDB().Exec("SELECT 1").Error)
+
+	percentiles := db.LatencyPercentiles()
+	assert.Equal(t, uint64(3), percentiles["health.probe"].Count)
+	assert.Equal(t, uint64(4), percentiles[OverallLatencyKey].Count)
+
+	collector := newPoolCollector(func() map[string]*ProductionDatabase {
+		return map[string]*ProductionDatabase{"app": db}
+	})
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "nutrition_platform_db_query_duration_seconds"))
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultLeaderElectionInterval is how often a LeaderElector renews its lock or retries taking it
+const defaultLeaderElectionInterval = 5 * time.Second
+
+// LeaderElection configures a LeaderElector
+type LeaderElection struct {
+	// Name identifies the election; every replica campaigning under it competes for one advisory lock
+	Name string
+	// Interval between lock renewals while leading, and between attempts otherwise (defaults to 5s)
+	Interval time.Duration
+	// OnStartedLeading runs the singleton work when leadership is won; ctx is
+	// cancelled when leadership is lost and the callback must return promptly then
+	OnStartedLeading func(ctx context.Context)
+	// OnStoppedLeading runs after OnStartedLeading returned and the lock was released
+	OnStoppedLeading func()
+}
+
+// LeaderElector elects one leader among app replicas with a session-level
+// advisory lock on the primary, so singleton background workers such as report
+// generation run on one replica at a time. The leader renews the lock by checking
+// its session is alive; on Postgres the lock is freed when the leader's session
+// ends, letting another replica take over.
+type LeaderElector struct {
+	db       *ProductionDatabase
+	election LeaderElection
+	key      int64
+	renew    func(ctx context.Context, lock *AdvisoryLock) error
+
+	mu     sync.Mutex
+	leader bool
+	lost   chan struct{}
+}
+
+// NewLeaderElector returns an elector for election; it campaigns once Run is called
+func (db *ProductionDatabase) NewLeaderElector(election LeaderElection) *LeaderElector {
+	if election.Interval <= 0 {
+		election.Interval = defaultLeaderElectionInterval
+	}
+	lost := make(chan struct{})
+	close(lost)
+	return &LeaderElector{
+		db:       db,
+		election: election,
+		key:      AdvisoryLockKey(election.Name),
+		renew: func(ctx context.Context, lock *AdvisoryLock) error {
+			return lock.held(ctx)
+		},
+		lost: lost,
+	}
+}
+
+// IsLeader reports whether this replica currently leads
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// Lost returns a channel closed when the current leadership ends; it is already
+// closed while this replica is not leading
+func (e *LeaderElector) Lost() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lost
+}
+
+// Run campaigns for leadership until ctx is done, leading whenever the lock is
+// won and campaigning again after leadership is lost
+func (e *LeaderElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.election.Interval)
+	defer ticker.Stop()
+
+	for {
+		lock, err := e.db.TryAdvisoryLock(ctx, e.key)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			e.db.logger().Warn("Leader election attempt failed", "election", e.election.Name, "error", err)
+		case lock != nil:
+			e.lead(ctx, lock, ticker.C)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lead runs one leadership term, renewing lock on every tick until renewal fails
+// or ctx is done, then stops the work before releasing the lock
+func (e *LeaderElector) lead(ctx context.Context, lock *AdvisoryLock, ticks <-chan time.Time) {
+	termCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.mu.Lock()
+	e.leader = true
+	e.lost = make(chan struct{})
+	e.mu.Unlock()
+
+	e.db.logger().Info("Started leading", "election", e.election.Name)
+	e.db.emit(EventLeadershipAcquired, "started leading "+e.election.Name, nil)
+
+	working := make(chan struct{})
+	go func() {
+		defer close(working)
+		if e.election.OnStartedLeading != nil {
+			e.election.OnStartedLeading(termCtx)
+		}
+	}()
+
+	var lostErr error
+renewal:
+	for {
+		select {
+		case <-ticks:
+			if lostErr = e.renew(ctx, lock); lostErr != nil {
+				break renewal
+			}
+		case <-ctx.Done():
+			break renewal
+		}
+	}
+
+	// Stop the work before anything else; a failed renewal means another replica may already lead
+	cancel()
+	e.mu.Lock()
+	e.leader = false
+	close(e.lost)
+	e.mu.Unlock()
+	<-working
+
+	if err := lock.Release(); err != nil && lostErr == nil {
+		e.db.logger().Warn("Failed to release leader lock", "election", e.election.Name, "error", err)
+	}
+	if lostErr != nil && ctx.Err() == nil {
+		e.db.logger().Warn("Lost leadership", "election", e.election.Name, "error", lostErr)
+	} else {
+		e.db.logger().Info("Stopped leading", "election", e.election.Name)
+	}
+	e.db.emit(EventLeadershipLost, "stopped leading "+e.election.Name, lostErr)
+
+	if e.election.OnStoppedLeading != nil {
+		e.election.OnStoppedLeading()
+	}
+}
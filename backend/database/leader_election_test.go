@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderElector_LeadsUntilContextDone(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	var started, stopped int32
+	elector := db.NewLeaderElector(LeaderElection{
+		Name:     "nightly_rollup",
+		Interval: 5 * time.Millisecond,
+		OnStartedLeading: func(ctx context.Context) {
+			atomic.AddInt32(&started, 1)
+			<-ctx.Done()
+		},
+		OnStoppedLeading: func() { atomic.AddInt32(&stopped, 1) },
+	})
+	assert.False(t, elector.IsLeader())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(ctx)
+	}()
+
+	require.Eventually(t, elector.IsLeader, time.Second, time.Millisecond)
+	lost := elector.Lost()
+	select {
+	case <-lost:
+		t.Fatal("leadership reported lost while leading")
+	default:
+	}
+
+	cancel()
+	<-done
+	assert.False(t, elector.IsLeader())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&started))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stopped))
+	_, open := <-lost
+	assert.False(t, open)
+}
+
+func TestLeaderElector_StopsWorkAndCampaignsAgainAfterFailedRenewal(t *testing.T) {
+	var events []EventType
+	var eventsMu sync.Mutex
+	config := newSQLiteTestConfig(t, "primary")
+	config.OnEvent = func(event Event) {
+		eventsMu.Lock()
+		defer eventsMu.Unlock()
+		events = append(events, event.Type)
+	}
+	db := newSQLiteTestDatabase(t, config)
+
+	var terms, stopped int32
+	elector := db.NewLeaderElector(LeaderElection{
+		Name:     "purge",
+		Interval: 5 * time.Millisecond,
+		OnStartedLeading: func(ctx context.Context) {
+			atomic.AddInt32(&terms, 1)
+			<-ctx.Done()
+		},
+		OnStoppedLeading: func() { atomic.AddInt32(&stopped, 1) },
+	})
+	var renewals int32
+	elector.renew = func(context.Context, *AdvisoryLock) error {
+		if atomic.AddInt32(&renewals, 1) == 2 {
+			return errors.New("connection lost")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&terms) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stopped), "the first term ended when renewal failed")
+
+	cancel()
+	<-done
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	assert.Equal(t, []EventType{EventLeadershipAcquired, EventLeadershipLost, EventLeadershipAcquired, EventLeadershipLost}, events)
+}
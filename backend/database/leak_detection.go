@@ -0,0 +1,69 @@
+package database
+
+// LeakTrend describes the in-use connection samples that made a pool look like it leaks
+type LeakTrend struct {
+	Role string
+
+	// Samples are InUse at each of the last LeakDetectionWindow health checks, oldest first
+	Samples []int
+
+	// Baseline is the lowest InUse seen in the window, Growth the rise above it
+	Baseline int
+	Growth   int
+}
+
+// checkConnectionLeaks samples every pool's in-use connections for leak detection
+func (hc *HealthChecker) checkConnectionLeaks() {
+	if hc.db.config.LeakDetectionWindow <= 0 {
+		return
+	}
+	for role, stats := range hc.db.poolStats() {
+		hc.observeInUse(role, stats.InUse)
+	}
+}
+
+// observeInUse records an in-use sample for role and reports a suspected leak once
+// the last LeakDetectionWindow samples only ever rose. Load comes and goes, so
+// any drop in between means connections are being returned. After a report the
+// history restarts, so a leak that keeps growing is reported once per window.
+func (hc *HealthChecker) observeInUse(role string, inUse int) {
+	window := hc.db.config.LeakDetectionWindow
+	if window <= 0 {
+		return
+	}
+	if hc.inUseHistory == nil {
+		hc.inUseHistory = make(map[string][]int)
+	}
+
+	history := append(hc.inUseHistory[role], inUse)
+	if len(history) > window {
+		history = history[len(history)-window:]
+	}
+	hc.inUseHistory[role] = history
+
+	if len(history) < window {
+		return
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i] < history[i-1] {
+			return
+		}
+	}
+
+	trend := LeakTrend{
+		Role:     role,
+		Samples:  append([]int(nil), history...),
+		Baseline: history[0],
+		Growth:   history[len(history)-1] - history[0],
+	}
+	if trend.Growth <= 0 {
+		return
+	}
+
+	hc.db.logger().Warn("Suspected connection leak: pool in-use connections rose on every health check",
+		"role", role, "from", trend.Baseline, "to", trend.Baseline+trend.Growth, "health_checks", window)
+	if hc.db.config.OnSuspectedLeak != nil {
+		hc.db.config.OnSuspectedLeak(trend)
+	}
+	delete(hc.inUseHistory, role)
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLeakTestDatabase(t *testing.T, trends *[]LeakTrend) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.LeakDetectionWindow = 5
+	config.OnSuspectedLeak = func(trend LeakTrend) { *trends = append(*trends, trend) }
+	return newSQLiteTestDatabase(t, config)
+}
+
+func TestLeakDetection_SteadilyRisingInUseFires(t *testing.T) {
+	var trends []LeakTrend
+	db := newLeakTestDatabase(t, &trends)
+
+	for _, inUse := range []int{2, 3, 3, 5, 6} {
+		db.healthChecker.observeInUse("primary", inUse)
+	}
+
+	require.Len(t, trends, 1)
+	assert.Equal(t, LeakTrend{Role: "primary", Samples: []int{2, 3, 3, 5, 6}, Baseline: 2, Growth: 4}, trends[0])
+
+	// The history restarts after a report
+	db.healthChecker.observeInUse("primary", 7)
+	assert.Len(t, trends, 1)
+}
+
+func TestLeakDetection_FluctuatingLoadDoesNotFire(t *testing.T) {
+	var trends []LeakTrend
+	db := newLeakTestDatabase(t, &trends)
+
+	for _, inUse := range []int{2, 5, 8, 3, 6, 9, 2, 4, 7, 10, 2, 2, 2, 2, 2} {
+		db.healthChecker.observeInUse("primary", inUse)
+	}
+
+	assert.Empty(t, trends)
+}
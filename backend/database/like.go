@@ -0,0 +1,55 @@
+package database
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MatchMode decides where WhereLike lets a pattern match within a column
+type MatchMode int
+
+const (
+	// MatchContains matches the input anywhere in the column
+	MatchContains MatchMode = iota
+
+	// MatchPrefix matches columns starting with the input
+	MatchPrefix
+
+	// MatchSuffix matches columns ending with the input
+	MatchSuffix
+
+	// MatchExact matches columns equal to the input
+	MatchExact
+)
+
+// likeEscaper escapes the escape character itself before the wildcards
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes the LIKE wildcards % and _ in user input so they match literally
+// The result is meant for a LIKE clause with ESCAPE '\', as WhereLike builds.
+func EscapeLike(pattern string) string {
+	return likeEscaper.Replace(pattern)
+}
+
+// WhereLike adds a LIKE condition matching userInput literally within column
+// Wildcards in the input are escaped, so a search for "50%" cannot match every row
+// beginning with "50" or fall back to scanning for a leading wildcard the caller
+// did not ask for; only mode decides where the wildcards go.
+func WhereLike(db *gorm.DB, column, userInput string, mode MatchMode) *gorm.DB {
+	pattern := EscapeLike(userInput)
+	switch mode {
+	case MatchContains:
+		pattern = "%" + pattern + "%"
+	case MatchPrefix:
+		pattern = pattern + "%"
+	case MatchSuffix:
+		pattern = "%" + pattern
+	}
+
+	return db.Where(clause.Expr{
+		SQL:  `? LIKE ? ESCAPE '\'`,
+		Vars: []interface{}{clause.Column{Name: column}, pattern},
+	})
+}
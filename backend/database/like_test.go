@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `50\%`, EscapeLike("50%"))
+	assert.Equal(t, `snake\_case`, EscapeLike("snake_case"))
+	assert.Equal(t, `back\\slash`, EscapeLike(`back\slash`))
+	assert.Equal(t, "oats", EscapeLike("oats"))
+}
+
+func TestWhereLike_BuildsEscapedClause(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	sql := db.GetDB().ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var names []string
+		return WhereLike(tx.Table("foods").Select("name"), "name", "50%", MatchPrefix).Find(&names)
+	})
+
+	assert.Contains(t, sql, "`name` LIKE \"50\\%%\" ESCAPE '\\'")
+}
+
+func TestWhereLike_MatchesInputLiterally(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE foods (id integer PRIMARY KEY, name text)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO foods (name) VALUES ('50% cocoa'), ('500g oats'), ('brown_rice'), ('brownie')").Error)
+
+	search := func(input string, mode MatchMode) []string {
+		var names []string
+		require.NoError(t, WhereLike(db.GetDB().Table("foods"), "name", input, mode).Order("name").Pluck("name", &names).Error)
+		return names
+	}
+
+	assert.Equal(t, []string{"50% cocoa"}, search("50%", MatchPrefix))
+	assert.Equal(t, []string{"brown_rice"}, search("_rice", MatchSuffix))
+	assert.Equal(t, []string{"brown_rice", "brownie"}, search("brown", MatchContains))
+	assert.Empty(t, search("brown", MatchExact))
+	assert.Equal(t, []string{"brownie"}, search("brownie", MatchExact))
+}
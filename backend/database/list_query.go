@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// listFilterOperators maps the operators of filter[field][op] parameters to SQL
+var listFilterOperators = map[string]string{
+	"eq":  "=",
+	"ne":  "<>",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// ListQuerySpec whitelists what a list endpoint lets clients filter and sort by,
+// mapping the field names clients use to columns
+type ListQuerySpec struct {
+	Filters     map[string]string
+	Sorts       map[string]string
+	DefaultSort string // e.g. "-created_at", used without a sort parameter
+}
+
+// ParseListQuery parses the filtering and sorting parameters of a list request into
+// a Filter, whose Scope applies them to a GORM query:
+//
+//	filter[calories][gte]=200   calories >= 200 (also eq, ne, gt, lt, lte)
+//	filter[category]=fruit      category = 'fruit'
+//	filter[category][in]=a,b    category IN ('a', 'b')
+//	filter[name][contains]=oat  name contains "oat" literally (see EscapeLike)
+//	sort=-created_at,name       created_at descending, then name
+//
+// Only fields spec whitelists are accepted; anything else fails with
+// ErrInvalidQueryParameter. Other parameters, such as pagination, are ignored.
+// Values are passed as strings, which the database converts to the column's type.
+func ParseListQuery(values url.Values, spec ListQuerySpec) (*Filter, error) {
+	filter := NewFilter()
+
+	// Sorted so the conditions, and the SQL, do not depend on map order
+	params := make([]string, 0, len(values))
+	for param := range values {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		field, operator, ok := parseFilterParam(param)
+		if !ok {
+			continue
+		}
+		column, allowed := spec.Filters[field]
+		if !allowed {
+			return nil, fmt.Errorf("%w: cannot filter by %q", ErrInvalidQueryParameter, field)
+		}
+		for _, value := range values[param] {
+			if err := addListFilter(filter, column, operator, value); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidQueryParameter, param, err)
+			}
+		}
+	}
+
+	sorts := values.Get("sort")
+	if sorts == "" {
+		sorts = spec.DefaultSort
+	}
+	for _, field := range strings.Split(sorts, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+		column, allowed := spec.Sorts[field]
+		if !allowed {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQueryParameter, field)
+		}
+		filter.OrderBy(column, desc)
+	}
+
+	if err := filter.Err(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// parseFilterParam splits filter[field] or filter[field][op] into field and op,
+// which defaults to eq
+func parseFilterParam(param string) (field, operator string, ok bool) {
+	rest, ok := strings.CutPrefix(param, "filter[")
+	if !ok {
+		return "", "", false
+	}
+	field, rest, ok = strings.Cut(rest, "]")
+	if !ok || field == "" {
+		return "", "", false
+	}
+	if rest == "" {
+		return field, "eq", true
+	}
+	operator, ok = strings.CutPrefix(rest, "[")
+	if !ok || !strings.HasSuffix(operator, "]") {
+		return "", "", false
+	}
+	return field, strings.TrimSuffix(operator, "]"), true
+}
+
+// addListFilter adds the condition of one filter parameter to filter
+func addListFilter(filter *Filter, column, operator, value string) error {
+	if !filter.validColumn(column) {
+		return filter.Err()
+	}
+	switch operator {
+	case "in":
+		filter.In(column, strings.Split(value, ","))
+	case "contains":
+		filter.Where(column+` LIKE ? ESCAPE '\'`, "%"+EscapeLike(value)+"%")
+	default:
+		sqlOperator, ok := listFilterOperators[operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %q", operator)
+		}
+		filter.Where(column+" "+sqlOperator+" ?", value)
+	}
+	return nil
+}
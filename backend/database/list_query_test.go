@@ -0,0 +1,67 @@
+package database
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mealListSpec = ListQuerySpec{
+	Filters:     map[string]string{"calories": "calories", "category": "category", "name": "name"},
+	Sorts:       map[string]string{"calories": "calories", "name": "name", "created_at": "id"},
+	DefaultSort: "-created_at",
+}
+
+func TestParseListQuery_BuildsWhitelistedFilter(t *testing.T) {
+	values, err := url.ParseQuery("filter[calories][gte]=100&filter[calories][lt]=400&filter[category][in]=fruit,grain&filter[name][contains]=a&sort=-calories,name&page=2")
+	require.NoError(t, err)
+
+	filter, err := ParseListQuery(values, mealListSpec)
+	require.NoError(t, err)
+	query, args, err := filter.SQL("SELECT * FROM meals")
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM meals WHERE (calories >= $1) AND (calories < $2) AND (category IN ($3, $4)) AND (name LIKE $5 ESCAPE '\') ORDER BY calories DESC, name`, query)
+	assert.Equal(t, []interface{}{"100", "400", "fruit", "grain", "%a%"}, args)
+
+	filter, err = ParseListQuery(url.Values{"filter[category]": {"fruit"}}, mealListSpec)
+	require.NoError(t, err)
+	query, _, err = filter.SQL("SELECT * FROM meals")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM meals WHERE (category = $1) ORDER BY id DESC", query)
+}
+
+func TestParseListQuery_RejectsUnlistedFieldsAndOperators(t *testing.T) {
+	for _, raw := range []string{
+		"filter[password_hash]=x",
+		"filter[calories][regex]=1",
+		"sort=password_hash",
+		"sort=-name%3BDROP+TABLE+meals",
+	} {
+		values, err := url.ParseQuery(raw)
+		require.NoError(t, err)
+		_, err = ParseListQuery(values, mealListSpec)
+		assert.True(t, errors.Is(err, ErrInvalidQueryParameter), "%s: %v", raw, err)
+	}
+}
+
+func TestParseListQuery_ScopesGormQueries(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&filterFood{}))
+	require.NoError(t, db.GetDB().Create([]filterFood{
+		{Name: "apple", Category: "fruit", Calories: 52},
+		{Name: "oats", Category: "grain", Calories: 389},
+		{Name: "100% juice", Category: "fruit", Calories: 120},
+	}).Error)
+
+	values := url.Values{"filter[calories][gte]": {"100"}, "filter[name][contains]": {"%"}}
+	filter, err := ParseListQuery(values, mealListSpec)
+	require.NoError(t, err)
+
+	var foods []filterFood
+	require.NoError(t, db.GetDB().Scopes(filter.Scope()).Find(&foods).Error)
+	require.Len(t, foods, 1)
+	assert.Equal(t, "100% juice", foods[0].Name)
+}
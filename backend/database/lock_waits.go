@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BlockedQuery describes a backend waiting on a lock held by another backend
+type BlockedQuery struct {
+	BlockedPID        int
+	BlockingPID       int
+	BlockedStatement  string
+	BlockingStatement string
+	BlockedFor        time.Duration
+}
+
+// blockedQueriesSQL joins ungranted locks with the granted locks they conflict with
+const blockedQueriesSQL = `
+SELECT blocked_activity.pid,
+       blocking_activity.pid,
+       blocked_activity.query,
+       blocking_activity.query,
+       COALESCE(EXTRACT(EPOCH FROM now() - blocked_activity.query_start), 0)
+FROM pg_catalog.pg_locks blocked_locks
+JOIN pg_catalog.pg_stat_activity blocked_activity ON blocked_activity.pid = blocked_locks.pid
+JOIN pg_catalog.pg_locks blocking_locks
+  ON blocking_locks.locktype = blocked_locks.locktype
+ AND blocking_locks.database IS NOT DISTINCT FROM blocked_locks.database
+ AND blocking_locks.relation IS NOT DISTINCT FROM blocked_locks.relation
+ AND blocking_locks.page IS NOT DISTINCT FROM blocked_locks.page
+ AND blocking_locks.tuple IS NOT DISTINCT FROM blocked_locks.tuple
+ AND blocking_locks.virtualxid IS NOT DISTINCT FROM blocked_locks.virtualxid
+ AND blocking_locks.transactionid IS NOT DISTINCT FROM blocked_locks.transactionid
+ AND blocking_locks.classid IS NOT DISTINCT FROM blocked_locks.classid
+ AND blocking_locks.objid IS NOT DISTINCT FROM blocked_locks.objid
+ AND blocking_locks.objsubid IS NOT DISTINCT FROM blocked_locks.objsubid
+ AND blocking_locks.pid != blocked_locks.pid
+JOIN pg_catalog.pg_stat_activity blocking_activity ON blocking_activity.pid = blocking_locks.pid
+WHERE NOT blocked_locks.granted
+  AND blocking_locks.granted`
+
+// BlockedQueries returns every backend on the primary that is waiting on a lock,
+// together with the backend holding it
+func (db *ProductionDatabase) BlockedQueries(ctx context.Context) ([]BlockedQuery, error) {
+	rows, err := db.primary().WithContext(ctx).Raw(blockedQueriesSQL).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lock waits: %w", err)
+	}
+	defer rows.Close()
+
+	var blocked []BlockedQuery
+	for rows.Next() {
+		var (
+			q       BlockedQuery
+			seconds float64
+		)
+		if err := rows.Scan(&q.BlockedPID, &q.BlockingPID, &q.BlockedStatement, &q.BlockingStatement, &seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan lock wait: %w", err)
+		}
+		q.BlockedFor = time.Duration(seconds * float64(time.Second))
+		blocked = append(blocked, q)
+	}
+
+	return blocked, rows.Err()
+}
+
+// checkBlockedQueries warns about queries blocked longer than the configured threshold
+func (hc *HealthChecker) checkBlockedQueries() {
+	threshold := hc.db.config.BlockedQueryWarnThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
+	defer cancel()
+
+	blocked, err := hc.db.BlockedQueries(ctx)
+	if err != nil {
+		hc.db.logger().Error("Lock wait check failed", "error", err)
+		return
+	}
+
+	for _, q := range blocked {
+		if q.BlockedFor >= threshold {
+			hc.db.logger().Warn("Query blocked on a lock",
+				"pid", q.BlockedPID, "blocked_for", q.BlockedFor, "blocking_pid", q.BlockingPID,
+				"blocked_query", q.BlockedStatement, "blocking_query", q.BlockingStatement)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockedQueries_ReportsLockWait(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE IF NOT EXISTS lock_wait_test (id int PRIMARY KEY, v int)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO lock_wait_test (id, v) VALUES (1, 0) ON CONFLICT DO NOTHING").Error)
+	t.Cleanup(func() { db.GetDB().Exec("DROP TABLE IF EXISTS lock_wait_test") })
+
+	holder, err := db.sqlDB.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer holder.Rollback()
+
+	var holderPID int
+	require.NoError(t, holder.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&holderPID))
+	_, err = holder.ExecContext(ctx, "UPDATE lock_wait_test SET v = v + 1 WHERE id = 1")
+	require.NoError(t, err)
+
+	waiter, err := db.sqlDB.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer waiter.Rollback()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := waiter.ExecContext(ctx, "UPDATE lock_wait_test SET v = v + 1 WHERE id = 1")
+		done <- err
+	}()
+
+	var found *BlockedQuery
+	assert.Eventually(t, func() bool {
+		blocked, err := db.BlockedQueries(ctx)
+		if err != nil {
+			return false
+		}
+		for i := range blocked {
+			if blocked[i].BlockingPID == holderPID {
+				found = &blocked[i]
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NotNil(t, found)
+	assert.Contains(t, found.BlockedStatement, "UPDATE lock_wait_test")
+	assert.Contains(t, found.BlockingStatement, "UPDATE lock_wait_test")
+
+	require.NoError(t, holder.Rollback())
+	require.NoError(t, <-done)
+}
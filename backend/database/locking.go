@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LockForUpdate is a scope locking the selected rows against concurrent updates,
+// deletes and locks until the transaction ends (SELECT ... FOR UPDATE)
+func LockForUpdate() func(*gorm.DB) *gorm.DB {
+	return lockingScope(func(locking *clause.Locking) { locking.Strength = clause.LockingStrengthUpdate })
+}
+
+// LockShare is a scope locking the selected rows against concurrent updates and
+// deletes, while letting other transactions share the lock (SELECT ... FOR SHARE)
+func LockShare() func(*gorm.DB) *gorm.DB {
+	return lockingScope(func(locking *clause.Locking) { locking.Strength = clause.LockingStrengthShare })
+}
+
+// SkipLocked is a scope skipping rows another transaction holds locked instead of
+// waiting for them, e.g. for workers claiming free coaching slots or queued jobs.
+// Without LockShare the rows are locked FOR UPDATE.
+func SkipLocked() func(*gorm.DB) *gorm.DB {
+	return lockingScope(func(locking *clause.Locking) { locking.Options = clause.LockingOptionsSkipLocked })
+}
+
+// NoWait is a scope failing right away, with SQLSTATE 55P03, when a selected row
+// is locked instead of waiting for it. Without LockShare the rows are locked FOR UPDATE.
+func NoWait() func(*gorm.DB) *gorm.DB {
+	return lockingScope(func(locking *clause.Locking) { locking.Options = clause.LockingOptionsNoWait })
+}
+
+// lockingScope returns a scope adjusting the statement's locking clause, which
+// starts out as FOR UPDATE, so the helpers combine in any order
+func lockingScope(adjust func(*clause.Locking)) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		locking := clause.Locking{Strength: clause.LockingStrengthUpdate}
+		if existing, ok := tx.Statement.Clauses[locking.Name()]; ok {
+			if current, ok := existing.Expression.(clause.Locking); ok {
+				locking = current
+			}
+		}
+		adjust(&locking)
+		return tx.Clauses(locking)
+	}
+}
+
+// GetForUpdate returns the row whose primary key is id, locked FOR UPDATE until the
+// repository's transaction (see WithTx) ends; options such as NoWait or LockShare
+// adjust the lock. Outside a transaction it fails with ErrLockOutsideTransaction.
+func (r *Repository[T]) GetForUpdate(ctx context.Context, id interface{}, options ...func(*gorm.DB) *gorm.DB) (*T, error) {
+	if r.tx == nil {
+		return nil, ErrLockOutsideTransaction
+	}
+
+	var entity T
+	err := r.read(ctx, func(tx *gorm.DB) error {
+		return tx.Scopes(LockForUpdate()).Scopes(options...).
+			Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).
+			First(&entity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
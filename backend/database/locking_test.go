@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockingClause returns the locking clause the scopes give a query
+func lockingClause(t *testing.T, db *ProductionDatabase, scopes ...func(*gorm.DB) *gorm.DB) clause.Locking {
+	t.Helper()
+	var meals []repositoryMeal
+	tx := db.GetDB().Session(&gorm.Session{DryRun: true}).Scopes(scopes...).Find(&meals)
+	require.NoError(t, tx.Error)
+	locking, ok := tx.Statement.Clauses["FOR"].Expression.(clause.Locking)
+	require.True(t, ok)
+	return locking
+}
+
+func TestLockingScopes_Combine(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	assert.Equal(t, clause.Locking{Strength: "UPDATE"}, lockingClause(t, db, LockForUpdate()))
+	assert.Equal(t, clause.Locking{Strength: "SHARE"}, lockingClause(t, db, LockShare()))
+	assert.Equal(t, clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}, lockingClause(t, db, SkipLocked()))
+	assert.Equal(t, clause.Locking{Strength: "SHARE", Options: "NOWAIT"}, lockingClause(t, db, NoWait(), LockShare()))
+	assert.Equal(t, clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}, lockingClause(t, db, LockForUpdate(), NoWait(), SkipLocked()))
+}
+
+func TestRepository_GetForUpdateNeedsTransaction(t *testing.T) {
+	db := newRepositoryTestDatabase(t)
+	repo := NewRepository[repositoryMeal](db)
+	ctx := context.Background()
+
+	_, err := repo.GetForUpdate(ctx, 1)
+	assert.ErrorIs(t, err, ErrLockOutsideTransaction)
+
+	meal := &repositoryMeal{Name: "slot"}
+	require.NoError(t, repo.Create(ctx, meal))
+
+	// SQLite ignores the lock, but the row is read in the transaction, on the primary
+	err = db.Transaction(func(tx *gorm.DB) error {
+		locked, err := repo.WithTx(tx).GetForUpdate(ctx, meal.ID, NoWait())
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, "slot", locked.Name)
+		return nil
+	})
+	require.NoError(t, err)
+}
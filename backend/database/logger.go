@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// logger returns the Logger database logs go to
+func (config *ProductionConfig) logger() *slog.Logger {
+	if config != nil && config.Logger != nil {
+		return config.Logger
+	}
+	return slog.Default()
+}
+
+// logger returns the Logger the database logs to
+func (db *ProductionDatabase) logger() *slog.Logger {
+	return db.config.logger()
+}
+
+// slogGormLogger is a GORM logger emitting slog records, with each statement's
+// query, duration, rows and error as attributes
+type slogGormLogger struct {
+	logger        *slog.Logger
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+// newSlogGormLogger returns the GORM logger for config's Logger, LogLevel and SlowThreshold
+func newSlogGormLogger(config *ProductionConfig) *slogGormLogger {
+	return &slogGormLogger{
+		logger:        config.Logger,
+		level:         config.LogLevel,
+		slowThreshold: config.SlowThreshold,
+	}
+}
+
+// LogMode implements logger.Interface
+func (l *slogGormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info implements logger.Interface
+func (l *slogGormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		l.logger.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Warn implements logger.Interface
+func (l *slogGormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		l.logger.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Error implements logger.Interface
+func (l *slogGormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		l.logger.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace implements logger.Interface, logging failed statements as errors, slow
+// ones as warnings and, at the Info level, every other statement
+// Missing rows are not failures, as with GORM's default logger.
+func (l *slogGormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level, msg := slog.LevelInfo, "Database query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		level, msg = slog.LevelError, "Database query failed"
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		level, msg = slog.LevelWarn, "Slow database query"
+	case l.level < logger.Info:
+		return
+	}
+
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("query", sql),
+		slog.Duration("duration", elapsed),
+		slog.Int64("rows", rows),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	if level == slog.LevelWarn {
+		attrs = append(attrs, slog.Duration("slow_threshold", l.slowThreshold))
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+// jsonRecords decodes the JSON lines a slog.JSONHandler wrote
+func jsonRecords(t *testing.T, output string) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+// recordWithMessage returns the first record whose msg is message, or nil
+func recordWithMessage(records []map[string]interface{}, message string) map[string]interface{} {
+	for _, record := range records {
+		if record["msg"] == message {
+			return record
+		}
+	}
+	return nil
+}
+
+func TestLogger_RoutesDatabaseAndQueryLogsToSlog(t *testing.T) {
+	var output syncBuffer
+	config := newSQLiteTestConfig(t, "primary")
+	config.Logger = slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	config.LogLevel = logger.Warn
+	db := newSQLiteTestDatabase(t, config)
+
+	require.Error(t, db.GetDB().Exec("SELECT * FROM missing_table").Error)
+
+	records := jsonRecords(t, output.String())
+	assert.NotNil(t, recordWithMessage(records, "Production database connected"))
+
+	failed := recordWithMessage(records, "Database query failed")
+	require.NotNil(t, failed, "GORM's statement log must go through the slog logger")
+	assert.Equal(t, "ERROR", failed["level"])
+	assert.Equal(t, "SELECT * FROM missing_table", failed["query"])
+	assert.Contains(t, failed["error"], "no such table")
+	assert.Contains(t, failed, "duration")
+}
+
+func TestSlogGormLogger_LevelsAndSlowQueries(t *testing.T) {
+	var output syncBuffer
+	gormLogger := newSlogGormLogger(&ProductionConfig{
+		Logger:        slog.New(slog.NewJSONHandler(&output, nil)),
+		LogLevel:      logger.Warn,
+		SlowThreshold: 10 * time.Millisecond,
+	})
+	statement := func() (string, int64) { return "SELECT 1", 1 }
+
+	// Fast statements are only logged at the Info level
+	gormLogger.Trace(t.Context(), time.Now(), statement, nil)
+	assert.Empty(t, output.String())
+
+	gormLogger.Trace(t.Context(), time.Now().Add(-time.Second), statement, nil)
+	slow := jsonRecords(t, output.String())[0]
+	assert.Equal(t, "Slow database query", slow["msg"])
+	assert.Equal(t, "WARN", slow["level"])
+	assert.Equal(t, float64(1), slow["rows"])
+
+	silent := gormLogger.LogMode(logger.Silent)
+	silent.Trace(t.Context(), time.Now().Add(-time.Second), statement, nil)
+	assert.Len(t, jsonRecords(t, output.String()), 1)
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// MaintenanceTask is periodic housekeeping run in the background by ScheduleMaintenance
+type MaintenanceTask struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, db *ProductionDatabase) error
+}
+
+// ScheduleMaintenance runs task once immediately and then every Interval until the
+// database is closed. Failures are logged and retried on the next run.
+func (db *ProductionDatabase) ScheduleMaintenance(task MaintenanceTask) {
+	db.maintenance.Add(1)
+	go func() {
+		defer db.maintenance.Done()
+
+		ticker := time.NewTicker(task.Interval)
+		defer ticker.Stop()
+
+		for {
+			db.runMaintenance(task)
+
+			select {
+			case <-ticker.C:
+			case <-db.maintenanceCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// runMaintenance runs one pass of a maintenance task
+func (db *ProductionDatabase) runMaintenance(task MaintenanceTask) {
+	if err := task.Run(db.maintenanceCtx, db); err != nil && db.maintenanceCtx.Err() == nil {
+		db.logger().Error("Database maintenance task failed", "task", task.Name, "error", err)
+	}
+}
+
+// stopMaintenance cancels running maintenance and waits for every task to return
+func (db *ProductionDatabase) stopMaintenance() {
+	if db.stopMaintenanceTasks == nil {
+		return
+	}
+	db.stopMaintenanceTasks()
+	db.maintenance.Wait()
+}
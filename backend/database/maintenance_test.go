@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleMaintenance_RunsUntilClose(t *testing.T) {
+	db, err := NewProductionDatabase(newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, err)
+
+	var runs int32
+	db.ScheduleMaintenance(MaintenanceTask{
+		Name:     "count",
+		Interval: 10 * time.Millisecond,
+		Run: func(ctx context.Context, db *ProductionDatabase) error {
+			atomic.AddInt32(&runs, 1)
+			return errors.New("failures are retried on the next run")
+		},
+	})
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 3 }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, db.Close())
+	stopped := atomic.LoadInt32(&runs)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt32(&runs), "no runs after Close")
+}
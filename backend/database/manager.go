@@ -0,0 +1,149 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DatabaseManager holds several named production databases and routes models to them
+type DatabaseManager struct {
+	mu          sync.RWMutex
+	databases   map[string]*ProductionDatabase
+	models      map[reflect.Type]string
+	defaultName string
+}
+
+// NewDatabaseManager creates a manager; models without a registration resolve to defaultName
+func NewDatabaseManager(defaultName string) *DatabaseManager {
+	return &DatabaseManager{
+		databases:   make(map[string]*ProductionDatabase),
+		models:      make(map[reflect.Type]string),
+		defaultName: defaultName,
+	}
+}
+
+// Add registers a named database with the manager, replacing any previous one
+func (m *DatabaseManager) Add(name string, db *ProductionDatabase) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.databases[name] = db
+}
+
+// Get returns the named database
+func (m *DatabaseManager) Get(name string) (*ProductionDatabase, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	db, ok := m.databases[name]
+	return db, ok
+}
+
+// Default returns the database used for unregistered models
+func (m *DatabaseManager) Default() *ProductionDatabase {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.databases[m.defaultName]
+}
+
+// Names returns the names of all registered databases
+func (m *DatabaseManager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.databases))
+	for name := range m.databases {
+		names = append(names, name)
+	}
+	return names
+}
+
+// RegisterModelDatabase routes a model (struct, pointer or slice of it) to the named database
+func (m *DatabaseManager) RegisterModelDatabase(model interface{}, dbName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models[modelType(model)] = dbName
+}
+
+// For returns the database a model is routed to
+// Unregistered models, and models registered to an unknown database, use the default database
+func (m *DatabaseManager) For(model interface{}) *ProductionDatabase {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if name, ok := m.models[modelType(model)]; ok {
+		if db, ok := m.databases[name]; ok {
+			return db
+		}
+	}
+	return m.databases[m.defaultName]
+}
+
+// Health performs a health check on every registered database
+func (m *DatabaseManager) Health() map[string]error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := make(map[string]error, len(m.databases))
+	for name, db := range m.databases {
+		results[name] = db.Health()
+	}
+	return results
+}
+
+// AllStats returns the connection pool statistics of every registered database keyed by name
+func (m *DatabaseManager) AllStats() map[string]map[string]interface{} {
+	databases := m.snapshot()
+
+	stats := make(map[string]map[string]interface{}, len(databases))
+	for name, db := range databases {
+		stats[name] = db.Stats()
+	}
+	return stats
+}
+
+// RegisterMetrics registers a single pool collector covering every managed database,
+// with metrics labelled by database name
+func (m *DatabaseManager) RegisterMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(newPoolCollector(m.snapshot))
+}
+
+// snapshot returns a copy of the registered databases
+func (m *DatabaseManager) snapshot() map[string]*ProductionDatabase {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	databases := make(map[string]*ProductionDatabase, len(m.databases))
+	for name, db := range m.databases {
+		databases[name] = db
+	}
+	return databases
+}
+
+// Close closes every registered database
+func (m *DatabaseManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errors []error
+	for name, db := range m.databases {
+		if err := db.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("database manager close errors: %v", errors)
+	}
+	return nil
+}
+
+// modelType resolves the struct type behind pointers, slices and arrays
+func modelType(model interface{}) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type managerTestUser struct {
+	ID   uint
+	Name string
+}
+
+type managerTestEvent struct {
+	ID   uint
+	Kind string
+}
+
+type managerTestUnregistered struct {
+	ID uint
+}
+
+func TestDatabaseManager_RoutesModelsToConfiguredDatabases(t *testing.T) {
+	appDB := &ProductionDatabase{config: DefaultProductionConfig()}
+	analyticsDB := &ProductionDatabase{config: DefaultProductionConfig()}
+
+	manager := NewDatabaseManager("app")
+	manager.Add("app", appDB)
+	manager.Add("analytics", analyticsDB)
+
+	manager.RegisterModelDatabase(&managerTestUser{}, "app")
+	manager.RegisterModelDatabase(managerTestEvent{}, "analytics")
+
+	assert.Same(t, appDB, manager.For(&managerTestUser{}))
+	assert.Same(t, analyticsDB, manager.For(&managerTestEvent{}))
+
+	// Slices and values resolve to the same registration as pointers
+	assert.Same(t, analyticsDB, manager.For([]managerTestEvent{}))
+	assert.Same(t, analyticsDB, manager.For(&[]*managerTestEvent{}))
+	assert.Same(t, appDB, manager.For(managerTestUser{}))
+}
+
+func TestDatabaseManager_UnregisteredModelUsesDefault(t *testing.T) {
+	appDB := &ProductionDatabase{config: DefaultProductionConfig()}
+	analyticsDB := &ProductionDatabase{config: DefaultProductionConfig()}
+
+	manager := NewDatabaseManager("app")
+	manager.Add("app", appDB)
+	manager.Add("analytics", analyticsDB)
+	manager.RegisterModelDatabase(&managerTestEvent{}, "analytics")
+
+	assert.Same(t, appDB, manager.For(&managerTestUnregistered{}))
+	assert.Same(t, appDB, manager.Default())
+
+	// A registration pointing at an unknown database falls back to the default
+	manager.RegisterModelDatabase(&managerTestUser{}, "missing")
+	assert.Same(t, appDB, manager.For(&managerTestUser{}))
+
+	db, ok := manager.Get("analytics")
+	require.True(t, ok)
+	assert.Same(t, analyticsDB, db)
+	assert.ElementsMatch(t, []string{"app", "analytics"}, manager.Names())
+}
+
+func TestDatabaseManager_AllStatsCoversEveryDatabase(t *testing.T) {
+	appDB := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "app"))
+	analyticsDB := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "analytics"))
+	require.NoError(t, appDB.GetDB().Exec("SELECT 1").Error)
+	require.NoError(t, analyticsDB.GetDB().Exec("SELECT 1").Error)
+
+	manager := NewDatabaseManager("app")
+	manager.Add("app", appDB)
+	manager.Add("analytics", analyticsDB)
+
+	stats := manager.AllStats()
+	require.Len(t, stats, 2)
+
+	for _, name := range []string{"app", "analytics"} {
+		require.Contains(t, stats, name)
+		primary, ok := stats[name]["primary"].(map[string]interface{})
+		require.True(t, ok, "missing primary pool stats for %s", name)
+		assert.Contains(t, primary, "open_connections")
+		assert.Contains(t, primary, "in_use")
+		assert.GreaterOrEqual(t, primary["open_connections"], 1)
+	}
+}
+
+func TestDatabaseManager_RegisterMetricsLabelsByDatabase(t *testing.T) {
+	manager := NewDatabaseManager("app")
+	manager.Add("app", newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "app")))
+	manager.Add("analytics", newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "analytics")))
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, manager.RegisterMetrics(registry))
+
+	// One series per database for each pool metric
+	collector := newPoolCollector(manager.snapshot)
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "nutrition_platform_db_pool_open_connections"))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "nutrition_platform_db_pool_in_use_connections"))
+}
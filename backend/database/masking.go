@@ -0,0 +1,356 @@
+package database
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// defaultCloneBatchSize is how many rows CloneMasked inserts per statement
+const defaultCloneBatchSize = 500
+
+// Masker replaces a column value with a fake. seed is derived from the value
+// and MaskingSalt, so equal values get equal fakes and joins on them still match.
+// Maskers are not called for NULL values.
+type Masker func(value interface{}, seed uint64) interface{}
+
+var (
+	maskFirstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie",
+		"Avery", "Quinn", "Rowan", "Skyler", "Reese", "Emerson", "Harper", "Dakota"}
+	maskLastNames = []string{"Smith", "Garcia", "Chen", "Okafor", "Novak", "Haddad", "Silva", "Kowalski",
+		"Nguyen", "Murphy", "Rossi", "Tanaka", "Ibrahim", "Larsen", "Patel", "Moreau"}
+	maskWords = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit",
+		"sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "magna"}
+)
+
+// MaskEmail replaces an email address with a fake one at example.com
+func MaskEmail(value interface{}, seed uint64) interface{} {
+	return fmt.Sprintf("user%08x@example.com", uint32(seed))
+}
+
+// MaskName replaces a name with a fake first and last name
+func MaskName(value interface{}, seed uint64) interface{} {
+	return maskFirstNames[seed%uint64(len(maskFirstNames))] + " " +
+		maskLastNames[(seed>>8)%uint64(len(maskLastNames))]
+}
+
+// MaskText replaces free text, e.g. notes, with filler words of about the same length
+func MaskText(value interface{}, seed uint64) interface{} {
+	length := len(fmt.Sprint(value))
+	var text strings.Builder
+	for text.Len() < length {
+		if text.Len() > 0 {
+			text.WriteByte(' ')
+		}
+		text.WriteString(maskWords[seed%uint64(len(maskWords))])
+		seed = seed*6364136223846793005 + 1442695040888963407
+	}
+	return text.String()
+}
+
+// MaskNumber returns a Masker moving numbers, e.g. weights, by up to spread of
+// their value either way (0.1 for ±10%), keeping their type; integers are rounded
+func MaskNumber(spread float64) Masker {
+	return func(value interface{}, seed uint64) interface{} {
+		factor := 1 + spread*(2*float64(seed>>11)/float64(1<<53)-1)
+		switch number := reflect.ValueOf(value); number.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return reflect.ValueOf(math.Round(float64(number.Int()) * factor)).Convert(number.Type()).Interface()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return reflect.ValueOf(math.Round(float64(number.Uint()) * factor)).Convert(number.Type()).Interface()
+		case reflect.Float32, reflect.Float64:
+			return reflect.ValueOf(number.Float() * factor).Convert(number.Type()).Interface()
+		case reflect.String:
+			// NUMERIC columns scan as strings
+			if parsed, err := strconv.ParseFloat(number.String(), 64); err == nil {
+				return strconv.FormatFloat(parsed*factor, 'f', -1, 64)
+			}
+		}
+		return nil
+	}
+}
+
+// MaskNull replaces a value with NULL, or the zero value in a model
+func MaskNull(value interface{}, seed uint64) interface{} {
+	return nil
+}
+
+// masker returns the MaskedColumns rule for column of table, preferring "table.column"
+func (db *ProductionDatabase) masker(table, column string) Masker {
+	if masker, ok := db.config.MaskedColumns[table+"."+column]; ok && table != "" {
+		return masker
+	}
+	return db.config.MaskedColumns[column]
+}
+
+// maskValue fakes value with masker, leaving NULLs alone
+func (db *ProductionDatabase) maskValue(masker Masker, value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return value
+	}
+	value = reflect.Indirect(v).Interface()
+
+	mac := hmac.New(sha256.New, []byte(db.config.MaskingSalt))
+	fmt.Fprint(mac, value)
+	return masker(value, binary.BigEndian.Uint64(mac.Sum(nil)))
+}
+
+// maskRow fakes the masked columns of a row read as a map
+func (db *ProductionDatabase) maskRow(table string, row map[string]interface{}) {
+	for column, value := range row {
+		if masker := db.masker(table, column); masker != nil {
+			row[column] = db.maskValue(masker, value)
+		}
+	}
+}
+
+// maskedReadKey carries the *maskedRead of a statement whose rows are masked
+type maskedReadKey struct{}
+
+// unmaskedReadKey marks a context whose reads are never masked, e.g. CloneMasked's
+// own reads, which apply the rules themselves
+type unmaskedReadKey struct{}
+
+// maskedRead is a statement whose rows the connection masks as the driver returns
+// them; table selects the "table.column" rules and is empty for raw queries
+type maskedRead struct {
+	db    *ProductionDatabase
+	table string
+}
+
+// markMaskedRead tags the statement's context so its rows are masked by column
+// name, whatever they are scanned into: models, DTOs, maps or Pluck slices
+func (db *ProductionDatabase) markMaskedRead(tx *gorm.DB) {
+	ctx := tx.Statement.Context
+	if tx.Error != nil || ctx.Value(unmaskedReadKey{}) != nil {
+		return
+	}
+	tx.Statement.Context = context.WithValue(ctx, maskedReadKey{}, &maskedRead{db: db, table: tx.Statement.Table})
+}
+
+// registerMasking installs the callbacks masking the rows of queries, and of the
+// Row path that Scan, Rows and Raw(...).Scan read through
+func (db *ProductionDatabase) registerMasking(gormDB *gorm.DB) error {
+	callbacks := gormDB.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("database:mask_reads", db.markMaskedRead); err != nil {
+		return fmt.Errorf("failed to register masking callback: %w", err)
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("database:mask_reads", db.markMaskedRead); err != nil {
+		return fmt.Errorf("failed to register masking callback: %w", err)
+	}
+	return nil
+}
+
+// maskDriverRows wraps rows read for a masked statement so their masked columns are faked
+func maskDriverRows(ctx context.Context, rows driver.Rows) driver.Rows {
+	read, ok := ctx.Value(maskedReadKey{}).(*maskedRead)
+	if !ok {
+		return rows
+	}
+
+	columns := rows.Columns()
+	maskers := make([]Masker, len(columns))
+	masked := false
+	for i, column := range columns {
+		maskers[i] = read.db.masker(read.table, column)
+		masked = masked || maskers[i] != nil
+	}
+	if !masked {
+		return rows
+	}
+	return &maskedRows{Rows: rows, db: read.db, maskers: maskers}
+}
+
+// maskedRows fakes the masked columns of each row and forwards the column type
+// interfaces, so scanning behaves as on the driver's own rows
+type maskedRows struct {
+	driver.Rows
+	db      *ProductionDatabase
+	maskers []Masker
+}
+
+// Next reads the next row and fakes its masked columns
+func (r *maskedRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, masker := range r.maskers {
+		if masker == nil {
+			continue
+		}
+		value := dest[i]
+		if raw, ok := value.([]byte); ok {
+			// Text read as bytes must hash like the string a model field holds
+			value = string(raw)
+		}
+		fake, err := driver.DefaultParameterConverter.ConvertValue(r.db.maskValue(masker, value))
+		if err != nil {
+			return fmt.Errorf("failed to mask column %s: %w", r.Columns()[i], err)
+		}
+		dest[i] = fake
+	}
+	return nil
+}
+
+// ColumnTypeScanType forwards to the wrapped rows
+func (r *maskedRows) ColumnTypeScanType(index int) reflect.Type {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return typed.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf((*interface{})(nil)).Elem()
+}
+
+// ColumnTypeDatabaseTypeName forwards to the wrapped rows
+func (r *maskedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return typed.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+// ColumnTypeLength forwards to the wrapped rows
+func (r *maskedRows) ColumnTypeLength(index int) (int64, bool) {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return typed.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+// ColumnTypeNullable forwards to the wrapped rows
+func (r *maskedRows) ColumnTypeNullable(index int) (bool, bool) {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return typed.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+// ColumnTypePrecisionScale forwards to the wrapped rows
+func (r *maskedRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if typed, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return typed.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+// maskedStmt masks the rows of prepared statements, which GORM reuses across
+// statements, so the context of each query decides whether it is masked
+type maskedStmt struct {
+	driver.Stmt
+	conn *hookedConn
+}
+
+// QueryContext runs the statement and masks its rows for masked reads
+func (s *maskedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rows, err = s.Stmt.Query(namedValues(args)) //nolint:staticcheck // fallback for drivers without QueryContext
+	}
+	if err != nil {
+		return nil, err
+	}
+	return maskDriverRows(ctx, rows), nil
+}
+
+// ExecContext forwards to the wrapped statement
+func (s *maskedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(namedValues(args)) //nolint:staticcheck // fallback for drivers without ExecContext
+}
+
+// CheckNamedValue forwards to the wrapped statement, or its connection as database/sql would
+func (s *maskedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return s.conn.CheckNamedValue(value)
+}
+
+// namedValues drops the names of args for the pre-context driver interfaces
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+// CloneMasked copies every row of the models' tables, soft-deleted ones included,
+// into the same tables of target with the MaskedColumns faked, so a staging
+// database can be filled with production-shaped data. The tables must exist in
+// target. It returns how many rows were copied.
+func (db *ProductionDatabase) CloneMasked(ctx context.Context, target *gorm.DB, models ...interface{}) (int64, error) {
+	var copied int64
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db.primary()}
+		if err := stmt.Parse(model); err != nil {
+			return copied, fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+
+		unmasked := context.WithValue(ctx, unmaskedReadKey{}, true)
+		rows, err := db.GetReadDB().WithContext(unmasked).Model(model).Unscoped().Rows()
+		if err != nil {
+			return copied, fmt.Errorf("failed to read %s: %w", stmt.Table, err)
+		}
+
+		n, err := db.cloneMaskedRows(ctx, rows, stmt.Table, target)
+		copied += n
+		if err != nil {
+			return copied, fmt.Errorf("failed to clone %s: %w", stmt.Table, err)
+		}
+	}
+	return copied, nil
+}
+
+// cloneMaskedRows masks rows of table and inserts them into target in batches
+func (db *ProductionDatabase) cloneMaskedRows(ctx context.Context, rows *sql.Rows, table string, target *gorm.DB) (int64, error) {
+	defer rows.Close()
+
+	var copied int64
+	batch := make([]map[string]interface{}, 0, defaultCloneBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := target.WithContext(ctx).Table(table).Create(&batch).Error; err != nil {
+			return err
+		}
+		copied += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	err := scanRowMaps(rows, &resultBudget{}, nil, func(row map[string]interface{}) error {
+		db.maskRow(table, row)
+		batch = append(batch, row)
+		if len(batch) == cap(batch) {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return copied, err
+	}
+	return copied, flush()
+}
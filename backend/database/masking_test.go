@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maskedProfile is a model with personal columns to mask
+type maskedProfile struct {
+	ID       uint `gorm:"primaryKey"`
+	Email    string
+	Name     string
+	WeightKg float64
+	Notes    *string
+}
+
+// maskingTestColumns masks every personal column of maskedProfile
+func maskingTestColumns() map[string]Masker {
+	return map[string]Masker{
+		"email":                     MaskEmail,
+		"masked_profiles.name":      MaskName,
+		"masked_profiles.weight_kg": MaskNumber(0.1),
+		"notes":                     MaskText,
+	}
+}
+
+func TestMaskReads_FakesConfiguredColumnsDeterministically(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaskReads = true
+	config.MaskedColumns = maskingTestColumns()
+	config.MaskingSalt = "staging"
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&maskedProfile{}))
+
+	notes := "struggles with late-night snacking"
+	profiles := []maskedProfile{
+		{Email: "amira@clinic.example", Name: "Amira Haddad", WeightKg: 70, Notes: &notes},
+		{Email: "amira@clinic.example", Name: "Omar Haddad", WeightKg: 82},
+	}
+	require.NoError(t, db.GetDB().Create(&profiles).Error)
+
+	var loaded []maskedProfile
+	require.NoError(t, db.GetDB().Order("id").Find(&loaded).Error)
+	require.Len(t, loaded, 2)
+
+	first := loaded[0]
+	assert.Regexp(t, `^user[0-9a-f]{8}@example\.com$`, first.Email)
+	assert.Equal(t, first.Email, loaded[1].Email, "equal values get equal fakes")
+	assert.NotEqual(t, "Amira Haddad", first.Name)
+	assert.InDelta(t, 70, first.WeightKg, 7)
+	assert.NotEqual(t, 70.0, first.WeightKg)
+	require.NotNil(t, first.Notes)
+	assert.NotContains(t, *first.Notes, "snacking")
+	assert.Nil(t, loaded[1].Notes, "NULLs stay NULL")
+
+	var again maskedProfile
+	require.NoError(t, db.GetDB().First(&again, first.ID).Error)
+	assert.Equal(t, first, again, "fakes are deterministic")
+
+	// Raw queries only match rules keyed by column name
+	rows, err := db.QueryMaps(context.Background(), "SELECT email, name FROM masked_profiles ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, first.Email, rows[0]["email"])
+	assert.Equal(t, "Amira Haddad", rows[0]["name"])
+}
+
+func TestMaskReads_FakesPluckAndScanDestinations(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaskReads = true
+	config.MaskedColumns = maskingTestColumns()
+	config.MaskingSalt = "staging"
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&maskedProfile{}))
+	require.NoError(t, db.GetDB().Create(&maskedProfile{Email: "amira@clinic.example", Name: "Amira Haddad", WeightKg: 70}).Error)
+
+	var model maskedProfile
+	require.NoError(t, db.GetDB().First(&model).Error)
+
+	var emails []string
+	require.NoError(t, db.GetDB().Model(&maskedProfile{}).Pluck("email", &emails).Error)
+	assert.Equal(t, []string{model.Email}, emails)
+
+	type profileDTO struct {
+		Email string
+		Name  string
+	}
+	var selected profileDTO
+	require.NoError(t, db.GetDB().Model(&maskedProfile{}).Select("email", "name").Scan(&selected).Error)
+	assert.Equal(t, profileDTO{Email: model.Email, Name: model.Name}, selected)
+
+	// Raw queries only match rules keyed by column name
+	var raw profileDTO
+	require.NoError(t, db.GetDB().Raw("SELECT email, name FROM masked_profiles").Scan(&raw).Error)
+	assert.Equal(t, model.Email, raw.Email)
+	assert.Equal(t, "Amira Haddad", raw.Name)
+}
+
+func TestCloneMasked_CopiesRowsWithFakes(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaskedColumns = maskingTestColumns()
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&maskedProfile{}))
+	require.NoError(t, db.GetDB().Create(&[]maskedProfile{
+		{Email: "amira@clinic.example", Name: "Amira Haddad", WeightKg: 70},
+		{Email: "omar@clinic.example", Name: "Omar Haddad", WeightKg: 82},
+	}).Error)
+
+	staging := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "staging"))
+	require.NoError(t, staging.Migrate(&maskedProfile{}))
+
+	copied, err := db.CloneMasked(context.Background(), staging.GetDB(), &maskedProfile{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), copied)
+
+	var cloned []maskedProfile
+	require.NoError(t, staging.GetDB().Order("id").Find(&cloned).Error)
+	require.Len(t, cloned, 2)
+	assert.Equal(t, uint(1), cloned[0].ID)
+	assert.Contains(t, cloned[0].Email, "@example.com")
+	assert.NotEqual(t, "Amira Haddad", cloned[0].Name)
+
+	// Reads of the source are not masked without MaskReads
+	var original maskedProfile
+	require.NoError(t, db.GetDB().First(&original, 1).Error)
+	assert.Equal(t, "amira@clinic.example", original.Email)
+}
+
+func TestValidate_RejectsMaskReadsInProduction(t *testing.T) {
+	config := DefaultProductionConfig()
+	config.Environment = "production"
+	config.MaskReads = true
+	assert.Error(t, config.Validate())
+}
+
+func TestMaskNumber_KeepsTypes(t *testing.T) {
+	mask := MaskNumber(0.1)
+	assert.IsType(t, int64(0), mask(int64(80), 42))
+	assert.IsType(t, float32(0), mask(float32(80), 42))
+	assert.IsType(t, "", mask("80.5", 42))
+	assert.Nil(t, mask(true, 42))
+}
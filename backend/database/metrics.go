@@ -0,0 +1,188 @@
+package database
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// PoolCollector exports connection pool statistics as Prometheus metrics,
+// labelled by database name and connection role (primary/replica), along with
+// statement latency summaries labelled by database and operation name and
+// statement error and health check failure counters and the monitored replica lag
+type PoolCollector struct {
+	databases func() map[string]*ProductionDatabase
+
+	maxOpenConnections *prometheus.Desc
+	openConnections    *prometheus.Desc
+	inUse              *prometheus.Desc
+	idle               *prometheus.Desc
+	waitCount          *prometheus.Desc
+	waitDuration       *prometheus.Desc
+	maxIdleClosed      *prometheus.Desc
+	maxIdleTimeClosed  *prometheus.Desc
+	maxLifetimeClosed  *prometheus.Desc
+	queryDuration      *prometheus.Desc
+	statementTimeouts  *prometheus.Desc
+	statementErrors    *prometheus.Desc
+	healthFailures     *prometheus.Desc
+	replicaLag         *prometheus.Desc
+}
+
+// newPoolCollector creates a collector over the databases returned by the source function
+func newPoolCollector(databases func() map[string]*ProductionDatabase) *PoolCollector {
+	labels := []string{"database", "role"}
+
+	return &PoolCollector{
+		databases: databases,
+		maxOpenConnections: prometheus.NewDesc(
+			"nutrition_platform_db_pool_max_open_connections",
+			"Maximum number of open connections to the database",
+			labels, nil,
+		),
+		openConnections: prometheus.NewDesc(
+			"nutrition_platform_db_pool_open_connections",
+			"Number of established connections, both in use and idle",
+			labels, nil,
+		),
+		inUse: prometheus.NewDesc(
+			"nutrition_platform_db_pool_in_use_connections",
+			"Number of connections currently in use",
+			labels, nil,
+		),
+		idle: prometheus.NewDesc(
+			"nutrition_platform_db_pool_idle_connections",
+			"Number of idle connections",
+			labels, nil,
+		),
+		waitCount: prometheus.NewDesc(
+			"nutrition_platform_db_pool_wait_count_total",
+			"Total number of connections waited for",
+			labels, nil,
+		),
+		waitDuration: prometheus.NewDesc(
+			"nutrition_platform_db_pool_wait_duration_seconds_total",
+			"Total time blocked waiting for a new connection",
+			labels, nil,
+		),
+		maxIdleClosed: prometheus.NewDesc(
+			"nutrition_platform_db_pool_max_idle_closed_total",
+			"Total number of connections closed due to SetMaxIdleConns",
+			labels, nil,
+		),
+		maxIdleTimeClosed: prometheus.NewDesc(
+			"nutrition_platform_db_pool_max_idle_time_closed_total",
+			"Total number of connections closed due to SetConnMaxIdleTime",
+			labels, nil,
+		),
+		maxLifetimeClosed: prometheus.NewDesc(
+			"nutrition_platform_db_pool_max_lifetime_closed_total",
+			"Total number of connections closed due to SetConnMaxLifetime",
+			labels, nil,
+		),
+		queryDuration: prometheus.NewDesc(
+			"nutrition_platform_db_query_duration_seconds",
+			"Statement latency; operation \"all\" covers every statement",
+			[]string{"database", "operation"}, nil,
+		),
+		statementTimeouts: prometheus.NewDesc(
+			"nutrition_platform_db_statement_timeouts_total",
+			"Total number of statements cancelled by statement_timeout",
+			[]string{"database"}, nil,
+		),
+		statementErrors: prometheus.NewDesc(
+			"nutrition_platform_db_statement_errors_total",
+			"Total number of failed statements by error class (see ClassifyError)",
+			[]string{"database", "class"}, nil,
+		),
+		healthFailures: prometheus.NewDesc(
+			"nutrition_platform_db_health_check_failures_total",
+			"Total number of health checks that found the primary unhealthy",
+			[]string{"database"}, nil,
+		),
+		replicaLag: prometheus.NewDesc(
+			"nutrition_platform_db_replica_lag_seconds",
+			"Replica replication lag last measured by the lag monitor (see ReplicaLagInterval)",
+			[]string{"database"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpenConnections
+	ch <- c.openConnections
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+	ch <- c.queryDuration
+	ch <- c.statementTimeouts
+	ch <- c.statementErrors
+	ch <- c.healthFailures
+	ch <- c.replicaLag
+}
+
+// Collect implements prometheus.Collector
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, db := range c.databases() {
+		for role, stats := range db.metricsPoolStats() {
+			ch <- prometheus.MustNewConstMetric(c.maxOpenConnections, prometheus.GaugeValue, float64(stats.MaxOpenConnections), name, role)
+			ch <- prometheus.MustNewConstMetric(c.openConnections, prometheus.GaugeValue, float64(stats.OpenConnections), name, role)
+			ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse), name, role)
+			ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle), name, role)
+			ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), name, role)
+			ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), name, role)
+			ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed), name, role)
+			ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed), name, role)
+			ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed), name, role)
+		}
+		ch <- prometheus.MustNewConstMetric(c.statementTimeouts, prometheus.CounterValue, float64(db.StatementTimeoutTotal()), name)
+		ch <- prometheus.MustNewConstMetric(c.healthFailures, prometheus.CounterValue, float64(db.HealthCheckFailures()), name)
+		if lag, ok := db.MonitoredReplicaLag(); ok {
+			ch <- prometheus.MustNewConstMetric(c.replicaLag, prometheus.GaugeValue, lag.Seconds(), name)
+		}
+		for class, count := range db.StatementErrorsByClass() {
+			ch <- prometheus.MustNewConstMetric(c.statementErrors, prometheus.CounterValue, float64(count), name, string(class))
+		}
+
+		if db.latency != nil {
+			// Build the summaries under the tracker lock but send them after releasing it
+			var summaries []prometheus.Metric
+			db.latency.visit(func(operation string, histogram *latencyHistogram) {
+				quantiles := map[float64]float64{
+					0.5:  histogram.quantile(0.5).Seconds(),
+					0.95: histogram.quantile(0.95).Seconds(),
+					0.99: histogram.quantile(0.99).Seconds(),
+				}
+				summaries = append(summaries, prometheus.MustNewConstSummary(c.queryDuration, histogram.count, histogram.sum.Seconds(), quantiles, name, operation))
+			})
+			for _, summary := range summaries {
+				ch <- summary
+			}
+		}
+	}
+}
+
+// RegisterMetrics registers a pool collector for db, with metrics labelled database=name
+// Databases run through a DatabaseManager are covered by its RegisterMetrics instead.
+func (db *ProductionDatabase) RegisterMetrics(registerer prometheus.Registerer, name string) error {
+	return registerer.Register(newPoolCollector(func() map[string]*ProductionDatabase {
+		return map[string]*ProductionDatabase{name: db}
+	}))
+}
+
+// MetricsHandler serves the metrics of db labelled database=name on a registry of
+// its own, for applications without a Prometheus registry of their own to mount
+// under /metrics
+func (db *ProductionDatabase) MetricsHandler(name string) (http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	if err := db.RegisterMetrics(registry, name); err != nil {
+		return nil, err
+	}
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// tickerFunc starts a ticker firing every d, returning its channel and a stop function
+// Tests substitute it to drive the health checker's tickers by hand.
+type tickerFunc func(d time.Duration) (<-chan time.Time, func())
+
+// newTimeTicker is the tickerFunc backed by time.Ticker
+func newTimeTicker(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+// samplePoolMetrics snapshots the pool statistics PoolCollector exports
+func (db *ProductionDatabase) samplePoolMetrics() {
+	stats := db.poolStats()
+
+	db.metricsMu.Lock()
+	defer db.metricsMu.Unlock()
+	db.metricsSnapshot = stats
+}
+
+// metricsPoolStats returns the pool statistics to export: the latest snapshot when
+// MetricsSampleInterval is set and one has been taken, otherwise live statistics
+func (db *ProductionDatabase) metricsPoolStats() map[string]sql.DBStats {
+	if db.config.MetricsSampleInterval > 0 {
+		db.metricsMu.RLock()
+		snapshot := db.metricsSnapshot
+		db.metricsMu.RUnlock()
+
+		if snapshot != nil {
+			return snapshot
+		}
+	}
+	return db.poolStats()
+}
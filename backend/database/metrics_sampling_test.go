@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// manualTickers hands out unbuffered tick channels keyed by interval, so a test
+// fires each ticker explicitly and a send returns once the checker has taken it
+type manualTickers struct {
+	mu    sync.Mutex
+	ticks map[time.Duration]chan time.Time
+}
+
+func (m *manualTickers) newTicker(d time.Duration) (<-chan time.Time, func()) {
+	return m.channel(d), func() {}
+}
+
+func (m *manualTickers) channel(d time.Duration) chan time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ticks == nil {
+		m.ticks = make(map[time.Duration]chan time.Time)
+	}
+	if _, ok := m.ticks[d]; !ok {
+		m.ticks[d] = make(chan time.Time)
+	}
+	return m.ticks[d]
+}
+
+// tick fires the ticker for d and waits for the checker to take the tick
+func (m *manualTickers) tick(d time.Duration) {
+	m.channel(d) <- time.Now()
+}
+
+func TestMetricsSampleInterval_IndependentOfHealthChecks(t *testing.T) {
+	const (
+		healthInterval  = time.Hour
+		metricsInterval = time.Minute
+	)
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.HealthCheckInterval = healthInterval
+	config.MetricsSampleInterval = metricsInterval
+	db := newSQLiteTestDatabase(t, config)
+
+	// Replace the running checker with one on hand-driven tickers
+	tickers := &manualTickers{}
+	db.healthChecker.Stop()
+	db.healthChecker = &HealthChecker{
+		db:        db,
+		interval:  healthInterval,
+		timeout:   time.Second,
+		stop:      make(chan bool),
+		newTicker: tickers.newTicker,
+	}
+	go db.healthChecker.Start()
+
+	// The initial snapshot is taken before the first tick is accepted
+	tickers.tick(metricsInterval)
+	assert.Equal(t, 0, db.metricsPoolStats()["primary"].InUse)
+
+	conn, err := db.primaryPool().Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Health checks do not refresh the snapshot; the second tick waits for the first to finish
+	tickers.tick(healthInterval)
+	tickers.tick(healthInterval)
+	assert.Equal(t, 0, db.metricsPoolStats()["primary"].InUse, "the snapshot must not refresh on health checks")
+
+	// The metrics ticker does, once its tick has been handled
+	tickers.tick(metricsInterval)
+	tickers.tick(healthInterval)
+	assert.Equal(t, 1, db.metricsPoolStats()["primary"].InUse)
+}
+
+func TestMetricsPoolStats_LiveWithoutSampling(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	conn, err := db.primaryPool().Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, 1, db.metricsPoolStats()["primary"].InUse)
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPoolCollector_CountsStatementErrorsByClass(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	require.Error(t, db.GetDB().Exec("SELECT * FROM missing_table").Error)
+	var count int64
+	require.NoError(t, db.GetDB().Raw("SELECT 1").Scan(&count).Error)
+
+	// SQLite errors carry no SQLSTATE
+	assert.Equal(t, map[ErrorClass]int64{ErrorClassUnknown: 1}, db.StatementErrorsByClass())
+
+	collector := newPoolCollector(func() map[string]*ProductionDatabase {
+		return map[string]*ProductionDatabase{"app": db}
+	})
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "nutrition_platform_db_statement_errors_total"))
+}
+
+func TestMetricsHandler_ServesPoolAndHealthMetrics(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	// A failed health check is counted
+	require.NoError(t, db.primaryPool().Close())
+	db.healthChecker.check()
+	assert.Equal(t, int64(1), db.HealthCheckFailures())
+
+	handler, err := db.MetricsHandler("app")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "nutrition_platform_db_pool_open_connections")
+	assert.Contains(t, body, "nutrition_platform_db_pool_wait_duration_seconds_total")
+	assert.Contains(t, body, "nutrition_platform_db_health_check_failures_total")
+}
+
+func TestStats_IncludesErrorAndHealthCounters(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	db.statementErrors.record(errors.New("boom"))
+
+	stats := db.Stats()
+	assert.Equal(t, map[ErrorClass]int64{ErrorClassUnknown: 1}, stats["statement_errors"])
+	assert.Equal(t, int64(0), stats["health_check_failures"])
+}
+
+func TestPoolCollector_ExportsMonitoredReplicaLag(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	db := newSQLiteTestDatabase(t, config)
+	db.lagProbe = func(context.Context, *gorm.DB) (time.Duration, error) { return 3 * time.Second, nil }
+
+	collector := newPoolCollector(func() map[string]*ProductionDatabase {
+		return map[string]*ProductionDatabase{"app": db}
+	})
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "nutrition_platform_db_replica_lag_seconds"))
+
+	require.NoError(t, db.monitorReplicaLag(context.Background()))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "nutrition_platform_db_replica_lag_seconds"))
+}
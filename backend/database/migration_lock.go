@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MigrationLockPolicy decides what an instance does when another one holds the migration lock
+type MigrationLockPolicy int
+
+const (
+	// WaitForMigrationLock waits for the lock, up to MigrationLockTimeout, then migrates
+	WaitForMigrationLock MigrationLockPolicy = iota
+
+	// SkipIfMigrationLocked returns straight away without migrating, leaving the
+	// migrations to the instance holding the lock
+	SkipIfMigrationLocked
+)
+
+// migrationLockKey is the advisory lock key that serialises schema migrations across instances
+const migrationLockKey int64 = 4206841130126077953
+
+// migrationLockPollInterval is how often a bounded lock acquisition retries pg_try_advisory_lock
+const migrationLockPollInterval = 250 * time.Millisecond
+
+// withMigrationLock runs fn holding the migration lock
+// Under SkipIfMigrationLocked, fn is skipped without error while another instance
+// holds the lock.
+func (db *ProductionDatabase) withMigrationLock(ctx context.Context, fn func() error) error {
+	release, err := db.acquireMigrationLock(ctx)
+	if errors.Is(err, errMigrationLocked) {
+		db.logger().Info("Another instance holds the migration lock, skipping migrations")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return fn()
+}
+
+// errMigrationLocked is returned by acquireMigrationLock under SkipIfMigrationLocked
+// while another instance holds the lock
+var errMigrationLocked = errors.New("database: migration lock is held by another instance")
+
+// acquireMigrationLock takes the migration advisory lock on a dedicated connection
+// With MigrationLockTimeout set it polls pg_try_advisory_lock until the deadline and
+// returns ErrMigrationLockTimeout; otherwise it blocks on pg_advisory_lock. Under
+// SkipIfMigrationLocked it tries once and returns errMigrationLocked.
+// Databases other than Postgres have no advisory locks and are not locked.
+func (db *ProductionDatabase) acquireMigrationLock(ctx context.Context) (release func(), err error) {
+	if db.primary().Dialector.Name() != "postgres" {
+		return func() {}, nil
+	}
+
+	conn, err := db.primaryPool().Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for migration lock: %w", err)
+	}
+
+	release = func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			db.logger().Error("Failed to release migration lock", "error", err)
+		}
+		conn.Close()
+	}
+
+	timeout := db.config.MigrationLockTimeout
+	if db.config.MigrationLockPolicy == SkipIfMigrationLocked {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&acquired); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if !acquired {
+			conn.Close()
+			return nil, errMigrationLocked
+		}
+		return release, nil
+	}
+	if timeout <= 0 {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		return release, nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&acquired); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if acquired {
+			return release, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			conn.Close()
+			return nil, fmt.Errorf("%w after %v", ErrMigrationLockTimeout, timeout)
+		}
+
+		wait := migrationLockPollInterval
+		if remaining < wait {
+			wait = remaining
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		}
+	}
+}
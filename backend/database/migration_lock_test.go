@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationLock_TimesOutWhileHeldElsewhere(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	db.config.MigrationLockTimeout = 500 * time.Millisecond
+	ctx := context.Background()
+
+	holder, err := db.sqlDB.Conn(ctx)
+	require.NoError(t, err)
+	defer holder.Close()
+
+	locked := make(chan struct{})
+	unlock := make(chan struct{})
+	go func() {
+		_, err := holder.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+		assert.NoError(t, err)
+		close(locked)
+		<-unlock
+		holder.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	}()
+	<-locked
+
+	start := time.Now()
+	_, err = db.acquireMigrationLock(ctx)
+	assert.ErrorIs(t, err, ErrMigrationLockTimeout)
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+
+	assert.ErrorIs(t, db.Migrate(), ErrMigrationLockTimeout)
+
+	close(unlock)
+	assert.Eventually(t, func() bool {
+		release, err := db.acquireMigrationLock(ctx)
+		if err != nil {
+			return false
+		}
+		release()
+		return true
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestMigrationLock_SkipIfLockedReturnsWithoutMigrating(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	db.config.MigrationLockPolicy = SkipIfMigrationLocked
+	db.config.Migrations = []Migration{{
+		Version: 1,
+		Name:    "create_skipped_lock_test",
+		Up:      "CREATE TABLE skipped_lock_test (id int)",
+		Down:    "DROP TABLE skipped_lock_test",
+	}}
+	ctx := context.Background()
+
+	// Another instance is migrating
+	holder, err := db.sqlDB.Conn(ctx)
+	require.NoError(t, err)
+	defer holder.Close()
+	_, err = holder.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, db.MigrateUp(ctx))
+	assert.NoError(t, db.Migrate())
+	assert.Less(t, time.Since(start), time.Second, "locked migrations must be skipped, not waited for")
+
+	var exists bool
+	require.NoError(t, db.sqlDB.QueryRowContext(ctx, "SELECT to_regclass('skipped_lock_test') IS NOT NULL").Scan(&exists))
+	assert.False(t, exists, "the skipping instance must not migrate")
+
+	// Once the lock is free the instance migrates
+	_, err = holder.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	require.NoError(t, err)
+	require.NoError(t, db.MigrateUp(ctx))
+	t.Cleanup(func() { db.MigrateTo(context.Background(), 0) })
+
+	require.NoError(t, db.sqlDB.QueryRowContext(ctx, "SELECT to_regclass('skipped_lock_test') IS NOT NULL").Scan(&exists))
+	assert.True(t, exists)
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// namedQuerySettingKey carries a named query's name from Named to the statement callbacks
+const namedQuerySettingKey = "database:named_query"
+
+// namedQueryMarker starts a query in a .sql file holding several, e.g. "-- name: get_user_macros"
+const namedQueryMarker = "-- name:"
+
+// QueryRegistry holds raw SQL statements by name, so they are defined and reviewed
+// in one place and instrumented per name. Statements use ? placeholders.
+type QueryRegistry struct {
+	mu      sync.RWMutex
+	queries map[string]string
+}
+
+// NewQueryRegistry returns an empty registry
+func NewQueryRegistry() *QueryRegistry {
+	return &QueryRegistry{queries: make(map[string]string)}
+}
+
+// Register adds the statement sql under name, which must not be taken
+func (r *QueryRegistry) Register(name, sql string) error {
+	name, sql = strings.TrimSpace(name), strings.TrimSpace(sql)
+	if name == "" || sql == "" {
+		return fmt.Errorf("named query %q needs a name and SQL", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, taken := r.queries[name]; taken {
+		return fmt.Errorf("named query %q is already registered", name)
+	}
+	r.queries[name] = sql
+	return nil
+}
+
+// Load registers the statements of the .sql files in fsys matching pattern, e.g.
+// an embed.FS and "queries/*.sql". A file holds one statement named after the file,
+// or several, each after a "-- name: <name>" line.
+func (r *QueryRegistry) Load(fsys fs.FS, pattern string) error {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return fmt.Errorf("invalid query file pattern: %w", err)
+	}
+
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to read query file %s: %w", file, err)
+		}
+		for name, sql := range parseQueryFile(strings.TrimSuffix(path.Base(file), ".sql"), string(data)) {
+			if err := r.Register(name, sql); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SQL returns the statement registered under name
+func (r *QueryRegistry) SQL(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sql, ok := r.queries[name]
+	return sql, ok
+}
+
+// Names returns the registered names in order
+func (r *QueryRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.queries))
+	for name := range r.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseQueryFile splits a query file into its statements, naming a file without
+// name markers after the file
+func parseQueryFile(fileName, data string) map[string]string {
+	queries := make(map[string]string)
+	name := fileName
+	var sql strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(sql.String()); text != "" {
+			queries[name] = text
+		}
+		sql.Reset()
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		if marker, ok := strings.CutPrefix(strings.TrimSpace(line), namedQueryMarker); ok {
+			flush()
+			name = strings.TrimSpace(marker)
+			continue
+		}
+		sql.WriteString(line)
+		sql.WriteString("\n")
+	}
+	flush()
+	return queries
+}
+
+// Named returns the statement registered in Queries under name with args bound,
+// ready to Scan, Rows or Row. Read-only statements go to a replica as
+// GetReadDBContext decides, others to the primary. The statement runs with name as
+// its operation name (see WithOperationName), is counted in NamedQueryStats and,
+// with PrepareStmt, is prepared once per connection. Unknown names fail with
+// ErrUnknownQuery.
+func (db *ProductionDatabase) Named(ctx context.Context, name string, args ...interface{}) *gorm.DB {
+	sql, ok := db.namedSQL(name)
+	if !ok {
+		tx := db.primary().WithContext(ctx)
+		tx.AddError(fmt.Errorf("%w: %s", ErrUnknownQuery, name))
+		return tx
+	}
+
+	ctx = WithOperationName(ctx, name)
+	conn := db.primary()
+	if isReadOnlySQL(sql) {
+		conn = db.GetReadDBContext(ctx)
+	}
+	return conn.WithContext(ctx).Set(namedQuerySettingKey, name).Raw(sql, args...)
+}
+
+// NamedExec runs the statement registered in Queries under name on the primary,
+// instrumented as Named's are, returning how many rows it affected
+func (db *ProductionDatabase) NamedExec(ctx context.Context, name string, args ...interface{}) (int64, error) {
+	sql, ok := db.namedSQL(name)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownQuery, name)
+	}
+
+	ctx = WithOperationName(ctx, name)
+	result := db.primary().WithContext(ctx).Set(namedQuerySettingKey, name).Exec(sql, args...)
+	return result.RowsAffected, result.Error
+}
+
+// namedSQL returns the statement registered in Queries under name
+func (db *ProductionDatabase) namedSQL(name string) (string, bool) {
+	if db.config.Queries == nil {
+		return "", false
+	}
+	return db.config.Queries.SQL(name)
+}
+
+// NamedQueryStat counts the statements run under one query name
+type NamedQueryStat struct {
+	Calls  int64 `json:"calls"`
+	Errors int64 `json:"errors"`
+}
+
+// namedQueryStats accumulates NamedQueryStat per query name
+type namedQueryStats struct {
+	mu     sync.Mutex
+	byName map[string]NamedQueryStat
+}
+
+// record counts one statement of the named query, failed unless err is nil or a missing row
+func (s *namedQueryStats) record(name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byName == nil {
+		s.byName = make(map[string]NamedQueryStat)
+	}
+	stat := s.byName[name]
+	stat.Calls++
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		stat.Errors++
+	}
+	s.byName[name] = stat
+}
+
+// NamedQueryStats returns how many statements ran, and failed, per query name
+func (db *ProductionDatabase) NamedQueryStats() map[string]NamedQueryStat {
+	db.namedQueryStats.mu.Lock()
+	defer db.namedQueryStats.mu.Unlock()
+
+	stats := make(map[string]NamedQueryStat, len(db.namedQueryStats.byName))
+	for name, stat := range db.namedQueryStats.byName {
+		stats[name] = stat
+	}
+	return stats
+}
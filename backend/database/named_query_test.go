@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRegistry_LoadsQueryFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"queries/get_user_macros.sql": {Data: []byte("SELECT protein, carbs, fat\nFROM macros WHERE user_id = ?\n")},
+		"queries/meals.sql": {Data: []byte("-- name: insert_meal\nINSERT INTO meals (name) VALUES (?)\n\n" +
+			"-- name: count_meals\nSELECT count(*) FROM meals\n")},
+		"queries/README.md": {Data: []byte("not a query")},
+	}
+
+	registry := NewQueryRegistry()
+	require.NoError(t, registry.Load(fsys, "queries/*.sql"))
+	assert.Equal(t, []string{"count_meals", "get_user_macros", "insert_meal"}, registry.Names())
+
+	sql, ok := registry.SQL("get_user_macros")
+	require.True(t, ok)
+	assert.Equal(t, "SELECT protein, carbs, fat\nFROM macros WHERE user_id = ?", sql)
+
+	// Names are unique
+	assert.Error(t, registry.Load(fsys, "queries/meals.sql"))
+}
+
+func TestNamed_RunsRegisteredQueriesByName(t *testing.T) {
+	registry := NewQueryRegistry()
+	require.NoError(t, registry.Register("insert_meal", "INSERT INTO meals (name) VALUES (?)"))
+	require.NoError(t, registry.Register("count_meals", "SELECT count(*) FROM meals WHERE name = ?"))
+	require.NoError(t, registry.Register("broken", "SELECT * FROM missing_table"))
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.Queries = registry
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE meals (id integer PRIMARY KEY, name text)").Error)
+	ctx := context.Background()
+
+	affected, err := db.NamedExec(ctx, "insert_meal", "salad")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	var count int64
+	require.NoError(t, db.Named(ctx, "count_meals", "salad").Scan(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	assert.Error(t, db.Named(ctx, "broken").Scan(&count).Error)
+	assert.ErrorIs(t, db.Named(ctx, "missing").Scan(&count).Error, ErrUnknownQuery)
+	_, err = db.NamedExec(ctx, "missing")
+	assert.ErrorIs(t, err, ErrUnknownQuery)
+
+	assert.Equal(t, map[string]NamedQueryStat{
+		"insert_meal": {Calls: 1},
+		"count_meals": {Calls: 1},
+		"broken":      {Calls: 1, Errors: 1},
+	}, db.NamedQueryStats())
+	assert.Contains(t, db.Stats(), "named_queries")
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// defaultNotifyReconnectInterval is the first LISTEN reconnect delay used when NotifyReconnectInterval is unset
+	defaultNotifyReconnectInterval = time.Second
+
+	// defaultNotifyMaxReconnectInterval caps the LISTEN reconnect delay when NotifyMaxReconnectInterval is unset
+	defaultNotifyMaxReconnectInterval = time.Minute
+
+	// defaultNotificationBuffer is the Subscribe channel buffer used when NotificationBuffer is unset
+	defaultNotificationBuffer = 64
+)
+
+// Notification is a message published on a LISTEN/NOTIFY channel
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+// notifier fans notifications from the LISTEN connection out to subscribers
+type notifier struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Notification]struct{}
+	resyncHooks map[string][]func(ctx context.Context)
+
+	// listener is the dedicated LISTEN connection, started by the first Postgres Subscribe
+	listener *pq.Listener
+
+	// listenMu serialises LISTEN and UNLISTEN so they follow the subscriber count
+	listenMu sync.Mutex
+
+	// dropped counts notifications a full subscriber channel could not take
+	dropped int64
+}
+
+// Subscribe returns a channel receiving the notifications published on channel
+// until ctx is done or the database is closed, when it is closed. On Postgres the
+// notifications arrive over one dedicated LISTEN connection shared by every
+// subscriber, which reconnects with backoff when lost; notifications sent while
+// it was down are missed, so register OnNotificationResync hooks to catch up.
+// Notifications a subscriber is too slow to take are dropped. Other databases
+// only deliver notifications published by this process.
+func (db *ProductionDatabase) Subscribe(ctx context.Context, channel string) <-chan Notification {
+	buffer := db.config.NotificationBuffer
+	if buffer <= 0 {
+		buffer = defaultNotificationBuffer
+	}
+	subscription := make(chan Notification, buffer)
+	if db.maintenanceCtx.Err() != nil {
+		close(subscription)
+		return subscription
+	}
+
+	n := &db.notifications
+	n.mu.Lock()
+	if n.subscribers == nil {
+		n.subscribers = make(map[string]map[chan Notification]struct{})
+	}
+	if n.subscribers[channel] == nil {
+		n.subscribers[channel] = make(map[chan Notification]struct{})
+	}
+	n.subscribers[channel][subscription] = struct{}{}
+	first := len(n.subscribers[channel]) == 1
+	if n.listener == nil && db.primary().Dialector.Name() == "postgres" {
+		n.listener = db.startListener()
+	}
+	n.mu.Unlock()
+
+	if first {
+		go db.syncListen(channel)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-db.maintenanceCtx.Done():
+		}
+		db.unsubscribe(channel, subscription)
+	}()
+	return subscription
+}
+
+// unsubscribe removes and closes a subscription, unlistening once its channel has no subscribers
+func (db *ProductionDatabase) unsubscribe(channel string, subscription chan Notification) {
+	n := &db.notifications
+	n.mu.Lock()
+	if _, ok := n.subscribers[channel][subscription]; !ok {
+		n.mu.Unlock()
+		return
+	}
+	delete(n.subscribers[channel], subscription)
+	close(subscription)
+	last := len(n.subscribers[channel]) == 0
+	if last {
+		delete(n.subscribers, channel)
+	}
+	n.mu.Unlock()
+
+	if last {
+		go db.syncListen(channel)
+	}
+}
+
+// syncListen LISTENs on channel while it has subscribers and UNLISTENs once it
+// has none. LISTEN blocks while the connection is down, so it runs in the background.
+func (db *ProductionDatabase) syncListen(channel string) {
+	n := &db.notifications
+	n.listenMu.Lock()
+	defer n.listenMu.Unlock()
+
+	n.mu.Lock()
+	listener, wanted := n.listener, len(n.subscribers[channel]) > 0 && db.maintenanceCtx.Err() == nil
+	n.mu.Unlock()
+	if listener == nil {
+		return
+	}
+
+	var err error
+	if wanted {
+		if err = listener.Listen(channel); errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			err = nil
+		}
+	} else if err = listener.Unlisten(channel); errors.Is(err, pq.ErrChannelNotOpen) {
+		err = nil
+	}
+	if err != nil && db.maintenanceCtx.Err() == nil {
+		db.logger().Error("Failed to update notification channel", "channel", channel, "listen", wanted, "error", err)
+	}
+}
+
+// Notify publishes payload on channel. On Postgres it is delivered to listeners
+// once the publishing transaction commits; other databases deliver it to this
+// process's subscribers at once.
+func (db *ProductionDatabase) Notify(ctx context.Context, channel, payload string) error {
+	if db.primary().Dialector.Name() != "postgres" {
+		db.deliverNotification(Notification{Channel: channel, Payload: payload})
+		return nil
+	}
+	if err := db.primary().WithContext(ctx).Exec("SELECT pg_notify(?, ?)", channel, payload).Error; err != nil {
+		return fmt.Errorf("failed to notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// OnNotificationResync registers fn to run after the LISTEN connection was
+// re-established, so subscribers of channel can reload what they may have missed
+func (db *ProductionDatabase) OnNotificationResync(channel string, fn func(ctx context.Context)) {
+	n := &db.notifications
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.resyncHooks == nil {
+		n.resyncHooks = make(map[string][]func(ctx context.Context))
+	}
+	n.resyncHooks[channel] = append(n.resyncHooks[channel], fn)
+}
+
+// NotificationsDropped returns how many notifications were dropped because a subscriber fell behind
+func (db *ProductionDatabase) NotificationsDropped() int64 {
+	return atomic.LoadInt64(&db.notifications.dropped)
+}
+
+// startListener opens the LISTEN connection and delivers its notifications until
+// the database is closed; called with notifications.mu held
+func (db *ProductionDatabase) startListener() *pq.Listener {
+	minInterval := db.config.NotifyReconnectInterval
+	if minInterval <= 0 {
+		minInterval = defaultNotifyReconnectInterval
+	}
+	maxInterval := db.config.NotifyMaxReconnectInterval
+	if maxInterval <= 0 {
+		maxInterval = defaultNotifyMaxReconnectInterval
+	}
+
+	primaryURL, _ := db.regionURLs()
+	listener := pq.NewListener(primaryURL, minInterval, maxInterval, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			db.logger().Warn("Notification listener disconnected", "error", err)
+		case pq.ListenerEventConnectionAttemptFailed:
+			db.logger().Warn("Notification listener failed to reconnect", "error", err)
+		case pq.ListenerEventReconnected:
+			db.logger().Info("Notification listener reconnected")
+		}
+	})
+
+	db.maintenance.Add(1)
+	go func() {
+		defer db.maintenance.Done()
+		for {
+			select {
+			case notification, ok := <-listener.NotificationChannel():
+				if !ok {
+					return
+				}
+				// The listener sends nil once it reconnected
+				if notification == nil {
+					db.resyncNotifications()
+					continue
+				}
+				db.deliverNotification(Notification{Channel: notification.Channel, Payload: notification.Extra})
+			case <-db.maintenanceCtx.Done():
+				listener.Close()
+				return
+			}
+		}
+	}()
+	return listener
+}
+
+// deliverNotification hands notification to every subscriber of its channel that has room
+func (db *ProductionDatabase) deliverNotification(notification Notification) {
+	n := &db.notifications
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for subscription := range n.subscribers[notification.Channel] {
+		select {
+		case subscription <- notification:
+		default:
+			atomic.AddInt64(&n.dropped, 1)
+			db.logger().Warn("Dropped notification for slow subscriber", "channel", notification.Channel)
+		}
+	}
+}
+
+// resyncNotifications runs the resync hooks of every channel in the background
+func (db *ProductionDatabase) resyncNotifications() {
+	n := &db.notifications
+	n.mu.Lock()
+	var hooks []func(ctx context.Context)
+	for _, channelHooks := range n.resyncHooks {
+		hooks = append(hooks, channelHooks...)
+	}
+	n.mu.Unlock()
+
+	for _, hook := range hooks {
+		db.maintenance.Add(1)
+		go func() {
+			defer db.maintenance.Done()
+			hook(db.maintenanceCtx)
+		}()
+	}
+}
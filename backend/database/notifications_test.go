@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiveNotification waits for the next notification on subscription
+func receiveNotification(t *testing.T, subscription <-chan Notification) Notification {
+	t.Helper()
+	select {
+	case notification, ok := <-subscription:
+		require.True(t, ok, "subscription closed")
+		return notification
+	case <-time.After(time.Second):
+		t.Fatal("no notification received")
+		return Notification{}
+	}
+}
+
+func TestNotify_DeliversToSubscribersOfTheChannel(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	meals := db.Subscribe(ctx, "meals")
+	alsoMeals := db.Subscribe(ctx, "meals")
+	plans := db.Subscribe(ctx, "plans")
+
+	require.NoError(t, db.Notify(ctx, "meals", `{"id":7}`))
+	assert.Equal(t, Notification{Channel: "meals", Payload: `{"id":7}`}, receiveNotification(t, meals))
+	assert.Equal(t, Notification{Channel: "meals", Payload: `{"id":7}`}, receiveNotification(t, alsoMeals))
+	assert.Empty(t, plans)
+
+	// Cancelling the subscription's context closes its channel
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, open := <-meals
+		return !open
+	}, time.Second, time.Millisecond)
+}
+
+func TestNotify_DropsNotificationsForSlowSubscribers(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.NotificationBuffer = 1
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	subscription := db.Subscribe(ctx, "meals")
+	require.NoError(t, db.Notify(ctx, "meals", "1"))
+	require.NoError(t, db.Notify(ctx, "meals", "2"))
+
+	assert.Equal(t, "1", receiveNotification(t, subscription).Payload)
+	assert.Equal(t, int64(1), db.NotificationsDropped())
+	assert.Equal(t, int64(1), db.Stats()["notifications_dropped"])
+}
+
+func TestSubscribe_ClosesWhenTheDatabaseCloses(t *testing.T) {
+	db, err := NewProductionDatabase(newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, err)
+
+	subscription := db.Subscribe(context.Background(), "meals")
+	require.NoError(t, db.Close())
+	assert.Eventually(t, func() bool {
+		_, open := <-subscription
+		return !open
+	}, time.Second, time.Millisecond)
+
+	_, open := <-db.Subscribe(context.Background(), "meals")
+	assert.False(t, open)
+}
+
+func TestOnNotificationResync_RunsHooksAfterReconnect(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	resynced := make(chan string, 2)
+	db.OnNotificationResync("meals", func(context.Context) { resynced <- "meals" })
+	db.OnNotificationResync("plans", func(context.Context) { resynced <- "plans" })
+
+	db.resyncNotifications()
+	assert.ElementsMatch(t, []string{"meals", "plans"}, []string{<-resynced, <-resynced})
+}
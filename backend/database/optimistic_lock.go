@@ -0,0 +1,133 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// lockVersionInstanceKey carries the version an update expects from before to after gorm:update
+const lockVersionInstanceKey = "database:lock_version"
+
+// lockVersionType is the field type optimistic locking recognizes
+var lockVersionType = reflect.TypeOf(LockVersion(0))
+
+// LockVersion is the type of the version column optimistic locking maintains
+type LockVersion int64
+
+// OptimisticLock is embedded in models edited concurrently, e.g. from several
+// devices. Every update of a model read from the database increments its version
+// and only applies if the row still has the version the model was read with;
+// otherwise the update fails with ErrStaleObject instead of silently overwriting
+// the other writer's changes. Created models start at version 1.
+type OptimisticLock struct {
+	Version LockVersion `gorm:"not null;default:1" json:"version"`
+}
+
+// registerOptimisticLocking installs the callbacks maintaining LockVersion columns
+func registerOptimisticLocking(gormDB *gorm.DB) error {
+	callbacks := gormDB.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("database:lock_version_create", initLockVersion); err != nil {
+		return fmt.Errorf("failed to register optimistic locking callback: %w", err)
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("database:lock_version_check", checkLockVersion); err != nil {
+		return fmt.Errorf("failed to register optimistic locking callback: %w", err)
+	}
+	if err := callbacks.Update().After("gorm:update").Register("database:lock_version_bump", bumpLockVersion); err != nil {
+		return fmt.Errorf("failed to register optimistic locking callback: %w", err)
+	}
+	return nil
+}
+
+// lockVersionField returns the statement model's LockVersion field, or nil
+func lockVersionField(tx *gorm.DB) *schema.Field {
+	if tx.Statement.Schema == nil {
+		return nil
+	}
+	for _, field := range tx.Statement.Schema.Fields {
+		if field.FieldType == lockVersionType && field.DBName != "" {
+			return field
+		}
+	}
+	return nil
+}
+
+// initLockVersion starts created models without a version at version 1
+func initLockVersion(tx *gorm.DB) {
+	field := lockVersionField(tx)
+	if field == nil || tx.Error != nil {
+		return
+	}
+
+	initialize := func(model reflect.Value) {
+		if _, zero := field.ValueOf(tx.Statement.Context, model); zero {
+			tx.AddError(field.Set(tx.Statement.Context, model, LockVersion(1)))
+		}
+	}
+	switch model := tx.Statement.ReflectValue; model.Kind() {
+	case reflect.Struct:
+		initialize(model)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < model.Len(); i++ {
+			initialize(reflect.Indirect(model.Index(i)))
+		}
+	}
+}
+
+// checkLockVersion makes an update of a model read at version v apply only to
+// rows still at v, setting the version to v+1. Updates without a model version,
+// such as bulk updates by condition with a map, still increment the version.
+func checkLockVersion(tx *gorm.DB) {
+	field := lockVersionField(tx)
+	if field == nil || tx.Error != nil {
+		return
+	}
+	stmt := tx.Statement
+
+	var version LockVersion
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		if value, zero := field.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			version = value.(LockVersion)
+		}
+	}
+
+	if len(stmt.Selects) > 0 {
+		stmt.Selects = append(stmt.Selects, field.DBName)
+	}
+	if version == 0 {
+		if updates, ok := stmt.Dest.(map[string]interface{}); ok {
+			updates[field.DBName] = gorm.Expr("? + 1", clause.Column{Table: clause.CurrentTable, Name: field.DBName})
+		}
+		return
+	}
+
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: version},
+	}})
+	stmt.SetColumn(field.DBName, version+1, true)
+	tx.InstanceSet(lockVersionInstanceKey, version)
+}
+
+// bumpLockVersion fails an update that matched no row at the expected version with
+// ErrStaleObject, leaving the model at the version it was read with
+func bumpLockVersion(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(lockVersionInstanceKey)
+	if !ok {
+		return
+	}
+	field := lockVersionField(tx)
+	version := value.(LockVersion)
+
+	if tx.Error == nil && tx.RowsAffected == 0 && !tx.DryRun {
+		tx.AddError(fmt.Errorf("%w: %s at version %d", ErrStaleObject, tx.Statement.Schema.Name, version))
+	}
+	if tx.Error != nil {
+		_ = field.Set(tx.Statement.Context, tx.Statement.ReflectValue, version)
+		return
+	}
+	// Updates with a map leave the model's version to the callbacks
+	tx.AddError(field.Set(tx.Statement.Context, tx.Statement.ReflectValue, version+1))
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lockedMealPlan struct {
+	ID   int64
+	Name string
+	OptimisticLock
+}
+
+func TestOptimisticLock_RejectsConcurrentUpdates(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&lockedMealPlan{}))
+
+	plan := &lockedMealPlan{Name: "cutting"}
+	require.NoError(t, db.GetDB().Create(plan).Error)
+	assert.Equal(t, LockVersion(1), plan.Version)
+
+	// Two devices load the same plan
+	var phone, laptop lockedMealPlan
+	require.NoError(t, db.GetDB().First(&phone, plan.ID).Error)
+	require.NoError(t, db.GetDB().First(&laptop, plan.ID).Error)
+
+	phone.Name = "bulking"
+	require.NoError(t, db.GetDB().Save(&phone).Error)
+	assert.Equal(t, LockVersion(2), phone.Version)
+
+	laptop.Name = "maintenance"
+	err := db.GetDB().Save(&laptop).Error
+	assert.ErrorIs(t, err, ErrStaleObject)
+	assert.Equal(t, LockVersion(1), laptop.Version, "the stale model keeps the version it was read with")
+
+	var stored lockedMealPlan
+	require.NoError(t, db.GetDB().First(&stored, plan.ID).Error)
+	assert.Equal(t, "bulking", stored.Name)
+	assert.Equal(t, LockVersion(2), stored.Version)
+
+	// Reloaded, the laptop's edit applies
+	require.NoError(t, db.GetDB().First(&laptop, plan.ID).Error)
+	require.NoError(t, db.GetDB().Model(&laptop).Update("name", "maintenance").Error)
+	assert.Equal(t, LockVersion(3), laptop.Version)
+}
+
+func TestOptimisticLock_BulkUpdatesIncrementVersions(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&lockedMealPlan{}))
+	require.NoError(t, db.GetDB().Create([]*lockedMealPlan{{Name: "a"}, {Name: "b"}}).Error)
+
+	require.NoError(t, db.GetDB().Model(&lockedMealPlan{}).Where("1 = 1").Updates(map[string]interface{}{"name": "renamed"}).Error)
+
+	var versions []LockVersion
+	require.NoError(t, db.GetDB().Model(&lockedMealPlan{}).Order("id").Pluck("version", &versions).Error)
+	assert.Equal(t, []LockVersion{2, 2}, versions)
+}
+
+func TestRepository_UpdateReportsStaleObjects(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&lockedMealPlan{}))
+	repo := NewRepository[lockedMealPlan](db)
+	ctx := context.Background()
+
+	plan := &lockedMealPlan{Name: "cutting"}
+	require.NoError(t, repo.Create(ctx, plan))
+	stale := *plan
+
+	plan.Name = "bulking"
+	require.NoError(t, repo.Update(ctx, plan))
+	stale.Name = "maintenance"
+	assert.ErrorIs(t, repo.Update(ctx, &stale), ErrStaleObject)
+}
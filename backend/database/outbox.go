@@ -0,0 +1,265 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// outboxChannel is the NOTIFY channel EnqueueOutbox wakes relays on
+	outboxChannel = "outbox_events"
+
+	// defaultOutboxPollInterval is how often the relay polls when OutboxPollInterval is unset
+	defaultOutboxPollInterval = time.Second
+
+	// defaultOutboxBatchSize is how many events a relay pass claims when OutboxBatchSize is unset
+	defaultOutboxBatchSize = 100
+
+	// defaultOutboxMaxAttempts is how often an event is tried when OutboxMaxAttempts is unset
+	defaultOutboxMaxAttempts = 10
+
+	// defaultOutboxRetryInterval is the first redelivery delay when OutboxRetryInterval is unset
+	defaultOutboxRetryInterval = time.Second
+)
+
+// OutboxEvent is an event queued in the outbox table until the relay delivered it
+// Create the table with Migrate(&OutboxEvent{}).
+type OutboxEvent struct {
+	ID          uint64 `gorm:"primaryKey"`
+	Topic       string `gorm:"not null;index"`
+	Key         string
+	Payload     string    `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+	AvailableAt time.Time `gorm:"not null;index"`
+	Attempts    int       `gorm:"not null;default:0"`
+	LastError   string
+
+	// DeadAt is when the event was dead-lettered after OutboxMaxAttempts failed deliveries
+	DeadAt *time.Time `gorm:"index"`
+}
+
+// TableName implements gorm's Tabler
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// OutboxSink delivers outbox events, e.g. to a webhook, Kafka or an in-process bus
+// An error leaves the event queued for redelivery, so sinks must tolerate duplicates.
+type OutboxSink interface {
+	Publish(ctx context.Context, event OutboxEvent) error
+}
+
+// OutboxSinkFunc adapts a function, e.g. one producing to Kafka, to an OutboxSink
+type OutboxSinkFunc func(ctx context.Context, event OutboxEvent) error
+
+// Publish implements OutboxSink
+func (f OutboxSinkFunc) Publish(ctx context.Context, event OutboxEvent) error {
+	return f(ctx, event)
+}
+
+// WebhookOutboxSink POSTs each event's payload to URL, with its topic, key and ID
+// in the X-Outbox-Topic, X-Outbox-Key and X-Outbox-Event-ID headers. Responses
+// other than 2xx fail the delivery.
+type WebhookOutboxSink struct {
+	URL string
+	// Client sends the requests (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Publish implements OutboxSink
+func (s *WebhookOutboxSink) Publish(ctx context.Context, event OutboxEvent) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewBufferString(event.Payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Outbox-Topic", event.Topic)
+	request.Header.Set("X-Outbox-Key", event.Key)
+	request.Header.Set("X-Outbox-Event-ID", fmt.Sprint(event.ID))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", response.Status)
+	}
+	return nil
+}
+
+// EnqueueOutbox queues an event on topic in tx, so it is delivered if and only if
+// tx commits. payload is stored as is when it is a string or []byte and as JSON
+// otherwise; key groups related events for sinks that partition by it.
+func EnqueueOutbox(tx *gorm.DB, topic, key string, payload interface{}) error {
+	var body string
+	switch payload := payload.(type) {
+	case string:
+		body = payload
+	case []byte:
+		body = string(payload)
+	default:
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode outbox payload: %w", err)
+		}
+		body = string(encoded)
+	}
+
+	now := time.Now().UTC()
+	event := OutboxEvent{Topic: topic, Key: key, Payload: body, CreatedAt: now, AvailableAt: now}
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to enqueue outbox event: %w", err)
+	}
+
+	// Wakes the relays once tx commits instead of at their next poll
+	if tx.Dialector.Name() == "postgres" {
+		return tx.Exec("SELECT pg_notify(?, '')", outboxChannel).Error
+	}
+	return nil
+}
+
+// OutboxDeadLetters returns up to limit dead-lettered events, oldest first
+func (db *ProductionDatabase) OutboxDeadLetters(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := db.primary().WithContext(ctx).Where("dead_at IS NOT NULL").Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// RetryOutboxDeadLetter queues the dead-lettered event id for delivery again,
+// with a fresh set of attempts
+func (db *ProductionDatabase) RetryOutboxDeadLetter(ctx context.Context, id uint64) error {
+	result := db.primary().WithContext(ctx).Model(&OutboxEvent{}).
+		Where("id = ? AND dead_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"dead_at": nil, "attempts": 0, "available_at": time.Now().UTC()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RelayOutbox delivers the due outbox events to the OutboxSink, oldest first, and
+// returns how many were delivered. Events are claimed with FOR UPDATE SKIP LOCKED
+// so relays on other replicas skip them, and deleted once delivered; failures are
+// retried with exponential backoff and dead-lettered after OutboxMaxAttempts.
+func (db *ProductionDatabase) RelayOutbox(ctx context.Context) (int, error) {
+	if db.config.OutboxSink == nil {
+		return 0, nil
+	}
+	batchSize := db.config.OutboxBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultOutboxBatchSize
+	}
+
+	delivered := 0
+	err := db.TransactionWithOptions(ctx, sql.TxOptions{}, func(tx *gorm.DB) error {
+		var events []OutboxEvent
+		err := tx.Scopes(SkipLocked()).
+			Where("dead_at IS NULL AND available_at <= ?", time.Now().UTC()).
+			Order("id").Limit(batchSize).Find(&events).Error
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := db.config.OutboxSink.Publish(ctx, event); err != nil {
+				if err := db.failOutboxEvent(tx, event, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Delete(&OutboxEvent{}, event.ID).Error; err != nil {
+				return err
+			}
+			delivered++
+		}
+		return nil
+	})
+	return delivered, err
+}
+
+// failOutboxEvent schedules event's redelivery after a failed attempt, or
+// dead-letters it once it used up its attempts
+func (db *ProductionDatabase) failOutboxEvent(tx *gorm.DB, event OutboxEvent, deliveryErr error) error {
+	maxAttempts := db.config.OutboxMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultOutboxMaxAttempts
+	}
+	retryInterval := db.config.OutboxRetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultOutboxRetryInterval
+	}
+
+	attempts := event.Attempts + 1
+	retry := backoff{base: retryInterval, failures: attempts - 1}
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"attempts":     attempts,
+		"last_error":   deliveryErr.Error(),
+		"available_at": now.Add(retry.failed()),
+	}
+	if attempts >= maxAttempts {
+		updates["dead_at"] = now
+		db.logger().Error("Outbox event dead-lettered", "id", event.ID, "topic", event.Topic, "attempts", attempts, "error", deliveryErr)
+	} else {
+		db.logger().Warn("Outbox event delivery failed", "id", event.ID, "topic", event.Topic, "attempts", attempts, "error", deliveryErr)
+	}
+	return tx.Model(&OutboxEvent{}).Where("id = ?", event.ID).Updates(updates).Error
+}
+
+// startOutboxRelay relays outbox events every OutboxPollInterval until the database
+// is closed, and on Postgres as soon as an enqueuing transaction commits
+func (db *ProductionDatabase) startOutboxRelay() {
+	interval := db.config.OutboxPollInterval
+	if interval <= 0 {
+		interval = defaultOutboxPollInterval
+	}
+
+	var wake <-chan Notification
+	if db.primary().Dialector.Name() == "postgres" {
+		wake = db.Subscribe(db.maintenanceCtx, outboxChannel)
+	}
+
+	db.maintenance.Add(1)
+	go func() {
+		defer db.maintenance.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// Relay until the backlog is drained rather than one batch per tick
+			for {
+				delivered, err := db.RelayOutbox(db.maintenanceCtx)
+				if err != nil && db.maintenanceCtx.Err() == nil {
+					db.logger().Error("Outbox relay failed", "error", err)
+				}
+				if err != nil || delivered == 0 {
+					break
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-wake:
+			case <-db.maintenanceCtx.Done():
+				return
+			}
+		}
+	}()
+}
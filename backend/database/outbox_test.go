@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// recordingOutboxSink records published events and fails while err is set
+type recordingOutboxSink struct {
+	mu     sync.Mutex
+	events []OutboxEvent
+	err    error
+}
+
+func (s *recordingOutboxSink) Publish(ctx context.Context, event OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingOutboxSink) topics() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var topics []string
+	for _, event := range s.events {
+		topics = append(topics, event.Topic)
+	}
+	return topics
+}
+
+func TestRelayOutbox_DeliversOnlyCommittedEvents(t *testing.T) {
+	sink := &recordingOutboxSink{}
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	// Set after opening, so no background relay competes with the test's
+	db.config.OutboxSink = sink
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(&OutboxEvent{}))
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return EnqueueOutbox(tx, "meal.logged", "user-7", map[string]int{"calories": 420})
+	}))
+	require.Error(t, db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, EnqueueOutbox(tx, "meal.deleted", "user-7", "{}"))
+		return errors.New("rolled back")
+	}))
+
+	delivered, err := db.RelayOutbox(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	require.Len(t, sink.events, 1)
+	assert.Equal(t, "meal.logged", sink.events[0].Topic)
+	assert.Equal(t, "user-7", sink.events[0].Key)
+	assert.JSONEq(t, `{"calories":420}`, sink.events[0].Payload)
+
+	// Delivered events leave the outbox
+	var queued int64
+	require.NoError(t, db.GetDB().Model(&OutboxEvent{}).Count(&queued).Error)
+	assert.Zero(t, queued)
+}
+
+func TestRelayOutbox_RetriesAndDeadLettersFailedEvents(t *testing.T) {
+	sink := &recordingOutboxSink{err: errors.New("broker down")}
+	config := newSQLiteTestConfig(t, "primary")
+	config.OutboxRetryInterval = time.Nanosecond
+	config.OutboxMaxAttempts = 2
+	db := newSQLiteTestDatabase(t, config)
+	db.config.OutboxSink = sink
+	ctx := context.Background()
+	require.NoError(t, db.Migrate(&OutboxEvent{}))
+	require.NoError(t, EnqueueOutbox(db.GetDB(), "plan.updated", "", "{}"))
+
+	for range 3 {
+		delivered, err := db.RelayOutbox(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+	}
+
+	dead, err := db.OutboxDeadLetters(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, "broker down", dead[0].LastError)
+
+	sink.err = nil
+	require.NoError(t, db.RetryOutboxDeadLetter(ctx, dead[0].ID))
+	assert.ErrorIs(t, db.RetryOutboxDeadLetter(ctx, dead[0].ID), ErrNotFound)
+
+	delivered, err := db.RelayOutbox(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{"plan.updated"}, sink.topics())
+}
+
+func TestOutboxRelay_PollsInTheBackground(t *testing.T) {
+	sink := &recordingOutboxSink{}
+	config := newSQLiteTestConfig(t, "primary")
+	config.OutboxSink = sink
+	config.OutboxPollInterval = 5 * time.Millisecond
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.Migrate(&OutboxEvent{}))
+
+	require.NoError(t, EnqueueOutbox(db.GetDB(), "meal.logged", "", "{}"))
+	assert.Eventually(t, func() bool { return len(sink.topics()) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestWebhookOutboxSink_PostsEvents(t *testing.T) {
+	var topic, id string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		topic, id = r.Header.Get("X-Outbox-Topic"), r.Header.Get("X-Outbox-Event-ID")
+		if topic == "rejected" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	sink := &WebhookOutboxSink{URL: server.URL}
+	require.NoError(t, sink.Publish(context.Background(), OutboxEvent{ID: 3, Topic: "meal.logged", Payload: "{}"}))
+	assert.Equal(t, "meal.logged", topic)
+	assert.Equal(t, "3", id)
+
+	assert.Error(t, sink.Publish(context.Background(), OutboxEvent{ID: 4, Topic: "rejected", Payload: "{}"}))
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPageSize is the page size of requests without one
+	DefaultPageSize = 20
+
+	// MaxPageSize caps the page size clients may request
+	MaxPageSize = 100
+
+	// MaxPaginationOffset caps how many rows a page may skip, as the database reads
+	// and discards every skipped row; deeper lists should use PaginateKeyset
+	MaxPaginationOffset = 10000
+)
+
+// CountMode selects how PaginateOffset counts the rows of a list
+type CountMode int
+
+const (
+	// CountNone skips counting; HasMore still tells whether another page follows
+	CountNone CountMode = iota
+
+	// CountExact runs a COUNT(*) of the rows the query selects
+	CountExact
+
+	// CountEstimated reads the planner's row estimate of the model's table from
+	// pg_class.reltuples, which is cheap on huge tables but ignores filters, so it
+	// only suits unfiltered lists. Without an estimate, e.g. on other databases or
+	// before the table was analyzed, the rows are counted exactly.
+	CountEstimated
+)
+
+// estimatedCountSQL reads the planner's row estimate of a table
+const estimatedCountSQL = "SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass(?)"
+
+// Page is the envelope list endpoints return: one page of items and where it lies
+// in the list. Total and TotalPages are only set when the list was counted.
+type Page[T any] struct {
+	Items          []T   `json:"items"`
+	Page           int   `json:"page"`
+	Size           int   `json:"size"`
+	HasMore        bool  `json:"has_more"`
+	Total          int64 `json:"total,omitempty"`
+	TotalPages     int64 `json:"total_pages,omitempty"`
+	TotalEstimated bool  `json:"total_estimated,omitempty"`
+}
+
+// Paginate is a scope applying LIMIT and OFFSET for page (from 1) of size rows
+// Pages below 1 are the first page, and sizes default to DefaultPageSize and are
+// capped at MaxPageSize. Pages beyond MaxPaginationOffset select nothing.
+func Paginate(page, size int) func(*gorm.DB) *gorm.DB {
+	page, size = pageBounds(page, size)
+	return func(tx *gorm.DB) *gorm.DB {
+		offset := (page - 1) * size
+		if offset > MaxPaginationOffset {
+			return tx.Where("1 = 0")
+		}
+		return tx.Limit(size).Offset(offset)
+	}
+}
+
+// PaginateOffset returns page (from 1) of size rows of the rows tx selects, bounded
+// as Paginate bounds them, counting the rows as count says. tx should order the
+// rows, or pages may overlap. Pages beyond MaxPaginationOffset fail with ErrPageTooDeep.
+func PaginateOffset[T any](tx *gorm.DB, page, size int, count CountMode) (*Page[T], error) {
+	page, size = pageBounds(page, size)
+	offset := (page - 1) * size
+	if offset > MaxPaginationOffset {
+		return nil, fmt.Errorf("%w: page %d of %d rows skips more than %d rows", ErrPageTooDeep, page, size, MaxPaginationOffset)
+	}
+
+	result := &Page[T]{Page: page, Size: size}
+	if count != CountNone {
+		total, estimated, err := countRows[T](tx.Session(&gorm.Session{}), count)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = total
+		result.TotalPages = (total + int64(size) - 1) / int64(size)
+		result.TotalEstimated = estimated
+	}
+
+	// One row beyond the page tells whether another page follows
+	var items []T
+	if err := tx.Limit(size + 1).Offset(offset).Find(&items).Error; err != nil {
+		return nil, TranslateError(err)
+	}
+	result.Items = items
+	if len(items) > size {
+		result.Items = items[:size]
+		result.HasMore = true
+	}
+	return result, nil
+}
+
+// Paginate is PaginateOffset on the rows the scopes select, read as the repository reads
+func (r *Repository[T]) Paginate(ctx context.Context, page, size int, count CountMode, scopes ...func(*gorm.DB) *gorm.DB) (*Page[T], error) {
+	var result *Page[T]
+	err := r.read(ctx, func(tx *gorm.DB) error {
+		var err error
+		result, err = PaginateOffset[T](tx.Model(new(T)).Scopes(scopes...), page, size, count)
+		return err
+	})
+	return result, err
+}
+
+// pageBounds returns page and size within the bounds Paginate documents
+func pageBounds(page, size int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	return page, size
+}
+
+// countRows counts the rows tx selects as count says, reporting whether the total is an estimate
+func countRows[T any](tx *gorm.DB, count CountMode) (int64, bool, error) {
+	if count == CountEstimated && tx.Dialector.Name() == "postgres" {
+		table := tx.Statement.Table
+		if table == "" {
+			stmt := &gorm.Statement{DB: tx}
+			if err := stmt.Parse(new(T)); err != nil {
+				return 0, false, fmt.Errorf("failed to parse paginated model: %w", err)
+			}
+			table = stmt.Table
+		}
+
+		var estimate int64
+		err := tx.Session(&gorm.Session{NewDB: true}).Raw(estimatedCountSQL, table).Scan(&estimate).Error
+		if err != nil {
+			return 0, false, TranslateError(err)
+		}
+		// Tables never vacuumed or analyzed have no estimate (-1, or 0 before Postgres 14)
+		if estimate > 0 {
+			return estimate, true, nil
+		}
+	}
+
+	var total int64
+	if err := tx.Model(new(T)).Count(&total).Error; err != nil {
+		return 0, false, TranslateError(err)
+	}
+	return total, false, nil
+}
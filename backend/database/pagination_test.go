@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type paginationFood struct {
+	ID       int64
+	Name     string
+	Calories int
+}
+
+func newPaginationTestDatabase(t *testing.T, foods int) *ProductionDatabase {
+	t.Helper()
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&paginationFood{}))
+	for i := 1; i <= foods; i++ {
+		require.NoError(t, db.GetDB().Create(&paginationFood{Name: fmt.Sprintf("food %02d", i), Calories: i * 10}).Error)
+	}
+	return db
+}
+
+func TestPaginateOffset_ReturnsPagesWithTotals(t *testing.T) {
+	db := newPaginationTestDatabase(t, 5)
+	ordered := db.GetDB().Model(&paginationFood{}).Order("id")
+
+	page, err := PaginateOffset[paginationFood](ordered.Session(&gorm.Session{}), 2, 2, CountExact)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, int64(3), page.Items[0].ID)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, int64(5), page.Total)
+	assert.Equal(t, int64(3), page.TotalPages)
+	assert.False(t, page.TotalEstimated)
+
+	// SQLite has no estimates, so the rows are counted
+	page, err = PaginateOffset[paginationFood](ordered.Session(&gorm.Session{}), 3, 2, CountEstimated)
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.False(t, page.HasMore)
+	assert.Equal(t, int64(5), page.Total)
+
+	page, err = PaginateOffset[paginationFood](ordered.Session(&gorm.Session{}), 0, 0, CountNone)
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.Page)
+	assert.Equal(t, DefaultPageSize, page.Size)
+	assert.Len(t, page.Items, 5)
+	assert.Zero(t, page.Total)
+
+	_, err = PaginateOffset[paginationFood](ordered.Session(&gorm.Session{}), MaxPaginationOffset, MaxPageSize, CountNone)
+	assert.ErrorIs(t, err, ErrPageTooDeep)
+}
+
+func TestPaginate_CapsPageSize(t *testing.T) {
+	db := newPaginationTestDatabase(t, MaxPageSize+1)
+
+	var foods []paginationFood
+	require.NoError(t, db.GetDB().Order("id").Scopes(Paginate(1, 1000)).Find(&foods).Error)
+	assert.Len(t, foods, MaxPageSize)
+
+	require.NoError(t, db.GetDB().Order("id").Scopes(Paginate(MaxPaginationOffset, MaxPageSize)).Find(&foods).Error)
+	assert.Empty(t, foods)
+}
+
+func TestRepository_Paginate(t *testing.T) {
+	db := newPaginationTestDatabase(t, 5)
+	repo := NewRepository[paginationFood](db)
+
+	page, err := repo.Paginate(context.Background(), 1, 2, CountExact, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("calories > ?", 20).Order("calories DESC")
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, 50, page.Items[0].Calories)
+	assert.Equal(t, int64(3), page.Total, "the count honors the filter")
+	assert.True(t, page.HasMore)
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// defaultParallelReadConcurrency bounds ParallelRead when ParallelReadConcurrency is unset
+const defaultParallelReadConcurrency = 4
+
+// ShardQuery is one part of a ParallelRead, e.g. a key range of a large report
+type ShardQuery struct {
+	Name string
+
+	// Query scopes the read database to the shard; its rows are found into a []T
+	Query func(*gorm.DB) *gorm.DB
+}
+
+// ParallelRead runs the shard queries concurrently, spreading them across the healthy
+// replicas (the primary when there are none and PrimaryReadFallback is set), and
+// merges their rows. At most ParallelReadConcurrency shards run at once. Every shard
+// runs to completion; the errors of all failed shards are returned together and
+// merge is only called when every shard succeeded.
+func ParallelRead[T any](ctx context.Context, db *ProductionDatabase, shards []ShardQuery, merge func([][]T) []T) ([]T, error) {
+	nodes := db.parallelReadNodes(ctx)
+	if len(nodes) == 0 {
+		return nil, ErrReplicaUnavailable
+	}
+
+	limit := db.config.ParallelReadConcurrency
+	if limit <= 0 {
+		limit = defaultParallelReadConcurrency
+	}
+	slots := make(chan struct{}, limit)
+
+	results := make([][]T, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				errs[i] = fmt.Errorf("shard %s: %w", shard.Name, ctx.Err())
+				return
+			}
+
+			var rows []T
+			if err := db.readShard(ctx, nodes[i%len(nodes)], func(tx *gorm.DB) error {
+				return shard.Query(tx).Find(&rows).Error
+			}); err != nil {
+				errs[i] = fmt.Errorf("shard %s: %w", shard.Name, err)
+				return
+			}
+			results[i] = rows
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return merge(results), nil
+}
+
+// parallelReadNodes returns the healthy replicas ParallelRead spreads shards across,
+// in ReplicaFilter order, or the primary when there are none and fallback is enabled
+func (db *ProductionDatabase) parallelReadNodes(ctx context.Context) []*gorm.DB {
+	candidates := db.replicaCandidates(ctx)
+	if db.config.ReplicaFilter != nil {
+		candidates = db.config.ReplicaFilter(candidates, ctx)
+	}
+
+	var nodes []*gorm.DB
+	for _, candidate := range candidates {
+		if candidate.Healthy && candidate.db != nil {
+			nodes = append(nodes, candidate.db)
+		}
+	}
+	if len(nodes) == 0 && db.config.PrimaryReadFallback {
+		nodes = append(nodes, db.primary())
+	}
+	return nodes
+}
+
+// readShard runs one shard on node, within the replica read budget like Read
+func (db *ProductionDatabase) readShard(ctx context.Context, node *gorm.DB, fn func(*gorm.DB) error) error {
+	if node != db.primary() {
+		release, ok := db.acquireReplicaSlot()
+		switch {
+		case ok:
+			defer release()
+			ctx = withReplicaSlot(ctx)
+		case db.config.ReplicaBudgetPolicy == RejectOverBudget:
+			return ErrReplicaBudgetExceeded
+		default:
+			node = db.primary()
+		}
+	}
+	return fn(node.WithContext(ctx))
+}
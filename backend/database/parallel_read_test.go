@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type parallelReadTestMeal struct {
+	ID       int
+	Calories int
+}
+
+func newParallelReadTestDatabase(t *testing.T) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	db := newSQLiteTestDatabase(t, config)
+
+	// Only the replica has the data, so results prove where the shards ran
+	require.NoError(t, db.replicaDB.Exec("CREATE TABLE meals (id integer PRIMARY KEY, calories integer)").Error)
+	for id := 1; id <= 9; id++ {
+		require.NoError(t, db.replicaDB.Exec("INSERT INTO meals (id, calories) VALUES (?, ?)", id, id*100).Error)
+	}
+	return db
+}
+
+// idRange scopes a shard to meals with from <= id < to
+func idRange(from, to int) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Table("meals").Where("id >= ? AND id < ?", from, to)
+	}
+}
+
+func concatMeals(shards [][]parallelReadTestMeal) []parallelReadTestMeal {
+	var merged []parallelReadTestMeal
+	for _, shard := range shards {
+		merged = append(merged, shard...)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ID < merged[j].ID })
+	return merged
+}
+
+func TestParallelRead_RunsShardsConcurrentlyAndMerges(t *testing.T) {
+	db := newParallelReadTestDatabase(t)
+
+	// Every shard waits until all three are running, which only happens concurrently
+	var arrived sync.WaitGroup
+	arrived.Add(3)
+	allRunning := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(allRunning)
+	}()
+
+	shard := func(name string, from, to int) ShardQuery {
+		scope := idRange(from, to)
+		return ShardQuery{Name: name, Query: func(tx *gorm.DB) *gorm.DB {
+			arrived.Done()
+			select {
+			case <-allRunning:
+			case <-time.After(2 * time.Second):
+				tx.AddError(errors.New("shards did not run concurrently"))
+			}
+			return scope(tx)
+		}}
+	}
+
+	meals, err := ParallelRead(context.Background(), db, []ShardQuery{
+		shard("low", 1, 4),
+		shard("mid", 4, 7),
+		shard("high", 7, 10),
+	}, concatMeals)
+	require.NoError(t, err)
+
+	require.Len(t, meals, 9)
+	for i, meal := range meals {
+		assert.Equal(t, parallelReadTestMeal{ID: i + 1, Calories: (i + 1) * 100}, meal)
+	}
+}
+
+func TestParallelRead_AggregatesShardErrors(t *testing.T) {
+	db := newParallelReadTestDatabase(t)
+
+	failing := func(tx *gorm.DB) *gorm.DB { return tx.Table("missing_meals") }
+	_, err := ParallelRead(context.Background(), db, []ShardQuery{
+		{Name: "low", Query: idRange(1, 4)},
+		{Name: "broken_a", Query: failing},
+		{Name: "broken_b", Query: failing},
+	}, concatMeals)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "shard broken_a")
+	assert.Contains(t, err.Error(), "shard broken_b")
+	assert.NotContains(t, err.Error(), "shard low")
+}
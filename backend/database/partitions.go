@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PartitionInterval is the time range covered by each partition
+type PartitionInterval int
+
+const (
+	// PartitionMonthly creates one partition per calendar month, named <parent>_pYYYYMM
+	PartitionMonthly PartitionInterval = iota
+
+	// PartitionDaily creates one partition per day, named <parent>_pYYYYMMDD
+	PartitionDaily
+)
+
+// PartitionSpec describes how EnsurePartitions manages a range-partitioned table
+type PartitionSpec struct {
+	Interval PartitionInterval
+
+	// Upcoming partitions to keep created after the current one
+	Premake int
+
+	// Past partitions to keep before the current one; older ones are detached (0 keeps all)
+	Retention int
+
+	// Drop expired partitions after detaching them instead of leaving them as plain tables
+	DropExpired bool
+}
+
+// start returns the start of the partition containing t
+func (i PartitionInterval) start(t time.Time) time.Time {
+	t = t.UTC()
+	if i == PartitionDaily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// add moves a partition start by n partitions
+func (i PartitionInterval) add(t time.Time, n int) time.Time {
+	if i == PartitionDaily {
+		return t.AddDate(0, 0, n)
+	}
+	return t.AddDate(0, n, 0)
+}
+
+// layout is the date layout of partition name suffixes
+func (i PartitionInterval) layout() string {
+	if i == PartitionDaily {
+		return "20060102"
+	}
+	return "200601"
+}
+
+// partitionName returns the name of the partition of parentTable starting at start
+func partitionName(parentTable string, interval PartitionInterval, start time.Time) string {
+	return parentTable + "_p" + start.Format(interval.layout())
+}
+
+// quoteIdentifier quotes a Postgres identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// EnsurePartitions creates the current and the next spec.Premake range partitions of
+// parentTable if absent, and detaches (optionally drops) partitions older than
+// spec.Retention. Only partitions following the <parent>_p<date> naming are expired.
+func (db *ProductionDatabase) EnsurePartitions(ctx context.Context, parentTable string, spec PartitionSpec) error {
+	primary := db.primary().WithContext(ctx)
+	current := spec.Interval.start(time.Now())
+
+	for n := 0; n <= spec.Premake; n++ {
+		from := spec.Interval.add(current, n)
+		to := spec.Interval.add(from, 1)
+
+		statement := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			quoteIdentifier(partitionName(parentTable, spec.Interval, from)), quoteIdentifier(parentTable),
+			from.Format("2006-01-02"), to.Format("2006-01-02"))
+		if err := primary.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create partition of %s from %s: %w", parentTable, from.Format("2006-01-02"), err)
+		}
+	}
+
+	if spec.Retention <= 0 {
+		return nil
+	}
+
+	var partitions []string
+	if err := primary.Raw("SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid WHERE i.inhparent = ?::regclass",
+		parentTable).Scan(&partitions).Error; err != nil {
+		return fmt.Errorf("failed to list partitions of %s: %w", parentTable, err)
+	}
+
+	cutoff := spec.Interval.add(current, -spec.Retention)
+	prefix := parentTable + "_p"
+	for _, partition := range partitions {
+		if !strings.HasPrefix(partition, prefix) {
+			continue
+		}
+		start, err := time.Parse(spec.Interval.layout(), strings.TrimPrefix(partition, prefix))
+		if err != nil || !start.Before(cutoff) {
+			continue
+		}
+
+		if err := primary.Exec(fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", quoteIdentifier(parentTable), quoteIdentifier(partition))).Error; err != nil {
+			return fmt.Errorf("failed to detach partition %s: %w", partition, err)
+		}
+		if spec.DropExpired {
+			if err := primary.Exec(fmt.Sprintf("DROP TABLE %s", quoteIdentifier(partition))).Error; err != nil {
+				return fmt.Errorf("failed to drop partition %s: %w", partition, err)
+			}
+		}
+		db.logger().Info("Expired partition", "partition", partition, "table", parentTable)
+	}
+
+	return nil
+}
+
+// PartitionMaintenanceTask returns a maintenance task running EnsurePartitions every interval
+func PartitionMaintenanceTask(parentTable string, spec PartitionSpec, interval time.Duration) MaintenanceTask {
+	return MaintenanceTask{
+		Name:     "partitions:" + parentTable,
+		Interval: interval,
+		Run: func(ctx context.Context, db *ProductionDatabase) error {
+			return db.EnsurePartitions(ctx, parentTable, spec)
+		},
+	}
+}
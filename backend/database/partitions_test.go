@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsurePartitions_CreatesUpcomingAndDropsExpired(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	ctx := context.Background()
+	parent := "partition_test_events"
+
+	require.NoError(t, db.GetDB().Exec("DROP TABLE IF EXISTS "+parent).Error)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE "+parent+" (id bigserial, recorded_on date NOT NULL) PARTITION BY RANGE (recorded_on)").Error)
+	t.Cleanup(func() { db.GetDB().Exec("DROP TABLE IF EXISTS " + parent) })
+
+	current := PartitionMonthly.start(time.Now())
+	expiredStart := PartitionMonthly.add(current, -6)
+	expired := partitionName(parent, PartitionMonthly, expiredStart)
+	require.NoError(t, db.GetDB().Exec(fmt.Sprintf("CREATE TABLE %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		expired, parent, expiredStart.Format("2006-01-02"), PartitionMonthly.add(expiredStart, 1).Format("2006-01-02"))).Error)
+
+	spec := PartitionSpec{Interval: PartitionMonthly, Premake: 1, Retention: 3, DropExpired: true}
+	require.NoError(t, db.EnsurePartitions(ctx, parent, spec))
+
+	var partitions []string
+	require.NoError(t, db.GetDB().Raw("SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid WHERE i.inhparent = ?::regclass", parent).Scan(&partitions).Error)
+	assert.ElementsMatch(t, []string{
+		partitionName(parent, PartitionMonthly, current),
+		partitionName(parent, PartitionMonthly, PartitionMonthly.add(current, 1)),
+	}, partitions)
+
+	var exists bool
+	require.NoError(t, db.GetDB().Raw("SELECT to_regclass(?) IS NOT NULL", expired).Scan(&exists).Error)
+	assert.False(t, exists, "the expired partition must be dropped")
+
+	// Running again is a no-op
+	require.NoError(t, db.EnsurePartitions(ctx, parent, spec))
+}
+
+func TestPartitionInterval_NamesAndBounds(t *testing.T) {
+	at := time.Date(2026, time.December, 17, 15, 4, 5, 0, time.UTC)
+
+	month := PartitionMonthly.start(at)
+	assert.Equal(t, time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC), month)
+	assert.Equal(t, time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC), PartitionMonthly.add(month, 1))
+	assert.Equal(t, "events_p202612", partitionName("events", PartitionMonthly, month))
+
+	day := PartitionDaily.start(at)
+	assert.Equal(t, "events_p20261217", partitionName("events", PartitionDaily, day))
+	assert.Equal(t, time.Date(2026, time.December, 18, 0, 0, 0, 0, time.UTC), PartitionDaily.add(day, 1))
+}
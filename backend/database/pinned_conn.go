@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const pinnedConnKey contextKey = "database.pinned_conn"
+
+// WithPinnedConn checks out one primary connection and returns a context on which
+// every statement issued through the primary handle runs on it, saving a pool
+// checkout per statement. Unlike a transaction nothing is held open on the server.
+// release returns the connection to the pool and must be called once the context
+// is no longer used. If no connection can be checked out, ctx is returned as is.
+func (db *ProductionDatabase) WithPinnedConn(ctx context.Context) (context.Context, func()) {
+	conn, err := db.primaryPool().Conn(ctx)
+	if err != nil {
+		db.logger().Warn("Failed to pin a connection, statements will use the pool", "error", err)
+		return ctx, func() {}
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			if err := conn.Close(); err != nil {
+				db.logger().Error("Failed to release pinned connection", "error", err)
+			}
+		})
+	}
+	return context.WithValue(ctx, pinnedConnKey, conn), release
+}
+
+// pinnedConn returns the connection pinned to ctx by WithPinnedConn, or nil
+func pinnedConn(ctx context.Context) *sql.Conn {
+	if ctx == nil {
+		return nil
+	}
+	conn, _ := ctx.Value(pinnedConnKey).(*sql.Conn)
+	return conn
+}
+
+// pinStatement runs a statement issued on the primary pool on the connection pinned
+// to its context, if any. Statements in a transaction or Session keep their connection.
+func (db *ProductionDatabase) pinStatement(tx *gorm.DB) {
+	conn := pinnedConn(tx.Statement.Context)
+	if conn == nil || tx.Statement.ConnPool != db.primary().Statement.ConnPool {
+		return
+	}
+	tx.Statement.ConnPool = conn
+}
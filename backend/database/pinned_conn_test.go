@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithPinnedConn_ReusesOneConnection(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	ctx, release := db.WithPinnedConn(context.Background())
+	assert.Equal(t, 1, db.primaryPool().Stats().InUse)
+
+	// A temporary table is private to its connection, so it is only visible to
+	// later statements if they run on the same one
+	require.NoError(t, db.GetDB().WithContext(ctx).Exec("CREATE TEMP TABLE pinned_items (item text)").Error)
+	require.NoError(t, db.GetDB().WithContext(ctx).Exec("INSERT INTO pinned_items (item) VALUES ('oats')").Error)
+
+	var items []string
+	require.NoError(t, db.GetDB().WithContext(ctx).Raw("SELECT item FROM pinned_items").Scan(&items).Error)
+	assert.Equal(t, []string{"oats"}, items)
+	assert.Equal(t, 1, db.primaryPool().Stats().InUse, "statements must not check out further connections")
+
+	// Statements without the pinned context do not see it
+	assert.Error(t, db.GetDB().Raw("SELECT item FROM pinned_items").Scan(&items).Error)
+
+	release()
+	release()
+	assert.Equal(t, 0, db.primaryPool().Stats().InUse, "release must return the connection to the pool")
+}
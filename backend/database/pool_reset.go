@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// primary returns the current primary GORM instance
+func (db *ProductionDatabase) primary() *gorm.DB {
+	db.poolMu.RLock()
+	defer db.poolMu.RUnlock()
+	return db.primaryDB
+}
+
+// replica returns the current read replica GORM instance, or nil without one
+func (db *ProductionDatabase) replica() *gorm.DB {
+	db.poolMu.RLock()
+	defer db.poolMu.RUnlock()
+	return db.replicaDB
+}
+
+// regionURLs returns the primary and read replica URLs of the region the pools connect to
+func (db *ProductionDatabase) regionURLs() (primaryURL, replicaURL string) {
+	db.poolMu.RLock()
+	defer db.poolMu.RUnlock()
+	return db.primaryURL, db.replicaURL
+}
+
+// primaryPool returns the current primary connection pool
+func (db *ProductionDatabase) primaryPool() *sql.DB {
+	db.poolMu.RLock()
+	defer db.poolMu.RUnlock()
+	return db.sqlDB
+}
+
+// PoolResets returns how many times the primary pool has been rebuilt
+func (db *ProductionDatabase) PoolResets() int64 {
+	return atomic.LoadInt64(&db.poolResets)
+}
+
+// observePrimaryHealth tracks how long the primary has been unhealthy and rebuilds
+// its pool once that exceeds PoolResetAfter. A failed rebuild is retried on later
+// ticks, once its backoff has passed.
+func (hc *HealthChecker) observePrimaryHealth(healthErr error) {
+	if healthErr == nil {
+		hc.unhealthySince = time.Time{}
+		hc.rebuildBackoff.reset()
+		hc.nextRebuildAt = time.Time{}
+		return
+	}
+
+	resetAfter := hc.db.config.PoolResetAfter
+	if resetAfter <= 0 {
+		return
+	}
+
+	if hc.unhealthySince.IsZero() {
+		hc.unhealthySince = time.Now()
+		return
+	}
+
+	unhealthyFor := time.Since(hc.unhealthySince)
+	if unhealthyFor < resetAfter || time.Now().Before(hc.nextRebuildAt) {
+		return
+	}
+
+	hc.db.logger().Warn("Primary database unhealthy, rebuilding connection pool", "unhealthy_for", unhealthyFor.Round(time.Second))
+	if err := hc.db.rebuildPrimaryPool(); err != nil {
+		wait := hc.rebuildBackoff.failed()
+		hc.nextRebuildAt = time.Now().Add(wait)
+		hc.db.logger().Error("Failed to rebuild primary connection pool", "retry_in", wait, "error", err)
+		return
+	}
+	hc.unhealthySince = time.Time{}
+	hc.rebuildBackoff.reset()
+	hc.nextRebuildAt = time.Time{}
+}
+
+// rebuildPrimaryPool opens a fresh primary pool, warms it, swaps it in and closes the old one
+// Statements already running on the old pool finish before its connections close.
+func (db *ProductionDatabase) rebuildPrimaryPool() error {
+	db.reconnectMu.Lock()
+	defer db.reconnectMu.Unlock()
+
+	primaryURL, _ := db.regionURLs()
+	primaryDB, sqlDB, err := openPool(db.config, "primary", primaryURL, db.gormConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to primary database: %w", err)
+	}
+
+	if err := db.registerCallbacks(primaryDB, "primary"); err != nil {
+		sqlDB.Close()
+		return err
+	}
+
+	transactionDB, err := db.openTransactionPool(primaryURL)
+	if err != nil {
+		sqlDB.Close()
+		return err
+	}
+
+	db.warmPrimaryPool(sqlDB)
+
+	db.poolMu.Lock()
+	oldPool, oldTransactionDB := db.sqlDB, db.transactionDB
+	db.primaryDB, db.sqlDB, db.transactionDB = primaryDB, sqlDB, transactionDB
+	db.poolMu.Unlock()
+
+	atomic.AddInt64(&db.poolResets, 1)
+
+	if oldPool != nil {
+		if err := oldPool.Close(); err != nil {
+			db.logger().Error("Failed to close previous primary pool", "error", err)
+		}
+	}
+	if oldTransactionDB != nil {
+		if oldSQLDB, err := oldTransactionDB.DB(); err == nil {
+			if err := oldSQLDB.Close(); err != nil {
+				db.logger().Error("Failed to close previous transaction pool", "error", err)
+			}
+		}
+	}
+
+	db.logger().Info("Primary database connection pool rebuilt")
+	return nil
+}
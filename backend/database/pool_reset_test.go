@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// partitionedConnector opens SQLite connections that fail while the network is partitioned
+// A connector that saw the partition stays broken, like a pool poisoned by a long outage.
+type partitionedConnector struct {
+	dsn       string
+	partition *atomic.Bool
+	poisoned  atomic.Bool
+}
+
+func (c *partitionedConnector) down() bool {
+	if c.partition.Load() {
+		c.poisoned.Store(true)
+	}
+	return c.poisoned.Load()
+}
+
+func (c *partitionedConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.down() {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &partitionedConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), connector: c}, nil
+}
+
+func (c *partitionedConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+type partitionedConn struct {
+	*sqlite3.SQLiteConn
+	connector *partitionedConnector
+}
+
+func (c *partitionedConn) Ping(ctx context.Context) error {
+	if c.connector.down() {
+		return driver.ErrBadConn
+	}
+	return c.SQLiteConn.Ping(ctx)
+}
+
+func TestPoolReset_RebuildsPrimaryAfterSustainedFailure(t *testing.T) {
+	var partition atomic.Bool
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.PoolResetAfter = 20 * time.Millisecond
+	config.Connector = func(dsn string) (driver.Connector, error) {
+		return &partitionedConnector{dsn: dsn, partition: &partition}, nil
+	}
+	db := newSQLiteTestDatabase(t, config)
+	hc := db.healthChecker
+	originalPool := db.primaryPool()
+
+	partition.Store(true)
+	require.Error(t, db.Health())
+	hc.observePrimaryHealth(db.Health())
+	assert.Equal(t, int64(0), db.PoolResets(), "a short failure must not rebuild the pool")
+
+	// Past PoolResetAfter the rebuild is attempted, but fails while still partitioned
+	time.Sleep(2 * config.PoolResetAfter)
+	hc.observePrimaryHealth(db.Health())
+	assert.Equal(t, int64(0), db.PoolResets())
+	assert.Same(t, originalPool, db.primaryPool())
+
+	// The network recovers but the old pool stays poisoned until it is rebuilt
+	partition.Store(false)
+	healthErr := db.Health()
+	require.Error(t, healthErr)
+	hc.observePrimaryHealth(healthErr)
+
+	assert.Equal(t, int64(1), db.PoolResets())
+	assert.NotSame(t, originalPool, db.primaryPool())
+	require.NoError(t, db.Health())
+
+	var one int
+	require.NoError(t, db.GetDB().Raw("SELECT 1").Scan(&one).Error)
+	assert.Equal(t, 1, one)
+	assert.Equal(t, int64(1), db.Stats()["primary_pool_resets"])
+
+	// Recovery clears the failure window
+	hc.observePrimaryHealth(db.Health())
+	assert.True(t, hc.unhealthySince.IsZero())
+}
+
+func TestPoolReset_DisabledByDefault(t *testing.T) {
+	var partition atomic.Bool
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.Connector = func(dsn string) (driver.Connector, error) {
+		return &partitionedConnector{dsn: dsn, partition: &partition}, nil
+	}
+	db := newSQLiteTestDatabase(t, config)
+
+	partition.Store(true)
+	db.healthChecker.observePrimaryHealth(db.Health())
+	time.Sleep(10 * time.Millisecond)
+	db.healthChecker.observePrimaryHealth(db.Health())
+
+	assert.Equal(t, int64(0), db.PoolResets())
+	assert.NotContains(t, db.Stats(), "primary_pool_resets")
+}
+
+func TestPoolReset_BackoffRestartsFromBaseAfterReconnect(t *testing.T) {
+	var partition atomic.Bool
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.PoolResetAfter = time.Millisecond
+	config.PoolResetBackoff = time.Minute
+	config.PoolResetMaxBackoff = time.Hour
+	config.Connector = func(dsn string) (driver.Connector, error) {
+		return &partitionedConnector{dsn: dsn, partition: &partition}, nil
+	}
+	db := newSQLiteTestDatabase(t, config)
+	hc := db.healthChecker
+
+	// failRebuild skips the current backoff and lets a health check attempt a rebuild
+	// that fails, returning the backoff before the next attempt
+	failRebuild := func() time.Duration {
+		hc.nextRebuildAt = time.Time{}
+		hc.observePrimaryHealth(db.Health())
+		return time.Until(hc.nextRebuildAt).Round(time.Minute)
+	}
+	startOutage := func() {
+		partition.Store(true)
+		hc.observePrimaryHealth(db.Health())
+		time.Sleep(2 * config.PoolResetAfter)
+	}
+
+	startOutage()
+	assert.Equal(t, time.Minute, failRebuild())
+	assert.Equal(t, 2*time.Minute, failRebuild())
+	assert.Equal(t, 4*time.Minute, failRebuild())
+
+	// A rebuild during the backoff is not attempted, even once the network recovers
+	partition.Store(false)
+	hc.observePrimaryHealth(db.Health())
+	assert.Equal(t, int64(0), db.PoolResets())
+
+	hc.nextRebuildAt = time.Time{}
+	hc.observePrimaryHealth(db.Health())
+	require.Equal(t, int64(1), db.PoolResets())
+	require.NoError(t, db.Health())
+
+	// The next outage backs off from the base again
+	startOutage()
+	assert.Equal(t, time.Minute, failRebuild())
+}
+
+func TestBackoff_DoublesUpToMax(t *testing.T) {
+	b := backoff{base: time.Second, max: 5 * time.Second}
+	assert.Equal(t, time.Second, b.failed())
+	assert.Equal(t, 2*time.Second, b.failed())
+	assert.Equal(t, 4*time.Second, b.failed())
+	assert.Equal(t, 5*time.Second, b.failed())
+	assert.Equal(t, 5*time.Second, b.failed())
+
+	b.reset()
+	assert.Equal(t, time.Second, b.failed())
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// prePingIdleConnections pings a sample of idle connections in each pool and evicts
+// the dead ones, so requests rarely pick up a stale connection after a failover
+func (db *ProductionDatabase) prePingIdleConnections() int {
+	ctx, cancel := context.WithTimeout(context.Background(), db.config.HealthCheckTimeout)
+	defer cancel()
+
+	evicted := 0
+	if db.primaryPool() != nil {
+		evicted += prePingPool(ctx, db.primaryPool(), db.config.PrePingSampleSize)
+	}
+	if replicaDB := db.replica(); replicaDB != nil {
+		if sqlDB, err := replicaDB.DB(); err == nil {
+			evicted += prePingPool(ctx, sqlDB, db.config.PrePingSampleSize)
+		}
+	}
+
+	if evicted > 0 {
+		db.logger().Info("Pre-ping evicted dead idle connections", "evicted", evicted)
+	}
+	return evicted
+}
+
+// prePingPool checks out up to limit idle connections, pings each and returns the
+// live ones to the pool. The sample is bounded by the idle count at the start of
+// the cycle so pre-ping does not churn the pool or starve requests.
+func prePingPool(ctx context.Context, sqlDB *sql.DB, limit int) int {
+	sample := sqlDB.Stats().Idle
+	if limit > 0 && sample > limit {
+		sample = limit
+	}
+
+	// Hold the sampled connections together so each one is a distinct idle connection
+	conns := make([]*sql.Conn, 0, sample)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	for i := 0; i < sample; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			break
+		}
+		conns = append(conns, conn)
+	}
+
+	evicted := 0
+	for _, conn := range conns {
+		err := conn.PingContext(ctx)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, driver.ErrBadConn) {
+			// database/sql only discards a connection when the driver reports ErrBadConn
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		evicted++
+	}
+	return evicted
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStubPoolDatabase returns a database whose primary pool holds idle stub connections
+func newStubPoolDatabase(t *testing.T, config *ProductionConfig, idle int) (*ProductionDatabase, *stubConnector) {
+	stub := &stubConnector{}
+	config.Connector = func(string) (driver.Connector, error) { return stub, nil }
+
+	connector, err := newConnector(config, "primary", "stub")
+	require.NoError(t, err)
+
+	sqlDB := sql.OpenDB(connector)
+	t.Cleanup(func() { sqlDB.Close() })
+	sqlDB.SetMaxIdleConns(idle)
+
+	// Check out the connections together so the pool opens distinct ones
+	conns := make([]*sql.Conn, idle)
+	for i := range conns {
+		conns[i], err = sqlDB.Conn(context.Background())
+		require.NoError(t, err)
+	}
+	for _, conn := range conns {
+		require.NoError(t, conn.Close())
+	}
+	require.Equal(t, idle, sqlDB.Stats().Idle)
+
+	return &ProductionDatabase{sqlDB: sqlDB, config: config}, stub
+}
+
+func TestPrePing_EvictsDeadIdleConnections(t *testing.T) {
+	db, stub := newStubPoolDatabase(t, DefaultProductionConfig(), 4)
+
+	conns := stub.Conns()
+	require.Len(t, conns, 4)
+	conns[0].Kill()
+	conns[2].Kill()
+
+	assert.Equal(t, 2, db.prePingIdleConnections())
+
+	assert.True(t, conns[0].Closed())
+	assert.True(t, conns[2].Closed())
+	assert.False(t, conns[1].Closed())
+	assert.False(t, conns[3].Closed())
+	assert.Equal(t, 2, db.sqlDB.Stats().Idle)
+
+	// Pre-ping reuses the idle connections instead of opening new ones
+	assert.Len(t, stub.Conns(), 4)
+}
+
+func TestPrePing_SampleSizeBoundsTheCycle(t *testing.T) {
+	config := DefaultProductionConfig()
+	config.PrePingSampleSize = 2
+	db, stub := newStubPoolDatabase(t, config, 4)
+
+	for _, conn := range stub.Conns() {
+		conn.Kill()
+	}
+
+	assert.Equal(t, 2, db.prePingIdleConnections())
+	assert.Equal(t, 2, db.sqlDB.Stats().Idle)
+	assert.Len(t, stub.Conns(), 4)
+}
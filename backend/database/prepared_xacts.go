@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PreparedTxnInfo describes a two-phase transaction left prepared on the primary
+type PreparedTxnInfo struct {
+	GID      string
+	Owner    string
+	Database string
+	Age      time.Duration
+}
+
+// preparedXactsSQL lists prepared transactions at least $1 seconds old, oldest first
+const preparedXactsSQL = `
+SELECT gid, owner, database, EXTRACT(EPOCH FROM now() - prepared)
+FROM pg_catalog.pg_prepared_xacts
+WHERE prepared <= now() - make_interval(secs => ?)
+ORDER BY prepared`
+
+// OrphanedPreparedTransactions returns the prepared transactions on the primary older
+// than PreparedTransactionMaxAge (all of them when it is unset). Until committed or
+// rolled back with COMMIT PREPARED / ROLLBACK PREPARED they keep their locks and
+// hold back vacuum, even across server restarts.
+func (db *ProductionDatabase) OrphanedPreparedTransactions(ctx context.Context) ([]PreparedTxnInfo, error) {
+	rows, err := db.primary().WithContext(ctx).Raw(preparedXactsSQL, db.config.PreparedTransactionMaxAge.Seconds()).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prepared transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var prepared []PreparedTxnInfo
+	for rows.Next() {
+		var (
+			txn     PreparedTxnInfo
+			seconds float64
+		)
+		if err := rows.Scan(&txn.GID, &txn.Owner, &txn.Database, &seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan prepared transaction: %w", err)
+		}
+		txn.Age = time.Duration(seconds * float64(time.Second))
+		prepared = append(prepared, txn)
+	}
+
+	return prepared, rows.Err()
+}
+
+// checkPreparedTransactions warns about prepared transactions older than the configured maximum age
+func (hc *HealthChecker) checkPreparedTransactions() {
+	if hc.db.config.PreparedTransactionMaxAge <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
+	defer cancel()
+
+	prepared, err := hc.db.OrphanedPreparedTransactions(ctx)
+	if err != nil {
+		hc.db.logger().Error("Prepared transaction check failed", "error", err)
+		return
+	}
+
+	for _, txn := range prepared {
+		hc.db.logger().Warn("Orphaned prepared transaction is holding locks and blocking vacuum",
+			"gid", txn.GID, "owner", txn.Owner, "database", txn.Database, "age", txn.Age.Round(time.Second))
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrphanedPreparedTransactions_ReportsPreparedTransaction(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	ctx := context.Background()
+
+	var maxPrepared int
+	require.NoError(t, db.GetDB().Raw("SELECT current_setting('max_prepared_transactions')::int").Scan(&maxPrepared).Error)
+	if maxPrepared == 0 {
+		t.Skip("max_prepared_transactions is 0, skipping prepared transaction test")
+	}
+
+	gid := fmt.Sprintf("orphan_test_%d", time.Now().UnixNano())
+	conn, err := db.sqlDB.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "BEGIN")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("PREPARE TRANSACTION '%s'", gid))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.GetDB().Exec(fmt.Sprintf("ROLLBACK PREPARED '%s'", gid)) })
+
+	prepared, err := db.OrphanedPreparedTransactions(ctx)
+	require.NoError(t, err)
+
+	var found *PreparedTxnInfo
+	for i := range prepared {
+		if prepared[i].GID == gid {
+			found = &prepared[i]
+		}
+	}
+	require.NotNil(t, found, "prepared transaction %s not reported", gid)
+	assert.NotEmpty(t, found.Owner)
+	assert.GreaterOrEqual(t, found.Age, time.Duration(0))
+
+	// Younger than the configured maximum age it is not orphaned yet
+	db.config.PreparedTransactionMaxAge = time.Hour
+	prepared, err = db.OrphanedPreparedTransactions(ctx)
+	require.NoError(t, err)
+	for _, txn := range prepared {
+		assert.NotEqual(t, gid, txn.GID)
+	}
+}
@@ -1,13 +1,20 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
-	"gorm.io/driver/postgres"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -26,42 +33,459 @@ type ProductionConfig struct {
 	ConnectionMaxLifetime time.Duration
 	ConnectionMaxIdleTime time.Duration
 
+	// Give transactions a primary sub-pool of their own with this many connections,
+	// on top of MaxOpenConnections, so long transactions cannot take every connection
+	// quick non-transactional statements need (0 shares the primary pool)
+	MaxTransactionConnections int
+
+	// Pace new physical connections to ConnectionOpenRateLimit per second with bursts
+	// of ConnectionOpenBurst (default 1), so a cold-start stampede cannot overwhelm
+	// the server; connections already open are unaffected (0 disables)
+	ConnectionOpenRateLimit float64
+	ConnectionOpenBurst     int
+
 	// Health check settings
 	HealthCheckInterval time.Duration
 	HealthCheckTimeout  time.Duration
 
-	// Retry settings
-	MaxRetries    int
-	RetryInterval time.Duration
+	// Ping up to PrePingSampleSize idle connections per pool every PrePingInterval,
+	// evicting dead ones before a request picks them up (0 disables)
+	PrePingInterval   time.Duration
+	PrePingSampleSize int
+
+	// Snapshot pool statistics for metrics every MetricsSampleInterval on a ticker of
+	// its own, instead of reading them on every scrape (0 reads them live)
+	MetricsSampleInterval time.Duration
+
+	// Log the full health report to Logger on every health check tick
+	LogHealthEveryTick bool
+
+	// Warn when a query has been waiting on a lock longer than this (0 disables)
+	BlockedQueryWarnThreshold time.Duration
+
+	// Warn about two-phase transactions left prepared longer than this (0 disables)
+	PreparedTransactionMaxAge time.Duration
+
+	// Warn about SELECTs with LIMIT but no ORDER BY, whose rows may differ between
+	// runs and break pagination and caching; once per query shape, counted in
+	// UnorderedLimitTotal
+	WarnOnUnorderedLimit bool
+
+	// Statements cancelled by statement_timeout are logged to Logger as a warning
+	// with their fingerprint, the configured timeout and the calling code; optionally
+	// with the statement's EXPLAIN plan, which costs one planning round trip
+	ExplainOnStatementTimeout bool
+
+	// AuditSink receives a record of every successful INSERT, UPDATE and DELETE with
+	// its fingerprint, table, rows affected and the actor from WithAuditActor, never
+	// its values. Records queue for the sink in a buffer of AuditBufferSize (defaults
+	// to 1024); once it is full they are dropped, counted in AuditRecordsDropped.
+	AuditSink       AuditSink
+	AuditBufferSize int
+
+	// Models whose row changes are appended to the audit_log table with the
+	// changed values, the actor from WithAuditActor and the request ID from
+	// WithRequestID, in the transaction making them (see AuditLogEntry)
+	AuditLogModels []interface{}
+
+	// Keys encrypting the model fields tagged `gorm:"serializer:encrypted"`, e.g.
+	// medical conditions, so they are never stored in plaintext (see Keyring)
+	EncryptionKeyring *Keyring
+
+	// OutboxSink receives the events EnqueueOutbox queued, relayed every
+	// OutboxPollInterval (defaults to 1s) and on Postgres as soon as they commit,
+	// OutboxBatchSize at a time (defaults to 100). Failed deliveries are retried
+	// after OutboxRetryInterval (defaults to 1s), doubling with every failure, and
+	// dead-lettered after OutboxMaxAttempts (defaults to 10).
+	OutboxSink          OutboxSink
+	OutboxPollInterval  time.Duration
+	OutboxBatchSize     int
+	OutboxRetryInterval time.Duration
+	OutboxMaxAttempts   int
+
+	// Warn when the primary generates WAL faster than this many bytes per second,
+	// measured between health check ticks (0 disables)
+	WALRateWarnThreshold float64
+
+	// Call OnSuspectedLeak when a pool's in-use connections rose on every one of the
+	// last LeakDetectionWindow health checks without falling back toward their
+	// baseline, which points at leaked connections rather than load (0 disables)
+	LeakDetectionWindow int
+	OnSuspectedLeak     func(LeakTrend)
+
+	// Serve reads from the primary when the replica is lagged or unavailable.
+	// Disable it on heavily loaded primaries to get an error instead.
+	PrimaryReadFallback bool
+
+	// Measure replica lag every ReplicaLagInterval in the background (0 disables) and,
+	// while it exceeds MaxReplicaLag, serve reads from the primary (0 never does)
+	ReplicaLagInterval time.Duration
+	MaxReplicaLag      time.Duration
+
+	// Route read-only statements issued on the primary handle (GetDB, GetWriteDB)
+	// to a healthy replica, guessing intent from the SQL; ContextWithIntent overrides
+	// the guess. Statements in transactions and Sessions are never routed.
+	AutoRouteReads bool
+
+	// ReplicaFilter filters and reorders the replica candidates for each read, e.g. to
+	// avoid a node the request just wrote through; the first healthy candidate it
+	// returns serves the read, and none means the primary (nil passes all through)
+	ReplicaFilter func(candidates []ReplicaInfo, ctx context.Context) []ReplicaInfo
+
+	// After a write, reads with the same request context go to the primary for this
+	// long, so the request reads its own writes (0 disables; see WithReadYourWrites)
+	ReadYourWritesWindow time.Duration
+
+	// RequestContextMiddleware starts a causal session on every request, whose reads
+	// only use a replica once it replayed the request's writes (see WithCausalConsistency)
+	CausalConsistency bool
+
+	// Maximum concurrent replica reads through Read (0 disables the budget)
+	ReplicaReadBudget int
+
+	// What happens to reads beyond the replica read budget
+	ReplicaBudgetPolicy ReplicaBudgetPolicy
+
+	// Maximum shards of one ParallelRead running at once (defaults to 4)
+	ParallelReadConcurrency int
+
+	// Circuit breaking: consecutive failures before a breaker opens (0 disables),
+	// how long it fails fast before probing, and whether each operation name
+	// (see WithOperationName) gets its own breaker instead of one for the database
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+	PerOperationBreakers    bool
+
+	// Also open breakers once this share of their last CircuitBreakerWindow
+	// statements failed (0 disables; the window defaults to 20)
+	CircuitBreakerErrorRate float64
+	CircuitBreakerWindow    int
+
+	// Keep a breaker per target (primary, replica) as well, fed by every statement
+	// run on it. While the primary's is open RetryOperation and transactions fail
+	// fast, and while the replica's is open reads go to the primary. After the
+	// cooldown a ping probes the target before traffic returns to it.
+	TargetBreakers bool
+
+	// Retry settings for RetryOperation: attempts, the base of the exponential
+	// backoff, the cap on one delay (defaults to 5m) and on the time spent
+	// retrying (0 does not limit it)
+	MaxRetries       int
+	RetryInterval    time.Duration
+	RetryMaxInterval time.Duration
+	RetryMaxElapsed  time.Duration
+
+	// RetryPolicy replaces the ExponentialBackoff MaxRetries, RetryInterval and
+	// RetryMaxInterval describe, e.g. NoRetry for user-facing services; RetryMaxElapsed
+	// and the context still bound it
+	RetryPolicy RetryPolicy
 
-	// Logging
+	// Extra attempts SerializableTransaction gives a transaction failing to serialize,
+	// and whether it then falls back to REPEATABLE READ (see SerializableTransaction)
+	SerializableRetries  int
+	SerializableFallback bool
+
+	// Extra attempts NestedTransactionWithRetry gives a deadlocked savepoint step
+	SavepointRetries int
+
+	// Extra attempts TransactionWithRetry gives a transaction failing to serialize
+	// or deadlocking, and the base of the backoff between them (capped at RetryMaxInterval)
+	TransactionRetries       int
+	TransactionRetryInterval time.Duration
+
+	// Rebuild the primary pool from scratch once the primary has been unhealthy
+	// for this long, as a fresh pool can recover faster than a poisoned one (0 disables)
+	PoolResetAfter time.Duration
+
+	// Wait this long after a failed rebuild before trying again, doubling with every
+	// further failure up to PoolResetMaxBackoff (defaults to 5m); reconnecting
+	// resets the wait (0 retries on every health check)
+	PoolResetBackoff    time.Duration
+	PoolResetMaxBackoff time.Duration
+
+	// Statements run on a rebuilt primary pool before it takes traffic, such as
+	// reads touching hot tables and indexes, so the first writes after a failover
+	// do not hit a cold cache. Bounded by PrimaryWarmupTimeout (defaults to 30s).
+	PrimaryWarmupQueries []string
+	PrimaryWarmupTimeout time.Duration
+
+	// VacuumTables skips tables autovacuum processed more recently than this (0 disables)
+	VacuumCooldown time.Duration
+
+	// Migrations run under an advisory lock so concurrent instances don't race.
+	// Instances finding it held wait up to MigrationLockTimeout (0 waits indefinitely)
+	// or, with SkipIfMigrationLocked, return without migrating.
+	MigrationLockTimeout time.Duration
+	MigrationLockPolicy  MigrationLockPolicy
+
+	// Versioned migrations applied by MigrateUp and MigrateTo and reverted by
+	// MigrateDown and MigrateTo; versions must be positive and unique
+	Migrations []Migration
+
+	// Raw SQL statements Named and NamedExec run by name
+	Queries *QueryRegistry
+
+	// Permanently delete rows of SoftDeleteModels soft-deleted longer than
+	// SoftDeleteRetention ago (0 disables), checking every SoftDeletePurgeInterval
+	// (defaults to 1h)
+	SoftDeleteModels        []interface{}
+	SoftDeleteRetention     time.Duration
+	SoftDeletePurgeInterval time.Duration
+
+	// Models whose every version is kept in a <table>_history table, for AsOf
+	// queries; create the tables with MigrateHistory
+	HistoryModels []interface{}
+
+	// Deployment environment, e.g. development, staging or production; Seed only runs
+	// seeders allowed in it, so when empty only seeders listing "" run
+	Environment string
+
+	// Cancel a running statement on the server as soon as its context is done, e.g.
+	// when the HTTP client disconnects, instead of relying on the driver to notice.
+	// Costs one pg_backend_pid query per new connection with lib/pq.
+	CancelBackendOnDisconnect bool
+
+	// Set application_name to the trace and span ID of each statement's context
+	// (see WithTraceID), so pg_stat_activity can be matched with the trace.
+	// Costs one SET whenever a connection switches traces.
+	TraceApplicationName bool
+
+	// Maximum bytes QueryMaps, Select and StreamJSON may scan for one result (0 disables)
+	MaxResultBytes int64
+
+	// Rows StreamRowsChan buffers ahead of its consumer (defaults to 64)
+	StreamRowsBuffer int
+
+	// Rows Stream fetches per round trip from its Postgres cursor (defaults to 1000)
+	StreamFetchSize int
+
+	// Rows UpsertMany writes per INSERT ... ON CONFLICT statement (defaults to 500)
+	UpsertBatchSize int
+
+	// Delay before Subscribe's LISTEN connection reconnects, doubling with every
+	// failed attempt up to NotifyMaxReconnectInterval (defaults to 1s and 1m)
+	NotifyReconnectInterval    time.Duration
+	NotifyMaxReconnectInterval time.Duration
+
+	// Notifications each Subscribe channel buffers ahead of its consumer (defaults to 64)
+	NotificationBuffer int
+
+	// Rewrite the MaskedColumns of rows read through GORM, whatever they are scanned
+	// into, with deterministic fakes derived with MaskingSalt, so staging can run on a
+	// copy of production data; refused when Environment is production. Keys are
+	// "table.column", or a column name for every table; raw queries only match the
+	// latter. CloneMasked applies the rules whether or not MaskReads is set.
+	MaskReads     bool
+	MaskedColumns map[string]Masker
+	MaskingSalt   string
+
+	// Converts scanned values by column name in QueryMaps, StreamJSON and
+	// StreamRowsChan, e.g. timestamps to epoch millis for JSON exports
+	ColumnCoercion map[string]func(interface{}) interface{}
+
+	// Wrap statement errors in a QueryError naming the operation, table and
+	// fingerprint; errors.Is and errors.As still see the original error
+	WrapQueryErrors bool
+
+	// Logging; Logger receives the package's logs as structured records and, when
+	// set, GORM's statement logs with query, duration and error attributes
+	// (defaults to slog.Default and GORM's standard logger)
 	LogLevel      logger.LogLevel
 	SlowThreshold time.Duration
+	Logger        *slog.Logger
+
+	// Session settings applied to every new replica (read) and primary (write)
+	// connection, e.g. a higher work_mem for reports; names must be in allowedConnSettings
+	ReadConnSettings  map[string]string
+	WriteConnSettings map[string]string
+
+	// Prepare statements in GORM's statement cache
+	PrepareStmt bool
+
+	// Run DEALLOCATE ALL before a pooled connection is reused, for PgBouncer
+	// setups where prepared statements leak across server sessions.
+	// Requires PrepareStmt to be disabled.
+	DeallocateOnReturn bool
+
+	// Store for CachedQuery results; use a RedisCacheStore to share the cache
+	// across instances (defaults to a process-local memory store)
+	CacheStore CacheStore
+
+	// Cache CachedQuery lookups that found no record for this long, so repeated
+	// lookups of a missing key skip the database; keep it short, as the record may
+	// soon be created (0 disables)
+	NegativeTTL time.Duration
+
+	// TracerProvider enables OpenTelemetry tracing: every statement gets a client span
+	// with its fingerprint, rows affected and target pool, and transactions,
+	// migrations and health checks get spans of their own (nil disables)
+	TracerProvider trace.TracerProvider
+
+	// OnEvent receives notable events such as read fallbacks; it must not block
+	OnEvent func(Event)
+
+	// CredentialProvider returns dsn with current credentials, e.g. a fresh IAM
+	// token as the password. Credentials are reused until a connection fails to
+	// authenticate (SQLSTATE 28xxx), then refreshed once for the next connection.
+	CredentialProvider func(ctx context.Context, dsn string) (string, error)
+
+	// Connector builds the driver connector for a DSN (defaults to lib/pq)
+	Connector func(dsn string) (driver.Connector, error)
+
+	// Dialector wraps an opened pool for GORM (defaults to postgres)
+	Dialector func(conn *sql.DB) gorm.Dialector
 }
 
 // DefaultProductionConfig returns default production database configuration
 func DefaultProductionConfig() *ProductionConfig {
 	return &ProductionConfig{
-		MaxOpenConnections:    25,
-		MaxIdleConnections:    10,
-		ConnectionMaxLifetime: 5 * time.Minute,
-		ConnectionMaxIdleTime: 5 * time.Minute,
-		HealthCheckInterval:   30 * time.Second,
-		HealthCheckTimeout:    5 * time.Second,
-		MaxRetries:            3,
-		RetryInterval:         1 * time.Second,
-		LogLevel:              logger.Warn, // Only warnings and errors in production
-		SlowThreshold:         200 * time.Millisecond,
+		MaxOpenConnections:       25,
+		MaxIdleConnections:       10,
+		ConnectionMaxLifetime:    5 * time.Minute,
+		ConnectionMaxIdleTime:    5 * time.Minute,
+		HealthCheckInterval:      30 * time.Second,
+		HealthCheckTimeout:       5 * time.Second,
+		PrePingSampleSize:        5,
+		PrimaryReadFallback:      true,
+		CircuitBreakerCooldown:   30 * time.Second,
+		MaxRetries:               3,
+		RetryInterval:            1 * time.Second,
+		RetryMaxInterval:         30 * time.Second,
+		SerializableRetries:      3,
+		SavepointRetries:         3,
+		TransactionRetries:       3,
+		TransactionRetryInterval: 50 * time.Millisecond,
+		LogLevel:                 logger.Warn, // Only warnings and errors in production
+		SlowThreshold:            200 * time.Millisecond,
+		PrepareStmt:              true, // Preprepare statements for better performance
+	}
+}
+
+// Validate checks the configuration for incompatible settings
+func (config *ProductionConfig) Validate() error {
+	if config.DeallocateOnReturn && config.PrepareStmt {
+		return errors.New("DeallocateOnReturn cannot be combined with PrepareStmt: GORM's statement cache would reference deallocated statements")
+	}
+	if err := validateConnSettings("ReadConnSettings", config.ReadConnSettings); err != nil {
+		return err
+	}
+	if err := validateConnSettings("WriteConnSettings", config.WriteConnSettings); err != nil {
+		return err
 	}
+	if err := validateMigrations(config.Migrations); err != nil {
+		return err
+	}
+	if config.MaskReads && config.Environment == "production" {
+		return errors.New("MaskReads cannot be enabled in production: writes would store the fakes")
+	}
+	return nil
 }
 
 // ProductionDatabase manages production database connections with pooling and failover
 type ProductionDatabase struct {
-	primaryDB     *gorm.DB
-	replicaDB     *gorm.DB
-	sqlDB         *sql.DB
+	// poolMu guards the pools, which are swapped when the primary pool is rebuilt
+	// or the database fails over to another region
+	poolMu    sync.RWMutex
+	primaryDB *gorm.DB
+	sqlDB     *sql.DB
+	replicaDB *gorm.DB
+
+	// transactionDB is the primary sub-pool serving transactions; nil without MaxTransactionConnections
+	transactionDB *gorm.DB
+
+	// primaryURL and replicaURL locate the region the pools connect to; they start
+	// as the configured URLs and change on FailoverToRegion, leaving config untouched
+	primaryURL string
+	replicaURL string
+
+	// reconnectMu serialises primary pool rebuilds with region failovers
+	reconnectMu sync.Mutex
+
 	config        *ProductionConfig
+	gormConfig    *gorm.Config
 	healthChecker *HealthChecker
+
+	// poolResets counts primary pool rebuilds after sustained failure
+	poolResets int64
+
+	// statementTimeouts counts statements cancelled by statement_timeout
+	statementTimeouts int64
+
+	// statementErrors counts failed statements per ErrorClass
+	statementErrors errorCounts
+
+	// healthCheckFailures counts health checks that found the primary unhealthy
+	healthCheckFailures int64
+
+	// unorderedLimits counts SELECTs with LIMIT but no ORDER BY; unorderedLimitWarned
+	// holds the fingerprints already warned about
+	unorderedLimits      int64
+	unorderedLimitWarned sync.Map
+
+	// auditRecords queues records for the AuditSink; auditDropped counts those that did not fit
+	auditRecords chan AuditRecord
+	auditDropped int64
+
+	// tracer starts the package's spans; nil when tracing is disabled
+	tracer trace.Tracer
+
+	// breakers guards statements per operation; nil when circuit breaking is disabled
+	breakers *breakerSet
+
+	// targetBreakers track the primary and replica; nil without TargetBreakers
+	targetBreakers *breakerSet
+
+	// replicaSlots is the replica read budget semaphore; nil when unlimited
+	replicaSlots chan struct{}
+
+	// cache holds CachedQuery results
+	cache CacheStore
+
+	// cacheLoads coalesces concurrent CachedQuery misses on the same key
+	cacheLoads singleflight.Group
+
+	// Maintenance tasks run on maintenanceCtx, which Close cancels
+	maintenanceCtx       context.Context
+	stopMaintenanceTasks context.CancelFunc
+	maintenance          sync.WaitGroup
+
+	// retryStats counts RetryOperation and TransactionWithRetry errors per SQLSTATE
+	retryStats retryStats
+
+	// namedQueryStats counts Named and NamedExec statements per query name
+	namedQueryStats namedQueryStats
+
+	// notifications delivers LISTEN/NOTIFY messages to Subscribe channels
+	notifications notifier
+
+	// latency tracks statement latency percentiles
+	latency *latencyTracker
+
+	// metricsSnapshot holds the pool statistics sampled every MetricsSampleInterval
+	metricsMu       sync.RWMutex
+	metricsSnapshot map[string]sql.DBStats
+
+	// walSample is the WAL position WALGenerationRate measures from
+	walSample walSample
+
+	// autovacuumProbe reports when a table was last autovacuumed; nil uses LastAutovacuum
+	autovacuumProbe func(ctx context.Context, table string) (time.Time, error)
+
+	// lagProbe measures replica lag; nil uses measureReplicaLag
+	lagProbe func(ctx context.Context, replica *gorm.DB) (time.Duration, error)
+
+	// lsnProbe reads a WAL position with query; nil uses queryLSN
+	lsnProbe func(ctx context.Context, conn *gorm.DB, query string) (LSN, error)
+
+	// replicaLagNanos is the lag the lag monitor last measured, once replicaLagMeasured
+	// is 1; replicaStale is 1 while that lag exceeds MaxReplicaLag
+	replicaLagNanos    int64
+	replicaLagMeasured int32
+	replicaStale       int32
+
+	// replicaUnhealthy is 1 while the health checker's last replica ping failed;
+	// reads consult it instead of pinging the replica themselves
+	replicaUnhealthy int32
 }
 
 // HealthChecker monitors database health
@@ -70,6 +494,22 @@ type HealthChecker struct {
 	interval time.Duration
 	timeout  time.Duration
 	stop     chan bool
+
+	// newTicker starts the checker's tickers; nil uses newTimeTicker
+	newTicker tickerFunc
+
+	// unhealthySince is when the primary started failing health checks; zero while healthy
+	unhealthySince time.Time
+
+	// rebuildBackoff spaces out failed primary pool rebuilds until nextRebuildAt
+	rebuildBackoff backoff
+	nextRebuildAt  time.Time
+
+	// wake runs a health check ahead of the next tick
+	wake chan struct{}
+
+	// inUseHistory holds the recent in-use connection samples of each pool role
+	inUseHistory map[string][]int
 }
 
 // NewProductionDatabase creates a new production database instance
@@ -78,60 +518,120 @@ func NewProductionDatabase(config *ProductionConfig) (*ProductionDatabase, error
 		config = DefaultProductionConfig()
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+
 	// Configure GORM logger
+	gormLogger := logger.New(
+		log.New(log.Writer(), "\r\n", log.LstdFlags),
+		logger.Config{
+			SlowThreshold:             config.SlowThreshold,
+			LogLevel:                  config.LogLevel,
+			IgnoreRecordNotFoundError: true,
+		},
+	)
+	if config.Logger != nil {
+		gormLogger = newSlogGormLogger(config)
+	}
+
 	gormConfig := &gorm.Config{
-		Logger: logger.New(
-			log.New(log.Writer(), "\r\n", log.LstdFlags),
-			logger.Config{
-				SlowThreshold:             config.SlowThreshold,
-				LogLevel:                  config.LogLevel,
-				IgnoreRecordNotFoundError: true,
-			},
-		),
-		PrepareStmt:                              true, // Preprepare statements for better performance
+		Logger:                                   gormLogger,
+		PrepareStmt:                              config.PrepareStmt,
 		DisableForeignKeyConstraintWhenMigrating: true,
 	}
 
-	// Connect to primary database
-	primaryDB, err := gorm.Open(postgres.Open(config.DatabaseURL), gormConfig)
+	// Connect to primary database with a configured connection pool
+	primaryDB, sqlDB, err := openPool(config, "primary", config.DatabaseURL, gormConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to primary database: %w", err)
 	}
 
-	// Get underlying SQL DB for connection pool configuration
-	sqlDB, err := primaryDB.DB()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get underlying SQL DB: %w", err)
+	prodDB := &ProductionDatabase{
+		primaryDB:  primaryDB,
+		sqlDB:      sqlDB,
+		primaryURL: config.DatabaseURL,
+		replicaURL: config.ReadReplicaURL,
+		config:     config,
+		gormConfig: gormConfig,
+		cache:      config.CacheStore,
+		latency:    newLatencyTracker(),
 	}
 
-	// Configure connection pool
-	sqlDB.SetMaxOpenConns(config.MaxOpenConnections)
-	sqlDB.SetMaxIdleConns(config.MaxIdleConnections)
-	sqlDB.SetConnMaxLifetime(config.ConnectionMaxLifetime)
-	sqlDB.SetConnMaxIdleTime(config.ConnectionMaxIdleTime)
+	if prodDB.cache == nil {
+		prodDB.cache = NewMemoryCacheStore()
+	}
 
-	prodDB := &ProductionDatabase{
-		primaryDB: primaryDB,
-		sqlDB:     sqlDB,
-		config:    config,
+	prodDB.maintenanceCtx, prodDB.stopMaintenanceTasks = context.WithCancel(context.Background())
+
+	if config.ReplicaReadBudget > 0 {
+		prodDB.replicaSlots = make(chan struct{}, config.ReplicaReadBudget)
+	}
+
+	if config.TracerProvider != nil {
+		prodDB.tracer = config.TracerProvider.Tracer(tracerName)
+	}
+
+	if config.CircuitBreakerThreshold > 0 {
+		prodDB.breakers = newBreakerSet(config)
+		if config.TargetBreakers {
+			prodDB.targetBreakers = newBreakerSet(config)
+		}
+	}
+
+	if err := prodDB.registerCallbacks(primaryDB, "primary"); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	if prodDB.transactionDB, err = prodDB.openTransactionPool(config.DatabaseURL); err != nil {
+		sqlDB.Close()
+		return nil, err
 	}
 
 	// Connect to read replica if configured
 	if config.ReadReplicaURL != "" {
-		replicaDB, err := gorm.Open(postgres.Open(config.ReadReplicaURL), gormConfig)
+		replicaDB, _, err := openPool(config, "replica", config.ReadReplicaURL, gormConfig)
 		if err != nil {
-			log.Printf("Warning: failed to connect to read replica: %v", err)
+			prodDB.logger().Warn("Failed to connect to read replica", "error", err)
+		} else if err := prodDB.registerCallbacks(replicaDB, "replica"); err != nil {
+			prodDB.logger().Warn("Failed to instrument read replica", "error", err)
 		} else {
 			prodDB.replicaDB = replicaDB
+		}
+	}
 
-			// Configure replica connection pool
-			if replicaSQLDB, err := replicaDB.DB(); err == nil {
-				replicaSQLDB.SetMaxOpenConns(config.MaxOpenConnections)
-				replicaSQLDB.SetMaxIdleConns(config.MaxIdleConnections)
-				replicaSQLDB.SetConnMaxLifetime(config.ConnectionMaxLifetime)
-				replicaSQLDB.SetConnMaxIdleTime(config.ConnectionMaxIdleTime)
-			}
+	if config.AuditSink != nil {
+		prodDB.startAuditing()
+	}
+
+	if config.OutboxSink != nil {
+		prodDB.startOutboxRelay()
+	}
+
+	if config.SoftDeleteRetention > 0 && len(config.SoftDeleteModels) > 0 {
+		interval := config.SoftDeletePurgeInterval
+		if interval <= 0 {
+			interval = defaultSoftDeletePurgeInterval
 		}
+		prodDB.ScheduleMaintenance(MaintenanceTask{
+			Name:     "soft_delete_purge",
+			Interval: interval,
+			Run: func(ctx context.Context, db *ProductionDatabase) error {
+				_, err := db.PurgeSoftDeleted(ctx, db.config.SoftDeleteRetention, db.config.SoftDeleteModels...)
+				return err
+			},
+		})
+	}
+
+	if prodDB.replicaDB != nil && config.ReplicaLagInterval > 0 {
+		prodDB.ScheduleMaintenance(MaintenanceTask{
+			Name:     "replica_lag",
+			Interval: config.ReplicaLagInterval,
+			Run: func(ctx context.Context, db *ProductionDatabase) error {
+				return db.monitorReplicaLag(ctx)
+			},
+		})
 	}
 
 	// Start health checker
@@ -140,48 +640,73 @@ func NewProductionDatabase(config *ProductionConfig) (*ProductionDatabase, error
 		interval: config.HealthCheckInterval,
 		timeout:  config.HealthCheckTimeout,
 		stop:     make(chan bool),
+		wake:     make(chan struct{}, 1),
+
+		rebuildBackoff: backoff{base: config.PoolResetBackoff, max: config.PoolResetMaxBackoff},
 	}
 
 	prodDB.healthChecker = healthChecker
 	go healthChecker.Start()
 
-	log.Println("✅ Production database connected successfully")
+	prodDB.logger().Info("Production database connected")
 	if prodDB.replicaDB != nil {
-		log.Println("✅ Read replica connected successfully")
+		prodDB.logger().Info("Read replica connected")
 	}
 
 	return prodDB, nil
 }
 
 // GetReadDB returns the appropriate database for read operations
-// Uses replica if available, falls back to primary
+// Uses replica if available, falls back to primary. Each statement run on the
+// replica holds a slot of the replica read budget while it runs; statements
+// beyond the budget are routed to the primary or fail with
+// ErrReplicaBudgetExceeded, depending on ReplicaBudgetPolicy.
 func (db *ProductionDatabase) GetReadDB() *gorm.DB {
-	if db.replicaDB != nil {
-		// Check if replica is healthy
-		if sqlDB, err := db.replicaDB.DB(); err == nil {
-			if err := sqlDB.Ping(); err == nil {
-				return db.replicaDB
-			}
-			log.Printf("Read replica unhealthy, falling back to primary: %v", err)
-		}
+	return db.GetReadDBContext(context.Background())
+}
+
+// GetReadDBContext is GetReadDB with the replica chosen for ctx by ReplicaFilter
+func (db *ProductionDatabase) GetReadDBContext(ctx context.Context) *gorm.DB {
+	replica := db.selectReplica(ctx)
+	if replica == nil || (db.replicaBudgetExhausted() && db.config.ReplicaBudgetPolicy == ShedToPrimary) {
+		return db.primary()
+	}
+	return replica
+}
+
+// healthyReplica returns the replica if it is configured and passed its last health
+// check, otherwise nil. It never touches the network: it runs on the request path.
+func (db *ProductionDatabase) healthyReplica() *gorm.DB {
+	if atomic.LoadInt32(&db.replicaUnhealthy) == 1 {
+		return nil
+	}
+	return db.replica()
+}
+
+// observeReplicaHealth records the result of a replica health check for healthyReplica
+func (db *ProductionDatabase) observeReplicaHealth(err error) {
+	switch {
+	case err != nil && atomic.CompareAndSwapInt32(&db.replicaUnhealthy, 0, 1):
+		db.logger().Warn("Read replica unhealthy, reading from primary", "error", err)
+	case err == nil && atomic.CompareAndSwapInt32(&db.replicaUnhealthy, 1, 0):
+		db.logger().Info("Read replica healthy again")
 	}
-	return db.primaryDB
 }
 
 // GetWriteDB returns the primary database for write operations
 func (db *ProductionDatabase) GetWriteDB() *gorm.DB {
-	return db.primaryDB
+	return db.primary()
 }
 
 // GetDB returns the primary database (for backward compatibility)
 func (db *ProductionDatabase) GetDB() *gorm.DB {
-	return db.primaryDB
+	return db.primary()
 }
 
 // Health performs health check on all database connections
 func (db *ProductionDatabase) Health() error {
 	// Check primary database
-	if sqlDB, err := db.primaryDB.DB(); err == nil {
+	if sqlDB, err := db.primary().DB(); err == nil {
 		if err := sqlDB.Ping(); err != nil {
 			return fmt.Errorf("primary database unhealthy: %w", err)
 		}
@@ -189,14 +714,17 @@ func (db *ProductionDatabase) Health() error {
 		return fmt.Errorf("cannot access primary database: %w", err)
 	}
 
-	// Check replica if configured
-	if db.replicaDB != nil {
-		if sqlDB, err := db.replicaDB.DB(); err == nil {
-			if err := sqlDB.Ping(); err != nil {
-				log.Printf("Read replica health check failed: %v", err)
-				// Don't return error, just log it
-			}
+	// Check replica if configured; reads fall back to the primary while it fails
+	if replicaDB := db.replica(); replicaDB != nil {
+		sqlDB, err := replicaDB.DB()
+		if err == nil {
+			err = sqlDB.Ping()
+		}
+		if err != nil {
+			db.logger().Warn("Read replica health check failed", "error", err)
+			// Don't return error, just log it
 		}
+		db.observeReplicaHealth(err)
 	}
 
 	return nil
@@ -206,39 +734,88 @@ func (db *ProductionDatabase) Health() error {
 func (db *ProductionDatabase) Stats() map[string]interface{} {
 	stats := make(map[string]interface{})
 
-	if sqlDB, err := db.primaryDB.DB(); err == nil {
-		dbStats := sqlDB.Stats()
-		stats["primary"] = map[string]interface{}{
-			"open_connections":     dbStats.OpenConnections,
-			"in_use":               dbStats.InUse,
-			"idle":                 dbStats.Idle,
-			"wait_count":           dbStats.WaitCount,
-			"wait_duration":        dbStats.WaitDuration.String(),
-			"max_idle_closed":      dbStats.MaxIdleClosed,
-			"max_idle_time_closed": dbStats.MaxIdleTimeClosed,
-			"max_lifetime_closed":  dbStats.MaxLifetimeClosed,
+	for role, dbStats := range db.poolStats() {
+		stats[role] = poolStatsMap(dbStats)
+	}
+
+	if db.breakers != nil {
+		stats["circuit_breakers"] = db.CircuitBreakerStates()
+	}
+	if db.targetBreakers != nil {
+		stats["target_circuit_breakers"] = db.TargetBreakerStates()
+	}
+
+	if db.config.PoolResetAfter > 0 {
+		stats["primary_pool_resets"] = db.PoolResets()
+	}
+
+	stats["statement_timeouts"] = db.StatementTimeoutTotal()
+	stats["statement_errors"] = db.StatementErrorsByClass()
+	stats["health_check_failures"] = db.HealthCheckFailures()
+
+	if db.config.Queries != nil {
+		stats["named_queries"] = db.NamedQueryStats()
+	}
+
+	stats["notifications_dropped"] = db.NotificationsDropped()
+
+	if db.config.WarnOnUnorderedLimit {
+		stats["unordered_limits"] = db.UnorderedLimitTotal()
+	}
+	if db.config.AuditSink != nil {
+		stats["audit_records_dropped"] = db.AuditRecordsDropped()
+	}
+
+	if lag, ok := db.MonitoredReplicaLag(); ok {
+		stats["replica_lag_seconds"] = lag.Seconds()
+		stats["replica_stale"] = db.replicaIsStale()
+	}
+
+	if db.replicaSlots != nil {
+		stats["replica_reads_in_flight"] = db.ReplicaReadsInFlight()
+		stats["replica_read_budget"] = cap(db.replicaSlots)
+	}
+
+	return stats
+}
+
+// poolStats returns the raw pool statistics of each connection pool keyed by role
+func (db *ProductionDatabase) poolStats() map[string]sql.DBStats {
+	stats := make(map[string]sql.DBStats)
+
+	if sqlDB, err := db.primary().DB(); err == nil {
+		stats["primary"] = sqlDB.Stats()
+	}
+
+	if transactionDB := db.transactionPool(); transactionDB != nil {
+		if sqlDB, err := transactionDB.DB(); err == nil {
+			stats["transaction"] = sqlDB.Stats()
 		}
 	}
 
-	if db.replicaDB != nil {
-		if sqlDB, err := db.replicaDB.DB(); err == nil {
-			dbStats := sqlDB.Stats()
-			stats["replica"] = map[string]interface{}{
-				"open_connections":     dbStats.OpenConnections,
-				"in_use":               dbStats.InUse,
-				"idle":                 dbStats.Idle,
-				"wait_count":           dbStats.WaitCount,
-				"wait_duration":        dbStats.WaitDuration.String(),
-				"max_idle_closed":      dbStats.MaxIdleClosed,
-				"max_idle_time_closed": dbStats.MaxIdleTimeClosed,
-				"max_lifetime_closed":  dbStats.MaxLifetimeClosed,
-			}
+	if replicaDB := db.replica(); replicaDB != nil {
+		if sqlDB, err := replicaDB.DB(); err == nil {
+			stats["replica"] = sqlDB.Stats()
 		}
 	}
 
 	return stats
 }
 
+// poolStatsMap converts pool statistics into the map format returned by Stats
+func poolStatsMap(dbStats sql.DBStats) map[string]interface{} {
+	return map[string]interface{}{
+		"open_connections":     dbStats.OpenConnections,
+		"in_use":               dbStats.InUse,
+		"idle":                 dbStats.Idle,
+		"wait_count":           dbStats.WaitCount,
+		"wait_duration":        dbStats.WaitDuration.String(),
+		"max_idle_closed":      dbStats.MaxIdleClosed,
+		"max_idle_time_closed": dbStats.MaxIdleTimeClosed,
+		"max_lifetime_closed":  dbStats.MaxLifetimeClosed,
+	}
+}
+
 // Close closes all database connections and stops health checker
 func (db *ProductionDatabase) Close() error {
 	// Stop health checker
@@ -246,18 +823,29 @@ func (db *ProductionDatabase) Close() error {
 		db.healthChecker.Stop()
 	}
 
+	// Stop maintenance tasks before their connections go away
+	db.stopMaintenance()
+
 	var errors []error
 
 	// Close primary database
-	if db.sqlDB != nil {
-		if err := db.sqlDB.Close(); err != nil {
+	if sqlDB := db.primaryPool(); sqlDB != nil {
+		if err := sqlDB.Close(); err != nil {
 			errors = append(errors, fmt.Errorf("failed to close primary database: %w", err))
 		}
 	}
 
+	if transactionDB := db.transactionPool(); transactionDB != nil {
+		if sqlDB, err := transactionDB.DB(); err == nil {
+			if err := sqlDB.Close(); err != nil {
+				errors = append(errors, fmt.Errorf("failed to close transaction pool: %w", err))
+			}
+		}
+	}
+
 	// Close replica database
-	if db.replicaDB != nil {
-		if replicaSQLDB, err := db.replicaDB.DB(); err == nil {
+	if replicaDB := db.replica(); replicaDB != nil {
+		if replicaSQLDB, err := replicaDB.DB(); err == nil {
 			if err := replicaSQLDB.Close(); err != nil {
 				errors = append(errors, fmt.Errorf("failed to close replica database: %w", err))
 			}
@@ -268,125 +856,181 @@ func (db *ProductionDatabase) Close() error {
 		return fmt.Errorf("database close errors: %v", errors)
 	}
 
-	log.Println("✅ Production database connections closed")
+	db.logger().Info("Production database connections closed")
 	return nil
 }
 
 // Start begins the health checking routine
 func (hc *HealthChecker) Start() {
-	ticker := time.NewTicker(hc.interval)
-	defer ticker.Stop()
+	newTicker := hc.newTicker
+	if newTicker == nil {
+		newTicker = newTimeTicker
+	}
+
+	healthTicks, stopHealthTicks := newTicker(hc.interval)
+	defer stopHealthTicks()
+
+	// A nil channel never fires, leaving pre-ping disabled
+	var prePing <-chan time.Time
+	if hc.db.config.PrePingInterval > 0 {
+		var stopPrePing func()
+		prePing, stopPrePing = newTicker(hc.db.config.PrePingInterval)
+		defer stopPrePing()
+	}
+
+	// Likewise metrics sampling, which starts with a snapshot so scrapes have one
+	var metricsTicks <-chan time.Time
+	if hc.db.config.MetricsSampleInterval > 0 {
+		var stopMetricsTicks func()
+		metricsTicks, stopMetricsTicks = newTicker(hc.db.config.MetricsSampleInterval)
+		defer stopMetricsTicks()
+		hc.db.samplePoolMetrics()
+	}
 
 	for {
 		select {
-		case <-ticker.C:
-			if err := hc.db.Health(); err != nil {
-				log.Printf("Database health check failed: %v", err)
-			}
+		case <-healthTicks:
+			hc.check()
+		case <-hc.wake:
+			hc.check()
+		case <-prePing:
+			hc.db.prePingIdleConnections()
+		case <-metricsTicks:
+			hc.db.samplePoolMetrics()
 		case <-hc.stop:
 			return
 		}
 	}
 }
 
+// check runs one health check
+func (hc *HealthChecker) check() {
+	err := hc.db.traced(context.Background(), "db.health_check", func(context.Context) error {
+		return hc.db.Health()
+	})
+	if err != nil {
+		atomic.AddInt64(&hc.db.healthCheckFailures, 1)
+		hc.db.logger().Error("Database health check failed", "error", err)
+	}
+	hc.observePrimaryHealth(err)
+	hc.checkBlockedQueries()
+	hc.checkPreparedTransactions()
+	hc.checkWALRate()
+	hc.checkConnectionLeaks()
+	hc.logHealthReport()
+}
+
+// HealthCheckFailures returns how many health checks found the primary unhealthy
+func (db *ProductionDatabase) HealthCheckFailures() int64 {
+	return atomic.LoadInt64(&db.healthCheckFailures)
+}
+
+// Wake runs a health check now instead of waiting for the next tick
+// Calls made while a check is already pending are coalesced.
+func (hc *HealthChecker) Wake() {
+	select {
+	case hc.wake <- struct{}{}:
+	default:
+	}
+}
+
 // Stop stops the health checking routine
 func (hc *HealthChecker) Stop() {
 	close(hc.stop)
 }
 
-// RetryOperation retries a database operation with exponential backoff
-func (db *ProductionDatabase) RetryOperation(operation func() error) error {
+// RetryOperation runs operation, retrying failures as the RetryPolicy decides
+// (by default retryable errors up to MaxRetries attempts, waiting a random delay of
+// up to RetryInterval doubled per attempt, capped at RetryMaxInterval). Retrying
+// stops once the next attempt would start after RetryMaxElapsed or ctx is done.
+// Waiting ends as soon as ctx is done, and the error then wraps both ctx's error
+// and the operation's last error.
+func (db *ProductionDatabase) RetryOperation(ctx context.Context, operation func() error) error {
+	started := time.Now()
+	policy := db.retryPolicy()
 	var lastErr error
 
-	for attempt := 0; attempt < db.config.MaxRetries; attempt++ {
-		if err := operation(); err != nil {
-			lastErr = err
-
-			// Don't retry on certain errors
-			if isNonRetryableError(err) {
-				return err
+	for attempt := 1; ; attempt++ {
+		if err := db.primaryAvailable(); err != nil {
+			if lastErr != nil {
+				return fmt.Errorf("%w (last error: %v)", err, lastErr)
 			}
+			return err
+		}
 
-			if attempt < db.config.MaxRetries-1 {
-				backoff := time.Duration(attempt+1) * db.config.RetryInterval
-				log.Printf("Database operation failed (attempt %d/%d), retrying in %v: %v",
-					attempt+1, db.config.MaxRetries, backoff, err)
-				time.Sleep(backoff)
-			}
-		} else {
+		err := TranslateError(operation())
+		if err == nil {
 			return nil
 		}
-	}
+		lastErr = err
 
-	return fmt.Errorf("database operation failed after %d attempts: %w", db.config.MaxRetries, lastErr)
-}
+		if !policy.ShouldRetry(err, attempt) {
+			db.retryStats.record(err, false)
+			if attempt == 1 {
+				return err
+			}
+			return fmt.Errorf("database operation failed after %d attempts: %w", attempt, err)
+		}
 
-// isNonRetryableError checks if an error should not be retried
-func isNonRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
+		delay := policy.NextDelay(attempt)
+		if maxElapsed := db.config.RetryMaxElapsed; maxElapsed > 0 && time.Since(started)+delay > maxElapsed {
+			db.retryStats.record(err, false)
+			return fmt.Errorf("database operation failed after %d attempts in %v: %w", attempt, time.Since(started).Round(time.Millisecond), err)
+		}
 
-	errStr := err.Error()
-	nonRetryableErrors := []string{
-		"constraint violation",
-		"unique constraint",
-		"foreign key constraint",
-		"invalid input syntax",
-		"division by zero",
-		"out of range",
-	}
+		db.logger().Warn("Database operation failed, retrying",
+			"attempt", attempt, "retry_in", delay, "error", err)
 
-	for _, nonRetryable := range nonRetryableErrors {
-		if contains(errStr, nonRetryable) {
-			return true
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+			db.retryStats.record(err, true)
+		case <-ctx.Done():
+			timer.Stop()
+			db.retryStats.record(err, false)
+			return fmt.Errorf("database operation aborted after %d attempts: %w: %w", attempt, ctx.Err(), err)
 		}
 	}
-
-	return false
-}
-
-// contains checks if a string contains a substring (case-insensitive)
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) &&
-		(s == substr ||
-			len(s) > len(substr) &&
-				(s[:len(substr)] == substr ||
-					s[len(s)-len(substr):] == substr ||
-					indexOf(s, substr) >= 0))
 }
 
-// indexOf returns the index of a substring in a string
-func indexOf(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
-	}
-	return -1
+// isNonRetryableError checks if an error should not be retried
+// Errors are classified by SQLSTATE (see ClassifyError); unclassified errors are retried
+func isNonRetryableError(err error) bool {
+	return nonRetryableErrorClasses[ClassifyError(err)]
 }
 
 // Migrate performs database migrations with retry logic
+// Migrations hold an advisory lock so concurrent instances don't race
 func (db *ProductionDatabase) Migrate(models ...interface{}) error {
-	return db.RetryOperation(func() error {
-		return db.primaryDB.AutoMigrate(models...)
+	return db.traced(context.Background(), "db.migrate", func(ctx context.Context) error {
+		return db.withMigrationLock(ctx, func() error {
+			return db.RetryOperation(ctx, func() error {
+				return db.primary().WithContext(ctx).AutoMigrate(models...)
+			})
+		})
 	})
 }
 
 // CreateTables creates tables with retry logic
 func (db *ProductionDatabase) CreateTables(models ...interface{}) error {
-	return db.RetryOperation(func() error {
-		return db.primaryDB.Migrator().CreateTable(models...)
+	return db.RetryOperation(context.Background(), func() error {
+		return db.primary().Migrator().CreateTable(models...)
 	})
 }
 
 // Transaction executes a function within a database transaction with retry logic
 func (db *ProductionDatabase) Transaction(fn func(*gorm.DB) error) error {
-	return db.primaryDB.Transaction(fn)
+	if err := db.primaryAvailable(); err != nil {
+		return err
+	}
+	return db.traced(context.Background(), "db.transaction", func(ctx context.Context) error {
+		return runTransaction(ctx, db.transactionPrimary(), fn)
+	})
 }
 
 // ReplicaTransaction executes a read-only transaction on the replica
 func (db *ProductionDatabase) ReplicaTransaction(fn func(*gorm.DB) error) error {
-	readDB := db.GetReadDB()
-	return readDB.Transaction(fn)
+	return db.traced(context.Background(), "db.transaction", func(ctx context.Context) error {
+		return runTransaction(ctx, db.GetReadDB(), fn)
+	})
 }
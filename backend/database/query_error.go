@@ -0,0 +1,52 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// QueryError annotates a failed statement with what was running, so errors
+// reaching a handler identify their query. Err is the original error.
+type QueryError struct {
+	Operation   string
+	Table       string
+	Fingerprint string
+	Err         error
+}
+
+func (e *QueryError) Error() string {
+	var labels []string
+	for _, label := range []string{e.Operation, e.Table} {
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return fmt.Sprintf("db error [%s] %s: %v", strings.Join(labels, " "), e.Fingerprint, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// wrapQueryError replaces a failed statement's error with a QueryError
+// Not-found results are left alone, as they are outcomes rather than failures.
+func wrapQueryError(tx *gorm.DB) {
+	if tx.Error == nil || errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		return
+	}
+
+	var queryErr *QueryError
+	if errors.As(tx.Error, &queryErr) {
+		return
+	}
+
+	tx.Error = &QueryError{
+		Operation:   OperationName(tx.Statement.Context),
+		Table:       tx.Statement.Table,
+		Fingerprint: fingerprintSQL(tx.Statement.SQL.String()),
+		Err:         tx.Error,
+	}
+}
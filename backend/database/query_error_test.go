@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type queryErrorTestUser struct {
+	ID   int
+	Name string
+}
+
+func (queryErrorTestUser) TableName() string { return "users" }
+
+func TestWrapQueryErrors_NamesFailedQuery(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.WrapQueryErrors = true
+	db := newSQLiteTestDatabase(t, config)
+
+	// The users table does not exist, so the driver rejects the query
+	_, driverErr := db.primaryPool().Exec("SELECT * FROM users")
+	require.Error(t, driverErr)
+
+	ctx := WithOperationName(context.Background(), "get_user")
+	var user queryErrorTestUser
+	err := db.GetDB().WithContext(ctx).Where("id = ?", 7).Find(&user).Error
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "db error [get_user users]")
+	assert.Contains(t, err.Error(), "SELECT * FROM `users` WHERE id = ?")
+	assert.ErrorIs(t, err, driverErr)
+
+	var sqliteErr sqlite3.Error
+	require.True(t, errors.As(err, &sqliteErr))
+	assert.Equal(t, sqlite3.ErrError, sqliteErr.Code)
+
+	var queryErr *QueryError
+	require.True(t, errors.As(err, &queryErr))
+	assert.Equal(t, "get_user", queryErr.Operation)
+	assert.Equal(t, "users", queryErr.Table)
+}
+
+func TestWrapQueryErrors_LeavesNotFoundAlone(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.WrapQueryErrors = true
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE users (id integer PRIMARY KEY, name text)").Error)
+
+	var user queryErrorTestUser
+	err := db.GetDB().First(&user, 1).Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var queryErr *QueryError
+	assert.False(t, errors.As(err, &queryErr))
+}
+
+func TestWrapQueryErrors_DisabledByDefault(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	var user queryErrorTestUser
+	err := db.GetDB().Find(&user).Error
+	require.Error(t, err)
+
+	var queryErr *QueryError
+	assert.False(t, errors.As(err, &queryErr))
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Read runs a read operation against the read database
+// Replica reads are bounded by ReplicaReadBudget; overflow is shed to the
+// primary or rejected with ErrReplicaBudgetExceeded depending on the policy.
+// Reads cancelled on a hot-standby replica because of a recovery conflict are
+// transparently retried on the primary, since the replica cannot serve them
+func (db *ProductionDatabase) Read(ctx context.Context, fn func(*gorm.DB) error) error {
+	primary := db.primary()
+	readDB, readCtx := primary, ctx
+	// release is swapped out once called, so the deferred call only frees a slot
+	// fn panicked while holding
+	release := func() {}
+	defer func() { release() }()
+	if replica := db.selectReplica(ctx); replica != nil {
+		slotRelease, ok := db.acquireReplicaSlot()
+		switch {
+		case ok:
+			release = slotRelease
+			readDB = replica
+			readCtx = withReplicaSlot(ctx)
+		case db.config.ReplicaBudgetPolicy == RejectOverBudget:
+			return ErrReplicaBudgetExceeded
+		}
+	}
+
+	err := fn(readDB.WithContext(readCtx))
+	release()
+	release = func() {}
+	if err == nil || readDB == primary || !isRecoveryConflict(err) {
+		return err
+	}
+
+	db.logger().Warn("Replica read conflicted with recovery, retrying on primary", "error", err)
+	db.emit(EventReplicaRecoveryConflict, "replica read conflicted with recovery, retried on primary", err)
+
+	// The slot is already free: replica concurrency is not held by primary work
+	return fn(db.primary().WithContext(ctx))
+}
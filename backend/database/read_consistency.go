@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReadConsistency is how fresh the data served to a read must be
+type ReadConsistency int
+
+const (
+	// Eventual reads from any healthy replica regardless of lag
+	Eventual ReadConsistency = iota
+
+	// BoundedStaleness reads from a replica only while its lag is within ReadOptions.MaxLag
+	BoundedStaleness
+
+	// Strong always reads from the primary
+	Strong
+)
+
+// String returns the name of the consistency level
+func (c ReadConsistency) String() string {
+	switch c {
+	case Eventual:
+		return "eventual"
+	case BoundedStaleness:
+		return "bounded_staleness"
+	case Strong:
+		return "strong"
+	default:
+		return fmt.Sprintf("ReadConsistency(%d)", int(c))
+	}
+}
+
+// ReadOptions tunes GetReadDBWithConsistency
+type ReadOptions struct {
+	// Maximum replica lag accepted under BoundedStaleness
+	MaxLag time.Duration
+}
+
+// GetReadDBWithConsistency returns the database to read from for a consistency level
+// Eventual routes like GetReadDBContext, BoundedStaleness like GetReadDBOrError with
+// opts.MaxLag (so the primary fallback follows PrimaryReadFallback) and Strong to the primary.
+func (db *ProductionDatabase) GetReadDBWithConsistency(ctx context.Context, level ReadConsistency, opts ReadOptions) (*gorm.DB, error) {
+	switch level {
+	case Eventual:
+		return db.GetReadDBContext(ctx), nil
+	case BoundedStaleness:
+		return db.GetReadDBOrError(ctx, opts.MaxLag)
+	case Strong:
+		return db.primary(), nil
+	default:
+		return nil, fmt.Errorf("unknown read consistency level %v", level)
+	}
+}
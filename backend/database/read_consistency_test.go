@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newConsistencyTestDatabase opens a primary and a reachable replica whose lag is stubbed
+func newConsistencyTestDatabase(t *testing.T, lag time.Duration) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	db := newSQLiteTestDatabase(t, config)
+	require.NotNil(t, db.replicaDB)
+
+	db.lagProbe = func(context.Context, *gorm.DB) (time.Duration, error) {
+		return lag, nil
+	}
+	return db
+}
+
+func TestGetReadDBWithConsistency_EventualUsesLaggedReplica(t *testing.T) {
+	db := newConsistencyTestDatabase(t, time.Hour)
+
+	readDB, err := db.GetReadDBWithConsistency(context.Background(), Eventual, ReadOptions{})
+	require.NoError(t, err)
+	assert.Same(t, db.replicaDB, readDB)
+}
+
+func TestGetReadDBWithConsistency_EventualHonorsReadYourWrites(t *testing.T) {
+	db := newConsistencyTestDatabase(t, 0)
+	db.config.ReadYourWritesWindow = time.Minute
+
+	ctx := db.WithReadYourWrites(context.Background())
+	StickToPrimary(ctx)
+
+	readDB, err := db.GetReadDBWithConsistency(ctx, Eventual, ReadOptions{})
+	require.NoError(t, err)
+	assert.Same(t, db.GetWriteDB(), readDB)
+}
+
+func TestGetReadDBWithConsistency_BoundedStalenessHonorsMaxLag(t *testing.T) {
+	db := newConsistencyTestDatabase(t, 2*time.Second)
+	ctx := context.Background()
+
+	readDB, err := db.GetReadDBWithConsistency(ctx, BoundedStaleness, ReadOptions{MaxLag: 5 * time.Second})
+	require.NoError(t, err)
+	assert.Same(t, db.replicaDB, readDB)
+
+	readDB, err = db.GetReadDBWithConsistency(ctx, BoundedStaleness, ReadOptions{MaxLag: time.Second})
+	require.NoError(t, err)
+	assert.Same(t, db.GetWriteDB(), readDB)
+
+	db.config.PrimaryReadFallback = false
+	_, err = db.GetReadDBWithConsistency(ctx, BoundedStaleness, ReadOptions{MaxLag: time.Second})
+	assert.ErrorIs(t, err, ErrReplicaTooLagged)
+}
+
+func TestGetReadDBWithConsistency_StrongUsesPrimary(t *testing.T) {
+	db := newConsistencyTestDatabase(t, 0)
+
+	readDB, err := db.GetReadDBWithConsistency(context.Background(), Strong, ReadOptions{})
+	require.NoError(t, err)
+	assert.Same(t, db.GetWriteDB(), readDB)
+}
+
+func TestGetReadDBWithConsistency_RejectsUnknownLevel(t *testing.T) {
+	db := newConsistencyTestDatabase(t, 0)
+
+	_, err := db.GetReadDBWithConsistency(context.Background(), ReadConsistency(42), ReadOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// seedNodeName creates a single-row table naming the node a pool points at
+func seedNodeName(t *testing.T, db *gorm.DB, name string) {
+	t.Helper()
+	require.NoError(t, db.Exec("CREATE TABLE node (name TEXT)").Error)
+	require.NoError(t, db.Exec("INSERT INTO node (name) VALUES (?)", name).Error)
+}
+
+func TestRead_RecoveryConflictRetriesOnPrimary(t *testing.T) {
+	var events []Event
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.OnEvent = func(event Event) { events = append(events, event) }
+	db := newSQLiteTestDatabase(t, config)
+
+	seedNodeName(t, db.primaryDB, "primary")
+	seedNodeName(t, db.replicaDB, "replica")
+
+	var servedBy []string
+	err := db.Read(context.Background(), func(tx *gorm.DB) error {
+		var name string
+		if err := tx.Raw("SELECT name FROM node").Scan(&name).Error; err != nil {
+			return err
+		}
+		servedBy = append(servedBy, name)
+
+		if name == "replica" {
+			return &pq.Error{Code: "40001", Message: "canceling statement due to conflict with recovery"}
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"replica", "primary"}, servedBy)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventReplicaRecoveryConflict, events[0].Type)
+}
+
+func TestRead_RecoveryConflictRetryReleasesReplicaSlot(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.ReplicaReadBudget = 1
+	db := newSQLiteTestDatabase(t, config)
+
+	seedNodeName(t, db.primaryDB, "primary")
+	seedNodeName(t, db.replicaDB, "replica")
+
+	var inFlight []int
+	err := db.Read(context.Background(), func(tx *gorm.DB) error {
+		var name string
+		if err := tx.Raw("SELECT name FROM node").Scan(&name).Error; err != nil {
+			return err
+		}
+		inFlight = append(inFlight, db.ReplicaReadsInFlight())
+
+		if name == "replica" {
+			return &pq.Error{Code: "40001", Message: "canceling statement due to conflict with recovery"}
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 0}, inFlight, "the primary retry must not hold the replica slot")
+	assert.Zero(t, db.ReplicaReadsInFlight())
+}
+
+func TestRead_OtherErrorsAreNotRetried(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	db := newSQLiteTestDatabase(t, config)
+
+	attempts := 0
+	err := db.Read(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		return &pq.Error{Code: "42P01", Message: "relation does not exist"}
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
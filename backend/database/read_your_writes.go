@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const primaryStickinessKey contextKey = "database.primary_stickiness"
+
+// primaryStickiness keeps a request's reads on the primary for a while after it wrote
+type primaryStickiness struct {
+	window time.Duration
+	until  int64 // unix nanos; zero until the request writes
+}
+
+// WithReadYourWrites returns a context under which a write makes later reads with
+// it, or contexts derived from it, go to the primary for ReadYourWritesWindow, so
+// they see the write even while the replica lags. RequestContextMiddleware installs
+// it on every request; without ReadYourWritesWindow it returns ctx unchanged.
+func (db *ProductionDatabase) WithReadYourWrites(ctx context.Context) context.Context {
+	if db.config.ReadYourWritesWindow <= 0 || primaryStickinessFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, primaryStickinessKey, &primaryStickiness{window: db.config.ReadYourWritesWindow})
+}
+
+// StickToPrimary sends reads with ctx to the primary for the ReadYourWritesWindow
+// from now. Successful writes on the primary call it for their context; call it
+// directly after writing through another path, e.g. a queue consumer. It has no
+// effect on contexts not prepared by WithReadYourWrites.
+func StickToPrimary(ctx context.Context) {
+	if stickiness := primaryStickinessFrom(ctx); stickiness != nil {
+		atomic.StoreInt64(&stickiness.until, time.Now().Add(stickiness.window).UnixNano())
+	}
+}
+
+// stuckToPrimary reports whether reads with ctx must go to the primary
+func stuckToPrimary(ctx context.Context) bool {
+	stickiness := primaryStickinessFrom(ctx)
+	return stickiness != nil && time.Now().UnixNano() < atomic.LoadInt64(&stickiness.until)
+}
+
+// primaryStickinessFrom returns the stickiness carried by ctx, or nil if there is none
+func primaryStickinessFrom(ctx context.Context) *primaryStickiness {
+	if ctx == nil {
+		return nil
+	}
+	stickiness, _ := ctx.Value(primaryStickinessKey).(*primaryStickiness)
+	return stickiness
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReadYourWritesTestDatabase(t *testing.T, window time.Duration) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.AutoRouteReads = true
+	config.ReadYourWritesWindow = window
+	db := newSQLiteTestDatabase(t, config)
+
+	seedNodeName(t, db.primaryDB, "primary")
+	seedNodeName(t, db.replicaDB, "replica")
+	return db
+}
+
+func TestReadYourWrites_WriteSticksRequestReadsToPrimary(t *testing.T) {
+	db := newReadYourWritesTestDatabase(t, time.Minute)
+	ctx := db.WithReadYourWrites(context.Background())
+
+	// Reads on the primary do not stick
+	var count int64
+	require.NoError(t, db.GetWriteDB().WithContext(ctx).Raw("SELECT count(*) FROM node").Scan(&count).Error)
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(ctx))
+
+	require.NoError(t, db.GetWriteDB().WithContext(ctx).Exec("UPDATE node SET name = name").Error)
+	assert.Same(t, db.primaryDB, db.GetReadDBContext(ctx))
+	assert.Equal(t, "primary", servedBy(t, db.GetDB().WithContext(ctx)))
+
+	// Other requests still read from the replica
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(context.Background()))
+	assert.Equal(t, "replica", servedBy(t, db.GetDB().WithContext(context.Background())))
+}
+
+func TestReadYourWrites_StickinessExpiresAfterWindow(t *testing.T) {
+	db := newReadYourWritesTestDatabase(t, 50*time.Millisecond)
+	ctx := db.WithReadYourWrites(context.Background())
+
+	StickToPrimary(ctx)
+	assert.Same(t, db.primaryDB, db.GetReadDBContext(ctx))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(ctx))
+}
+
+func TestReadYourWrites_DisabledWithoutWindow(t *testing.T) {
+	db := newReadYourWritesTestDatabase(t, 0)
+	ctx := db.WithReadYourWrites(context.Background())
+
+	StickToPrimary(ctx)
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(ctx))
+}
+
+func TestRequestContextMiddleware_ReadsOwnWrites(t *testing.T) {
+	db := newReadYourWritesTestDatabase(t, time.Minute)
+
+	var before, after string
+	handler := db.RequestContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before = servedBy(t, FromContext(r.Context()))
+		require.NoError(t, FromContext(r.Context()).Exec("UPDATE node SET name = name").Error)
+		after = servedBy(t, FromContext(r.Context()))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/meals", nil))
+
+	assert.Equal(t, "replica", before)
+	assert.Equal(t, "primary", after)
+}
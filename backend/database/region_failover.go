@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// FailoverToRegion moves the database onto regionConfig's primary and read replica.
+// Only DatabaseURL and ReadReplicaURL are taken from regionConfig; every other
+// setting stays as configured. While the new region is connecting, the circuit
+// breakers are open so statements fail fast with ErrCircuitOpen instead of queueing
+// on a region that is going away. The new pools are swapped in atomically, then the
+// old ones are closed, which waits for statements already running on them to finish
+// or for ctx to be done. If the new primary cannot be reached, the old pools are kept.
+func (db *ProductionDatabase) FailoverToRegion(ctx context.Context, regionConfig *ProductionConfig) error {
+	if regionConfig == nil || regionConfig.DatabaseURL == "" {
+		return errors.New("region config must set DatabaseURL")
+	}
+
+	db.reconnectMu.Lock()
+	defer db.reconnectMu.Unlock()
+
+	if db.breakers != nil {
+		db.breakers.tripAll()
+		defer db.breakers.reset()
+	}
+	if db.targetBreakers != nil {
+		db.targetBreakers.tripAll()
+		defer db.targetBreakers.reset()
+	}
+	db.emit(EventRegionFailoverStarted, "failing over to another region", nil)
+
+	primaryDB, sqlDB, err := openPool(db.config, "primary", regionConfig.DatabaseURL, db.gormConfig)
+	if err != nil {
+		return db.regionFailoverFailed(fmt.Errorf("failed to connect to region primary: %w", err))
+	}
+	if err := db.registerCallbacks(primaryDB, "primary"); err != nil {
+		sqlDB.Close()
+		return db.regionFailoverFailed(err)
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return db.regionFailoverFailed(fmt.Errorf("failed to ping region primary: %w", err))
+	}
+
+	transactionDB, err := db.openTransactionPool(regionConfig.DatabaseURL)
+	if err != nil {
+		sqlDB.Close()
+		return db.regionFailoverFailed(err)
+	}
+
+	// Like at startup, a region without a reachable replica serves reads from its primary
+	var replicaDB *gorm.DB
+	if regionConfig.ReadReplicaURL != "" {
+		regionReplica, _, err := openPool(db.config, "replica", regionConfig.ReadReplicaURL, db.gormConfig)
+		if err != nil {
+			db.logger().Warn("Failed to connect to region read replica", "error", err)
+		} else if err := db.registerCallbacks(regionReplica, "replica"); err != nil {
+			db.logger().Warn("Failed to instrument region read replica", "error", err)
+		} else {
+			replicaDB = regionReplica
+		}
+	}
+
+	db.warmPrimaryPool(sqlDB)
+
+	db.poolMu.Lock()
+	oldPools := []*gorm.DB{db.primaryDB, db.transactionDB, db.replicaDB}
+	db.primaryDB, db.sqlDB, db.transactionDB, db.replicaDB = primaryDB, sqlDB, transactionDB, replicaDB
+	db.primaryURL, db.replicaURL = regionConfig.DatabaseURL, regionConfig.ReadReplicaURL
+	db.poolMu.Unlock()
+
+	if err := db.drainPools(ctx, oldPools); err != nil {
+		db.emit(EventRegionFailoverCompleted, "failed over to the new region before the old pools drained", err)
+		return err
+	}
+
+	db.emit(EventRegionFailoverCompleted, "failed over to the new region", nil)
+	db.logger().Info("Database failed over to the new region")
+	return nil
+}
+
+// regionFailoverFailed reports a failover that left the old pools in place
+func (db *ProductionDatabase) regionFailoverFailed(err error) error {
+	db.emit(EventRegionFailoverFailed, "region failover failed", err)
+	return err
+}
+
+// drainPools closes pools, waiting for their running statements to finish until ctx
+// is done. Pools still draining then are left to close in the background.
+func (db *ProductionDatabase) drainPools(ctx context.Context, pools []*gorm.DB) error {
+	var sqlDBs []*sql.DB
+	for _, pool := range pools {
+		if pool == nil {
+			continue
+		}
+		if sqlDB, err := pool.DB(); err == nil {
+			sqlDBs = append(sqlDBs, sqlDB)
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for _, sqlDB := range sqlDBs {
+			if err := sqlDB.Close(); err != nil {
+				db.logger().Error("Failed to close previous region pool", "error", err)
+			}
+		}
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("old region pools still draining: %w", ctx.Err())
+	}
+}
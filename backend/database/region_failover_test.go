@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openRegionStorage creates a region's in-memory database named after the region and
+// keeps it alive for the test, as the region's server would
+func openRegionStorage(t *testing.T, dsn, name string) {
+	t.Helper()
+	storage, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+	_, err = storage.Exec("CREATE TABLE node (name TEXT)")
+	require.NoError(t, err)
+	_, err = storage.Exec("INSERT INTO node (name) VALUES (?)", name)
+	require.NoError(t, err)
+}
+
+func TestFailoverToRegion_OperationsHitNewRegion(t *testing.T) {
+	config := newSQLiteTestConfig(t, "east_primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "east_replica")
+	config.CircuitBreakerThreshold = 5
+
+	region := &ProductionConfig{
+		DatabaseURL:    sqliteTestDSN(t, "west_primary"),
+		ReadReplicaURL: sqliteTestDSN(t, "west_replica"),
+	}
+	openRegionStorage(t, region.DatabaseURL, "west_primary")
+	openRegionStorage(t, region.ReadReplicaURL, "west_replica")
+
+	var events []EventType
+	var transitionErr error
+	var db *ProductionDatabase
+	config.OnEvent = func(event Event) {
+		events = append(events, event.Type)
+		if event.Type == EventRegionFailoverStarted {
+			transitionErr = db.GetDB().Exec("SELECT 1").Error
+		}
+	}
+	db = newSQLiteTestDatabase(t, config)
+	seedNodeName(t, db.primary(), "east_primary")
+	seedNodeName(t, db.replica(), "east_replica")
+	oldPool := db.primaryPool()
+
+	eastPrimaryURL, eastReplicaURL := config.DatabaseURL, config.ReadReplicaURL
+	require.NoError(t, db.FailoverToRegion(context.Background(), region))
+
+	// The caller's config keeps describing the region it was opened with
+	assert.Equal(t, eastPrimaryURL, config.DatabaseURL)
+	assert.Equal(t, eastReplicaURL, config.ReadReplicaURL)
+	primaryURL, replicaURL := db.regionURLs()
+	assert.Equal(t, region.DatabaseURL, primaryURL)
+	assert.Equal(t, region.ReadReplicaURL, replicaURL)
+
+	assert.Equal(t, []EventType{EventRegionFailoverStarted, EventRegionFailoverCompleted}, events)
+	assert.True(t, errors.Is(transitionErr, ErrCircuitOpen), "statements must fail fast during the transition, got %v", transitionErr)
+	assert.Error(t, oldPool.Ping(), "the old region's pool must be closed")
+
+	var primaryName, replicaName string
+	require.NoError(t, db.WithContext(context.Background()).Raw("SELECT name FROM node").Scan(&primaryName).Error)
+	require.NoError(t, db.GetReadDB().Raw("SELECT name FROM node").Scan(&replicaName).Error)
+	assert.Equal(t, "west_primary", primaryName)
+	assert.Equal(t, "west_replica", replicaName)
+
+	require.NoError(t, db.GetDB().Exec("INSERT INTO node (name) VALUES ('written')").Error)
+	var count int64
+	require.NoError(t, db.primary().Raw("SELECT COUNT(*) FROM node").Scan(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestFailoverToRegion_UnreachableRegionKeepsOldPools(t *testing.T) {
+	var events []EventType
+	config := newSQLiteTestConfig(t, "primary")
+	config.OnEvent = func(event Event) { events = append(events, event.Type) }
+	db := newSQLiteTestDatabase(t, config)
+	oldPool := db.primaryPool()
+
+	// Read-only mode refuses to create the missing database file
+	region := &ProductionConfig{DatabaseURL: "file:/nonexistent/region.db?mode=ro"}
+	require.Error(t, db.FailoverToRegion(context.Background(), region))
+
+	assert.Equal(t, []EventType{EventRegionFailoverStarted, EventRegionFailoverFailed}, events)
+	assert.Same(t, oldPool, db.primaryPool())
+	assert.NoError(t, db.GetDB().Exec("SELECT 1").Error, "breakers must close again after a failed failover")
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+const (
+	replicaSlotHeldKey = contextKey("database.replica_slot_held")
+
+	replicaSlotInstanceKey = "database:replica_slot"
+)
+
+// ReplicaBudgetPolicy decides what happens to replica reads beyond ReplicaReadBudget
+type ReplicaBudgetPolicy int
+
+const (
+	// ShedToPrimary routes reads beyond the budget to the primary
+	ShedToPrimary ReplicaBudgetPolicy = iota
+
+	// RejectOverBudget fails reads beyond the budget with ErrReplicaBudgetExceeded
+	RejectOverBudget
+)
+
+// acquireReplicaSlot reserves a replica read slot without blocking
+// ok is false when the budget is exhausted; release must be called once the read finishes
+func (db *ProductionDatabase) acquireReplicaSlot() (release func(), ok bool) {
+	if db.replicaSlots == nil {
+		return func() {}, true
+	}
+
+	select {
+	case db.replicaSlots <- struct{}{}:
+		return func() { <-db.replicaSlots }, true
+	default:
+		return nil, false
+	}
+}
+
+// withReplicaSlot marks ctx as holding a replica read slot, so statements run with
+// it are not counted against the budget a second time
+func withReplicaSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaSlotHeldKey, true)
+}
+
+// claimReplicaSlot holds a replica read slot for a statement about to run on a
+// replica, such as one issued on GetReadDB. Without a free slot the statement is
+// moved to the primary or fails with ErrReplicaBudgetExceeded, depending on the policy.
+// Statements in a transaction already hold their connection and are not counted.
+func (db *ProductionDatabase) claimReplicaSlot(tx *gorm.DB, role string) {
+	if value, ok := tx.InstanceGet(routedRoleInstanceKey); ok {
+		role = value.(string)
+	}
+	if role != "replica" || db.replicaSlots == nil || inTransaction(tx) {
+		return
+	}
+	if held, _ := tx.Statement.Context.Value(replicaSlotHeldKey).(bool); held {
+		return
+	}
+
+	release, ok := db.acquireReplicaSlot()
+	switch {
+	case ok:
+		tx.InstanceSet(replicaSlotInstanceKey, release)
+	case db.config.ReplicaBudgetPolicy == RejectOverBudget:
+		tx.AddError(ErrReplicaBudgetExceeded)
+	default:
+		tx.Statement.ConnPool = db.primary().Statement.ConnPool
+		tx.InstanceSet(routedRoleInstanceKey, "primary")
+	}
+}
+
+// releaseReplicaSlot frees the slot claimReplicaSlot took for a statement
+func releaseReplicaSlot(tx *gorm.DB) {
+	if value, ok := tx.InstanceGet(replicaSlotInstanceKey); ok {
+		value.(func())()
+	}
+}
+
+// replicaBudgetExhausted reports whether every replica read slot is taken
+func (db *ProductionDatabase) replicaBudgetExhausted() bool {
+	return db.replicaSlots != nil && len(db.replicaSlots) >= cap(db.replicaSlots)
+}
+
+// ReplicaReadsInFlight returns the number of replica reads currently holding a budget slot
+func (db *ProductionDatabase) ReplicaReadsInFlight() int {
+	if db.replicaSlots == nil {
+		return 0
+	}
+	return len(db.replicaSlots)
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// saturateReplicaBudget starts budget-many reads that block until the returned release is called
+func saturateReplicaBudget(t *testing.T, db *ProductionDatabase, budget int) (release func()) {
+	t.Helper()
+
+	var started, finished sync.WaitGroup
+	unblock := make(chan struct{})
+
+	for i := 0; i < budget; i++ {
+		started.Add(1)
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			err := db.Read(context.Background(), func(tx *gorm.DB) error {
+				started.Done()
+				<-unblock
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+
+	started.Wait()
+	return func() {
+		close(unblock)
+		finished.Wait()
+	}
+}
+
+func newBudgetTestDatabase(t *testing.T, policy ReplicaBudgetPolicy) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.ReplicaReadBudget = 2
+	config.ReplicaBudgetPolicy = policy
+	db := newSQLiteTestDatabase(t, config)
+
+	seedNodeName(t, db.primaryDB, "primary")
+	seedNodeName(t, db.replicaDB, "replica")
+	return db
+}
+
+func TestReplicaReadBudget_RejectPolicy(t *testing.T) {
+	db := newBudgetTestDatabase(t, RejectOverBudget)
+
+	release := saturateReplicaBudget(t, db, 2)
+	assert.Equal(t, 2, db.ReplicaReadsInFlight())
+	assert.Equal(t, 2, db.Stats()["replica_reads_in_flight"])
+
+	err := db.Read(context.Background(), func(tx *gorm.DB) error {
+		t.Fatal("read beyond the budget must not run")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrReplicaBudgetExceeded)
+
+	release()
+	assert.Equal(t, 0, db.ReplicaReadsInFlight())
+
+	// Once slots free up replica reads flow again
+	var name string
+	require.NoError(t, db.Read(context.Background(), func(tx *gorm.DB) error {
+		return tx.Raw("SELECT name FROM node").Scan(&name).Error
+	}))
+	assert.Equal(t, "replica", name)
+}
+
+func TestReplicaReadBudget_ShedPolicyRoutesOverflowToPrimary(t *testing.T) {
+	db := newBudgetTestDatabase(t, ShedToPrimary)
+
+	release := saturateReplicaBudget(t, db, 2)
+	defer release()
+
+	var name string
+	require.NoError(t, db.Read(context.Background(), func(tx *gorm.DB) error {
+		return tx.Raw("SELECT name FROM node").Scan(&name).Error
+	}))
+	assert.Equal(t, "primary", name)
+	assert.Same(t, db.primaryDB, db.GetReadDB())
+}
+
+func TestReplicaReadBudget_GetReadDBHoldsSlotPerStatement(t *testing.T) {
+	db := newBudgetTestDatabase(t, RejectOverBudget)
+
+	// The budget is checked when a statement runs, not when the handle is taken
+	readDB := db.GetReadDB()
+	release := saturateReplicaBudget(t, db, 2)
+
+	var name string
+	err := readDB.Raw("SELECT name FROM node").Scan(&name).Error
+	assert.ErrorIs(t, err, ErrReplicaBudgetExceeded)
+
+	release()
+	require.NoError(t, readDB.Raw("SELECT name FROM node").Scan(&name).Error)
+	assert.Equal(t, "replica", name)
+	assert.Equal(t, 0, db.ReplicaReadsInFlight())
+}
+
+func TestReplicaReadBudget_GetReadDBShedsStatementsToPrimary(t *testing.T) {
+	db := newBudgetTestDatabase(t, ShedToPrimary)
+
+	readDB := db.GetReadDB()
+	release := saturateReplicaBudget(t, db, 2)
+	defer release()
+
+	var name string
+	require.NoError(t, readDB.Raw("SELECT name FROM node").Scan(&name).Error)
+	assert.Equal(t, "primary", name)
+	assert.Equal(t, 2, db.ReplicaReadsInFlight())
+}
+
+func TestReplicaReadBudget_ReadCountsItsStatementsOnce(t *testing.T) {
+	db := newBudgetTestDatabase(t, RejectOverBudget)
+
+	release := saturateReplicaBudget(t, db, 1)
+	defer release()
+
+	// Read holds the last slot; its statements must not claim another
+	var name string
+	require.NoError(t, db.Read(context.Background(), func(tx *gorm.DB) error {
+		return tx.Raw("SELECT name FROM node").Scan(&name).Error
+	}))
+	assert.Equal(t, "replica", name)
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReplicaInfo describes a read replica offered to ReplicaFilter
+type ReplicaInfo struct {
+	Name    string
+	Healthy bool
+
+	// Lag is the replica's replication lag; LagErr is set when it could not be measured
+	Lag    time.Duration
+	LagErr error
+
+	db *gorm.DB
+}
+
+// replicaCandidates describes every configured replica, healthy or not
+func (db *ProductionDatabase) replicaCandidates(ctx context.Context) []ReplicaInfo {
+	replicaDB := db.replica()
+	if replicaDB == nil {
+		return nil
+	}
+
+	candidate := ReplicaInfo{Name: "replica", db: replicaDB}
+	candidate.Healthy = db.healthyReplica() != nil
+	candidate.Lag, candidate.LagErr = db.ReplicaLag(ctx)
+	return []ReplicaInfo{candidate}
+}
+
+// selectReplica returns the replica a read on ctx should use, or nil for none
+// Without a ReplicaFilter this is the healthy replica; with one, the first healthy
+// candidate the filter returns. There is none while the monitored lag exceeds
+// MaxReplicaLag, the replica's target breaker is open, ctx sticks to the primary
+// after a write (see StickToPrimary) or the replica has not replayed the writes of
+// ctx's causal session.
+func (db *ProductionDatabase) selectReplica(ctx context.Context) *gorm.DB {
+	if db.replicaIsStale() || stuckToPrimary(ctx) {
+		return nil
+	}
+	replica := db.filterReplica(ctx)
+	if replica == nil || !db.targetAvailable("replica") || !db.replicaReplayedSession(ctx, replica) {
+		return nil
+	}
+	return replica
+}
+
+// filterReplica returns the healthy replica, or the first healthy candidate ReplicaFilter returns
+func (db *ProductionDatabase) filterReplica(ctx context.Context) *gorm.DB {
+	if db.config.ReplicaFilter == nil {
+		return db.healthyReplica()
+	}
+
+	for _, candidate := range db.config.ReplicaFilter(db.replicaCandidates(ctx), ctx) {
+		if candidate.Healthy && candidate.db != nil {
+			return candidate.db
+		}
+	}
+	return nil
+}
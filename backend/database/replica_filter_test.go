@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestReplicaFilter_ExcludedReplicaNeverSelected(t *testing.T) {
+	var offered []ReplicaInfo
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.ReplicaFilter = func(candidates []ReplicaInfo, ctx context.Context) []ReplicaInfo {
+		offered = candidates
+		var kept []ReplicaInfo
+		for _, candidate := range candidates {
+			if candidate.Name != "replica" {
+				kept = append(kept, candidate)
+			}
+		}
+		return kept
+	}
+	db := newSQLiteTestDatabase(t, config)
+	db.lagProbe = func(context.Context, *gorm.DB) (time.Duration, error) { return 2 * time.Second, nil }
+
+	seedNodeName(t, db.primaryDB, "primary")
+	seedNodeName(t, db.replicaDB, "replica")
+
+	for i := 0; i < 5; i++ {
+		assert.Same(t, db.primary(), db.GetReadDBContext(context.Background()))
+
+		var name string
+		require.NoError(t, db.Read(context.Background(), func(tx *gorm.DB) error {
+			return tx.Raw("SELECT name FROM node").Scan(&name).Error
+		}))
+		assert.Equal(t, "primary", name)
+	}
+
+	// The filter saw the replica's health and lag
+	require.Len(t, offered, 1)
+	assert.True(t, offered[0].Healthy)
+	assert.Equal(t, 2*time.Second, offered[0].Lag)
+	assert.NoError(t, offered[0].LagErr)
+}
+
+func TestReplicaFilter_DefaultPassesReplicaThrough(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	db := newSQLiteTestDatabase(t, config)
+
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(context.Background()))
+}
+
+func TestReplicaSelection_UsesCachedHealthInsteadOfPinging(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.HealthCheckInterval = time.Hour
+	db := newSQLiteTestDatabase(t, config)
+
+	replicaSQL, err := db.replicaDB.DB()
+	require.NoError(t, err)
+	require.NoError(t, replicaSQL.Close())
+
+	// Reads do not ping: the replica stays selected until a health check fails
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(context.Background()))
+
+	require.NoError(t, db.Health())
+	assert.Same(t, db.primaryDB, db.GetReadDBContext(context.Background()))
+
+	// A passing check brings the replica back
+	db.observeReplicaHealth(nil)
+	assert.Same(t, db.replicaDB, db.GetReadDBContext(context.Background()))
+}
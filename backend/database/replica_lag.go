@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// replicaLagSQL reports how far the replica's replay is behind, treating a fully caught-up replica as zero lag
+const replicaLagSQL = `
+SELECT CASE
+         WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+         ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+       END`
+
+// measureReplicaLag queries a replica for its replication lag
+func measureReplicaLag(ctx context.Context, replica *gorm.DB) (time.Duration, error) {
+	var seconds float64
+	if err := replica.WithContext(ctx).Raw(replicaLagSQL).Scan(&seconds).Error; err != nil {
+		return 0, fmt.Errorf("failed to measure replica lag: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ReplicaLag returns the current replication lag of the read replica
+func (db *ProductionDatabase) ReplicaLag(ctx context.Context) (time.Duration, error) {
+	replicaDB := db.replica()
+	if replicaDB == nil {
+		return 0, ErrReplicaUnavailable
+	}
+
+	probe := db.lagProbe
+	if probe == nil {
+		probe = measureReplicaLag
+	}
+	return probe(ctx, replicaDB)
+}
+
+// GetReadDBOrError returns the replica only when its lag is within maxLag
+// Otherwise it returns the primary when PrimaryReadFallback is enabled,
+// or ErrReplicaTooLagged/ErrReplicaUnavailable so the caller can decide.
+// Statements on the returned replica are held to the replica read budget as
+// on GetReadDB.
+func (db *ProductionDatabase) GetReadDBOrError(ctx context.Context, maxLag time.Duration) (*gorm.DB, error) {
+	replicaDB := db.replica()
+	if replicaDB == nil {
+		if db.config.PrimaryReadFallback {
+			return db.primary(), nil
+		}
+		return nil, ErrReplicaUnavailable
+	}
+
+	lag, err := db.ReplicaLag(ctx)
+	if err != nil {
+		if db.config.PrimaryReadFallback {
+			db.logger().Warn("Read replica lag unavailable, falling back to primary", "error", err)
+			return db.primary(), nil
+		}
+		return nil, fmt.Errorf("%w: %v", ErrReplicaUnavailable, err)
+	}
+
+	if lag <= maxLag {
+		if !db.replicaBudgetExhausted() {
+			return replicaDB, nil
+		}
+		if db.config.ReplicaBudgetPolicy == RejectOverBudget {
+			return nil, ErrReplicaBudgetExceeded
+		}
+		return db.primary(), nil
+	}
+
+	if db.config.PrimaryReadFallback {
+		return db.primary(), nil
+	}
+	return nil, fmt.Errorf("%w: lag %v, max %v", ErrReplicaTooLagged, lag, maxLag)
+}
+
+// monitorReplicaLag measures the replica lag for the lag monitor and marks the replica
+// stale while the lag exceeds MaxReplicaLag. A failed measurement keeps the last state.
+func (db *ProductionDatabase) monitorReplicaLag(ctx context.Context) error {
+	if timeout := db.config.HealthCheckTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	lag, err := db.ReplicaLag(ctx)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&db.replicaLagNanos, int64(lag))
+	atomic.StoreInt32(&db.replicaLagMeasured, 1)
+
+	maxLag := db.config.MaxReplicaLag
+	stale := maxLag > 0 && lag > maxLag
+	switch {
+	case stale && atomic.CompareAndSwapInt32(&db.replicaStale, 0, 1):
+		db.logger().Warn("Read replica lag exceeds maximum, reading from primary", "lag", lag, "max_lag", maxLag)
+		db.emit(EventReplicaStale, fmt.Sprintf("replica lag %v exceeds %v, reading from primary", lag, maxLag), nil)
+	case !stale && atomic.CompareAndSwapInt32(&db.replicaStale, 1, 0):
+		db.logger().Info("Read replica caught up", "lag", lag, "max_lag", maxLag)
+		db.emit(EventReplicaCaughtUp, fmt.Sprintf("replica lag %v is within %v", lag, maxLag), nil)
+	}
+	return nil
+}
+
+// MonitoredReplicaLag returns the replica lag the lag monitor last measured; ok is
+// false until it has measured one, e.g. without ReplicaLagInterval
+func (db *ProductionDatabase) MonitoredReplicaLag() (lag time.Duration, ok bool) {
+	if atomic.LoadInt32(&db.replicaLagMeasured) == 0 {
+		return 0, false
+	}
+	return time.Duration(atomic.LoadInt64(&db.replicaLagNanos)), true
+}
+
+// replicaIsStale reports whether the monitored replica lag exceeds MaxReplicaLag
+func (db *ProductionDatabase) replicaIsStale() bool {
+	return atomic.LoadInt32(&db.replicaStale) == 1
+}
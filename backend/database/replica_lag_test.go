@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newLaggedTestDatabase(lag time.Duration, fallback bool) *ProductionDatabase {
+	config := DefaultProductionConfig()
+	config.PrimaryReadFallback = fallback
+
+	return &ProductionDatabase{
+		primaryDB: &gorm.DB{},
+		replicaDB: &gorm.DB{},
+		config:    config,
+		lagProbe: func(context.Context, *gorm.DB) (time.Duration, error) {
+			return lag, nil
+		},
+	}
+}
+
+func TestGetReadDBOrError_LaggedReplicaWithoutFallback(t *testing.T) {
+	db := newLaggedTestDatabase(10*time.Second, false)
+
+	readDB, err := db.GetReadDBOrError(context.Background(), time.Second)
+	assert.ErrorIs(t, err, ErrReplicaTooLagged)
+	assert.Nil(t, readDB)
+}
+
+func TestGetReadDBOrError_LaggedReplicaFallsBackToPrimary(t *testing.T) {
+	db := newLaggedTestDatabase(10*time.Second, true)
+
+	readDB, err := db.GetReadDBOrError(context.Background(), time.Second)
+	require.NoError(t, err)
+	assert.Same(t, db.primaryDB, readDB)
+}
+
+func TestGetReadDBOrError_ReplicaWithinLag(t *testing.T) {
+	db := newLaggedTestDatabase(100*time.Millisecond, false)
+
+	readDB, err := db.GetReadDBOrError(context.Background(), time.Second)
+	require.NoError(t, err)
+	assert.Same(t, db.replicaDB, readDB)
+}
+
+func TestGetReadDBOrError_NoReplicaWithoutFallback(t *testing.T) {
+	db := newLaggedTestDatabase(0, false)
+	db.replicaDB = nil
+
+	_, err := db.GetReadDBOrError(context.Background(), time.Second)
+	assert.ErrorIs(t, err, ErrReplicaUnavailable)
+}
+
+func TestMonitorReplicaLag_RoutesReadsToPrimaryWhileStale(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.MaxReplicaLag = time.Second
+	var events []EventType
+	config.OnEvent = func(event Event) { events = append(events, event.Type) }
+	db := newSQLiteTestDatabase(t, config)
+
+	lag := 5 * time.Second
+	db.lagProbe = func(context.Context, *gorm.DB) (time.Duration, error) { return lag, nil }
+	_, ok := db.MonitoredReplicaLag()
+	assert.False(t, ok)
+
+	require.NoError(t, db.monitorReplicaLag(context.Background()))
+	assert.Same(t, db.primaryDB, db.GetReadDB())
+	assert.Equal(t, 5.0, db.Stats()["replica_lag_seconds"])
+	assert.Equal(t, true, db.Stats()["replica_stale"])
+
+	lag = 200 * time.Millisecond
+	require.NoError(t, db.monitorReplicaLag(context.Background()))
+	assert.Same(t, db.replicaDB, db.GetReadDB())
+	monitored, ok := db.MonitoredReplicaLag()
+	assert.True(t, ok)
+	assert.Equal(t, lag, monitored)
+
+	assert.Equal(t, []EventType{EventReplicaStale, EventReplicaCaughtUp}, events)
+}
+
+func TestMonitorReplicaLag_FailedMeasurementKeepsState(t *testing.T) {
+	db := newLaggedTestDatabase(5*time.Second, true)
+	db.config.MaxReplicaLag = time.Second
+	require.NoError(t, db.monitorReplicaLag(context.Background()))
+
+	db.lagProbe = func(context.Context, *gorm.DB) (time.Duration, error) { return 0, assert.AnError }
+	assert.Error(t, db.monitorReplicaLag(context.Background()))
+	assert.True(t, db.replicaIsStale())
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository offers the common queries on the model T, reading through Read (the
+// replica when one is usable) and writing to the primary. Its errors are
+// translated (see TranslateError), so a missing row matches ErrNotFound.
+type Repository[T any] struct {
+	db      *ProductionDatabase
+	tx      *gorm.DB
+	deleted deletedRows
+}
+
+// NewRepository returns a repository of T on db
+func NewRepository[T any](db *ProductionDatabase) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// WithTx returns a copy of the repository running every query, reads included, in
+// the transaction tx, e.g. within a UnitOfWork operation or Transaction
+func (r *Repository[T]) WithTx(tx *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: r.db, tx: tx, deleted: r.deleted}
+}
+
+// Get returns the row whose primary key is id
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	var entity T
+	err := r.read(ctx, func(tx *gorm.DB) error {
+		return tx.Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).First(&entity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// List returns the rows the scopes select, e.g. to filter, order and paginate them
+func (r *Repository[T]) List(ctx context.Context, scopes ...func(*gorm.DB) *gorm.DB) ([]T, error) {
+	var entities []T
+	err := r.read(ctx, func(tx *gorm.DB) error {
+		return tx.Scopes(scopes...).Find(&entities).Error
+	})
+	return entities, err
+}
+
+// Count returns how many rows the scopes select
+func (r *Repository[T]) Count(ctx context.Context, scopes ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	err := r.read(ctx, func(tx *gorm.DB) error {
+		return tx.Model(new(T)).Scopes(scopes...).Count(&count).Error
+	})
+	return count, err
+}
+
+// Exists reports whether the scopes select any row, without counting them all
+func (r *Repository[T]) Exists(ctx context.Context, scopes ...func(*gorm.DB) *gorm.DB) (bool, error) {
+	var exists bool
+	err := r.read(ctx, func(tx *gorm.DB) error {
+		rows := r.scoped(tx.Session(&gorm.Session{NewDB: true})).Model(new(T)).Scopes(scopes...).Select("1")
+		return tx.Raw("SELECT EXISTS (?)", rows).Scan(&exists).Error
+	})
+	return exists, err
+}
+
+// Create inserts entity, filling in its generated fields such as the primary key
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return TranslateError(r.write(ctx).Create(entity).Error)
+}
+
+// Update writes every field of entity to its row, zero values included
+// It fails with ErrNotFound when no row has entity's primary key.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	result := r.write(ctx).Model(entity).Select("*").Updates(entity)
+	if result.Error != nil {
+		return TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete deletes the row whose primary key is id, soft-deleting models with a
+// gorm.DeletedAt field. It fails with ErrNotFound when there is no such row.
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	result := r.write(ctx).Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).Delete(new(T))
+	if result.Error != nil {
+		return TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// read runs fn on the repository's transaction, or through Read, selecting the
+// soft-deleted rows the repository reads
+func (r *Repository[T]) read(ctx context.Context, fn func(*gorm.DB) error) error {
+	if r.tx != nil {
+		return TranslateError(fn(r.scoped(r.tx.WithContext(ctx))))
+	}
+	return TranslateError(r.db.Read(ctx, func(tx *gorm.DB) error {
+		return fn(r.scoped(tx))
+	}))
+}
+
+// write returns the repository's transaction, or the primary, bound to ctx
+func (r *Repository[T]) write(ctx context.Context) *gorm.DB {
+	if r.tx != nil {
+		return r.tx.WithContext(ctx)
+	}
+	return r.db.GetWriteDB().WithContext(ctx)
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type repositoryMeal struct {
+	ID       int64
+	Name     string
+	Calories int
+}
+
+func newRepositoryTestDatabase(t *testing.T) *ProductionDatabase {
+	t.Helper()
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.primary().AutoMigrate(&repositoryMeal{}))
+	require.NoError(t, db.replica().AutoMigrate(&repositoryMeal{}))
+	return db
+}
+
+func TestRepository_WritesToPrimaryAndReadsFromReplica(t *testing.T) {
+	db := newRepositoryTestDatabase(t)
+	repo := NewRepository[repositoryMeal](db)
+	ctx := context.Background()
+
+	meal := &repositoryMeal{Name: "salad", Calories: 90}
+	require.NoError(t, repo.Create(ctx, meal))
+	assert.NotZero(t, meal.ID)
+
+	// The replica has not seen the insert
+	_, err := repo.Get(ctx, meal.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, db.replica().Create(&repositoryMeal{ID: meal.ID, Name: "salad", Calories: 90}).Error)
+	got, err := repo.Get(ctx, meal.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "salad", got.Name)
+
+	meal.Calories = 0
+	require.NoError(t, repo.Update(ctx, meal))
+	var stored repositoryMeal
+	require.NoError(t, db.primary().First(&stored, meal.ID).Error)
+	assert.Zero(t, stored.Calories, "zero values are written too")
+
+	assert.ErrorIs(t, repo.Update(ctx, &repositoryMeal{ID: 42}), ErrNotFound)
+
+	require.NoError(t, repo.Delete(ctx, meal.ID))
+	assert.ErrorIs(t, repo.Delete(ctx, meal.ID), ErrNotFound)
+}
+
+func TestRepository_ListCountAndExists(t *testing.T) {
+	db := newRepositoryTestDatabase(t)
+	repo := NewRepository[repositoryMeal](db)
+	ctx := context.Background()
+	require.NoError(t, db.replica().Create([]repositoryMeal{
+		{Name: "salad", Calories: 90},
+		{Name: "pasta", Calories: 600},
+		{Name: "steak", Calories: 700},
+	}).Error)
+
+	hearty := func(tx *gorm.DB) *gorm.DB { return tx.Where("calories > ?", 500) }
+	meals, err := repo.List(ctx, hearty, func(tx *gorm.DB) *gorm.DB { return tx.Order("name") })
+	require.NoError(t, err)
+	require.Len(t, meals, 2)
+	assert.Equal(t, "pasta", meals[0].Name)
+
+	count, err := repo.Count(ctx, hearty)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	exists, err := repo.Exists(ctx, hearty)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = repo.Exists(ctx, func(tx *gorm.DB) *gorm.DB { return tx.Where("calories > ?", 1000) })
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRepository_WithTxRunsInTheTransaction(t *testing.T) {
+	db := newRepositoryTestDatabase(t)
+	repo := NewRepository[repositoryMeal](db)
+	ctx := context.Background()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txRepo := repo.WithTx(tx)
+		meal := &repositoryMeal{Name: "salad"}
+		require.NoError(t, txRepo.Create(ctx, meal))
+
+		// Reads see the transaction's own writes
+		exists, err := txRepo.Exists(ctx, func(tx *gorm.DB) *gorm.DB { return tx.Where("id = ?", meal.ID) })
+		require.NoError(t, err)
+		assert.True(t, exists)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var count int64
+	require.NoError(t, db.primary().Model(&repositoryMeal{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// isCachedPlanError reports whether Postgres refused to reuse a prepared statement
+// because its result type changed, typically after another instance migrated the schema
+func isCachedPlanError(err error) bool {
+	return sqlState(err) == sqlStateFeatureNotSupported &&
+		strings.Contains(err.Error(), "cached plan must not change result type")
+}
+
+// reprepareOnCachedPlanError evicts a statement invalidated by a schema change from
+// GORM's prepared statement cache and re-executes it once, so it is prepared afresh.
+// Inside a transaction the failure has already aborted it, so the statement is only
+// evicted and the error returned.
+func (db *ProductionDatabase) reprepareOnCachedPlanError(tx *gorm.DB, run func(*gorm.DB)) {
+	if run == nil || !isCachedPlanError(tx.Error) {
+		return
+	}
+	if _, retried := tx.InstanceGet(repreparedInstanceKey); retried {
+		return
+	}
+
+	query := tx.Statement.SQL.String()
+	switch pool := tx.Statement.ConnPool.(type) {
+	case *gorm.PreparedStmtDB:
+		pool.Stmts.Delete(query)
+	case *gorm.PreparedStmtTX:
+		pool.PreparedStmtDB.Stmts.Delete(query)
+		return
+	default:
+		return
+	}
+
+	// gorm:row consumes the "rows" setting that selects Rows over Row; a failed Rows
+	// query leaves a typed nil *sql.Rows as the destination
+	if _, ok := tx.Statement.Dest.(*sql.Rows); ok {
+		tx.Statement.Settings.Store("rows", true)
+	}
+
+	db.logger().Warn("Prepared statement invalidated by a schema change, re-preparing", "error", tx.Error)
+	tx.InstanceSet(repreparedInstanceKey, true)
+	tx.Error = nil
+	run(tx)
+}
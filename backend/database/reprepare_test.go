@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// cachedPlanConnector opens SQLite connections whose prepared statements fail with
+// Postgres' cached-plan error while invalidations are pending
+type cachedPlanConnector struct {
+	dsn string
+
+	mu            sync.Mutex
+	invalidations int
+	prepares      map[string]int
+}
+
+// Invalidate makes the next execution of a prepared statement fail with a cached-plan error
+func (c *cachedPlanConnector) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidations++
+}
+
+// Prepares returns how often query was prepared
+func (c *cachedPlanConnector) Prepares(query string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.prepares[query]
+}
+
+func (c *cachedPlanConnector) consumeInvalidation() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.invalidations == 0 {
+		return false
+	}
+	c.invalidations--
+	return true
+}
+
+func (c *cachedPlanConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedPlanConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), connector: c}, nil
+}
+
+func (c *cachedPlanConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+type cachedPlanConn struct {
+	*sqlite3.SQLiteConn
+	connector *cachedPlanConnector
+}
+
+func (c *cachedPlanConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.connector.mu.Lock()
+	c.connector.prepares[query]++
+	c.connector.mu.Unlock()
+
+	return &cachedPlanStmt{Stmt: stmt, connector: c.connector}, nil
+}
+
+type cachedPlanStmt struct {
+	driver.Stmt
+	connector *cachedPlanConnector
+}
+
+var errCachedPlan = &pq.Error{Code: sqlStateFeatureNotSupported, Message: "cached plan must not change result type"}
+
+func (s *cachedPlanStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if s.connector.consumeInvalidation() {
+		return nil, errCachedPlan
+	}
+	return s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+}
+
+func (s *cachedPlanStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if s.connector.consumeInvalidation() {
+		return nil, errCachedPlan
+	}
+	return s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+}
+
+func newCachedPlanTestDatabase(t *testing.T) (*ProductionDatabase, *cachedPlanConnector) {
+	connector := &cachedPlanConnector{prepares: make(map[string]int)}
+
+	config := newSQLiteTestConfig(t, "primary")
+	require.True(t, config.PrepareStmt)
+	config.Connector = func(dsn string) (driver.Connector, error) {
+		connector.dsn = dsn
+		return connector, nil
+	}
+	db := newSQLiteTestDatabase(t, config)
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE foods (id integer PRIMARY KEY, name text)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO foods (id, name) VALUES (1, 'oats')").Error)
+	return db, connector
+}
+
+func TestReprepare_RetriesQueryAfterCachedPlanError(t *testing.T) {
+	db, connector := newCachedPlanTestDatabase(t)
+	query := "SELECT name FROM foods WHERE id = ?"
+
+	var name string
+	require.NoError(t, db.GetDB().Raw(query, 1).Scan(&name).Error)
+	require.Equal(t, 1, connector.Prepares(query))
+
+	// Another instance migrates; the cached statement now fails once
+	connector.Invalidate()
+
+	name = ""
+	require.NoError(t, db.GetDB().Raw(query, 1).Scan(&name).Error)
+	assert.Equal(t, "oats", name)
+	assert.Equal(t, 2, connector.Prepares(query), "the statement is evicted and prepared afresh")
+}
+
+func TestReprepare_RetriesOnlyOnce(t *testing.T) {
+	db, connector := newCachedPlanTestDatabase(t)
+	query := "UPDATE foods SET name = ? WHERE id = ?"
+
+	require.NoError(t, db.GetDB().Exec(query, "rolled oats", 1).Error)
+
+	connector.Invalidate()
+	connector.Invalidate()
+
+	err := db.GetDB().Exec(query, "steel cut oats", 1).Error
+	require.Error(t, err)
+	assert.True(t, isCachedPlanError(err))
+}
+
+func TestReprepare_DoesNotRetryInsideTransaction(t *testing.T) {
+	db, connector := newCachedPlanTestDatabase(t)
+	query := "SELECT name FROM foods WHERE id = ?"
+
+	var name string
+	require.NoError(t, db.GetDB().Raw(query, 1).Scan(&name).Error)
+
+	connector.Invalidate()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Raw(query, 1).Scan(&name).Error
+	})
+	assert.True(t, isCachedPlanError(err), "a failed statement aborts a Postgres transaction, so it is not retried")
+}
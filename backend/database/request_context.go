@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Statements only stop when their client disconnects if they run with the
+// request's context. Install RequestContextMiddleware and take the database
+// from the request instead of calling GetDB in handlers:
+//
+//	mux.Handle("/meals", db.RequestContextMiddleware(mealsHandler))
+//
+//	func mealsHandler(w http.ResponseWriter, r *http.Request) {
+//		var meals []Meal
+//		err := database.FromContext(r.Context()).Find(&meals).Error
+//		...
+//	}
+//
+// Read, TransactionContext and CachedQuery take the context directly. With
+// CancelBackendOnDisconnect the server is also told to stop the statement,
+// rather than relying on the driver to notice the cancelled context.
+
+const productionDatabaseKey contextKey = "database.production_database"
+
+// RequestContextMiddleware carries db on every request's context so handlers can
+// run statements bound to it with FromContext. With ReadYourWritesWindow, reads
+// after a write in the request go to the primary (see WithReadYourWrites), and with
+// CausalConsistency each request is a causal session (see WithCausalConsistency).
+// An X-Request-ID header becomes the request's WithRequestID.
+func (db *ProductionDatabase) RequestContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := db.WithReadYourWrites(r.Context())
+		if db.config.CausalConsistency {
+			ctx = db.WithCausalConsistency(ctx, 0)
+		}
+		if requestID := r.Header.Get("X-Request-ID"); requestID != "" {
+			ctx = WithRequestID(ctx, requestID)
+		}
+		ctx = context.WithValue(ctx, productionDatabaseKey, db)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithContext returns the primary database bound to ctx
+// Statements are cancelled as soon as ctx is done.
+func (db *ProductionDatabase) WithContext(ctx context.Context) *gorm.DB {
+	return db.primary().WithContext(ctx)
+}
+
+// FromContext returns the primary database bound to ctx, or nil if ctx does not
+// carry a database installed by RequestContextMiddleware
+func FromContext(ctx context.Context) *gorm.DB {
+	if ctx == nil {
+		return nil
+	}
+	db, _ := ctx.Value(productionDatabaseKey).(*ProductionDatabase)
+	if db == nil {
+		return nil
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContextMiddleware_ClientDisconnectCancelsQuery(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	queryErr := make(chan error, 1)
+	handler := db.RequestContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var count int64
+		queryErr <- FromContext(r.Context()).Raw(slowCountSQL).Scan(&count).Error
+	}))
+
+	// Cancelling the request context is what net/http does when the client disconnects
+	ctx, disconnect := context.WithCancel(context.Background())
+	request := httptest.NewRequest(http.MethodGet, "/report", nil).WithContext(ctx)
+	go handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	time.Sleep(100 * time.Millisecond)
+	disconnected := time.Now()
+	disconnect()
+
+	select {
+	case err := <-queryErr:
+		assert.Error(t, err)
+		assert.Less(t, time.Since(disconnected), time.Second, "the query must stop promptly after the disconnect")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the in-flight query kept running after the client disconnected")
+	}
+}
+
+func TestFromContext_NilWithoutMiddleware(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+}
+
+// blockingConnector opens connections whose statements ignore their context and
+// only return once cancelled on the "server"
+type blockingConnector struct{}
+
+func (blockingConnector) Connect(context.Context) (driver.Conn, error) {
+	return &blockingConn{cancelled: make(chan struct{})}, nil
+}
+
+func (c blockingConnector) Driver() driver.Driver { return c }
+
+func (c blockingConnector) Open(string) (driver.Conn, error) { return c.Connect(context.Background()) }
+
+type blockingConn struct {
+	cancelled chan struct{}
+}
+
+func (c *blockingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("blocking: prepare not supported")
+}
+
+func (c *blockingConn) Close() error { return nil }
+
+func (c *blockingConn) Begin() (driver.Tx, error) { return stubTx{}, nil }
+
+func (c *blockingConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	<-c.cancelled
+	return nil, errors.New("canceling statement due to user request")
+}
+
+func (c *blockingConn) CancelBackend() error {
+	close(c.cancelled)
+	return nil
+}
+
+func TestCancelBackendOnDisconnect_CancelsRunningStatement(t *testing.T) {
+	config := DefaultProductionConfig()
+	config.CancelBackendOnDisconnect = true
+	config.Connector = func(string) (driver.Connector, error) { return blockingConnector{}, nil }
+
+	connector, err := newConnector(config, "primary", "blocking")
+	require.NoError(t, err)
+
+	sqlDB := sql.OpenDB(connector)
+	defer sqlDB.Close()
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, disconnect)
+
+	started := time.Now()
+	_, err = sqlDB.QueryContext(ctx, "SELECT pg_sleep(60)")
+
+	require.Error(t, err)
+	assert.Less(t, time.Since(started), time.Second, "the statement must be cancelled on the server")
+}
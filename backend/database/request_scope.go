@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const requestScopeKey contextKey = "database.request_scope"
+
+// QuerySpan is one statement executed within a RequestScope
+type QuerySpan struct {
+	Fingerprint string        `json:"fingerprint"`
+	StartOffset time.Duration `json:"start_offset"`
+	Duration    time.Duration `json:"duration"`
+	Role        string        `json:"role"`
+}
+
+// RequestScope records every statement run with its context, for per-request tracing
+type RequestScope struct {
+	started time.Time
+
+	mu    sync.Mutex
+	spans []QuerySpan
+}
+
+// NewRequestScope starts a scope and returns a context carrying it
+// Statements run with the returned context, or contexts derived from it, are recorded.
+func NewRequestScope(ctx context.Context) (context.Context, *RequestScope) {
+	scope := &RequestScope{started: time.Now()}
+	return context.WithValue(ctx, requestScopeKey, scope), scope
+}
+
+// RequestScopeFrom returns the scope carried by ctx, or nil if there is none
+func RequestScopeFrom(ctx context.Context) *RequestScope {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(requestScopeKey).(*RequestScope)
+	return scope
+}
+
+// record adds a finished statement to the scope
+func (s *RequestScope) record(fingerprint string, startedAt time.Time, duration time.Duration, role string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spans = append(s.spans, QuerySpan{
+		Fingerprint: fingerprint,
+		StartOffset: startedAt.Sub(s.started),
+		Duration:    duration,
+		Role:        role,
+	})
+}
+
+// Trace returns the recorded statements ordered by start offset
+func (s *RequestScope) Trace() []QuerySpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trace := append([]QuerySpan(nil), s.spans...)
+	sort.SliceStable(trace, func(i, j int) bool {
+		return trace[i].StartOffset < trace[j].StartOffset
+	})
+	return trace
+}
+
+// chromeTraceEvent is a complete event in the Chrome trace event format
+type chromeTraceEvent struct {
+	Name      string            `json:"name"`
+	Category  string            `json:"cat"`
+	Phase     string            `json:"ph"`
+	Timestamp int64             `json:"ts"`
+	Duration  int64             `json:"dur"`
+	PID       int               `json:"pid"`
+	TID       int               `json:"tid"`
+	Args      map[string]string `json:"args"`
+}
+
+// WriteChromeTrace writes the trace in the Chrome trace event format, viewable in
+// chrome://tracing or Perfetto. Concurrent statements are placed on separate lanes.
+func (s *RequestScope) WriteChromeTrace(w io.Writer) error {
+	var (
+		events   []chromeTraceEvent
+		laneEnds []time.Duration
+	)
+
+	for _, span := range s.Trace() {
+		lane := 0
+		for lane < len(laneEnds) && laneEnds[lane] > span.StartOffset {
+			lane++
+		}
+		end := span.StartOffset + span.Duration
+		if lane == len(laneEnds) {
+			laneEnds = append(laneEnds, end)
+		} else {
+			laneEnds[lane] = end
+		}
+
+		events = append(events, chromeTraceEvent{
+			Name:      span.Fingerprint,
+			Category:  "db",
+			Phase:     "X",
+			Timestamp: span.StartOffset.Microseconds(),
+			Duration:  span.Duration.Microseconds(),
+			PID:       1,
+			TID:       lane + 1,
+			Args:      map[string]string{"role": span.Role},
+		})
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{"traceEvents": events})
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestScope_TraceRecordsOrderedSpans(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE meals (id integer PRIMARY KEY, name text)").Error)
+
+	ctx, scope := NewRequestScope(context.Background())
+	tx := db.GetDB().WithContext(ctx)
+	require.NoError(t, tx.Exec("INSERT INTO meals (name) VALUES (?)", "oats").Error)
+
+	var count int64
+	require.NoError(t, tx.Raw("SELECT count(*) FROM meals WHERE id IN (?)", []int{1, 2, 3}).Scan(&count).Error)
+	require.NoError(t, tx.Exec("DELETE FROM meals WHERE id = 1").Error)
+
+	// Statements outside the scope are not recorded
+	require.NoError(t, db.GetDB().Exec("SELECT 1").Error)
+
+	trace := scope.Trace()
+	require.Len(t, trace, 3)
+	assert.Equal(t, "INSERT INTO meals (name) VALUES (?)", trace[0].Fingerprint)
+	assert.Equal(t, "SELECT count(*) FROM meals WHERE id IN (?)", trace[1].Fingerprint)
+	assert.Equal(t, "DELETE FROM meals WHERE id = ?", trace[2].Fingerprint)
+
+	for i, span := range trace {
+		assert.Equal(t, "primary", span.Role)
+		assert.Positive(t, span.Duration)
+		if i > 0 {
+			assert.Greater(t, span.StartOffset, trace[i-1].StartOffset)
+			assert.GreaterOrEqual(t, span.StartOffset, trace[i-1].StartOffset+trace[i-1].Duration, "sequential statements must not overlap")
+		}
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, scope.WriteChromeTrace(&out))
+
+	var exported struct {
+		TraceEvents []chromeTraceEvent `json:"traceEvents"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &exported))
+	require.Len(t, exported.TraceEvents, 3)
+	for i, event := range exported.TraceEvents {
+		assert.Equal(t, "X", event.Phase)
+		assert.Equal(t, trace[i].Fingerprint, event.Name)
+		assert.Equal(t, 1, event.TID, "sequential statements share one lane")
+	}
+}
+
+func TestRequestScope_ChromeTraceSeparatesConcurrentSpans(t *testing.T) {
+	_, scope := NewRequestScope(context.Background())
+	scope.spans = []QuerySpan{
+		{Fingerprint: "SELECT ?", StartOffset: 0, Duration: 10 * time.Millisecond, Role: "primary"},
+		{Fingerprint: "SELECT ?", StartOffset: 2 * time.Millisecond, Duration: 3 * time.Millisecond, Role: "replica"},
+		{Fingerprint: "SELECT ?", StartOffset: 12 * time.Millisecond, Duration: time.Millisecond, Role: "primary"},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, scope.WriteChromeTrace(&out))
+
+	var exported struct {
+		TraceEvents []chromeTraceEvent `json:"traceEvents"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &exported))
+	require.Len(t, exported.TraceEvents, 3)
+	assert.Equal(t, []int{1, 2, 1}, []int{exported.TraceEvents[0].TID, exported.TraceEvents[1].TID, exported.TraceEvents[2].TID})
+	assert.Equal(t, int64(2000), exported.TraceEvents[1].Timestamp)
+	assert.Equal(t, "replica", exported.TraceEvents[1].Args["role"])
+}
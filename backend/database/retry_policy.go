@@ -0,0 +1,57 @@
+package database
+
+import "time"
+
+// RetryPolicy decides whether and when RetryOperation retries a failed attempt
+type RetryPolicy interface {
+	// ShouldRetry reports whether to retry after attempt (1 for the first) failed with err
+	ShouldRetry(err error, attempt int) bool
+
+	// NextDelay returns how long to wait after attempt before the next one
+	NextDelay(attempt int) time.Duration
+}
+
+// ExponentialBackoff is the default RetryPolicy, built from MaxRetries, RetryInterval
+// and RetryMaxInterval. It retries errors ClassifyError does not deem permanent until
+// MaxAttempts attempts ran, waiting a random delay of up to Interval doubled per
+// attempt and capped at MaxInterval (defaults to 5m).
+type ExponentialBackoff struct {
+	MaxAttempts int
+	Interval    time.Duration
+	MaxInterval time.Duration
+}
+
+// ShouldRetry implements RetryPolicy
+func (p ExponentialBackoff) ShouldRetry(err error, attempt int) bool {
+	return attempt < p.MaxAttempts && !isNonRetryableError(err)
+}
+
+// NextDelay implements RetryPolicy
+func (p ExponentialBackoff) NextDelay(attempt int) time.Duration {
+	delays := backoff{base: p.Interval, max: p.MaxInterval, failures: attempt - 1}
+	return fullJitter(delays.failed())
+}
+
+// NoRetry is a RetryPolicy running every operation once, e.g. for user-facing
+// requests that would rather fail fast than keep the user waiting
+var NoRetry RetryPolicy = noRetry{}
+
+type noRetry struct{}
+
+// ShouldRetry implements RetryPolicy
+func (noRetry) ShouldRetry(error, int) bool { return false }
+
+// NextDelay implements RetryPolicy
+func (noRetry) NextDelay(int) time.Duration { return 0 }
+
+// retryPolicy returns the configured RetryPolicy, or the default ExponentialBackoff
+func (db *ProductionDatabase) retryPolicy() RetryPolicy {
+	if db.config.RetryPolicy != nil {
+		return db.config.RetryPolicy
+	}
+	return ExponentialBackoff{
+		MaxAttempts: db.config.MaxRetries,
+		Interval:    db.config.RetryInterval,
+		MaxInterval: db.config.RetryMaxInterval,
+	}
+}
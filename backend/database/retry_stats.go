@@ -0,0 +1,57 @@
+package database
+
+import "sync"
+
+// retryStatsUnknownCode keys errors without a SQLSTATE in RetryStatsBySQLSTATE
+const retryStatsUnknownCode = "unknown"
+
+// RetryStat counts the errors RetryOperation saw for one SQLSTATE
+type RetryStat struct {
+	// Retried counts errors followed by another attempt
+	Retried int64 `json:"retried"`
+
+	// NotRetried counts errors returned to the caller, because they were not
+	// retryable or the attempts ran out
+	NotRetried int64 `json:"not_retried"`
+}
+
+// retryStats accumulates RetryStat per SQLSTATE
+type retryStats struct {
+	mu     sync.Mutex
+	byCode map[string]RetryStat
+}
+
+// record counts one error by its SQLSTATE
+func (s *retryStats) record(err error, retried bool) {
+	code := sqlState(err)
+	if code == "" {
+		code = retryStatsUnknownCode
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byCode == nil {
+		s.byCode = make(map[string]RetryStat)
+	}
+	stat := s.byCode[code]
+	if retried {
+		stat.Retried++
+	} else {
+		stat.NotRetried++
+	}
+	s.byCode[code] = stat
+}
+
+// RetryStatsBySQLSTATE returns how often RetryOperation retried, or gave up on,
+// errors of each SQLSTATE; errors without one are keyed "unknown"
+func (db *ProductionDatabase) RetryStatsBySQLSTATE() map[string]RetryStat {
+	db.retryStats.mu.Lock()
+	defer db.retryStats.mu.Unlock()
+
+	stats := make(map[string]RetryStat, len(db.retryStats.byCode))
+	for code, stat := range db.retryStats.byCode {
+		stats[code] = stat
+	}
+	return stats
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRetryStatsBySQLSTATE_CountsPerCode(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxRetries = 3
+	config.RetryInterval = time.Millisecond
+	db := newSQLiteTestDatabase(t, config)
+
+	// Two serialization failures are retried before the operation succeeds
+	failures := 0
+	require.NoError(t, db.RetryOperation(context.Background(), func() error {
+		if failures < 2 {
+			failures++
+			return &pq.Error{Code: sqlStateSerializationFailure, Message: "could not serialize access"}
+		}
+		return nil
+	}))
+
+	// A unique violation is returned straight away
+	err := db.RetryOperation(context.Background(), func() error {
+		return &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}
+	})
+	require.Error(t, err)
+
+	// Deadlocks on every attempt are retried until the attempts run out
+	err = db.RetryOperation(context.Background(), func() error {
+		return &pq.Error{Code: sqlStateDeadlockDetected, Message: "deadlock detected"}
+	})
+	require.Error(t, err)
+
+	// Errors without a SQLSTATE are counted too, and retried whatever their message says
+	require.Error(t, db.RetryOperation(context.Background(), func() error { return errors.New("invalid input syntax") }))
+
+	assert.Equal(t, map[string]RetryStat{
+		sqlStateSerializationFailure: {Retried: 2},
+		"23505":                      {NotRetried: 1},
+		sqlStateDeadlockDetected:     {Retried: 2, NotRetried: 1},
+		retryStatsUnknownCode:        {Retried: 2, NotRetried: 1},
+	}, db.RetryStatsBySQLSTATE())
+}
+
+func TestIsNonRetryableError_ClassifiesBySQLSTATE(t *testing.T) {
+	assert.False(t, isNonRetryableError(&pq.Error{Code: sqlStateSerializationFailure}))
+	assert.False(t, isNonRetryableError(&pq.Error{Code: "08006"}))
+	assert.True(t, isNonRetryableError(&pq.Error{Code: "23503"}))
+	assert.True(t, isNonRetryableError(&pq.Error{Code: "42P01"}))
+	assert.False(t, isNonRetryableError(errors.New("unique constraint failed")))
+	assert.True(t, isNonRetryableError(fmt.Errorf("create user: %w", gorm.ErrDuplicatedKey)))
+}
+
+func TestRetryOperation_CancelledContextAbortsBackoff(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxRetries = 5
+	config.RetryInterval = time.Hour
+	config.RetryMaxInterval = time.Hour
+	db := newSQLiteTestDatabase(t, config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	deadlock := &pq.Error{Code: sqlStateDeadlockDetected, Message: "deadlock detected"}
+	attempts := 0
+	started := time.Now()
+	err := db.RetryOperation(ctx, func() error {
+		attempts++
+		return deadlock
+	})
+
+	// The jittered delay may happen to be short, but never an hour
+	assert.Less(t, time.Since(started), time.Second)
+	if attempts < config.MaxRetries {
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	assert.ErrorIs(t, err, deadlock)
+}
+
+func TestRetryOperation_StopsAtMaxElapsed(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxRetries = 1000
+	config.RetryInterval = 5 * time.Millisecond
+	config.RetryMaxInterval = 10 * time.Millisecond
+	config.RetryMaxElapsed = 50 * time.Millisecond
+	db := newSQLiteTestDatabase(t, config)
+
+	started := time.Now()
+	attempts := 0
+	err := db.RetryOperation(context.Background(), func() error {
+		attempts++
+		return errors.New("connection reset by peer")
+	})
+	require.Error(t, err)
+	assert.LessOrEqual(t, time.Since(started), 100*time.Millisecond)
+	assert.Less(t, attempts, config.MaxRetries)
+}
+
+func TestFullJitter_StaysWithinDelay(t *testing.T) {
+	assert.Zero(t, fullJitter(0))
+	for i := 0; i < 100; i++ {
+		delay := fullJitter(10 * time.Millisecond)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, 10*time.Millisecond)
+	}
+}
+
+// countingPolicy retries up to attempts times without waiting, recording what it was asked
+type countingPolicy struct {
+	attempts int
+	asked    []int
+}
+
+func (p *countingPolicy) ShouldRetry(err error, attempt int) bool {
+	p.asked = append(p.asked, attempt)
+	return attempt < p.attempts
+}
+
+func (p *countingPolicy) NextDelay(int) time.Duration { return 0 }
+
+func TestRetryOperation_UsesConfiguredRetryPolicy(t *testing.T) {
+	policy := &countingPolicy{attempts: 4}
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxRetries = 1
+	config.RetryPolicy = policy
+	db := newSQLiteTestDatabase(t, config)
+
+	// The policy retries even errors the default policy deems permanent
+	calls := 0
+	err := db.RetryOperation(context.Background(), func() error {
+		calls++
+		return &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}
+	})
+	require.Error(t, err)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []int{1, 2, 3, 4}, policy.asked)
+}
+
+func TestRetryOperation_NoRetryRunsOnce(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.RetryPolicy = NoRetry
+	db := newSQLiteTestDatabase(t, config)
+
+	deadlock := &pq.Error{Code: sqlStateDeadlockDetected, Message: "deadlock detected"}
+	calls := 0
+	err := db.RetryOperation(context.Background(), func() error { calls++; return deadlock })
+	assert.Same(t, deadlock, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestExponentialBackoff_DoublesUpToMaxInterval(t *testing.T) {
+	policy := ExponentialBackoff{MaxAttempts: 3, Interval: 10 * time.Millisecond, MaxInterval: 25 * time.Millisecond}
+
+	assert.True(t, policy.ShouldRetry(errors.New("connection reset"), 2))
+	assert.False(t, policy.ShouldRetry(errors.New("connection reset"), 3))
+	assert.False(t, policy.ShouldRetry(&pq.Error{Code: "23505"}, 1))
+
+	for i := 0; i < 50; i++ {
+		assert.LessOrEqual(t, policy.NextDelay(1), 10*time.Millisecond)
+		assert.LessOrEqual(t, policy.NextDelay(2), 20*time.Millisecond)
+		assert.LessOrEqual(t, policy.NextDelay(5), 25*time.Millisecond)
+	}
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Intent forces the role a statement runs on, overriding SQL-based routing
+type Intent int
+
+const (
+	// ReadIntent runs statements on a healthy replica, falling back to the primary
+	ReadIntent Intent = iota + 1
+
+	// WriteIntent runs statements on the primary
+	WriteIntent
+)
+
+const (
+	intentKey = contextKey("database.intent")
+
+	routedRoleInstanceKey = "database:routed_role"
+)
+
+// ContextWithIntent forces statements run with ctx onto the role intent calls for
+// Use it where the SQL misleads routing, e.g. a WITH ... SELECT calling a function
+// that writes.
+func ContextWithIntent(ctx context.Context, intent Intent) context.Context {
+	return context.WithValue(ctx, intentKey, intent)
+}
+
+// IntentFrom returns the intent stored on ctx and whether there is one
+func IntentFrom(ctx context.Context) (Intent, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	intent, ok := ctx.Value(intentKey).(Intent)
+	return intent, ok
+}
+
+// readOnlySQLPrefixes start statements AutoRouteReads sends to a replica
+var readOnlySQLPrefixes = []string{"select", "with", "show", "explain"}
+
+// isReadOnlySQL guesses whether a statement only reads, from its leading keyword
+func isReadOnlySQL(sql string) bool {
+	normalized := strings.ToLower(strings.TrimSpace(sql))
+	if strings.Contains(normalized, " for update") || strings.Contains(normalized, " for share") {
+		return false
+	}
+	for _, prefix := range readOnlySQLPrefixes {
+		if strings.HasPrefix(normalized, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// guessReadOnly reports whether a statement only reads, judging by its locking
+// clause and SQL. Builder queries have no SQL yet and are reads.
+func guessReadOnly(tx *gorm.DB) bool {
+	if _, locking := tx.Statement.Clauses["FOR"]; locking {
+		return false
+	}
+	sql := tx.Statement.SQL.String()
+	return sql == "" || isReadOnlySQL(sql)
+}
+
+// routeStatement moves a statement issued on the pool serving role to the pool
+// its intent calls for. Without an intent on the context, AutoRouteReads sends
+// reads issued on the primary to a replica. Statements in a transaction, a
+// Session or on a pinned connection stay on their connection.
+func (db *ProductionDatabase) routeStatement(tx *gorm.DB, role string) {
+	replicaDB := db.replica()
+	if replicaDB == nil || pinnedConn(tx.Statement.Context) != nil {
+		return
+	}
+
+	intent, ok := IntentFrom(tx.Statement.Context)
+	if !ok {
+		if !db.config.AutoRouteReads || role != "primary" || !guessReadOnly(tx) {
+			return
+		}
+		intent = ReadIntent
+	}
+
+	primaryPool := db.primary().Statement.ConnPool
+	replicaPool := replicaDB.Statement.ConnPool
+
+	switch {
+	case intent == ReadIntent && role == "primary" && tx.Statement.ConnPool == primaryPool:
+		if replica := db.selectReplica(tx.Statement.Context); replica != nil && !db.replicaBudgetExhausted() {
+			tx.Statement.ConnPool = replicaPool
+			tx.InstanceSet(routedRoleInstanceKey, "replica")
+		}
+	case intent == WriteIntent && role == "replica" && tx.Statement.ConnPool == replicaPool:
+		tx.Statement.ConnPool = primaryPool
+		tx.InstanceSet(routedRoleInstanceKey, "primary")
+	}
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newRoutingTestDatabase opens a primary and a replica that each name themselves in a node table
+func newRoutingTestDatabase(t *testing.T) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.AutoRouteReads = true
+	db := newSQLiteTestDatabase(t, config)
+
+	seedNodeName(t, db.primaryDB, "primary")
+	seedNodeName(t, db.replicaDB, "replica")
+	return db
+}
+
+// servedBy runs a CTE that reads the node name, which AutoRouteReads takes for a read
+func servedBy(t *testing.T, tx *gorm.DB) string {
+	t.Helper()
+	var name string
+	require.NoError(t, tx.Raw("WITH n AS (SELECT name FROM node) SELECT name FROM n").Scan(&name).Error)
+	return name
+}
+
+func TestAutoRouteReads_SendsReadsToReplica(t *testing.T) {
+	db := newRoutingTestDatabase(t)
+
+	ctx, scope := NewRequestScope(context.Background())
+	assert.Equal(t, "replica", servedBy(t, db.GetDB().WithContext(ctx)))
+
+	// Instrumentation attributes the statement to the role that served it
+	require.Len(t, scope.Trace(), 1)
+	assert.Equal(t, "replica", scope.Trace()[0].Role)
+
+	var name string
+	require.NoError(t, db.GetDB().Table("node").Select("name").Scan(&name).Error)
+	assert.Equal(t, "replica", name)
+
+	// Writes stay on the primary
+	require.NoError(t, db.GetDB().Exec("INSERT INTO node (name) VALUES ('written')").Error)
+	var written int64
+	require.NoError(t, db.primaryPool().QueryRow("SELECT count(*) FROM node WHERE name = 'written'").Scan(&written))
+	assert.Equal(t, int64(1), written)
+}
+
+func TestContextWithIntent_WriteForcesPrimary(t *testing.T) {
+	db := newRoutingTestDatabase(t)
+
+	ctx := ContextWithIntent(context.Background(), WriteIntent)
+	assert.Equal(t, "primary", servedBy(t, db.GetDB().WithContext(ctx)))
+
+	// The intent also pulls statements issued on the replica handle back to the primary
+	assert.Equal(t, "primary", servedBy(t, db.replicaDB.WithContext(ctx)))
+}
+
+func TestContextWithIntent_ReadForcesReplica(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	db := newSQLiteTestDatabase(t, config)
+	seedNodeName(t, db.primaryDB, "primary")
+	seedNodeName(t, db.replicaDB, "replica")
+
+	// Without AutoRouteReads only an explicit intent routes
+	assert.Equal(t, "primary", servedBy(t, db.GetDB()))
+
+	ctx := ContextWithIntent(context.Background(), ReadIntent)
+	assert.Equal(t, "replica", servedBy(t, db.GetDB().WithContext(ctx)))
+}
+
+func TestAutoRouteReads_TransactionsStayOnPrimary(t *testing.T) {
+	db := newRoutingTestDatabase(t)
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		assert.Equal(t, "primary", servedBy(t, tx))
+		return nil
+	}))
+}
+
+func TestIsReadOnlySQL(t *testing.T) {
+	assert.True(t, isReadOnlySQL("  SELECT * FROM users"))
+	assert.True(t, isReadOnlySQL("WITH x AS (SELECT 1) SELECT * FROM x"))
+	assert.False(t, isReadOnlySQL("SELECT * FROM users FOR UPDATE"))
+	assert.False(t, isReadOnlySQL("UPDATE users SET name = 'x'"))
+	assert.False(t, isReadOnlySQL("INSERT INTO users (name) VALUES ('x')"))
+}
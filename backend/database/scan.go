@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// resultBudget tracks the bytes scanned for one result set against MaxResultBytes
+type resultBudget struct {
+	limit int64
+	used  int64
+}
+
+// add accounts for n more bytes and fails once the limit is exceeded
+func (b *resultBudget) add(n int64) error {
+	if b.limit <= 0 {
+		return nil
+	}
+	b.used += n
+	if b.used > b.limit {
+		return fmt.Errorf("%w: more than %d bytes", ErrResultTooLarge, b.limit)
+	}
+	return nil
+}
+
+func (db *ProductionDatabase) newResultBudget() *resultBudget {
+	return &resultBudget{limit: db.config.MaxResultBytes}
+}
+
+// QueryMaps runs a read query and returns each row as a column->value map
+// []byte values are returned as strings
+func (db *ProductionDatabase) QueryMaps(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	err := db.scanMaps(ctx, query, args, func(row map[string]interface{}) error {
+		results = append(results, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Select runs a read query and scans the rows into dest, which must be a pointer to a slice
+func (db *ProductionDatabase) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("select destination must be a pointer to a slice, got %T", dest)
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+
+	readDB := db.GetReadDB().WithContext(ctx)
+	rows, err := readDB.Raw(query, args...).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	budget := db.newResultBudget()
+	results := reflect.MakeSlice(slice.Type(), 0, 0)
+	for rows.Next() {
+		elem := reflect.New(elemType)
+		if err := readDB.ScanRows(rows, elem.Interface()); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := budget.add(estimateSize(elem.Elem())); err != nil {
+			return err
+		}
+		results = reflect.Append(results, elem.Elem())
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	slice.Set(results)
+	return nil
+}
+
+// StreamJSON runs a read query and writes the rows to w as a JSON array of objects
+// without holding the whole result in memory
+func (db *ProductionDatabase) StreamJSON(ctx context.Context, w io.Writer, query string, args ...interface{}) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	encoder := json.NewEncoder(w)
+	err := db.scanMaps(ctx, query, args, func(row map[string]interface{}) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return encoder.Encode(row)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// scanMaps runs a read query and hands each row to fn as a column->value map
+func (db *ProductionDatabase) scanMaps(ctx context.Context, query string, args []interface{}, fn func(map[string]interface{}) error) error {
+	rows, err := db.GetReadDB().WithContext(ctx).Raw(query, args...).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return scanRowMaps(rows, db.newResultBudget(), db.config.ColumnCoercion, fn)
+}
+
+// scanRowMaps scans every row into a map, applying the column coercions and
+// enforcing the result budget
+func scanRowMaps(rows *sql.Rows, budget *resultBudget, coercions map[string]func(interface{}) interface{}, fn func(map[string]interface{}) error) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		var size int64
+		for i, column := range columns {
+			value := values[i]
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			if coerce, ok := coercions[column]; ok {
+				value = coerce(value)
+			}
+			size += int64(len(column)) + estimateSize(reflect.ValueOf(value))
+			row[column] = value
+		}
+
+		if err := budget.add(size); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// estimateSize approximates the memory held by a scanned value
+func estimateSize(v reflect.Value) int64 {
+	if !v.IsValid() {
+		return 0
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return int64(v.Len())
+		}
+		var size int64
+		for i := 0; i < v.Len(); i++ {
+			size += estimateSize(v.Index(i))
+		}
+		return size
+	case reflect.Map:
+		var size int64
+		iter := v.MapRange()
+		for iter.Next() {
+			size += estimateSize(iter.Key()) + estimateSize(iter.Value())
+		}
+		return size
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return estimateSize(v.Elem())
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += estimateSize(v.Field(i))
+		}
+		if size == 0 {
+			size = int64(v.Type().Size())
+		}
+		return size
+	default:
+		return int64(v.Type().Size())
+	}
+}
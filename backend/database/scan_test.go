@@ -0,0 +1,100 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scanTestDocument struct {
+	ID      int
+	Payload string
+}
+
+// newScanTestDatabase seeds one small and one oversized row under a 1KB result cap
+func newScanTestDatabase(t *testing.T) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxResultBytes = 1024
+	db := newSQLiteTestDatabase(t, config)
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE documents (id integer PRIMARY KEY, payload text)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO documents (id, payload) VALUES (1, ?), (2, ?)", "small", strings.Repeat("x", 4096)).Error)
+	return db
+}
+
+func TestScanHelpers_RowOverCapFails(t *testing.T) {
+	db := newScanTestDatabase(t)
+	ctx := context.Background()
+	query := "SELECT id, payload FROM documents WHERE id = ?"
+
+	_, err := db.QueryMaps(ctx, query, 2)
+	assert.ErrorIs(t, err, ErrResultTooLarge)
+
+	var documents []scanTestDocument
+	err = db.Select(ctx, &documents, query, 2)
+	assert.ErrorIs(t, err, ErrResultTooLarge)
+	assert.Empty(t, documents)
+
+	var out bytes.Buffer
+	err = db.StreamJSON(ctx, &out, query, 2)
+	assert.ErrorIs(t, err, ErrResultTooLarge)
+}
+
+func TestScanHelpers_RowUnderCapSucceeds(t *testing.T) {
+	db := newScanTestDatabase(t)
+	ctx := context.Background()
+	query := "SELECT id, payload FROM documents WHERE id = ?"
+
+	rows, err := db.QueryMaps(ctx, query, 1)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "small", rows[0]["payload"])
+
+	var documents []scanTestDocument
+	require.NoError(t, db.Select(ctx, &documents, query, 1))
+	assert.Equal(t, []scanTestDocument{{ID: 1, Payload: "small"}}, documents)
+
+	var out bytes.Buffer
+	require.NoError(t, db.StreamJSON(ctx, &out, query, 1))
+
+	var streamed []map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &streamed))
+	require.Len(t, streamed, 1)
+	assert.Equal(t, "small", streamed[0]["payload"])
+}
+
+func TestColumnCoercion_ConvertsTimestampToEpochMillis(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.ColumnCoercion = map[string]func(interface{}) interface{}{
+		"created_at": func(value interface{}) interface{} {
+			if ts, ok := value.(time.Time); ok {
+				return ts.UnixMilli()
+			}
+			return value
+		},
+	}
+	db := newSQLiteTestDatabase(t, config)
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE exports (id integer PRIMARY KEY, created_at timestamp)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO exports (id, created_at) VALUES (1, ?)", createdAt).Error)
+
+	ctx := context.Background()
+	query := "SELECT id, created_at FROM exports"
+
+	rows, err := db.QueryMaps(ctx, query)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, createdAt.UnixMilli(), rows[0]["created_at"])
+
+	var out bytes.Buffer
+	require.NoError(t, db.StreamJSON(ctx, &out, query))
+	assert.JSONEq(t, `[{"id": 1, "created_at": 1709296200000}]`, out.String())
+}
@@ -0,0 +1,413 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// schemaMigrationsTable records the versions of the applied Migrations
+const schemaMigrationsTable = "schema_migrations"
+
+// Migration is a versioned schema change with the SQL that applies and reverts it
+// Up and Down may hold several statements. Each runs in a transaction, unless it
+// starts with a statement Postgres refuses to run in one, such as CREATE INDEX
+// CONCURRENTLY; such migrations should hold nothing else, as a failure part way
+// through cannot be rolled back.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string // empty when the migration cannot be reverted
+}
+
+// checksum returns the SHA-256 of the migration's Up SQL, recorded when it is applied
+func (migration Migration) checksum() string {
+	sum := sha256.Sum256([]byte(migration.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// AppliedMigration is a migration recorded in schema_migrations
+type AppliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string // empty for migrations applied before checksums were recorded
+	AppliedAt time.Time
+
+	// Dirty is set when SQL running outside a transaction failed part way through,
+	// leaving the schema to be repaired by hand
+	Dirty bool
+
+	// Modified is set when the configured migration's Up SQL no longer matches Checksum
+	Modified bool
+}
+
+// MigrationStatus is the schema state reported by ProductionDatabase.MigrationStatus
+type MigrationStatus struct {
+	Version int64 // newest applied migration, or 0
+	Applied []AppliedMigration
+	Pending []int64
+	Dirty   bool // any applied migration is dirty
+}
+
+// validateMigrations checks that migration versions are positive and unique
+func validateMigrations(migrations []Migration) error {
+	seen := make(map[int64]bool, len(migrations))
+	for _, migration := range migrations {
+		if migration.Version <= 0 {
+			return fmt.Errorf("migration %q must have a positive version", migration.Name)
+		}
+		if seen[migration.Version] {
+			return fmt.Errorf("migration version %d is declared twice", migration.Version)
+		}
+		seen[migration.Version] = true
+	}
+	return nil
+}
+
+// PlannedMigration is one migration step of a MigrationPlan
+type PlannedMigration struct {
+	Version   int64
+	Name      string
+	Direction string // "up" applies the migration, "down" reverts it
+	SQL       string
+
+	// Transactional is false for SQL that runs outside a transaction (see Migration)
+	Transactional bool
+}
+
+// MigrationPlan lists migration steps in the order they would run
+type MigrationPlan []PlannedMigration
+
+// SQL renders the plan as a script for review, each step headed by a comment
+func (plan MigrationPlan) SQL() string {
+	var script strings.Builder
+	for _, step := range plan {
+		fmt.Fprintf(&script, "-- %d %s (%s)\n", step.Version, step.Name, step.Direction)
+		if !step.Transactional {
+			script.WriteString("-- runs outside a transaction\n")
+		}
+		statements := strings.TrimSpace(step.SQL)
+		script.WriteString(statements)
+		if !strings.HasSuffix(statements, ";") {
+			script.WriteString(";")
+		}
+		script.WriteString("\n\n")
+	}
+	return script.String()
+}
+
+// MigrateUp applies every pending migration in version order
+func (db *ProductionDatabase) MigrateUp(ctx context.Context) error {
+	return db.migrate(ctx, db.planUp)
+}
+
+// MigratePlan reports the migrations MigrateUp would apply without running anything,
+// not even creating the schema_migrations table, e.g. to review them in CI
+func (db *ProductionDatabase) MigratePlan(ctx context.Context) (MigrationPlan, error) {
+	hasTable := db.primary().WithContext(ctx).Migrator().HasTable(schemaMigrationsTable)
+
+	var applied []int64
+	if hasTable {
+		var err error
+		if applied, err = db.appliedVersions(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	apply, revert, err := db.planUp(applied)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make(MigrationPlan, 0, len(revert)+len(apply))
+	for i := len(revert) - 1; i >= 0; i-- {
+		plan = append(plan, plannedMigration(revert[i], "down", revert[i].Down))
+	}
+	for _, migration := range apply {
+		plan = append(plan, plannedMigration(migration, "up", migration.Up))
+	}
+	return plan, nil
+}
+
+// plannedMigration describes running statements of migration in direction
+func plannedMigration(migration Migration, direction, statements string) PlannedMigration {
+	return PlannedMigration{
+		Version:       migration.Version,
+		Name:          migration.Name,
+		Direction:     direction,
+		SQL:           statements,
+		Transactional: !isNonTransactionalDDL(statements),
+	}
+}
+
+// planUp plans applying every pending migration
+func (db *ProductionDatabase) planUp(applied []int64) (apply, revert []Migration, err error) {
+	return db.pendingMigrations(applied, math.MaxInt64), nil, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first
+func (db *ProductionDatabase) MigrateDown(ctx context.Context, steps int) error {
+	return db.migrate(ctx, func(applied []int64) ([]Migration, []Migration, error) {
+		steps = max(0, min(steps, len(applied)))
+		revert, err := db.appliedMigrations(applied[len(applied)-steps:])
+		return nil, revert, err
+	})
+}
+
+// MigrateTo applies or reverts migrations until version is the newest one applied
+// Version 0 reverts every migration.
+func (db *ProductionDatabase) MigrateTo(ctx context.Context, version int64) error {
+	if version != 0 && db.migration(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+	}
+
+	return db.migrate(ctx, func(applied []int64) ([]Migration, []Migration, error) {
+		newer := sort.Search(len(applied), func(i int) bool { return applied[i] > version })
+		revert, err := db.appliedMigrations(applied[newer:])
+		if err != nil {
+			return nil, nil, err
+		}
+		return db.pendingMigrations(applied, version), revert, nil
+	})
+}
+
+// MigrationVersion returns the newest applied migration version, or 0 if there is none
+func (db *ProductionDatabase) MigrationVersion(ctx context.Context) (int64, error) {
+	if err := db.ensureSchemaMigrations(ctx); err != nil {
+		return 0, err
+	}
+	applied, err := db.appliedVersions(ctx)
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1], nil
+}
+
+// MigrationStatus reports the applied migrations, in version order, and the pending ones
+func (db *ProductionDatabase) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	if err := db.ensureSchemaMigrations(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.primaryPool().QueryContext(ctx, "SELECT version, name, checksum, applied_at, dirty FROM "+schemaMigrationsTable+" ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	status := &MigrationStatus{}
+	var versions []int64
+	for rows.Next() {
+		var applied AppliedMigration
+		if err := rows.Scan(&applied.Version, &applied.Name, &applied.Checksum, &applied.AppliedAt, &applied.Dirty); err != nil {
+			return nil, err
+		}
+		if migration := db.migration(applied.Version); migration != nil && applied.Checksum != "" {
+			applied.Modified = migration.checksum() != applied.Checksum
+		}
+		status.Applied = append(status.Applied, applied)
+		status.Version = applied.Version
+		status.Dirty = status.Dirty || applied.Dirty
+		versions = append(versions, applied.Version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, migration := range db.pendingMigrations(versions, math.MaxInt64) {
+		status.Pending = append(status.Pending, migration.Version)
+	}
+	return status, nil
+}
+
+// migrate runs the migrations plan picks from the applied versions under the migration lock
+func (db *ProductionDatabase) migrate(ctx context.Context, plan func(applied []int64) (apply, revert []Migration, err error)) error {
+	return db.traced(ctx, "db.migrate", func(ctx context.Context) error {
+		return db.withMigrationLock(ctx, func() error {
+			return db.runMigrations(ctx, plan)
+		})
+	})
+}
+
+// runMigrations plans migrations from the applied versions, then reverts the planned
+// ones newest first and applies the pending ones oldest first
+func (db *ProductionDatabase) runMigrations(ctx context.Context, plan func(applied []int64) (apply, revert []Migration, err error)) error {
+	if err := db.ensureSchemaMigrations(ctx); err != nil {
+		return err
+	}
+	applied, err := db.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	apply, revert, err := plan(applied)
+	if err != nil {
+		return err
+	}
+
+	for i := len(revert) - 1; i >= 0; i-- {
+		migration := revert[i]
+		err := db.runMigration(ctx, migration.Down,
+			migrationRecord{"UPDATE " + schemaMigrationsTable + " SET dirty = $1 WHERE version = $2", []interface{}{true, migration.Version}},
+			migrationRecord{"DELETE FROM " + schemaMigrationsTable + " WHERE version = $1", []interface{}{migration.Version}})
+		if err != nil {
+			return fmt.Errorf("failed to revert migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		db.logger().Info("Reverted migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	for _, migration := range apply {
+		err := db.runMigration(ctx, migration.Up,
+			migrationRecord{"INSERT INTO " + schemaMigrationsTable + " (version, name, checksum, applied_at, dirty) VALUES ($1, $2, $3, $4, $5)",
+				[]interface{}{migration.Version, migration.Name, migration.checksum(), time.Now().UTC(), true}},
+			migrationRecord{"UPDATE " + schemaMigrationsTable + " SET dirty = $1 WHERE version = $2", []interface{}{false, migration.Version}})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		db.logger().Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+// migrationRecord is a schema_migrations statement with its arguments
+type migrationRecord struct {
+	query string
+	args  []interface{}
+}
+
+// migrationExecer runs statements on the primary pool or in a transaction
+type migrationExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// runMigration marks a migration dirty, runs its SQL, then records the outcome, all
+// in one transaction when the SQL allows it
+// Otherwise a failure part way through leaves the migration marked dirty. The
+// statements go straight to the primary pool, as prepared statements cannot hold
+// several of them.
+func (db *ProductionDatabase) runMigration(ctx context.Context, statements string, markDirty, record migrationRecord) error {
+	pool := db.primaryPool()
+
+	if isNonTransactionalDDL(statements) {
+		return execMigration(ctx, pool, statements, markDirty, record)
+	}
+
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := execMigration(ctx, tx, statements, markDirty, record); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execMigration runs markDirty, a migration's SQL and record on execer
+func execMigration(ctx context.Context, execer migrationExecer, statements string, markDirty, record migrationRecord) error {
+	if _, err := execer.ExecContext(ctx, markDirty.query, markDirty.args...); err != nil {
+		return err
+	}
+	if statements != "" {
+		if _, err := execer.ExecContext(ctx, statements); err != nil {
+			return err
+		}
+	}
+	_, err := execer.ExecContext(ctx, record.query, record.args...)
+	return err
+}
+
+// ensureSchemaMigrations creates the schema_migrations table if it does not exist,
+// adding the checksum and dirty columns to tables created before them
+func (db *ProductionDatabase) ensureSchemaMigrations(ctx context.Context) error {
+	_, err := db.primaryPool().ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+schemaMigrationsTable+
+		" (version BIGINT PRIMARY KEY, name TEXT NOT NULL, checksum TEXT NOT NULL DEFAULT '',"+
+		" applied_at TIMESTAMP NOT NULL, dirty BOOLEAN NOT NULL DEFAULT FALSE)")
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", schemaMigrationsTable, err)
+	}
+
+	migrator := db.primary().WithContext(ctx).Migrator()
+	for column, definition := range map[string]string{
+		"checksum": "TEXT NOT NULL DEFAULT ''",
+		"dirty":    "BOOLEAN NOT NULL DEFAULT FALSE",
+	} {
+		if migrator.HasColumn(schemaMigrationsTable, column) {
+			continue
+		}
+		if _, err := db.primaryPool().ExecContext(ctx, "ALTER TABLE "+schemaMigrationsTable+" ADD COLUMN "+column+" "+definition); err != nil {
+			return fmt.Errorf("failed to add %s column to %s table: %w", column, schemaMigrationsTable, err)
+		}
+	}
+	return nil
+}
+
+// appliedVersions returns the applied migration versions in ascending order
+func (db *ProductionDatabase) appliedVersions(ctx context.Context) ([]int64, error) {
+	rows, err := db.primaryPool().QueryContext(ctx, "SELECT version FROM "+schemaMigrationsTable+" ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// pendingMigrations returns the unapplied migrations up to version in version order
+func (db *ProductionDatabase) pendingMigrations(applied []int64, version int64) []Migration {
+	isApplied := make(map[int64]bool, len(applied))
+	for _, v := range applied {
+		isApplied[v] = true
+	}
+
+	var pending []Migration
+	for _, migration := range db.config.Migrations {
+		if !isApplied[migration.Version] && migration.Version <= version {
+			pending = append(pending, migration)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+	return pending
+}
+
+// appliedMigrations looks up the migrations of applied versions, failing when one
+// is unknown or cannot be reverted, before anything is reverted
+func (db *ProductionDatabase) appliedMigrations(versions []int64) ([]Migration, error) {
+	migrations := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		migration := db.migration(version)
+		if migration == nil {
+			return nil, fmt.Errorf("%w: applied version %d", ErrUnknownMigration, version)
+		}
+		if migration.Down == "" {
+			return nil, fmt.Errorf("%w: %d %s", ErrIrreversibleMigration, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	return migrations, nil
+}
+
+// migration returns the configured migration with version, or nil
+func (db *ProductionDatabase) migration(version int64) *Migration {
+	for i := range db.config.Migrations {
+		if db.config.Migrations[i].Version == version {
+			return &db.config.Migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMigrations create a meals table, add a column to it and index it
+func testMigrations() []Migration {
+	return []Migration{
+		{
+			Version: 1,
+			Name:    "create_meals",
+			Up:      "CREATE TABLE meals (id INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+			Down:    "DROP TABLE meals",
+		},
+		{
+			Version: 2,
+			Name:    "add_meal_calories",
+			Up:      "ALTER TABLE meals ADD COLUMN calories INTEGER; UPDATE meals SET calories = 0",
+			Down:    "ALTER TABLE meals DROP COLUMN calories",
+		},
+		{
+			Version: 3,
+			Name:    "index_meal_names",
+			Up:      "CREATE INDEX meals_name_idx ON meals (name)",
+			Down:    "DROP INDEX meals_name_idx",
+		},
+	}
+}
+
+// tableColumns returns the columns of table in SQLite
+func tableColumns(t *testing.T, db *ProductionDatabase, table string) []string {
+	t.Helper()
+	var columns []string
+	require.NoError(t, db.GetDB().Raw("SELECT name FROM pragma_table_info(?) ORDER BY cid", table).Scan(&columns).Error)
+	return columns
+}
+
+func TestMigrations_UpDownAndTo(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Migrations = testMigrations()
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, db.MigrateUp(ctx))
+	version, err := db.MigrationVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version)
+	assert.Equal(t, []string{"id", "name", "calories"}, tableColumns(t, db, "meals"))
+
+	// Rolling back a bad deploy reverts the newest migrations first
+	require.NoError(t, db.MigrateDown(ctx, 2))
+	version, err = db.MigrationVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), version)
+	assert.Equal(t, []string{"id", "name"}, tableColumns(t, db, "meals"))
+
+	require.NoError(t, db.MigrateTo(ctx, 2))
+	version, err = db.MigrationVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, []string{"id", "name", "calories"}, tableColumns(t, db, "meals"))
+
+	require.NoError(t, db.MigrateTo(ctx, 0))
+	version, err = db.MigrationVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), version)
+	assert.Empty(t, tableColumns(t, db, "meals"))
+
+	assert.True(t, errors.Is(db.MigrateTo(ctx, 42), ErrUnknownMigration))
+}
+
+func TestMigrations_FailedRollbackLeavesSchemaUnchanged(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Migrations = testMigrations()
+	config.Migrations[1].Down = "ALTER TABLE meals DROP COLUMN calories; DROP TABLE missing_table"
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, db.MigrateUp(ctx))
+	require.Error(t, db.MigrateDown(ctx, 2))
+
+	// Migration 3 was reverted; migration 2 failed in its transaction and kept its column
+	version, err := db.MigrationVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, []string{"id", "name", "calories"}, tableColumns(t, db, "meals"))
+}
+
+func TestMigrations_IrreversibleMigrationIsNotRolledBack(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Migrations = testMigrations()
+	config.Migrations[0].Down = ""
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, db.MigrateUp(ctx))
+	err := db.MigrateDown(ctx, 3)
+	assert.True(t, errors.Is(err, ErrIrreversibleMigration), "got %v", err)
+
+	// Nothing is reverted when part of the rollback is impossible
+	version, err := db.MigrationVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version)
+}
+
+func TestValidate_RejectsDuplicateMigrationVersions(t *testing.T) {
+	config := DefaultProductionConfig()
+	config.Migrations = []Migration{{Version: 1, Name: "a"}, {Version: 1, Name: "b"}}
+	assert.Error(t, config.Validate())
+}
+
+func TestMigratePlan_ReportsPendingMigrationsWithoutRunningThem(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Migrations = testMigrations()
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	// Planning a fresh database creates nothing
+	plan, err := db.MigratePlan(ctx)
+	require.NoError(t, err)
+	assert.Len(t, plan, 3)
+	assert.False(t, db.GetDB().Migrator().HasTable(schemaMigrationsTable))
+
+	require.NoError(t, db.MigrateTo(ctx, 1))
+
+	plan, err = db.MigratePlan(ctx)
+	require.NoError(t, err)
+	require.Len(t, plan, 2)
+	assert.Equal(t, int64(2), plan[0].Version)
+	assert.Equal(t, "up", plan[0].Direction)
+	assert.True(t, plan[0].Transactional)
+	assert.Equal(t, int64(3), plan[1].Version)
+
+	assert.Equal(t, "-- 2 add_meal_calories (up)\n"+
+		"ALTER TABLE meals ADD COLUMN calories INTEGER; UPDATE meals SET calories = 0;\n\n"+
+		"-- 3 index_meal_names (up)\n"+
+		"CREATE INDEX meals_name_idx ON meals (name);\n\n", plan.SQL())
+
+	version, err := db.MigrationVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), version, "planning must not apply anything")
+	assert.Equal(t, []string{"id", "name"}, tableColumns(t, db, "meals"))
+}
+
+func TestMigrationPlan_MarksNonTransactionalSteps(t *testing.T) {
+	step := plannedMigration(Migration{Version: 4, Name: "index_concurrently"}, "up", "CREATE INDEX CONCURRENTLY meals_calories_idx ON meals (calories)")
+	assert.False(t, step.Transactional)
+	assert.Contains(t, MigrationPlan{step}.SQL(), "-- runs outside a transaction\n")
+}
+
+func TestMigrationStatus_ReportsAppliedAndPendingMigrations(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Migrations = append(testMigrations(), Migration{
+		Version: 4,
+		Name:    "index_meal_calories",
+		Up:      "CREATE INDEX CONCURRENTLY meals_calories_idx ON meals (calories)",
+	})
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	require.NoError(t, db.MigrateTo(ctx, 2))
+	status, err := db.MigrationStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), status.Version)
+	assert.Equal(t, []int64{3, 4}, status.Pending)
+	assert.False(t, status.Dirty)
+	require.Len(t, status.Applied, 2)
+	assert.Equal(t, "add_meal_calories", status.Applied[1].Name)
+	assert.Equal(t, config.Migrations[1].checksum(), status.Applied[1].Checksum)
+	assert.False(t, status.Applied[1].AppliedAt.IsZero())
+
+	// SQLite rejects CONCURRENTLY, which runs outside a transaction and stays dirty
+	require.Error(t, db.MigrateUp(ctx))
+	db.config.Migrations[0].Up = "CREATE TABLE meals (id INTEGER PRIMARY KEY)"
+
+	status, err = db.MigrationStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), status.Version)
+	assert.Empty(t, status.Pending)
+	assert.True(t, status.Dirty)
+	assert.True(t, status.Applied[3].Dirty)
+	assert.False(t, status.Applied[2].Dirty)
+	assert.True(t, status.Applied[0].Modified, "editing an applied migration changes its checksum")
+	assert.False(t, status.Applied[1].Modified)
+}
+
+func TestMigrationStatus_UpgradesSchemaMigrationsTable(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Migrations = testMigrations()
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	// A schema_migrations table from before checksums and the dirty flag
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE schema_migrations (version BIGINT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)").Error)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE meals (id INTEGER PRIMARY KEY, name TEXT NOT NULL)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", 1, "create_meals", time.Now().UTC()).Error)
+
+	require.NoError(t, db.MigrateUp(ctx))
+	status, err := db.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, status.Applied, 3)
+	assert.Empty(t, status.Applied[0].Checksum)
+	assert.False(t, status.Applied[0].Modified)
+	assert.NotEmpty(t, status.Applied[2].Checksum)
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// defaultSeedEnvironments are the environments a Seeder without Environments runs in
+var defaultSeedEnvironments = []string{"development", "staging"}
+
+// Seeder loads reference data, such as food nutrition tables or exercise catalogs
+// Seed runs on every call to ProductionDatabase.Seed, so it must be idempotent,
+// e.g. upserting rows with clause.OnConflict rather than inserting them.
+type Seeder struct {
+	Name         string
+	DependsOn    []string // seeders whose data this one needs; they run first
+	Environments []string // defaults to development and staging
+	Seed         func(ctx context.Context, tx *gorm.DB) error
+}
+
+// runsIn reports whether the seeder is allowed in environment
+func (seeder Seeder) runsIn(environment string) bool {
+	environments := seeder.Environments
+	if len(environments) == 0 {
+		environments = defaultSeedEnvironments
+	}
+	return slices.Contains(environments, environment)
+}
+
+// Seed runs the seeders allowed in the configured Environment, each in its own
+// primary transaction and after the seeders it depends on
+// Seeders depending on one that is not allowed are skipped as well. Nothing runs
+// when a seeder is unnamed, declared twice or has unknown or cyclic dependencies.
+func (db *ProductionDatabase) Seed(ctx context.Context, seeders ...Seeder) error {
+	ordered, err := orderSeeders(seeders)
+	if err != nil {
+		return err
+	}
+
+	return db.traced(ctx, "db.seed", func(ctx context.Context) error {
+		environment := db.config.Environment
+		skipped := make(map[string]bool)
+		for _, seeder := range ordered {
+			if !seeder.runsIn(environment) || slices.ContainsFunc(seeder.DependsOn, func(name string) bool { return skipped[name] }) {
+				skipped[seeder.Name] = true
+				db.logger().Info("Skipped seeder", "seeder", seeder.Name, "environment", environment)
+				continue
+			}
+
+			err := db.transactionPrimary().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return seeder.Seed(ctx, tx)
+			})
+			if err != nil {
+				return fmt.Errorf("seeder %s failed: %w", seeder.Name, err)
+			}
+			db.logger().Info("Ran seeder", "seeder", seeder.Name, "environment", environment)
+		}
+		return nil
+	})
+}
+
+// orderSeeders sorts seeders so each follows its dependencies, otherwise keeping
+// the order they were given in
+func orderSeeders(seeders []Seeder) ([]Seeder, error) {
+	index := make(map[string]int, len(seeders))
+	for i, seeder := range seeders {
+		if seeder.Name == "" || seeder.Seed == nil {
+			return nil, fmt.Errorf("%w: seeder %d needs a name and a Seed function", ErrInvalidSeeders, i)
+		}
+		if _, ok := index[seeder.Name]; ok {
+			return nil, fmt.Errorf("%w: seeder %s is declared twice", ErrInvalidSeeders, seeder.Name)
+		}
+		index[seeder.Name] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(seeders))
+	ordered := make([]Seeder, 0, len(seeders))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("%w: seeder %s depends on itself", ErrInvalidSeeders, seeders[i].Name)
+		case visited:
+			return nil
+		}
+
+		state[i] = visiting
+		for _, name := range seeders[i].DependsOn {
+			dependency, ok := index[name]
+			if !ok {
+				return fmt.Errorf("%w: seeder %s depends on unknown seeder %s", ErrInvalidSeeders, seeders[i].Name, name)
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		ordered = append(ordered, seeders[i])
+		return nil
+	}
+
+	for i := range seeders {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
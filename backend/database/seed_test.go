@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type seedTestFood struct {
+	Name     string `gorm:"primaryKey"`
+	Unit     string
+	Calories int
+}
+
+// seedTestSeeders seed units, then foods measured in them, recording the order they run in
+func seedTestSeeders(ran *[]string) []Seeder {
+	return []Seeder{
+		{
+			Name:      "foods",
+			DependsOn: []string{"units"},
+			Seed: func(ctx context.Context, tx *gorm.DB) error {
+				*ran = append(*ran, "foods")
+				return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&[]seedTestFood{
+					{Name: "apple", Unit: "g", Calories: 52},
+					{Name: "rice", Unit: "g", Calories: 130},
+				}).Error
+			},
+		},
+		{
+			Name: "units",
+			Seed: func(ctx context.Context, tx *gorm.DB) error {
+				*ran = append(*ran, "units")
+				return tx.AutoMigrate(&seedTestFood{})
+			},
+		},
+		{
+			Name:         "demo_accounts",
+			Environments: []string{"development"},
+			Seed: func(ctx context.Context, tx *gorm.DB) error {
+				*ran = append(*ran, "demo_accounts")
+				return nil
+			},
+		},
+	}
+}
+
+func TestSeed_RunsSeedersInDependencyOrderAndIsIdempotent(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Environment = "staging"
+	db := newSQLiteTestDatabase(t, config)
+
+	var ran []string
+	require.NoError(t, db.Seed(context.Background(), seedTestSeeders(&ran)...))
+	require.NoError(t, db.Seed(context.Background(), seedTestSeeders(&ran)...))
+
+	// demo_accounts is development only
+	assert.Equal(t, []string{"units", "foods", "units", "foods"}, ran)
+
+	var count int64
+	require.NoError(t, db.GetDB().Model(&seedTestFood{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestSeed_SkipsSeedersOutsideTheirEnvironments(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Environment = "production"
+	db := newSQLiteTestDatabase(t, config)
+
+	var ran []string
+	seeders := seedTestSeeders(&ran)
+	seeders[0].Environments = []string{"production"}
+	require.NoError(t, db.Seed(context.Background(), seeders...))
+
+	// foods is allowed in production but depends on units, which is not
+	assert.Empty(t, ran)
+}
+
+func TestSeed_RejectsInvalidSeedersBeforeRunningAny(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Environment = "development"
+	db := newSQLiteTestDatabase(t, config)
+	noop := func(ctx context.Context, tx *gorm.DB) error { return nil }
+
+	for name, seeders := range map[string][]Seeder{
+		"duplicate": {{Name: "units", Seed: noop}, {Name: "units", Seed: noop}},
+		"unknown":   {{Name: "foods", DependsOn: []string{"units"}, Seed: noop}},
+		"cycle":     {{Name: "a", DependsOn: []string{"b"}, Seed: noop}, {Name: "b", DependsOn: []string{"a"}, Seed: noop}},
+		"no seed":   {{Name: "units"}},
+		"no name":   {{Seed: noop}},
+	} {
+		err := db.Seed(context.Background(), seeders...)
+		assert.True(t, errors.Is(err, ErrInvalidSeeders), "%s: got %v", name, err)
+	}
+}
+
+func TestSeed_RollsBackAFailedSeeder(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.Environment = "development"
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.GetDB().AutoMigrate(&seedTestFood{}))
+
+	err := db.Seed(context.Background(), Seeder{
+		Name: "foods",
+		Seed: func(ctx context.Context, tx *gorm.DB) error {
+			require.NoError(t, tx.Create(&seedTestFood{Name: "apple"}).Error)
+			return errors.New("nutrition table unavailable")
+		},
+	})
+	assert.ErrorContains(t, err, "seeder foods failed")
+
+	var count int64
+	require.NoError(t, db.GetDB().Model(&seedTestFood{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// discardTempSQL drops the temporary tables a session created on its connection
+const discardTempSQL = "DISCARD TEMP"
+
+// Session pins one primary connection across calls, so state scoped to the server
+// session, such as temporary tables, is visible to every call until Close
+// A Session is not safe for concurrent use.
+type Session struct {
+	db   *ProductionDatabase
+	conn *sql.Conn
+}
+
+// OpenSession checks out a primary connection and pins it to the returned session
+// The connection is held until Close, so sessions must be short-lived.
+func (db *ProductionDatabase) OpenSession(ctx context.Context) (*Session, error) {
+	conn, err := db.primaryPool().Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session: %w", err)
+	}
+	return &Session{db: db, conn: conn}, nil
+}
+
+// DB returns a GORM handle bound to ctx whose statements run on the session's connection
+func (s *Session) DB(ctx context.Context) *gorm.DB {
+	tx := s.db.primary().Session(&gorm.Session{NewDB: true, Context: ctx})
+	tx.Statement.ConnPool = s.conn
+	return tx
+}
+
+// Exec runs a statement on the session's connection
+func (s *Session) Exec(ctx context.Context, query string, values ...interface{}) error {
+	return s.DB(ctx).Exec(query, values...).Error
+}
+
+// Query runs a query on the session's connection and scans the result into dest
+func (s *Session) Query(ctx context.Context, dest interface{}, query string, values ...interface{}) error {
+	return s.DB(ctx).Raw(query, values...).Scan(dest).Error
+}
+
+// Close returns the connection to the pool
+// On Postgres the session's temporary tables are dropped first so they do not
+// leak into whichever request reuses the connection.
+func (s *Session) Close() error {
+	var discardErr error
+	if s.db.primary().Dialector.Name() == "postgres" {
+		_, discardErr = s.conn.ExecContext(context.Background(), discardTempSQL)
+	}
+
+	if err := s.conn.Close(); err != nil {
+		return err
+	}
+	if discardErr != nil {
+		return fmt.Errorf("failed to discard session temporary tables: %w", discardErr)
+	}
+	return nil
+}
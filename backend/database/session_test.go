@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_TempTableVisibleAcrossCalls(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	ctx := context.Background()
+
+	session, err := db.OpenSession(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, session.Exec(ctx, "CREATE TEMP TABLE shopping_list (item text)"))
+	require.NoError(t, session.Exec(ctx, "INSERT INTO shopping_list (item) VALUES (?), (?)", "oats", "lentils"))
+
+	var items []string
+	require.NoError(t, session.Query(ctx, &items, "SELECT item FROM shopping_list ORDER BY item"))
+	assert.Equal(t, []string{"lentils", "oats"}, items)
+
+	require.NoError(t, session.Close())
+	assert.Equal(t, 0, db.primaryPool().Stats().InUse, "Close must return the connection to the pool")
+}
+
+func TestSession_PinsOneConnection(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxOpenConnections = 2
+	db := newSQLiteTestDatabase(t, config)
+	ctx := context.Background()
+
+	session, err := db.OpenSession(ctx)
+	require.NoError(t, err)
+	defer session.Close()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, session.Exec(ctx, "SELECT 1"))
+	}
+	assert.Equal(t, 1, db.primaryPool().Stats().InUse)
+}
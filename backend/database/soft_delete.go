@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultSoftDeletePurgeInterval is the purge interval used when SoftDeletePurgeInterval is unset
+const defaultSoftDeletePurgeInterval = time.Hour
+
+// deletedAtType is the field type GORM soft-deletes models by
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// SoftDelete is embedded in models to soft-delete them: Delete sets deleted_at
+// instead of removing the row, and queries skip rows where it is set
+type SoftDelete struct {
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// deletedRows selects which rows a Repository reads with respect to soft deletion
+type deletedRows int
+
+const (
+	liveRows deletedRows = iota
+	allRows
+	deletedRowsOnly
+)
+
+// WithDeleted returns a copy of the repository whose reads include soft-deleted rows
+func (r *Repository[T]) WithDeleted() *Repository[T] {
+	return &Repository[T]{db: r.db, tx: r.tx, deleted: allRows}
+}
+
+// OnlyDeleted returns a copy of the repository reading soft-deleted rows only
+func (r *Repository[T]) OnlyDeleted() *Repository[T] {
+	return &Repository[T]{db: r.db, tx: r.tx, deleted: deletedRowsOnly}
+}
+
+// Restore undeletes the soft-deleted row whose primary key is id
+// It fails with ErrNotFound when no soft-deleted row has that key.
+func (r *Repository[T]) Restore(ctx context.Context, id interface{}) error {
+	tx := r.write(ctx)
+	column, err := softDeleteColumn(tx, new(T))
+	if err != nil {
+		return err
+	}
+
+	result := tx.Unscoped().Model(new(T)).
+		Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).
+		Where(clause.Neq{Column: clause.Column{Name: column}, Value: nil}).
+		Update(column, nil)
+	if result.Error != nil {
+		return TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scoped applies the repository's choice of soft-deleted rows to tx
+func (r *Repository[T]) scoped(tx *gorm.DB) *gorm.DB {
+	switch r.deleted {
+	case allRows:
+		return tx.Unscoped()
+	case deletedRowsOnly:
+		column, err := softDeleteColumn(tx, new(T))
+		if err != nil {
+			tx.AddError(err)
+			return tx
+		}
+		return tx.Unscoped().Where(clause.Neq{Column: clause.Column{Name: column}, Value: nil})
+	}
+	return tx
+}
+
+// PurgeSoftDeleted permanently deletes the rows of models soft-deleted more than
+// retention ago, returning how many it deleted. Purging continues past a model
+// that fails; the errors are joined.
+func (db *ProductionDatabase) PurgeSoftDeleted(ctx context.Context, retention time.Duration, models ...interface{}) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+
+	var purged int64
+	var errs []error
+	for _, model := range models {
+		tx := db.primary().WithContext(ctx)
+		column, err := softDeleteColumn(tx, model)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		result := tx.Unscoped().Where(clause.Lt{Column: clause.Column{Name: column}, Value: cutoff}).Delete(model)
+		if result.Error != nil {
+			errs = append(errs, fmt.Errorf("failed to purge soft-deleted %T: %w", model, TranslateError(result.Error)))
+			continue
+		}
+		if result.RowsAffected > 0 {
+			db.logger().Info("Purged soft-deleted rows", "model", fmt.Sprintf("%T", model), "rows", result.RowsAffected, "retention", retention)
+		}
+		purged += result.RowsAffected
+	}
+	return purged, errors.Join(errs...)
+}
+
+// softDeleteColumn returns the column model is soft-deleted by
+func softDeleteColumn(tx *gorm.DB, model interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return "", fmt.Errorf("failed to parse %T: %w", model, err)
+	}
+	for _, field := range stmt.Schema.Fields {
+		if field.FieldType == deletedAtType && field.DBName != "" {
+			return field.DBName, nil
+		}
+	}
+	return "", fmt.Errorf("%T has no gorm.DeletedAt field to soft-delete by", model)
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type softDeleteRecipe struct {
+	ID   int64
+	Name string
+	SoftDelete
+}
+
+func TestRepository_SoftDeleteAndRestore(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&softDeleteRecipe{}))
+	repo := NewRepository[softDeleteRecipe](db)
+	ctx := context.Background()
+
+	kept := &softDeleteRecipe{Name: "porridge"}
+	deleted := &softDeleteRecipe{Name: "pancakes"}
+	require.NoError(t, repo.Create(ctx, kept))
+	require.NoError(t, repo.Create(ctx, deleted))
+	require.NoError(t, repo.Delete(ctx, deleted.ID))
+
+	_, err := repo.Get(ctx, deleted.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	all, err := repo.WithDeleted().List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	onlyDeleted, err := repo.OnlyDeleted().List(ctx)
+	require.NoError(t, err)
+	require.Len(t, onlyDeleted, 1)
+	assert.Equal(t, "pancakes", onlyDeleted[0].Name)
+
+	exists, err := repo.OnlyDeleted().Exists(ctx)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, repo.Restore(ctx, deleted.ID))
+	assert.ErrorIs(t, repo.Restore(ctx, deleted.ID), ErrNotFound, "the row is no longer deleted")
+	count, err := repo.Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// Models without a DeletedAt field cannot be restored
+	assert.Error(t, NewRepository[repositoryMeal](db).Restore(ctx, 1))
+}
+
+func TestPurgeSoftDeleted_RemovesRowsPastRetention(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&softDeleteRecipe{}))
+	ctx := context.Background()
+
+	recipes := []softDeleteRecipe{{Name: "live"}, {Name: "recently deleted"}, {Name: "long deleted"}}
+	require.NoError(t, db.GetDB().Create(&recipes).Error)
+	require.NoError(t, db.GetDB().Model(&softDeleteRecipe{}).Where("id = ?", recipes[1].ID).Update("deleted_at", time.Now().Add(-time.Hour)).Error)
+	require.NoError(t, db.GetDB().Model(&softDeleteRecipe{}).Where("id = ?", recipes[2].ID).Update("deleted_at", time.Now().Add(-48*time.Hour)).Error)
+
+	purged, err := db.PurgeSoftDeleted(ctx, 24*time.Hour, &softDeleteRecipe{}, &repositoryMeal{})
+	assert.Error(t, err, "repositoryMeal cannot be soft-deleted")
+	assert.Equal(t, int64(1), purged)
+
+	var names []string
+	require.NoError(t, db.GetDB().Unscoped().Model(&softDeleteRecipe{}).Order("id").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"live", "recently deleted"}, names)
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// SQLSTATE codes the package reacts to
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+	sqlStateFeatureNotSupported  = "0A000"
+	sqlStateQueryCanceled        = "57014"
+	sqlStateUniqueViolation      = "23505"
+	sqlStateForeignKeyViolation  = "23503"
+)
+
+// sqlState extracts the SQLSTATE code from a lib/pq or pgx error, or "" if there is none
+func sqlState(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+
+	return ""
+}
+
+// isRecoveryConflict reports whether a replica read was cancelled because it conflicted with WAL replay
+// Hot standbys cannot run serializable transactions, so a 40001 from a replica always
+// means "canceling statement due to conflict with recovery"
+func isRecoveryConflict(err error) bool {
+	return sqlState(err) == sqlStateSerializationFailure
+}
+
+// isDeadlock reports whether the statement was aborted to break a deadlock
+func isDeadlock(err error) bool {
+	return sqlState(err) == sqlStateDeadlockDetected
+}
+
+// isSerializationFailure reports whether a serializable transaction could not be serialized
+func isSerializationFailure(err error) bool {
+	return sqlState(err) == sqlStateSerializationFailure
+}
+
+// isConnectionFailure reports whether the connection to the server failed or was
+// refused, whether the driver reported a SQLSTATE or a network error
+func isConnectionFailure(err error) bool {
+	return ClassifyError(err) == ErrorClassConnection
+}
+
+// isStatementTimeout reports whether the statement run with ctx was cancelled by statement_timeout
+// 57014 is shared with the cancel request lib/pq and pgx send once the statement's
+// context ends, so a 57014 on a still live context came from the server. The
+// message is not consulted, since lc_messages may translate it.
+func isStatementTimeout(ctx context.Context, err error) bool {
+	if sqlState(err) != sqlStateQueryCanceled {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return ctx == nil || ctx.Err() == nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StatementStat is the accumulated execution statistics of one normalized statement
+type StatementStat struct {
+	Query     string
+	Calls     int64
+	TotalTime time.Duration
+	MeanTime  time.Duration
+	Rows      int64
+}
+
+// topStatementsOrders maps the orderings TopStatements accepts to their pg_stat_statements column
+var topStatementsOrders = map[string]string{
+	"total_exec_time": "total_exec_time",
+	"calls":           "calls",
+	"mean_exec_time":  "mean_exec_time",
+}
+
+// TopStatements returns the n statements with the highest orderBy in pg_stat_statements
+// on the primary. orderBy is total_exec_time, calls or mean_exec_time (defaults to
+// total_exec_time). Returns ErrStatStatementsUnavailable when the extension is not
+// installed in the database.
+func (db *ProductionDatabase) TopStatements(ctx context.Context, n int, orderBy string) ([]StatementStat, error) {
+	if orderBy == "" {
+		orderBy = "total_exec_time"
+	}
+	column, ok := topStatementsOrders[orderBy]
+	if !ok {
+		return nil, fmt.Errorf("cannot order statements by %q: use total_exec_time, calls or mean_exec_time", orderBy)
+	}
+
+	primary := db.primary().WithContext(ctx)
+
+	var installed bool
+	if err := primary.Raw("SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_extension WHERE extname = 'pg_stat_statements')").Scan(&installed).Error; err != nil {
+		return nil, fmt.Errorf("failed to check for pg_stat_statements: %w", err)
+	}
+	if !installed {
+		return nil, ErrStatStatementsUnavailable
+	}
+
+	rows, err := primary.Raw(fmt.Sprintf(`
+SELECT query, calls, total_exec_time, mean_exec_time, rows
+FROM pg_stat_statements
+WHERE dbid = (SELECT oid FROM pg_catalog.pg_database WHERE datname = current_database())
+ORDER BY %s DESC
+LIMIT ?`, column), n).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pg_stat_statements: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []StatementStat
+	for rows.Next() {
+		var (
+			stat                    StatementStat
+			totalMillis, meanMillis float64
+		)
+		if err := rows.Scan(&stat.Query, &stat.Calls, &totalMillis, &meanMillis, &stat.Rows); err != nil {
+			return nil, fmt.Errorf("failed to scan statement stats: %w", err)
+		}
+		stat.TotalTime = time.Duration(totalMillis * float64(time.Millisecond))
+		stat.MeanTime = time.Duration(meanMillis * float64(time.Millisecond))
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
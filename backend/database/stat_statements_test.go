@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopStatements_ReportsExecutedQueries(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		var n int
+		require.NoError(t, db.GetDB().Raw("SELECT count(*) AS top_statements_probe FROM pg_catalog.pg_class").Scan(&n).Error)
+	}
+
+	stats, err := db.TopStatements(ctx, 1000, "calls")
+	if errors.Is(err, ErrStatStatementsUnavailable) {
+		t.Skip("pg_stat_statements not installed, skipping test")
+	}
+	require.NoError(t, err)
+
+	var probe *StatementStat
+	for i := range stats {
+		if strings.Contains(stats[i].Query, "top_statements_probe") {
+			probe = &stats[i]
+		}
+	}
+	require.NotNil(t, probe, "the probe query must be reported")
+	assert.GreaterOrEqual(t, probe.Calls, int64(5))
+	assert.GreaterOrEqual(t, probe.TotalTime, probe.MeanTime)
+
+	for i := 1; i < len(stats); i++ {
+		assert.GreaterOrEqual(t, stats[i-1].Calls, stats[i].Calls, "stats must be ordered by calls")
+	}
+}
+
+func TestTopStatements_RejectsUnknownOrdering(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	_, err := db.TopStatements(context.Background(), 10, "query; DROP TABLE users")
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// explainTimeout bounds the EXPLAIN run for a statement that timed out
+const explainTimeout = 5 * time.Second
+
+// packageDir is the directory of the package's sources, used to skip its frames
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// StatementTimeoutTotal returns how many statements were cancelled by statement_timeout
+func (db *ProductionDatabase) StatementTimeoutTotal() int64 {
+	return atomic.LoadInt64(&db.statementTimeouts)
+}
+
+// statementTimedOut counts and logs a statement cancelled by statement_timeout on the pool serving role
+func (db *ProductionDatabase) statementTimedOut(tx *gorm.DB, role string) {
+	atomic.AddInt64(&db.statementTimeouts, 1)
+
+	timeout := db.config.connSettings(role)["statement_timeout"]
+	if timeout == "" {
+		timeout = "server default"
+	}
+
+	attrs := []interface{}{
+		"fingerprint", fingerprintSQL(tx.Statement.SQL.String()),
+		"role", role,
+		"timeout", timeout,
+		"error", tx.Error,
+	}
+	if operation := OperationName(tx.Statement.Context); operation != "" {
+		attrs = append(attrs, "operation", operation)
+	}
+	if caller := callerLocation(); caller != "" {
+		attrs = append(attrs, "caller", caller)
+	}
+
+	if db.config.ExplainOnStatementTimeout {
+		plan, err := db.explainStatement(role, tx.Statement.SQL.String(), tx.Statement.Vars)
+		if err != nil {
+			db.logger().Error("Failed to explain timed out statement", "error", err)
+		}
+		if plan != "" {
+			attrs = append(attrs, "plan", plan)
+		}
+	}
+
+	db.logger().Warn("Statement cancelled by statement_timeout", attrs...)
+}
+
+// explainStatement returns the text EXPLAIN plan of query on the pool serving role
+// The statement's own transaction may be aborted, so the plan comes from a fresh connection.
+func (db *ProductionDatabase) explainStatement(role, query string, vars []interface{}) (string, error) {
+	gormDB := db.primary()
+	if replicaDB := db.replica(); role == "replica" && replicaDB != nil {
+		gormDB = replicaDB
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	rows, err := sqlDB.QueryContext(ctx, "EXPLAIN "+query, vars...)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
+// callerLocation returns the file:line of the first caller outside GORM and this package
+func callerLocation() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		inPackage := filepath.Dir(frame.File) == packageDir && !strings.HasSuffix(frame.File, "_test.go")
+		if !inPackage && !strings.Contains(frame.File, "gorm.io/") && frame.File != "" {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestStatementTimeout_LogsWarningAndCounts(t *testing.T) {
+	var output syncBuffer
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.Logger = slog.New(slog.NewJSONHandler(&output, nil))
+	db := newSQLiteTestDatabase(t, config)
+
+	// Simulate the server cancelling the statement once statement_timeout expires
+	require.NoError(t, db.primaryDB.Callback().Raw().Before("gorm:raw").Register("test:statement_timeout", func(tx *gorm.DB) {
+		tx.AddError(&pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"})
+	}))
+
+	ctx := WithOperationName(context.Background(), "monthly_report")
+	err := db.GetDB().WithContext(ctx).Exec("UPDATE meals SET calories = 0 WHERE id = 42").Error
+	require.Error(t, err)
+	assert.Equal(t, int64(1), db.StatementTimeoutTotal())
+	assert.Equal(t, int64(1), db.Stats()["statement_timeouts"])
+
+	warning := recordWithMessage(jsonRecords(t, output.String()), "Statement cancelled by statement_timeout")
+	require.NotNil(t, warning)
+	assert.Equal(t, "WARN", warning["level"])
+	assert.Equal(t, "UPDATE meals SET calories = ? WHERE id = ?", warning["fingerprint"])
+	assert.Equal(t, "primary", warning["role"])
+	assert.Equal(t, "monthly_report", warning["operation"])
+	assert.Equal(t, "server default", warning["timeout"])
+	assert.Contains(t, warning["caller"], "statement_timeout_test.go:")
+	assert.Contains(t, warning["error"], "statement timeout")
+}
+
+func TestStatementTimeout_UserCancellationIsNotCounted(t *testing.T) {
+	var output syncBuffer
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.Logger = slog.New(slog.NewJSONHandler(&output, nil))
+	db := newSQLiteTestDatabase(t, config)
+
+	// Simulate the driver cancelling the statement because its context ended
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, db.primaryDB.Callback().Raw().Before("gorm:raw").Register("test:user_cancel", func(tx *gorm.DB) {
+		cancel()
+		tx.AddError(&pq.Error{Code: "57014", Message: "canceling statement due to user request"})
+	}))
+
+	require.Error(t, db.GetDB().WithContext(ctx).Exec("SELECT 1").Error)
+	assert.Zero(t, db.StatementTimeoutTotal())
+	assert.Nil(t, recordWithMessage(jsonRecords(t, output.String()), "Statement cancelled by statement_timeout"))
+}
+
+func TestStatementTimeout_IgnoresMessageLanguage(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	// lc_messages = 'de_DE' translates the message but not the SQLSTATE
+	require.NoError(t, db.primaryDB.Callback().Raw().Before("gorm:raw").Register("test:statement_timeout", func(tx *gorm.DB) {
+		tx.AddError(&pq.Error{Code: "57014", Message: "storniere Anfrage wegen Zeitüberschreitung der Anweisung"})
+	}))
+
+	require.Error(t, db.GetDB().Exec("UPDATE meals SET calories = 0").Error)
+	assert.Equal(t, int64(1), db.StatementTimeoutTotal())
+}
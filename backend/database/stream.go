@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultStreamBuffer is the StreamRowsChan buffer used when StreamRowsBuffer is unset
+	defaultStreamBuffer = 64
+
+	// defaultStreamFetchSize is the Stream fetch size used when StreamFetchSize is unset
+	defaultStreamFetchSize = 1000
+
+	// streamCursor names the cursor Stream declares in its transaction
+	streamCursor = "stream_cursor"
+)
+
+// RowResult is one row streamed by StreamRowsChan, or the error that ended the stream
+type RowResult struct {
+	Row map[string]interface{}
+	Err error
+}
+
+// StreamRowsChan runs a read query and emits each row as a column->value map on the
+// returned channel, which holds at most StreamRowsBuffer rows. When the consumer
+// falls behind, scanning blocks with the cursor open until it catches up or ctx is
+// done, so a slow client never causes unbounded buffering. The channel is closed
+// once the rows are exhausted, a scan fails (delivered as a final RowResult.Err) or
+// ctx is done; the rows and their connection are released in every case.
+// Consumers that stop reading early must cancel ctx.
+func (db *ProductionDatabase) StreamRowsChan(ctx context.Context, query string, args ...interface{}) (<-chan RowResult, error) {
+	rows, err := db.GetReadDBContext(ctx).WithContext(ctx).Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := db.config.StreamRowsBuffer
+	if buffer <= 0 {
+		buffer = defaultStreamBuffer
+	}
+	results := make(chan RowResult, buffer)
+
+	go func() {
+		defer close(results)
+		defer rows.Close()
+
+		// Rows are not accumulated, so the result budget does not apply
+		err := scanRowMaps(rows, &resultBudget{}, db.config.ColumnCoercion, func(row map[string]interface{}) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			select {
+			case results <- RowResult{Row: row}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			select {
+			case results <- RowResult{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// Stream runs a read query and hands each row to fn as a column->value map, so
+// millions of rows can be processed without holding them in memory. On Postgres
+// the rows come from a server-side cursor, StreamFetchSize rows per round trip,
+// within a read transaction; other databases scan the rows one by one. Streaming
+// stops at the first error fn returns, which Stream returns, or once ctx is done.
+// Rows are not accumulated, so MaxResultBytes does not apply.
+func (db *ProductionDatabase) Stream(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	each := func(row map[string]interface{}) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(row)
+	}
+
+	readDB := db.GetReadDBContext(ctx).WithContext(ctx)
+	if readDB.Dialector.Name() != "postgres" {
+		rows, err := readDB.Raw(query, args...).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		return scanRowMaps(rows, &resultBudget{}, db.config.ColumnCoercion, each)
+	}
+
+	fetchSize := db.config.StreamFetchSize
+	if fetchSize <= 0 {
+		fetchSize = defaultStreamFetchSize
+	}
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", fetchSize, streamCursor)
+
+	return readDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DECLARE "+streamCursor+" NO SCROLL CURSOR FOR "+query, args...).Error; err != nil {
+			return fmt.Errorf("failed to declare stream cursor: %w", err)
+		}
+
+		for {
+			rows, err := tx.Raw(fetch).Rows()
+			if err != nil {
+				return err
+			}
+			fetched := 0
+			err = scanRowMaps(rows, &resultBudget{}, db.config.ColumnCoercion, func(row map[string]interface{}) error {
+				fetched++
+				return each(row)
+			})
+			rows.Close()
+			if err != nil {
+				return err
+			}
+			// The cursor closes with the transaction
+			if fetched < fetchSize {
+				return nil
+			}
+		}
+	}, &sql.TxOptions{ReadOnly: true})
+}
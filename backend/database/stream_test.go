@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRowsSQL yields the integers 1 to 1000
+const countingRowsSQL = `
+WITH RECURSIVE counter(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM counter WHERE x < 1000)
+SELECT x FROM counter`
+
+func TestStreamRowsChan_StreamsEveryRow(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	results, err := db.StreamRowsChan(context.Background(), countingRowsSQL)
+	require.NoError(t, err)
+
+	count := 0
+	for result := range results {
+		require.NoError(t, result.Err)
+		count++
+		assert.EqualValues(t, count, result.Row["x"])
+	}
+	assert.Equal(t, 1000, count)
+	assert.Eventually(t, func() bool { return db.primaryPool().Stats().InUse == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestStreamRowsChan_SlowConsumerBlocksProduction(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.StreamRowsBuffer = 4
+	db := newSQLiteTestDatabase(t, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results, err := db.StreamRowsChan(ctx, countingRowsSQL)
+	require.NoError(t, err)
+
+	first := <-results
+	require.NoError(t, first.Err)
+
+	// Production stops at the buffer bound while the cursor stays open
+	assert.Eventually(t, func() bool { return len(results) == cap(results) }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 4, len(results), "production must block while the consumer is behind")
+	assert.Equal(t, 1, db.primaryPool().Stats().InUse, "the cursor holds its connection")
+
+	// Cancelling ends the stream and releases the rows and connection
+	cancel()
+	received := 1
+	for range results {
+		received++
+	}
+	assert.Less(t, received, 1000)
+	assert.Eventually(t, func() bool { return db.primaryPool().Stats().InUse == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestStream_HandsEveryRowToFn(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	ctx := context.Background()
+	query := "SELECT x FROM (" + countingRowsSQL + ") WHERE x <= ?"
+
+	var sum int64
+	require.NoError(t, db.Stream(ctx, query, func(row map[string]interface{}) error {
+		sum += row["x"].(int64)
+		return nil
+	}, 1000))
+	assert.Equal(t, int64(500500), sum)
+
+	// fn's error stops the stream
+	stop := errors.New("stop")
+	seen := 0
+	err := db.Stream(ctx, query, func(map[string]interface{}) error {
+		seen++
+		if seen == 10 {
+			return stop
+		}
+		return nil
+	}, 1000)
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 10, seen)
+
+	// So does ctx
+	cancelled, cancel := context.WithCancel(ctx)
+	seen = 0
+	err = db.Stream(cancelled, query, func(map[string]interface{}) error {
+		seen++
+		cancel()
+		return nil
+	}, 1000)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, seen)
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const traceTagKey contextKey = "database.trace_tag"
+
+// traceTag identifies the trace and span a statement is run for
+type traceTag struct {
+	traceID string
+	spanID  string
+}
+
+// WithTraceID tags the statements run with ctx with a distributed trace and span ID
+// With TraceApplicationName the IDs show up as the connection's application_name
+// in pg_stat_activity while it serves the statement.
+func WithTraceID(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceTagKey, traceTag{traceID: traceID, spanID: spanID})
+}
+
+// TraceID returns the trace and span ID stored on ctx, or empty strings if there are none
+func TraceID(ctx context.Context) (traceID, spanID string) {
+	if ctx == nil {
+		return "", ""
+	}
+	tag, _ := ctx.Value(traceTagKey).(traceTag)
+	return tag.traceID, tag.spanID
+}
+
+// applicationName renders the application_name for statements run with ctx, or "" if untraced
+func applicationName(ctx context.Context) string {
+	traceID, spanID := TraceID(ctx)
+	if traceID == "" {
+		return ""
+	}
+	if spanID == "" {
+		return "trace=" + traceID
+	}
+	return fmt.Sprintf("trace=%s span=%s", traceID, spanID)
+}
+
+// tagApplicationName points the connection's application_name at the trace of ctx
+// The name is only changed when it differs from the connection's current one, and is
+// reset by the next untraced statement, so an idle connection may keep showing the
+// trace it served last.
+func (c *hookedConn) tagApplicationName(ctx context.Context) error {
+	if !c.connector.traceApplicationName {
+		return nil
+	}
+
+	name := applicationName(ctx)
+	if name == c.applicationName {
+		return nil
+	}
+
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil
+	}
+
+	statement := "RESET application_name"
+	if name != "" {
+		statement = "SET application_name TO " + pq.QuoteLiteral(name)
+	}
+	if _, err := execer.ExecContext(ctx, statement, nil); err != nil {
+		return fmt.Errorf("failed to tag connection with trace: %w", err)
+	}
+	c.applicationName = name
+	return nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceApplicationName_VisibleInPgStatActivity(t *testing.T) {
+	config := newPostgresTestConfig(t)
+	config.TraceApplicationName = true
+	config.MaxOpenConnections = 1
+	db, err := NewProductionDatabase(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	const activitySQL = "SELECT application_name FROM pg_stat_activity WHERE pid = pg_backend_pid()"
+	ctx := WithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+
+	var applicationName string
+	require.NoError(t, db.GetDB().WithContext(ctx).Raw(activitySQL).Scan(&applicationName).Error)
+	assert.Contains(t, applicationName, "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Contains(t, applicationName, "00f067aa0ba902b7")
+
+	// The next untraced statement on the connection drops the tag
+	require.NoError(t, db.GetDB().Raw(activitySQL).Scan(&applicationName).Error)
+	assert.NotContains(t, applicationName, "4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+func TestApplicationName_FromTraceContext(t *testing.T) {
+	assert.Empty(t, applicationName(context.Background()))
+	assert.Equal(t, "trace=abc", applicationName(WithTraceID(context.Background(), "abc", "")))
+	assert.Equal(t, "trace=abc span=def", applicationName(WithTraceID(context.Background(), "abc", "def")))
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// tracerName is the instrumentation scope of the package's spans
+const tracerName = "trae-nutrition-backend/database"
+
+// traced runs fn in a span called name when tracing is enabled, passing it the
+// span's context so statements fn runs become its children
+func (db *ProductionDatabase) traced(ctx context.Context, name string, fn func(ctx context.Context) error, attributes ...attribute.KeyValue) error {
+	if db.tracer == nil {
+		return fn(ctx)
+	}
+
+	ctx, span := db.tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// startStatementSpan opens a client span for the statement of the given type
+// (create/query/update/delete/row/raw) and runs the statement under it
+func (db *ProductionDatabase) startStatementSpan(tx *gorm.DB, statementType string) {
+	ctx, span := db.tracer.Start(tx.Statement.Context, "db."+statementType,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "postgresql")),
+	)
+	tx.Statement.Context = ctx
+	tx.InstanceSet(spanInstanceKey, span)
+}
+
+// endStatementSpan describes the statement on its span and ends it
+// The statement is recorded by its fingerprint, so values never reach the tracer.
+func endStatementSpan(tx *gorm.DB, role string) {
+	value, ok := tx.InstanceGet(spanInstanceKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	span.SetAttributes(
+		attribute.String("db.statement", fingerprintSQL(tx.Statement.SQL.String())),
+		attribute.Int64("db.rows_affected", tx.RowsAffected),
+		attribute.String("db.target", role),
+	)
+	if table := tx.Statement.Table; table != "" {
+		span.SetAttributes(attribute.String("db.sql.table", table))
+	}
+	if name := OperationName(tx.Statement.Context); name != "" {
+		span.SetAttributes(attribute.String("db.operation", name))
+	}
+	endSpan(span, tx.Error)
+}
+
+// endSpan marks span failed when err is set, then ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
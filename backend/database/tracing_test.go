@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/gorm"
+)
+
+// recordingTracerProvider keeps every span its tracers start
+type recordingTracerProvider struct {
+	noop.TracerProvider
+
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (p *recordingTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{provider: p}
+}
+
+// named returns the ended spans called name
+func (p *recordingTracerProvider) named(name string) []*recordedSpan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var spans []*recordedSpan
+	for _, span := range p.spans {
+		if span.name == name && span.ended {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	provider *recordingTracerProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	span := &recordedSpan{name: name, parent: trace.SpanFromContext(ctx), attributes: map[attribute.Key]attribute.Value{}}
+	span.SetAttributes(config.Attributes()...)
+
+	t.provider.mu.Lock()
+	t.provider.spans = append(t.provider.spans, span)
+	t.provider.mu.Unlock()
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordedSpan struct {
+	noop.Span
+	name       string
+	parent     trace.Span
+	attributes map[attribute.Key]attribute.Value
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...attribute.KeyValue) {
+	for _, kv := range attributes {
+		s.attributes[kv.Key] = kv.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error, _ ...trace.EventOption) { s.err = err }
+
+func (s *recordedSpan) End(...trace.SpanEndOption) { s.ended = true }
+
+func TestTracerProvider_SpansForStatementsAndTransactions(t *testing.T) {
+	provider := &recordingTracerProvider{}
+	config := newSQLiteTestConfig(t, "primary")
+	config.ReadReplicaURL = sqliteTestDSN(t, "replica")
+	config.TracerProvider = provider
+	db := newSQLiteTestDatabase(t, config)
+	seedNodeName(t, db.replica(), "replica")
+
+	require.NoError(t, db.Migrate(&auditTestMeal{}))
+	require.Len(t, provider.named("db.migrate"), 1)
+
+	ctx := WithOperationName(context.Background(), "log_meal")
+	require.NoError(t, db.TransactionContext(ctx, 0, func(tx *gorm.DB) error {
+		return tx.Create(&auditTestMeal{Name: "secret oatmeal"}).Error
+	}))
+
+	transactions := provider.named("db.transaction")
+	require.Len(t, transactions, 1)
+
+	creates := provider.named("db.create")
+	require.Len(t, creates, 1)
+	create := creates[0]
+	assert.Same(t, transactions[0], create.parent, "statements must be children of their transaction")
+	assert.Equal(t, "postgresql", create.attributes["db.system"].AsString())
+	assert.Equal(t, "primary", create.attributes["db.target"].AsString())
+	assert.Equal(t, "log_meal", create.attributes["db.operation"].AsString())
+	assert.Equal(t, int64(1), create.attributes["db.rows_affected"].AsInt64())
+	assert.NotContains(t, create.attributes["db.statement"].AsString(), "secret", "values must not reach the tracer")
+
+	var name string
+	require.NoError(t, db.GetReadDB().Raw("SELECT name FROM node").Scan(&name).Error)
+	rows := provider.named("db.row")
+	require.NotEmpty(t, rows)
+	read := rows[len(rows)-1]
+	assert.Equal(t, "replica", read.attributes["db.target"].AsString())
+	assert.Equal(t, "SELECT name FROM node", read.attributes["db.statement"].AsString())
+
+	require.Error(t, db.GetDB().Exec("SELECT * FROM missing_table").Error)
+	raws := provider.named("db.raw")
+	assert.Error(t, raws[len(raws)-1].err, "failed statements must record their error")
+
+	db.healthChecker.check()
+	assert.Len(t, provider.named("db.health_check"), 1)
+}
+
+func TestTracerProvider_DisabledByDefault(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	assert.Nil(t, db.tracer)
+	require.NoError(t, db.GetDB().Exec("SELECT 1").Error)
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+const txOptionsKey contextKey = "database.tx_options"
+
+// deferrableSQL makes a SERIALIZABLE READ ONLY transaction DEFERRABLE; Postgres
+// accepts it before the transaction's first query
+const deferrableSQL = "SET TRANSACTION DEFERRABLE"
+
+// TransactionContext runs fn in a primary transaction bound to ctx
+// A positive budget caps the whole transaction: every statement shares one
+// deadline, and once it passes the running statement is cancelled and the
+// transaction rolls back. The error then wraps context.DeadlineExceeded.
+func (db *ProductionDatabase) TransactionContext(ctx context.Context, budget time.Duration, fn func(*gorm.DB) error) error {
+	if err := db.primaryAvailable(); err != nil {
+		return err
+	}
+	if budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+
+	err := db.traced(ctx, "db.transaction", func(ctx context.Context) error {
+		return runTransaction(ctx, db.transactionPrimary(), fn)
+	})
+	if err == nil {
+		return nil
+	}
+
+	if budget > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("transaction exceeded its %v budget: %w", budget, err)
+		}
+		// Drivers may report the cancellation with their own error, such as SQLite's "interrupted"
+		return fmt.Errorf("transaction exceeded its %v budget: %w: %v", budget, context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+// NestedTransactionWithRetry runs fn in a savepoint within the transaction tx
+// When fn fails with a deadlock, tx is rolled back to the savepoint and fn runs
+// again, up to SavepointRetries more times, so one deadlocked step does not abort
+// the whole transaction. Other errors are returned after the savepoint rollback.
+func (db *ProductionDatabase) NestedTransactionWithRetry(tx *gorm.DB, fn func(*gorm.DB) error) error {
+	attempts := db.config.SavepointRetries + 1
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = tx.Transaction(fn)
+		if err == nil || !isDeadlock(err) {
+			return err
+		}
+		if attempt < attempts {
+			db.logger().Warn("Nested transaction deadlocked, retrying from savepoint", "attempt", attempt, "max_attempts", attempts, "error", err)
+		}
+	}
+
+	return fmt.Errorf("nested transaction deadlocked after %d attempts: %w", attempts, err)
+}
+
+// TransactionWithRetry runs fn in a primary transaction started with opts, running
+// the whole transaction again when it fails to serialize (SQLSTATE 40001) or
+// deadlocks (40P01), up to TransactionRetries more times. Attempts are spaced by a
+// random delay of up to TransactionRetryInterval doubled per attempt, capped at
+// RetryMaxInterval; waiting ends as soon as ctx is done.
+// fn must be safe to run more than once, deferring side effects outside the
+// database until the transaction returns.
+func (db *ProductionDatabase) TransactionWithRetry(ctx context.Context, opts sql.TxOptions, fn func(*gorm.DB) error) error {
+	attempts := db.config.TransactionRetries + 1
+	delays := backoff{base: db.config.TransactionRetryInterval, max: db.config.RetryMaxInterval}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = db.TransactionWithOptions(ctx, opts, fn)
+		if err == nil || !isTransactionConflict(err) {
+			return err
+		}
+		if attempt == attempts {
+			db.retryStats.record(err, false)
+			break
+		}
+
+		delay := fullJitter(delays.failed())
+		db.logger().Warn("Transaction conflicted, retrying",
+			"attempt", attempt, "max_attempts", attempts, "retry_in", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+			db.retryStats.record(err, true)
+		case <-ctx.Done():
+			timer.Stop()
+			db.retryStats.record(err, false)
+			return fmt.Errorf("transaction aborted after %d attempts: %w: %w", attempt, ctx.Err(), err)
+		}
+	}
+
+	return fmt.Errorf("transaction failed after %d attempts: %w", attempts, err)
+}
+
+// isTransactionConflict reports whether a transaction failed only because it
+// conflicted with a concurrent one, so running it again may succeed
+func isTransactionConflict(err error) bool {
+	return isSerializationFailure(err) || isDeadlock(err)
+}
+
+// TransactionIsolation returns the isolation level tx was started at by
+// TransactionWithOptions or its helpers, so e.g. fn in SerializableTransaction
+// can tell whether it is on the downgraded path
+func TransactionIsolation(tx *gorm.DB) sql.IsolationLevel {
+	opts, _ := tx.Statement.Context.Value(txOptionsKey).(sql.TxOptions)
+	return opts.Isolation
+}
+
+// TransactionReadOnly reports whether tx was started read-only by
+// TransactionWithOptions or its helpers
+func TransactionReadOnly(tx *gorm.DB) bool {
+	opts, _ := tx.Statement.Context.Value(txOptionsKey).(sql.TxOptions)
+	return opts.ReadOnly
+}
+
+// TransactionWithOptions runs fn in a primary transaction started with opts
+// The statements fn runs on its session carry opts, which TransactionIsolation and
+// TransactionReadOnly report.
+func (db *ProductionDatabase) TransactionWithOptions(ctx context.Context, opts sql.TxOptions, fn func(*gorm.DB) error) error {
+	if err := db.primaryAvailable(); err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, txOptionsKey, opts)
+	return db.traced(ctx, "db.transaction", func(ctx context.Context) error {
+		return runTransaction(ctx, db.transactionPrimary(), fn, &opts)
+	}, attribute.String("db.isolation_level", opts.Isolation.String()), attribute.Bool("db.read_only", opts.ReadOnly))
+}
+
+// ReadOnlyTransaction runs fn in a READ ONLY primary transaction at the default
+// isolation level; statements writing to the database fail
+func (db *ProductionDatabase) ReadOnlyTransaction(ctx context.Context, fn func(*gorm.DB) error) error {
+	return db.TransactionWithOptions(ctx, sql.TxOptions{ReadOnly: true}, fn)
+}
+
+// DeferrableTransaction runs fn in a SERIALIZABLE READ ONLY DEFERRABLE primary
+// transaction. On Postgres it may wait for a safe snapshot before fn runs, but is
+// then never cancelled by serialization failures, suiting long reports and
+// backups. Other databases run it as a SERIALIZABLE READ ONLY transaction.
+func (db *ProductionDatabase) DeferrableTransaction(ctx context.Context, fn func(*gorm.DB) error) error {
+	opts := sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}
+	return db.TransactionWithOptions(ctx, opts, func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec(deferrableSQL).Error; err != nil {
+				return fmt.Errorf("failed to make transaction deferrable: %w", err)
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// SerializableTransaction runs fn in a SERIALIZABLE primary transaction, running it
+// again on serialization failures up to SerializableRetries more times. Attempts are
+// spaced like TransactionWithRetry's, by a random delay of up to
+// TransactionRetryInterval doubled per attempt; waiting ends as soon as ctx is done.
+//
+// With SerializableFallback, a transaction that keeps failing runs one last time at
+// REPEATABLE READ. That trades correctness for progress: REPEATABLE READ still sees
+// one snapshot, but no longer detects read/write dependencies between concurrent
+// transactions, so write skew becomes possible. Only enable it where fn tolerates
+// that, e.g. by locking the rows it depends on with SELECT ... FOR UPDATE.
+func (db *ProductionDatabase) SerializableTransaction(ctx context.Context, fn func(*gorm.DB) error) error {
+	attempts := db.config.SerializableRetries + 1
+	delays := backoff{base: db.config.TransactionRetryInterval, max: db.config.RetryMaxInterval}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = db.TransactionWithOptions(ctx, sql.TxOptions{Isolation: sql.LevelSerializable}, fn)
+		if err == nil || !isSerializationFailure(err) {
+			return err
+		}
+		if attempt == attempts {
+			break
+		}
+
+		delay := fullJitter(delays.failed())
+		db.logger().Warn("Serializable transaction failed to serialize, retrying",
+			"attempt", attempt, "max_attempts", attempts, "retry_in", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("serializable transaction aborted after %d attempts: %w: %w", attempt, ctx.Err(), err)
+		}
+	}
+
+	if !db.config.SerializableFallback {
+		return fmt.Errorf("serializable transaction failed after %d attempts: %w", attempts, err)
+	}
+
+	db.logger().Warn("Serializable transaction keeps failing, retrying at REPEATABLE READ", "attempts", attempts, "error", err)
+	db.emit(EventIsolationDowngraded, "serializable transaction retried at REPEATABLE READ", err)
+
+	return db.TransactionWithOptions(ctx, sql.TxOptions{Isolation: sql.LevelRepeatableRead}, fn)
+}
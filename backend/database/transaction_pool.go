@@ -0,0 +1,43 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// openTransactionPool opens the sub-pool transactions run on against the primary at
+// dsn, or returns nil when MaxTransactionConnections is unset
+func (db *ProductionDatabase) openTransactionPool(dsn string) (*gorm.DB, error) {
+	if db.config.MaxTransactionConnections <= 0 {
+		return nil, nil
+	}
+
+	transactionDB, sqlDB, err := openPool(db.config, "primary", dsn, db.gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transaction pool: %w", err)
+	}
+	// Lowering the open limit lowers the idle limit with it
+	sqlDB.SetMaxOpenConns(db.config.MaxTransactionConnections)
+
+	if err := db.registerCallbacks(transactionDB, "primary"); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return transactionDB, nil
+}
+
+// transactionPool returns the transaction sub-pool, or nil when transactions share the primary pool
+func (db *ProductionDatabase) transactionPool() *gorm.DB {
+	db.poolMu.RLock()
+	defer db.poolMu.RUnlock()
+	return db.transactionDB
+}
+
+// transactionPrimary returns the primary database transactions begin on
+func (db *ProductionDatabase) transactionPrimary() *gorm.DB {
+	if transactionDB := db.transactionPool(); transactionDB != nil {
+		return transactionDB
+	}
+	return db.primary()
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTransactionPool_SaturatedTransactionsLeaveReadsFlowing(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxOpenConnections = 2
+	config.MaxTransactionConnections = 2
+	db := newSQLiteTestDatabase(t, config)
+
+	// Hold every transaction connection open
+	release := make(chan struct{})
+	var started, finished sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		started.Add(1)
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			err := db.TransactionContext(context.Background(), 0, func(tx *gorm.DB) error {
+				var one int
+				if err := tx.Raw("SELECT 1").Scan(&one).Error; err != nil {
+					return err
+				}
+				started.Done()
+				<-release
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	started.Wait()
+	defer finished.Wait()
+	defer close(release)
+
+	stats := db.poolStats()
+	assert.Equal(t, 2, stats["transaction"].InUse)
+	assert.Equal(t, 0, stats["primary"].InUse)
+
+	// Another transaction has to wait for a transaction connection
+	err := db.TransactionContext(context.Background(), 50*time.Millisecond, func(tx *gorm.DB) error { return nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Quick statements outside transactions are unaffected
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		var answer int
+		require.NoError(t, db.GetDB().WithContext(ctx).Raw("SELECT 42").Scan(&answer).Error)
+		assert.Equal(t, 42, answer)
+	}
+}
+
+func TestTransactionPool_DisabledByDefault(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	assert.Nil(t, db.transactionPool())
+	assert.Same(t, db.primary(), db.transactionPrimary())
+	assert.NotContains(t, db.poolStats(), "transaction")
+}
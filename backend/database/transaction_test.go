@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// slowCountSQL keeps SQLite busy for far longer than any budget used in the tests
+const slowCountSQL = `
+WITH RECURSIVE counter(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM counter WHERE x < 1000000000)
+SELECT count(*) FROM counter`
+
+func TestTransactionContext_BudgetSharedAcrossStatements(t *testing.T) {
+	// A single connection makes the check below wait for the rollback to finish
+	config := newSQLiteTestConfig(t, "primary")
+	config.MaxOpenConnections = 1
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE budget_entries (id integer PRIMARY KEY, note text)").Error)
+
+	budget := 200 * time.Millisecond
+	secondStarted := false
+	started := time.Now()
+
+	err := db.TransactionContext(context.Background(), budget, func(tx *gorm.DB) error {
+		if err := tx.Exec("INSERT INTO budget_entries (note) VALUES ('first')").Error; err != nil {
+			return err
+		}
+
+		// Time spent after the first statement still counts against the budget
+		time.Sleep(150 * time.Millisecond)
+
+		secondStarted = true
+		var count int64
+		return tx.Raw(slowCountSQL).Scan(&count).Error
+	})
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, secondStarted, "the second statement must start within the budget")
+	assert.Less(t, time.Since(started), 2*time.Second, "the second statement must be cancelled at the deadline")
+
+	// The first statement was rolled back with the transaction
+	var rows int64
+	require.NoError(t, db.GetDB().Raw("SELECT count(*) FROM budget_entries").Scan(&rows).Error)
+	assert.Equal(t, int64(0), rows)
+}
+
+func TestTransactionContext_CommitsWithinBudget(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE budget_entries (id integer PRIMARY KEY, note text)").Error)
+
+	err := db.TransactionContext(context.Background(), time.Second, func(tx *gorm.DB) error {
+		if err := tx.Exec("INSERT INTO budget_entries (note) VALUES ('first')").Error; err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO budget_entries (note) VALUES ('second')").Error
+	})
+	require.NoError(t, err)
+
+	var rows int64
+	require.NoError(t, db.GetDB().Raw("SELECT count(*) FROM budget_entries").Scan(&rows).Error)
+	assert.Equal(t, int64(2), rows)
+}
+
+func TestNestedTransactionWithRetry_RetriesDeadlockedStep(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE budget_entries (id integer PRIMARY KEY, note text)").Error)
+
+	attempts := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("INSERT INTO budget_entries (note) VALUES ('outer')").Error; err != nil {
+			return err
+		}
+
+		return db.NestedTransactionWithRetry(tx, func(inner *gorm.DB) error {
+			attempts++
+			if err := inner.Exec("INSERT INTO budget_entries (note) VALUES ('inner')").Error; err != nil {
+				return err
+			}
+			if attempts == 1 {
+				return &pq.Error{Code: sqlStateDeadlockDetected, Message: "deadlock detected"}
+			}
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// The deadlocked attempt's insert was rolled back with its savepoint
+	var notes []string
+	require.NoError(t, db.GetDB().Raw("SELECT note FROM budget_entries ORDER BY id").Scan(&notes).Error)
+	assert.Equal(t, []string{"outer", "inner"}, notes)
+}
+
+func TestNestedTransactionWithRetry_GivesUpAfterConfiguredAttempts(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.SavepointRetries = 1
+	db := newSQLiteTestDatabase(t, config)
+
+	attempts := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return db.NestedTransactionWithRetry(tx, func(*gorm.DB) error {
+			attempts++
+			return &pq.Error{Code: sqlStateDeadlockDetected, Message: "deadlock detected"}
+		})
+	})
+	require.Error(t, err)
+	assert.True(t, isDeadlock(err))
+	assert.Equal(t, 2, attempts)
+}
+
+func TestSerializableTransaction_FallsBackToRepeatableRead(t *testing.T) {
+	var events []Event
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.SerializableRetries = 2
+	config.SerializableFallback = true
+	config.OnEvent = func(event Event) { events = append(events, event) }
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE budget_entries (id integer PRIMARY KEY, note text)").Error)
+
+	var levels []sql.IsolationLevel
+	err := db.SerializableTransaction(context.Background(), func(tx *gorm.DB) error {
+		level := TransactionIsolation(tx)
+		levels = append(levels, level)
+		if level == sql.LevelSerializable {
+			return &pq.Error{Code: sqlStateSerializationFailure, Message: "could not serialize access due to concurrent update"}
+		}
+		return tx.Exec("INSERT INTO budget_entries (note) VALUES ('fallback')").Error
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []sql.IsolationLevel{sql.LevelSerializable, sql.LevelSerializable, sql.LevelSerializable, sql.LevelRepeatableRead}, levels)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventIsolationDowngraded, events[0].Type)
+
+	var rows int64
+	require.NoError(t, db.GetDB().Raw("SELECT count(*) FROM budget_entries").Scan(&rows).Error)
+	assert.Equal(t, int64(1), rows)
+}
+
+func TestSerializableTransaction_NoFallbackByDefault(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+
+	attempts := 0
+	err := db.SerializableTransaction(context.Background(), func(tx *gorm.DB) error {
+		attempts++
+		assert.Equal(t, sql.LevelSerializable, TransactionIsolation(tx))
+		return &pq.Error{Code: sqlStateSerializationFailure, Message: "could not serialize access due to concurrent update"}
+	})
+	require.Error(t, err)
+	assert.True(t, isSerializationFailure(err))
+	assert.Equal(t, 4, attempts)
+}
+
+func TestSerializableTransaction_BacksOffBetweenAttempts(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.SerializableRetries = 5
+	config.TransactionRetryInterval = time.Hour
+	db := newSQLiteTestDatabase(t, config)
+
+	// The first wait is up to an hour, so only the context can end it
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := db.SerializableTransaction(ctx, func(tx *gorm.DB) error {
+		attempts++
+		return &pq.Error{Code: sqlStateSerializationFailure, Message: "could not serialize access due to concurrent update"}
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, isSerializationFailure(err))
+	assert.Equal(t, 1, attempts)
+}
+
+func TestTransactionWithOptions_ExposesOptionsToTheSession(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE budget_entries (id integer PRIMARY KEY, note text)").Error)
+
+	err := db.TransactionWithOptions(context.Background(), sql.TxOptions{Isolation: sql.LevelRepeatableRead}, func(tx *gorm.DB) error {
+		assert.Equal(t, sql.LevelRepeatableRead, TransactionIsolation(tx))
+		assert.False(t, TransactionReadOnly(tx))
+		return tx.Exec("INSERT INTO budget_entries (note) VALUES ('committed')").Error
+	})
+	require.NoError(t, err)
+
+	var rows int64
+	require.NoError(t, db.GetDB().Raw("SELECT count(*) FROM budget_entries").Scan(&rows).Error)
+	assert.Equal(t, int64(1), rows)
+
+	require.NoError(t, db.ReadOnlyTransaction(context.Background(), func(tx *gorm.DB) error {
+		assert.Equal(t, sql.LevelDefault, TransactionIsolation(tx))
+		assert.True(t, TransactionReadOnly(tx))
+		return nil
+	}))
+
+	// SQLite has no DEFERRABLE, so only the isolation level and read-only apply
+	require.NoError(t, db.DeferrableTransaction(context.Background(), func(tx *gorm.DB) error {
+		assert.Equal(t, sql.LevelSerializable, TransactionIsolation(tx))
+		assert.True(t, TransactionReadOnly(tx))
+		return nil
+	}))
+
+	// Outside these transactions no options apply
+	assert.Equal(t, sql.LevelDefault, TransactionIsolation(db.GetDB()))
+}
+
+func TestTransactionWithRetry_RerunsConflictingTransactions(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.TransactionRetryInterval = time.Millisecond
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE budget_entries (id integer PRIMARY KEY, note text)").Error)
+
+	conflicts := []string{sqlStateSerializationFailure, sqlStateDeadlockDetected}
+	attempts := 0
+	err := db.TransactionWithRetry(context.Background(), sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *gorm.DB) error {
+		attempts++
+		if err := tx.Exec("INSERT INTO budget_entries (note) VALUES ('attempt')").Error; err != nil {
+			return err
+		}
+		if attempts <= len(conflicts) {
+			return &pq.Error{Code: pq.ErrorCode(conflicts[attempts-1])}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// The failed attempts rolled back
+	var rows int64
+	require.NoError(t, db.GetDB().Raw("SELECT count(*) FROM budget_entries").Scan(&rows).Error)
+	assert.Equal(t, int64(1), rows)
+	assert.Equal(t, int64(1), db.RetryStatsBySQLSTATE()[sqlStateDeadlockDetected].Retried)
+}
+
+func TestTransactionWithRetry_GivesUp(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.TransactionRetries = 1
+	config.TransactionRetryInterval = time.Millisecond
+	db := newSQLiteTestDatabase(t, config)
+
+	attempts := 0
+	err := db.TransactionWithRetry(context.Background(), sql.TxOptions{}, func(*gorm.DB) error {
+		attempts++
+		return &pq.Error{Code: sqlStateSerializationFailure}
+	})
+	assert.ErrorIs(t, err, ErrSerializationFailure)
+	assert.Equal(t, 2, attempts)
+
+	// Other errors are not retried
+	attempts = 0
+	err = db.TransactionWithRetry(context.Background(), sql.TxOptions{}, func(*gorm.DB) error {
+		attempts++
+		return &pq.Error{Code: sqlStateUniqueViolation}
+	})
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.Equal(t, 1, attempts)
+
+	// Nor are conflicts once ctx is done
+	db.config.TransactionRetryInterval = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = db.TransactionWithRetry(ctx, sql.TxOptions{}, func(*gorm.DB) error {
+		return &pq.Error{Code: sqlStateDeadlockDetected}
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, isDeadlock(err))
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// translatedError is a driver or GORM error that also matches the sentinel
+// describing what went wrong
+type translatedError struct {
+	sentinel error
+	err      error
+}
+
+func (e *translatedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *translatedError) Unwrap() []error {
+	return []error{e.sentinel, e.err}
+}
+
+// TranslateError wraps err so that errors.Is matches ErrNotFound, ErrDuplicateKey,
+// ErrForeignKeyViolation, ErrSerializationFailure or ErrConnectionLost, judging by
+// its SQLSTATE or GORM's and database/sql's own errors. errors.Is and errors.As
+// still see err and its message is unchanged; errors matching none of the
+// sentinels are returned as they are.
+// Statements and transactions run through ProductionDatabase, and the methods of
+// Database, return translated errors already.
+func TranslateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var translated *translatedError
+	if errors.As(err, &translated) {
+		return err
+	}
+
+	if sentinel := errorSentinel(err); sentinel != nil {
+		return &translatedError{sentinel: sentinel, err: err}
+	}
+	return err
+}
+
+// errorSentinel returns the sentinel describing err, or nil if none does
+func errorSentinel(err error) error {
+	code := sqlState(err)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case code == sqlStateUniqueViolation, errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicateKey
+	case code == sqlStateForeignKeyViolation, errors.Is(err, gorm.ErrForeignKeyViolated):
+		return ErrForeignKeyViolation
+	case code == sqlStateSerializationFailure:
+		return ErrSerializationFailure
+	case ClassifyError(err) == ErrorClassConnection:
+		return ErrConnectionLost
+	}
+	return nil
+}
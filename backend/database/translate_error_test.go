@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError_BySQLSTATE(t *testing.T) {
+	cases := map[string]error{
+		"23505": ErrDuplicateKey,
+		"23503": ErrForeignKeyViolation,
+		"40001": ErrSerializationFailure,
+		"57P01": ErrConnectionLost,
+		"08006": ErrConnectionLost,
+	}
+	for code, sentinel := range cases {
+		pqErr := &pq.Error{Code: pq.ErrorCode(code), Message: "boom"}
+		translated := TranslateError(pqErr)
+		assert.ErrorIs(t, translated, sentinel, "lib/pq %s", code)
+		assert.Equal(t, pqErr.Error(), translated.Error())
+
+		var unwrapped *pq.Error
+		assert.True(t, errors.As(translated, &unwrapped), "the driver error stays reachable")
+
+		assert.ErrorIs(t, TranslateError(fmt.Errorf("insert: %w", &pgconn.PgError{Code: code})), sentinel, "pgx %s", code)
+	}
+}
+
+func TestTranslateError_WithoutSQLSTATE(t *testing.T) {
+	assert.NoError(t, TranslateError(nil))
+	assert.ErrorIs(t, TranslateError(gorm.ErrRecordNotFound), ErrNotFound)
+	assert.ErrorIs(t, TranslateError(gorm.ErrRecordNotFound), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, TranslateError(sql.ErrNoRows), ErrNotFound)
+	assert.ErrorIs(t, TranslateError(gorm.ErrDuplicatedKey), ErrDuplicateKey)
+	assert.ErrorIs(t, TranslateError(driver.ErrBadConn), ErrConnectionLost)
+	assert.ErrorIs(t, TranslateError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), ErrConnectionLost)
+	assert.ErrorIs(t, TranslateError(io.ErrUnexpectedEOF), ErrConnectionLost)
+
+	// Errors matching no sentinel are returned as they are
+	other := errors.New("boom")
+	assert.Equal(t, other, TranslateError(other))
+	unique := &pq.Error{Code: "42P01"}
+	assert.Equal(t, error(unique), TranslateError(unique))
+
+	// Translating twice wraps once
+	translated := TranslateError(gorm.ErrRecordNotFound)
+	assert.Same(t, translated, TranslateError(translated))
+}
+
+func TestProductionDatabase_TranslatesStatementErrors(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE meals (id INTEGER PRIMARY KEY, name TEXT NOT NULL)").Error)
+
+	var meal struct {
+		ID   int64
+		Name string
+	}
+	err := db.GetDB().Table("meals").First(&meal).Error
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "GORM's own error still matches")
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.Table("meals").Where("id = ?", 1).First(&meal).Error
+	})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = db.TransactionContext(context.Background(), time.Second, func(*gorm.DB) error {
+		return &pq.Error{Code: "40001"}
+	})
+	assert.ErrorIs(t, err, ErrSerializationFailure)
+}
+
+func TestDatabase_QueryRowErrorsTranslateOnRequest(t *testing.T) {
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	sqlDB, err := db.GetDB().DB()
+	require.NoError(t, err)
+	wrapper := NewDatabase(sqlDB)
+
+	_, err = wrapper.Exec("CREATE TABLE meals (id INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+
+	var id int64
+	err = wrapper.QueryRow("SELECT id FROM meals").Scan(&id)
+	assert.ErrorIs(t, TranslateError(err), ErrNotFound)
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// unitOfWorkStep is one change a UnitOfWork writes when it commits
+type unitOfWorkStep struct {
+	kind   string
+	entity interface{}
+	run    func(tx *gorm.DB) error
+}
+
+// UnitOfWork collects the entities a business operation creates, changes and
+// deletes, along with any other repository operations, and writes them all in one
+// primary transaction on Commit, so services need not pass *gorm.DB handles around
+// A UnitOfWork is not safe for concurrent use.
+type UnitOfWork struct {
+	db    *ProductionDatabase
+	steps []unitOfWorkStep
+}
+
+// NewUnitOfWork returns an empty unit of work committing to the primary
+func (db *ProductionDatabase) NewUnitOfWork() *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// RegisterNew schedules entities, pointers to models, to be created
+func (u *UnitOfWork) RegisterNew(entities ...interface{}) {
+	for _, entity := range entities {
+		u.steps = append(u.steps, unitOfWorkStep{kind: "new", entity: entity})
+	}
+}
+
+// RegisterDirty schedules entities to be saved with all their fields
+// Entities already registered as new or dirty are written once, with the state
+// they have at Commit.
+func (u *UnitOfWork) RegisterDirty(entities ...interface{}) {
+	for _, entity := range entities {
+		if u.registered(entity, "new") || u.registered(entity, "dirty") {
+			continue
+		}
+		u.steps = append(u.steps, unitOfWorkStep{kind: "dirty", entity: entity})
+	}
+}
+
+// RegisterDeleted schedules entities to be deleted
+// Entities registered as new are dropped from the unit instead, as they were never
+// written; pending saves of the others are dropped as well.
+func (u *UnitOfWork) RegisterDeleted(entities ...interface{}) {
+	for _, entity := range entities {
+		wasNew := u.registered(entity, "new")
+		u.forget(entity)
+		if !wasNew {
+			u.steps = append(u.steps, unitOfWorkStep{kind: "deleted", entity: entity})
+		}
+	}
+}
+
+// Do schedules a repository operation to run within the unit's transaction
+func (u *UnitOfWork) Do(operation func(tx *gorm.DB) error) {
+	u.steps = append(u.steps, unitOfWorkStep{kind: "operation", run: operation})
+}
+
+// Pending returns how many changes the unit will write on Commit
+func (u *UnitOfWork) Pending() int {
+	return len(u.steps)
+}
+
+// Commit writes the unit's changes in the order they were registered, in one
+// primary transaction: either all of them are kept or, if one fails, none is. The
+// unit is empty afterwards either way. Operations may register BeforeCommit and
+// AfterCommit hooks on the transaction.
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	steps := u.steps
+	u.steps = nil
+	if len(steps) == 0 {
+		return nil
+	}
+
+	return u.db.TransactionWithOptions(ctx, sql.TxOptions{}, func(tx *gorm.DB) error {
+		for i, step := range steps {
+			if err := step.apply(tx); err != nil {
+				return fmt.Errorf("unit of work step %d (%s) failed: %w", i+1, step.kind, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Rollback discards the unit's pending changes
+func (u *UnitOfWork) Rollback() {
+	u.steps = nil
+}
+
+// apply writes the step within tx
+func (s unitOfWorkStep) apply(tx *gorm.DB) error {
+	switch s.kind {
+	case "new":
+		return tx.Create(s.entity).Error
+	case "dirty":
+		return tx.Save(s.entity).Error
+	case "deleted":
+		return tx.Delete(s.entity).Error
+	}
+	return s.run(tx)
+}
+
+// registered reports whether entity has a step of kind
+func (u *UnitOfWork) registered(entity interface{}, kind string) bool {
+	for _, step := range u.steps {
+		if step.kind == kind && sameEntity(step.entity, entity) {
+			return true
+		}
+	}
+	return false
+}
+
+// forget drops every step registered for entity
+func (u *UnitOfWork) forget(entity interface{}) {
+	steps := u.steps[:0]
+	for _, step := range u.steps {
+		if step.run != nil || !sameEntity(step.entity, entity) {
+			steps = append(steps, step)
+		}
+	}
+	u.steps = steps
+}
+
+// sameEntity reports whether a and b point to the same model; other values, such
+// as slices, are never the same entity
+func sameEntity(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Kind() == reflect.Pointer && vb.Kind() == reflect.Pointer && va.Type() == vb.Type() && va.Pointer() == vb.Pointer()
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type unitOfWorkMeal struct {
+	ID       int64
+	Name     string
+	Calories int
+}
+
+func newUnitOfWorkTestDatabase(t *testing.T) *ProductionDatabase {
+	t.Helper()
+	db := newSQLiteTestDatabase(t, newSQLiteTestConfig(t, "primary"))
+	require.NoError(t, db.GetDB().AutoMigrate(&unitOfWorkMeal{}))
+	return db
+}
+
+func TestUnitOfWork_CommitsRegisteredChanges(t *testing.T) {
+	db := newUnitOfWorkTestDatabase(t)
+	existing := &unitOfWorkMeal{Name: "oatmeal", Calories: 150}
+	obsolete := &unitOfWorkMeal{Name: "soda", Calories: 140}
+	require.NoError(t, db.GetDB().Create([]*unitOfWorkMeal{existing, obsolete}).Error)
+
+	uow := db.NewUnitOfWork()
+	salad := &unitOfWorkMeal{Name: "salad", Calories: 90}
+	uow.RegisterNew(salad)
+	salad.Calories = 120
+	uow.RegisterDirty(salad) // written once, by the insert
+
+	existing.Calories = 300
+	uow.RegisterDirty(existing)
+	uow.RegisterDeleted(obsolete)
+
+	discarded := &unitOfWorkMeal{Name: "never written"}
+	uow.RegisterNew(discarded)
+	uow.RegisterDeleted(discarded)
+
+	uow.Do(func(tx *gorm.DB) error {
+		return tx.Model(&unitOfWorkMeal{}).Where("name = ?", "oatmeal").Update("name", "porridge").Error
+	})
+	assert.Equal(t, 4, uow.Pending())
+
+	require.NoError(t, uow.Commit(context.Background()))
+	assert.Zero(t, uow.Pending())
+
+	var meals []unitOfWorkMeal
+	require.NoError(t, db.GetDB().Order("id").Find(&meals).Error)
+	assert.Equal(t, []unitOfWorkMeal{
+		{ID: existing.ID, Name: "porridge", Calories: 300},
+		{ID: salad.ID, Name: "salad", Calories: 120},
+	}, meals)
+}
+
+func TestUnitOfWork_FailedStepRollsBackEverything(t *testing.T) {
+	db := newUnitOfWorkTestDatabase(t)
+
+	uow := db.NewUnitOfWork()
+	uow.RegisterNew(&unitOfWorkMeal{Name: "salad"})
+	uow.Do(func(tx *gorm.DB) error {
+		return tx.Exec("INSERT INTO missing_table VALUES (1)").Error
+	})
+
+	err := uow.Commit(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unit of work step 2 (operation) failed")
+
+	var count int64
+	require.NoError(t, db.GetDB().Model(&unitOfWorkMeal{}).Count(&count).Error)
+	assert.Zero(t, count)
+
+	// An empty unit commits nothing
+	uow.RegisterNew(&unitOfWorkMeal{Name: "discarded"})
+	uow.Rollback()
+	require.NoError(t, uow.Commit(context.Background()))
+	require.NoError(t, db.GetDB().Model(&unitOfWorkMeal{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"regexp"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+var (
+	selectSQL  = regexp.MustCompile(`(?is)^\s*(select|with)\b`)
+	limitSQL   = regexp.MustCompile(`(?is)\blimit\b`)
+	orderBySQL = regexp.MustCompile(`(?is)\border\s+by\b`)
+)
+
+// isUnorderedLimit reports whether a SELECT limits its rows without ordering them,
+// so which rows it returns is up to the plan
+func isUnorderedLimit(sql string) bool {
+	return selectSQL.MatchString(sql) && limitSQL.MatchString(sql) && !orderBySQL.MatchString(sql)
+}
+
+// UnorderedLimitTotal returns how many SELECTs ran with LIMIT but without ORDER BY
+// Only counted with WarnOnUnorderedLimit.
+func (db *ProductionDatabase) UnorderedLimitTotal() int64 {
+	return atomic.LoadInt64(&db.unorderedLimits)
+}
+
+// checkUnorderedLimit counts a SELECT with LIMIT but no ORDER BY and warns once per fingerprint
+func (db *ProductionDatabase) checkUnorderedLimit(tx *gorm.DB) {
+	sql := tx.Statement.SQL.String()
+	if !isUnorderedLimit(sql) {
+		return
+	}
+
+	atomic.AddInt64(&db.unorderedLimits, 1)
+	fingerprint := fingerprintSQL(sql)
+	if _, warned := db.unorderedLimitWarned.LoadOrStore(fingerprint, true); !warned {
+		db.logger().Warn("Query uses LIMIT without ORDER BY, its rows are nondeterministic", "query", fingerprint)
+	}
+}
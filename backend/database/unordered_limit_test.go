@@ -0,0 +1,58 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unorderedLimitTestMeal struct {
+	ID   int
+	Name string
+}
+
+func (unorderedLimitTestMeal) TableName() string { return "meals" }
+
+func newUnorderedLimitTestDatabase(t *testing.T) *ProductionDatabase {
+	config := newSQLiteTestConfig(t, "primary")
+	config.WarnOnUnorderedLimit = true
+	db := newSQLiteTestDatabase(t, config)
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE meals (id integer PRIMARY KEY, name text)").Error)
+	require.NoError(t, db.GetDB().Exec("INSERT INTO meals (id, name) VALUES (1, 'oats'), (2, 'lentils')").Error)
+	return db
+}
+
+func TestWarnOnUnorderedLimit_CountsLimitWithoutOrderBy(t *testing.T) {
+	db := newUnorderedLimitTestDatabase(t)
+
+	var meals []unorderedLimitTestMeal
+	require.NoError(t, db.GetDB().Limit(1).Find(&meals).Error)
+	require.NoError(t, db.GetDB().Raw("SELECT id, name FROM meals LIMIT 1").Scan(&meals).Error)
+
+	assert.Equal(t, int64(2), db.UnorderedLimitTotal())
+	assert.Equal(t, int64(2), db.Stats()["unordered_limits"])
+}
+
+func TestWarnOnUnorderedLimit_OrderedLimitIsFine(t *testing.T) {
+	db := newUnorderedLimitTestDatabase(t)
+
+	var meals []unorderedLimitTestMeal
+	require.NoError(t, db.GetDB().Order("id").Limit(1).Find(&meals).Error)
+	require.NoError(t, db.GetDB().Raw("SELECT id, name FROM meals ORDER BY id LIMIT 1").Scan(&meals).Error)
+
+	// First orders by primary key
+	var meal unorderedLimitTestMeal
+	require.NoError(t, db.GetDB().First(&meal).Error)
+
+	assert.Zero(t, db.UnorderedLimitTotal())
+}
+
+func TestIsUnorderedLimit(t *testing.T) {
+	assert.True(t, isUnorderedLimit("SELECT * FROM meals LIMIT 10"))
+	assert.True(t, isUnorderedLimit("WITH recent AS (SELECT * FROM meals) SELECT * FROM recent LIMIT 5"))
+	assert.False(t, isUnorderedLimit("SELECT * FROM meals ORDER BY id LIMIT 10"))
+	assert.False(t, isUnorderedLimit("SELECT * FROM meals"))
+	assert.False(t, isUnorderedLimit("DELETE FROM meals WHERE id IN (SELECT id FROM meals LIMIT 10)"))
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm/clause"
+)
+
+// defaultUpsertBatchSize is the UpsertMany batch size used when UpsertBatchSize is unset
+const defaultUpsertBatchSize = 500
+
+// UpsertMany inserts rows, a slice of models, on the primary, updating
+// updateColumns of the existing row instead wherever a row conflicts on
+// conflictColumns, which must be covered by a unique index. Without updateColumns
+// conflicting rows are left as they are. Rows are written UpsertBatchSize per
+// statement, all in one transaction, so running a sync twice changes nothing the
+// second time. It returns how many rows were inserted or updated.
+func (db *ProductionDatabase) UpsertMany(ctx context.Context, rows interface{}, conflictColumns, updateColumns []string) (int64, error) {
+	if len(conflictColumns) == 0 {
+		return 0, errors.New("upsert needs the conflict columns of a unique index")
+	}
+	if err := db.primaryAvailable(); err != nil {
+		return 0, err
+	}
+	batchSize := db.config.UpsertBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultUpsertBatchSize
+	}
+
+	onConflict := clause.OnConflict{DoNothing: len(updateColumns) == 0}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if len(updateColumns) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	}
+
+	var affected int64
+	err := db.traced(ctx, "db.upsert", func(ctx context.Context) error {
+		result := db.primary().WithContext(ctx).Clauses(onConflict).CreateInBatches(rows, batchSize)
+		if result.Error != nil {
+			return fmt.Errorf("upsert failed: %w", TranslateError(result.Error))
+		}
+		affected = result.RowsAffected
+		return nil
+	}, attribute.Int("db.upsert.batch_size", batchSize))
+	return affected, err
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type upsertFood struct {
+	ID       int64
+	FdcID    int64 `gorm:"uniqueIndex"`
+	Name     string
+	Calories int
+}
+
+func TestUpsertMany_InsertsAndUpdatesInBatches(t *testing.T) {
+	config := newSQLiteTestConfig(t, "primary")
+	config.UpsertBatchSize = 2
+	db := newSQLiteTestDatabase(t, config)
+	require.NoError(t, db.GetDB().AutoMigrate(&upsertFood{}))
+	ctx := context.Background()
+
+	foods := []upsertFood{{FdcID: 1, Name: "apple", Calories: 52}, {FdcID: 2, Name: "pear", Calories: 57}, {FdcID: 3, Name: "plum", Calories: 46}}
+	affected, err := db.UpsertMany(ctx, foods, []string{"fdc_id"}, []string{"name", "calories"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+
+	// The nightly sync runs again with a corrected row and a new one
+	synced := []upsertFood{{FdcID: 2, Name: "pear", Calories: 58}, {FdcID: 4, Name: "fig", Calories: 74}}
+	_, err = db.UpsertMany(ctx, synced, []string{"fdc_id"}, []string{"name", "calories"})
+	require.NoError(t, err)
+
+	var stored []upsertFood
+	require.NoError(t, db.GetDB().Order("fdc_id").Find(&stored).Error)
+	require.Len(t, stored, 4)
+	assert.Equal(t, 58, stored[1].Calories)
+	assert.Equal(t, "fig", stored[3].Name)
+
+	// Without update columns conflicting rows are kept
+	_, err = db.UpsertMany(ctx, []upsertFood{{FdcID: 1, Name: "ignored", Calories: 0}}, []string{"fdc_id"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.GetDB().Order("fdc_id").Find(&stored).Error)
+	assert.Equal(t, "apple", stored[0].Name)
+
+	_, err = db.UpsertMany(ctx, foods, nil, []string{"name"})
+	assert.Error(t, err)
+}
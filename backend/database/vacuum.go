@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LastAutovacuum returns when autovacuum last processed table on the primary, or
+// the zero time if it never has
+func (db *ProductionDatabase) LastAutovacuum(ctx context.Context, table string) (time.Time, error) {
+	rows, err := db.primary().WithContext(ctx).
+		Raw("SELECT last_autovacuum FROM pg_stat_user_tables WHERE relname = ?", table).Rows()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read autovacuum stats of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return time.Time{}, fmt.Errorf("failed to read autovacuum stats of %s: %w", table, err)
+		}
+		return time.Time{}, fmt.Errorf("table %s not found in pg_stat_user_tables", table)
+	}
+
+	var last sql.NullTime
+	if err := rows.Scan(&last); err != nil {
+		return time.Time{}, fmt.Errorf("failed to scan autovacuum stats of %s: %w", table, err)
+	}
+	return last.Time, nil
+}
+
+// VacuumTables runs VACUUM ANALYZE on each table, skipping tables autovacuumed
+// within VacuumCooldown, and returns the tables it vacuumed
+func (db *ProductionDatabase) VacuumTables(ctx context.Context, tables ...string) ([]string, error) {
+	lastAutovacuum := db.autovacuumProbe
+	if lastAutovacuum == nil {
+		lastAutovacuum = db.LastAutovacuum
+	}
+
+	var vacuumed []string
+	for _, table := range tables {
+		if cooldown := db.config.VacuumCooldown; cooldown > 0 {
+			last, err := lastAutovacuum(ctx, table)
+			if err != nil {
+				return vacuumed, err
+			}
+			if !last.IsZero() && time.Since(last) < cooldown {
+				db.logger().Info("Skipping vacuum of recently autovacuumed table", "table", table, "autovacuumed_ago", time.Since(last).Round(time.Second))
+				continue
+			}
+		}
+
+		if err := db.primary().WithContext(ctx).Exec(fmt.Sprintf("VACUUM ANALYZE %s", quoteIdentifier(table))).Error; err != nil {
+			return vacuumed, fmt.Errorf("failed to vacuum %s: %w", table, err)
+		}
+		vacuumed = append(vacuumed, table)
+	}
+
+	return vacuumed, nil
+}
+
+// VacuumMaintenanceTask returns a maintenance task running VacuumTables every interval
+func VacuumMaintenanceTask(interval time.Duration, tables ...string) MaintenanceTask {
+	return MaintenanceTask{
+		Name:     "vacuum",
+		Interval: interval,
+		Run: func(ctx context.Context, db *ProductionDatabase) error {
+			_, err := db.VacuumTables(ctx, tables...)
+			return err
+		},
+	}
+}
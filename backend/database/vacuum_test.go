@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastAutovacuum_ReadsTableStats(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE IF NOT EXISTS autovacuum_test (id int PRIMARY KEY)").Error)
+	t.Cleanup(func() { db.GetDB().Exec("DROP TABLE IF EXISTS autovacuum_test") })
+
+	var expected time.Time
+	require.NoError(t, db.GetDB().Raw("SELECT COALESCE(last_autovacuum, 'epoch') FROM pg_stat_user_tables WHERE relname = 'autovacuum_test'").Scan(&expected).Error)
+
+	last, err := db.LastAutovacuum(ctx, "autovacuum_test")
+	require.NoError(t, err)
+	if last.IsZero() {
+		assert.True(t, expected.Equal(time.Unix(0, 0)), "a table never autovacuumed reports the zero time")
+	} else {
+		assert.True(t, expected.Equal(last))
+	}
+
+	_, err = db.LastAutovacuum(ctx, "no_such_table")
+	assert.Error(t, err)
+}
+
+func TestVacuumTables_SkipsRecentlyAutovacuumed(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	db.config.VacuumCooldown = time.Hour
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE IF NOT EXISTS vacuum_fresh (id int PRIMARY KEY)").Error)
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE IF NOT EXISTS vacuum_stale (id int PRIMARY KEY)").Error)
+	t.Cleanup(func() { db.GetDB().Exec("DROP TABLE IF EXISTS vacuum_fresh, vacuum_stale") })
+
+	// Autovacuum cannot be triggered on demand, so its history is stubbed
+	db.autovacuumProbe = func(_ context.Context, table string) (time.Time, error) {
+		if table == "vacuum_fresh" {
+			return time.Now().Add(-time.Minute), nil
+		}
+		return time.Now().Add(-2 * time.Hour), nil
+	}
+
+	vacuumed, err := db.VacuumTables(ctx, "vacuum_fresh", "vacuum_stale")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vacuum_stale"}, vacuumed)
+}
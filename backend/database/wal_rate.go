@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// walPositionSQL reports the primary's current WAL position in bytes
+const walPositionSQL = "SELECT pg_wal_lsn_diff(pg_current_wal_lsn(), '0/0')::bigint"
+
+// walSample is a WAL position observed at a point in time
+type walSample struct {
+	mu       sync.Mutex
+	position int64
+	at       time.Time
+}
+
+// WALGenerationRate returns the bytes of WAL the primary generated per second
+// since the previous call. The first call only records a baseline and returns 0.
+func (db *ProductionDatabase) WALGenerationRate(ctx context.Context) (float64, error) {
+	var position int64
+	if err := db.primary().WithContext(ctx).Raw(walPositionSQL).Scan(&position).Error; err != nil {
+		return 0, fmt.Errorf("failed to read WAL position: %w", err)
+	}
+	now := time.Now()
+
+	sample := &db.walSample
+	sample.mu.Lock()
+	defer sample.mu.Unlock()
+
+	previous, previousAt := sample.position, sample.at
+	sample.position, sample.at = position, now
+
+	if previousAt.IsZero() || !now.After(previousAt) {
+		return 0, nil
+	}
+	return float64(position-previous) / now.Sub(previousAt).Seconds(), nil
+}
+
+// checkWALRate warns when the primary generates WAL faster than the configured threshold
+func (hc *HealthChecker) checkWALRate() {
+	threshold := hc.db.config.WALRateWarnThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
+	defer cancel()
+
+	rate, err := hc.db.WALGenerationRate(ctx)
+	if err != nil {
+		hc.db.logger().Error("WAL rate check failed", "error", err)
+		return
+	}
+
+	if rate >= threshold {
+		hc.db.logger().Warn("Primary is generating WAL above the threshold", "bytes_per_second", rate, "threshold", threshold)
+	}
+}
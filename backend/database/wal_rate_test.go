@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWALGenerationRate_ReportsWrites(t *testing.T) {
+	db := newPostgresTestDatabase(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.GetDB().Exec("CREATE TABLE IF NOT EXISTS wal_rate_test (id serial PRIMARY KEY, payload text)").Error)
+	t.Cleanup(func() { db.GetDB().Exec("DROP TABLE IF EXISTS wal_rate_test") })
+
+	// The first sample is only a baseline
+	rate, err := db.WALGenerationRate(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, rate)
+
+	require.NoError(t, db.GetDB().Exec("INSERT INTO wal_rate_test (payload) SELECT repeat('x', 100) FROM generate_series(1, 10000)").Error)
+	time.Sleep(10 * time.Millisecond)
+
+	rate, err = db.WALGenerationRate(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, rate, 0.0)
+}
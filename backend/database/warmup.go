@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// defaultPrimaryWarmupTimeout bounds the warmup when PrimaryWarmupTimeout is unset
+const defaultPrimaryWarmupTimeout = 30 * time.Second
+
+// warmPrimaryPool runs PrimaryWarmupQueries on a new primary pool before it is swapped in
+// Statements keep going to the previous pool meanwhile. A failed or timed out warmup
+// only leaves the cache colder, so it is logged and the new pool is used regardless.
+func (db *ProductionDatabase) warmPrimaryPool(sqlDB *sql.DB) {
+	queries := db.config.PrimaryWarmupQueries
+	if len(queries) == 0 {
+		return
+	}
+
+	timeout := db.config.PrimaryWarmupTimeout
+	if timeout <= 0 {
+		timeout = defaultPrimaryWarmupTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	db.emit(EventPrimaryWarming, fmt.Sprintf("warming new primary pool with %d queries", len(queries)), nil)
+	started := time.Now()
+
+	for _, query := range queries {
+		if _, err := sqlDB.ExecContext(ctx, query); err != nil {
+			db.logger().Warn("Primary warmup stopped early", "after", time.Since(started).Round(time.Millisecond), "error", err)
+			db.emit(EventPrimaryWarmed, "primary warmup stopped early", err)
+			return
+		}
+	}
+
+	db.logger().Info("Primary pool warmed", "took", time.Since(started).Round(time.Millisecond))
+	db.emit(EventPrimaryWarmed, "primary warmup finished", nil)
+}
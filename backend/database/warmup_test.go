@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrimaryWarmup_RunsBeforeWritesReachNewPrimary(t *testing.T) {
+	var partition atomic.Bool
+
+	config := newSQLiteTestConfig(t, "primary")
+	config.PoolResetAfter = time.Millisecond
+	config.PrimaryWarmupQueries = []string{"INSERT INTO journal (entry) VALUES ('warmup')"}
+	config.Connector = func(dsn string) (driver.Connector, error) {
+		return &partitionedConnector{dsn: dsn, partition: &partition}, nil
+	}
+
+	// The database itself outlives the connections, as the replacement primary's storage would
+	storage, err := sql.Open("sqlite3", config.DatabaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+	_, err = storage.Exec("CREATE TABLE journal (id integer PRIMARY KEY AUTOINCREMENT, entry text)")
+	require.NoError(t, err)
+
+	var events []EventType
+	var poolSwappedWhileWarming bool
+	var db *ProductionDatabase
+	var originalPool *sql.DB
+	config.OnEvent = func(event Event) {
+		events = append(events, event.Type)
+		assert.NoError(t, event.Err)
+		if event.Type == EventPrimaryWarming && db.primaryPool() != originalPool {
+			poolSwappedWhileWarming = true
+		}
+	}
+	db = newSQLiteTestDatabase(t, config)
+	originalPool = db.primaryPool()
+
+	// The primary goes away and its replacement comes up behind the same address
+	partition.Store(true)
+	db.healthChecker.observePrimaryHealth(db.Health())
+	time.Sleep(5 * time.Millisecond)
+	partition.Store(false)
+	db.healthChecker.observePrimaryHealth(db.Health())
+	require.Equal(t, int64(1), db.PoolResets())
+
+	assert.Equal(t, []EventType{EventPrimaryWarming, EventPrimaryWarmed}, events)
+	assert.False(t, poolSwappedWhileWarming, "writes must stay off the new primary while it warms")
+
+	require.NoError(t, db.GetDB().Exec("INSERT INTO journal (entry) VALUES ('write')").Error)
+
+	var entries []string
+	require.NoError(t, db.GetDB().Raw("SELECT entry FROM journal ORDER BY id").Scan(&entries).Error)
+	assert.Equal(t, []string{"warmup", "write"}, entries)
+}